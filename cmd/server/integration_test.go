@@ -0,0 +1,384 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/beingaloksharma/book-backend/internal/middleware"
+	"github.com/beingaloksharma/book-backend/utils/database"
+	"github.com/beingaloksharma/book-backend/utils/logger"
+	"github.com/beingaloksharma/book-backend/utils/token"
+	"github.com/beingaloksharma/book-backend/utils/totp"
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	adminEmail    = "admin@example.com"
+	adminPassword = "admin-password"
+)
+
+// testServer is the full router on a fresh in-memory SQLite database.
+type testServer struct {
+	t      *testing.T
+	router *gin.Engine
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	return newTestServerWith(t, nil)
+}
+
+// newTestServerWith is newTestServer with extra settings, applied on top of the
+// test defaults before the router is built.
+func newTestServerWith(t *testing.T, overrides map[string]any) *testServer {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	logger.Init()
+
+	settings := map[string]any{
+		"database.driver":          database.SQLite,
+		"database.path":            database.Memory,
+		"jwt.secret":               "integration-test-secret",
+		"mail.driver":              "log",
+		"mfa.required_roles":       []string{},
+		"rate_limit.enabled":       false,
+		"bootstrap_admin.email":    adminEmail,
+		"bootstrap_admin.password": adminPassword,
+	}
+	for key, value := range overrides {
+		settings[key] = value
+	}
+	for key, value := range settings {
+		viper.Set(key, value)
+	}
+	t.Cleanup(func() {
+		for key := range settings {
+			viper.Set(key, nil)
+		}
+	})
+
+	db, err := database.Open()
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	_, err = newMigrator(db).Up(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, token.Init())
+
+	return &testServer{t: t, router: newRouter(db)}
+}
+
+// do sends a JSON request, authenticated when accessToken is set, with any
+// extra headers given as name and value pairs.
+func (s *testServer) do(method, path, accessToken string, body any, headers ...string) *httptest.ResponseRecorder {
+	s.t.Helper()
+	var payload bytes.Buffer
+	if body != nil {
+		require.NoError(s.t, json.NewEncoder(&payload).Encode(body))
+	}
+	req := httptest.NewRequest(method, path, &payload)
+	req.Header.Set("Content-Type", "application/json")
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+// decode reads a response body that must have the wanted status.
+func (s *testServer) decode(w *httptest.ResponseRecorder, status int, v any) {
+	s.t.Helper()
+	require.Equal(s.t, status, w.Code, w.Body.String())
+	require.NoError(s.t, json.Unmarshal(w.Body.Bytes(), v))
+}
+
+func (s *testServer) login(email, password string) string {
+	s.t.Helper()
+	var tokens struct {
+		Token string `json:"token"`
+	}
+	s.decode(s.do("POST", "/auth/login", "", gin.H{"email": email, "password": password}), http.StatusOK, &tokens)
+	require.NotEmpty(s.t, tokens.Token)
+	return tokens.Token
+}
+
+func (s *testServer) signup(name, email, password string) string {
+	s.t.Helper()
+	w := s.do("POST", "/auth/signup", "", gin.H{"name": name, "email": email, "password": password})
+	require.Equal(s.t, http.StatusCreated, w.Code, w.Body.String())
+	return s.login(email, password)
+}
+
+type bookJSON struct {
+	ID    uint    `json:"ID"`
+	Title string  `json:"title"`
+	Price float64 `json:"price"`
+	Stock int     `json:"stock"`
+}
+
+func (s *testServer) book(accessToken string, id uint) bookJSON {
+	s.t.Helper()
+	var book bookJSON
+	s.decode(s.do("GET", "/api/books/"+itoa(id), accessToken, nil), http.StatusOK, &book)
+	return book
+}
+
+func itoa(id uint) string {
+	return strconv.FormatUint(uint64(id), 10)
+}
+
+func TestIntegrationCatalog(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.login(adminEmail, adminPassword)
+
+	// Case 1: Admin adds books
+	for _, book := range []gin.H{
+		{"title": "The Go Programming Language", "author": "Alan Donovan", "description": "Concurrency and interfaces in Go", "price": 40, "stock": 5},
+		{"title": "Designing Data-Intensive Applications", "author": "Martin Kleppmann", "description": "Replication, partitioning and transactions", "price": 50, "stock": 2},
+	} {
+		w := s.do("POST", "/api/admin/books", admin, book)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	// Case 2: Users may not
+	user := s.signup("Jane", "jane@example.com", "jane-password")
+	assert.Equal(t, http.StatusForbidden, s.do("POST", "/api/admin/books", user, gin.H{"title": "x", "author": "y", "price": 1, "stock": 1}).Code)
+
+	// Case 3: Filtering by author ignores case
+	var list struct {
+		Data []bookJSON `json:"data"`
+	}
+	s.decode(s.do("GET", "/api/books?author=kleppmann", user, nil), http.StatusOK, &list)
+	require.Len(t, list.Data, 1)
+	assert.Equal(t, "Designing Data-Intensive Applications", list.Data[0].Title)
+
+	// Case 4: Search matches whole words in any field, title first
+	var search struct {
+		Data []struct {
+			Book           bookJSON `json:"book"`
+			TitleHighlight string   `json:"title_highlight"`
+		} `json:"data"`
+	}
+	s.decode(s.do("GET", "/api/books/search?q=go", user, nil), http.StatusOK, &search)
+	require.Len(t, search.Data, 1)
+	assert.Equal(t, "The <mark>Go</mark> Programming Language", search.Data[0].TitleHighlight)
+
+	s.decode(s.do("GET", "/api/books/search?q=transactions", user, nil), http.StatusOK, &search)
+	require.Len(t, search.Data, 1)
+	assert.Equal(t, uint(2), search.Data[0].Book.ID)
+
+	// Case 5: A wrong password is refused and counted
+	assert.Equal(t, http.StatusUnauthorized, s.do("POST", "/auth/login", "", gin.H{"email": "jane@example.com", "password": "wrong"}).Code)
+}
+
+func TestIntegrationRoleChange(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.login(adminEmail, adminPassword)
+	user := s.signup("Jane", "jane@example.com", "jane-password")
+	var profile struct {
+		ID uint `json:"ID"`
+	}
+	s.decode(s.do("GET", "/api/profile", user, nil), http.StatusOK, &profile)
+
+	// Case 1: Changing the role ends the user's sessions
+	w := s.do("PATCH", "/api/admin/users/"+itoa(profile.ID)+"/role", admin, gin.H{"role": "ADMIN"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, http.StatusUnauthorized, s.do("GET", "/api/profile", user, nil).Code)
+
+	// Case 2: A new login carries the new role
+	user = s.login("jane@example.com", "jane-password")
+	assert.Equal(t, http.StatusOK, s.do("GET", "/api/admin/users", user, nil).Code)
+
+	// Case 3: A role that can only manage users cannot make anyone, itself included, an admin
+	w = s.do("POST", "/api/admin/roles", admin, gin.H{"name": "SUPPORT", "permissions": []string{"admin:access", "users:read", "users:manage"}})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	support := s.signup("Sam", "sam@example.com", "sam-password")
+	s.decode(s.do("GET", "/api/profile", support, nil), http.StatusOK, &profile)
+	w = s.do("PATCH", "/api/admin/users/"+itoa(profile.ID)+"/role", admin, gin.H{"role": "SUPPORT"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	support = s.login("sam@example.com", "sam-password")
+
+	assert.Equal(t, http.StatusForbidden, s.do("PATCH", "/api/admin/users/"+itoa(profile.ID)+"/role", support, gin.H{"role": "ADMIN"}).Code)
+	other := s.signup("Max", "max@example.com", "max-password")
+	s.decode(s.do("GET", "/api/profile", other, nil), http.StatusOK, &profile)
+	assert.Equal(t, http.StatusForbidden, s.do("PATCH", "/api/admin/users/"+itoa(profile.ID)+"/role", support, gin.H{"role": "ADMIN"}).Code)
+}
+
+func TestIntegrationMFALockout(t *testing.T) {
+	s := newTestServerWith(t, map[string]any{
+		"login_protection.account_lockout_threshold": 3,
+		"login_protection.backoff_after":             100,
+	})
+	admin := s.login(adminEmail, adminPassword)
+
+	var enrollment struct {
+		Secret string `json:"secret"`
+	}
+	s.decode(s.do("POST", "/api/mfa/enroll", admin, nil), http.StatusOK, &enrollment)
+	code, err := totp.Code(enrollment.Secret, time.Now())
+	require.NoError(t, err)
+	w := s.do("POST", "/api/mfa/confirm", admin, gin.H{"code": code})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// The right password followed by a wrong code, again and again, locks the account
+	for i := 0; i < 3; i++ {
+		var challenge struct {
+			MFAToken string `json:"mfa_token"`
+		}
+		s.decode(s.do("POST", "/auth/login", "", gin.H{"email": adminEmail, "password": adminPassword}), http.StatusOK, &challenge)
+		require.NotEmpty(t, challenge.MFAToken)
+		assert.Equal(t, http.StatusUnauthorized, s.do("POST", "/auth/mfa/verify", "", gin.H{"mfa_token": challenge.MFAToken, "code": "not-a-code"}).Code)
+	}
+	assert.Equal(t, http.StatusTooManyRequests, s.do("POST", "/auth/login", "", gin.H{"email": adminEmail, "password": adminPassword}).Code)
+}
+
+func TestIntegrationClientAddress(t *testing.T) {
+	limited := map[string]any{
+		"rate_limit.enabled":              true,
+		"rate_limit.groups.auth.requests": 1,
+		"rate_limit.groups.auth.period":   "1h",
+		"rate_limit.groups.auth.burst":    1,
+	}
+	login := func(s *testServer, forwardedFor string) int {
+		return s.do("POST", "/auth/login", "", gin.H{"email": "nobody@example.com", "password": "wrong"}, "X-Forwarded-For", forwardedFor).Code
+	}
+
+	// Case 1: A client cannot get a fresh bucket by claiming another address
+	s := newTestServerWith(t, limited)
+	assert.Equal(t, http.StatusUnauthorized, login(s, "203.0.113.1"))
+	assert.Equal(t, http.StatusTooManyRequests, login(s, "203.0.113.2"))
+
+	// Case 2: Behind a trusted proxy, the forwarded address is the client's
+	limited["server.trusted_proxies"] = []string{"192.0.2.0/24"}
+	s = newTestServerWith(t, limited)
+	assert.Equal(t, http.StatusUnauthorized, login(s, "203.0.113.1"))
+	assert.Equal(t, http.StatusUnauthorized, login(s, "203.0.113.2"))
+	assert.Equal(t, http.StatusTooManyRequests, login(s, "203.0.113.1"))
+}
+
+func TestIntegrationHealth(t *testing.T) {
+	s := newTestServer(t)
+
+	// Case 1: Anyone gets the status
+	var status map[string]any
+	s.decode(s.do("GET", "/health", "", nil), http.StatusOK, &status)
+	assert.Equal(t, map[string]any{"status": "ok"}, status)
+
+	// Case 2: The pool statistics need admin:access
+	user := s.signup("Jane", "jane@example.com", "jane-password")
+	assert.Equal(t, http.StatusForbidden, s.do("GET", "/api/admin/health", user, nil).Code)
+
+	var health struct {
+		Status    string                `json:"status"`
+		Databases []database.PoolStatus `json:"databases"`
+	}
+	s.decode(s.do("GET", "/api/admin/health", s.login(adminEmail, adminPassword), nil), http.StatusOK, &health)
+	assert.Equal(t, "ok", health.Status)
+	require.Len(t, health.Databases, 1)
+	assert.Equal(t, "primary", health.Databases[0].Name)
+	assert.Equal(t, 1, health.Databases[0].MaxOpenConnections)
+}
+
+func TestIntegrationOrder(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.login(adminEmail, adminPassword)
+	w := s.do("POST", "/api/admin/books", admin, gin.H{"title": "Go", "author": "Alan Donovan", "price": 40, "stock": 5})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	user := s.signup("Jane", "jane@example.com", "jane-password")
+	w = s.do("POST", "/api/addresses", user, gin.H{"street": "1 Main St", "city": "Springfield", "state": "IL", "zip_code": "62701", "country": "USA"})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var addresses []struct {
+		ID uint `json:"ID"`
+	}
+	s.decode(s.do("GET", "/api/addresses", user, nil), http.StatusOK, &addresses)
+	require.Len(t, addresses, 1)
+
+	// Case 1: More than is in stock cannot be added
+	assert.Equal(t, http.StatusConflict, s.do("POST", "/api/cart", user, gin.H{"book_id": 1, "quantity": 6}).Code)
+
+	// Case 2: Placing the order takes the books out of stock and empties the cart
+	w = s.do("POST", "/api/cart", user, gin.H{"book_id": 1, "quantity": 2})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	order := gin.H{"address_id": addresses[0].ID}
+	w = s.do("POST", "/api/orders", user, order, middleware.IdempotencyKeyHeader, "order-1")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, 3, s.book(user, 1).Stock)
+
+	var cart struct {
+		Items []any `json:"items"`
+	}
+	s.decode(s.do("GET", "/api/cart", user, nil), http.StatusOK, &cart)
+	assert.Empty(t, cart.Items)
+
+	// Case 3: Retrying with the same key replays the response without a second order
+	w = s.do("POST", "/api/orders", user, order, middleware.IdempotencyKeyHeader, "order-1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "true", w.Header().Get(middleware.IdempotentReplayedHeader))
+
+	var orders []struct {
+		ID     uint    `json:"ID"`
+		Amount float64 `json:"amount"`
+		Status string  `json:"status"`
+	}
+	s.decode(s.do("GET", "/api/orders", user, nil), http.StatusOK, &orders)
+	require.Len(t, orders, 1)
+	assert.Equal(t, 80.0, orders[0].Amount)
+	assert.Equal(t, "PENDING", orders[0].Status)
+
+	// Case 4: An empty cart cannot be ordered
+	assert.Equal(t, http.StatusBadRequest, s.do("POST", "/api/orders", user, order).Code)
+
+	// Case 5: Cancelling restocks the books and is recorded
+	w = s.do("POST", "/api/orders/"+itoa(orders[0].ID)+"/cancel", user, gin.H{"reason": "changed my mind"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, 5, s.book(user, 1).Stock)
+
+	var history []struct {
+		ToStatus string `json:"to_status"`
+		Note     string `json:"note"`
+	}
+	s.decode(s.do("GET", "/api/admin/orders/"+itoa(orders[0].ID)+"/history", admin, nil), http.StatusOK, &history)
+	require.Len(t, history, 1)
+	assert.Equal(t, "CANCELLED", history[0].ToStatus)
+	assert.Equal(t, "changed my mind", history[0].Note)
+
+	// Case 6: A cancelled order cannot be cancelled again
+	assert.Equal(t, http.StatusConflict, s.do("POST", "/api/orders/"+itoa(orders[0].ID)+"/cancel", user, nil).Code)
+
+	// Case 7: A book deleted while in the cart is unavailable and cannot be ordered
+	w = s.do("POST", "/api/cart", user, gin.H{"book_id": 1, "quantity": 1})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = s.do("DELETE", "/api/admin/books/1", admin, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var summary struct {
+		Items []struct {
+			Unavailable bool `json:"unavailable"`
+		} `json:"items"`
+		Subtotal     float64 `json:"subtotal"`
+		Checkoutable bool    `json:"checkoutable"`
+	}
+	s.decode(s.do("GET", "/api/cart", user, nil), http.StatusOK, &summary)
+	require.Len(t, summary.Items, 1)
+	assert.True(t, summary.Items[0].Unavailable)
+	assert.Equal(t, 0.0, summary.Subtotal)
+	assert.False(t, summary.Checkoutable)
+	assert.Equal(t, http.StatusBadRequest, s.do("POST", "/api/orders", user, order).Code)
+}
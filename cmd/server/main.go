@@ -6,22 +6,20 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"sort"
+	"strings"
 	"syscall"
 	"time"
 
 	_ "github.com/beingaloksharma/book-backend/docs"
-	"github.com/beingaloksharma/book-backend/internal/controller"
-	"github.com/beingaloksharma/book-backend/internal/middleware"
-	"github.com/beingaloksharma/book-backend/internal/model"
 	"github.com/beingaloksharma/book-backend/internal/repository"
 	"github.com/beingaloksharma/book-backend/internal/service"
 	"github.com/beingaloksharma/book-backend/utils/database"
 	"github.com/beingaloksharma/book-backend/utils/logger"
-	"github.com/gin-gonic/gin"
+	"github.com/beingaloksharma/book-backend/utils/token"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
-	swaggerFiles "github.com/swaggo/files"
-	ginSwagger "github.com/swaggo/gin-swagger"
+	"gorm.io/gorm"
 )
 
 // @title Book Store API
@@ -47,71 +45,14 @@ func main() {
 	// 3. Load Configuration
 	// Pass the value of the pointer (*configFilePath) directly to the function
 	loadConfig(*configFilePath)
-	r := gin.Default()
-	setupDatabase(r)
-
-	// Init Repositories
-	userRepo := repository.NewUserRepository()
-	bookRepo := repository.NewBookRepository()
-	cartRepo := repository.NewCartRepository()
-	orderRepo := repository.NewOrderRepository()
-
-	// Init Services
-	authService := service.NewAuthService(userRepo)
-	userService := service.NewUserService(userRepo)
-	bookService := service.NewBookService(bookRepo)
-	cartService := service.NewCartService(cartRepo, bookRepo)
-	orderService := service.NewOrderService(orderRepo, cartRepo, bookRepo)
-
-	// Init Controllers
-	authController := controller.NewAuthController(authService)
-	bookController := controller.NewBookController(bookService)
-	userController := controller.NewUserController(userService)
-	cartController := controller.NewCartController(cartService)
-	orderController := controller.NewOrderController(orderService)
-	adminController := controller.NewAdminController(userService, orderService)
-
-	// Routes
-	auth := r.Group("/auth")
-	{
-		auth.POST("/signup", authController.Signup)
-		auth.POST("/login", authController.Login)
+	if flag.Arg(0) == "migrate" {
+		runMigrate(flag.Args()[1:])
+		return
 	}
-
-	// Swagger
-	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
-
-	api := r.Group("/api")
-	api.Use(middleware.AuthMiddleware())
-
-	// Public/User Book Routes
-	api.GET("/books", bookController.ListBooks)
-	api.GET("/books/:id", bookController.GetBook)
-
-	// User Routes
-	api.GET("/profile", userController.GetProfile)
-	api.POST("/addresses", userController.AddAddress)
-	api.GET("/addresses", userController.GetAddresses)
-
-	// Cart Routes
-	api.POST("/cart", cartController.AddToCart)
-	api.GET("/cart", cartController.GetCart) // Review cart
-
-	// Order Routes
-	api.POST("/orders", orderController.PlaceOrder) // Make order
-	api.GET("/orders", orderController.GetOrders)
-
-	// Admin Book Routes
-	admin := api.Group("/admin")
-	admin.Use(middleware.RoleMiddleware("ADMIN"))
-	{
-		admin.POST("/books", bookController.CreateBook)
-		admin.PUT("/books/:id", bookController.UpdateBook)
-		admin.DELETE("/books/:id", bookController.DeleteBook)
-		admin.GET("/profile", userController.GetProfile) // reusing user profile for admin
-		admin.GET("/users", adminController.ListUsers)
-		admin.GET("/orders", adminController.ListOrders)
+	if err := token.Init(); err != nil {
+		logrus.Fatalf("Failed to load JWT signing keys: %s", err)
 	}
+	r := newRouter(setupDatabase())
 
 	port := viper.GetString("server.port")
 	if port == "" {
@@ -162,6 +103,9 @@ func loadConfig(path string) {
 	viper.AddConfigPath(".")            // Current directory
 	viper.AddConfigPath("../../config") // Parent config directory (if running from cmd/server)
 	viper.AddConfigPath("./config")     // Config directory in current
+	_ = viper.BindEnv("application.profile", "APP_PROFILE")
+	_ = viper.BindEnv("jwt.secret", "JWT_SECRET")
+	_ = viper.BindEnv("mail.smtp.password", "MAIL_SMTP_PASSWORD")
 
 	if err := viper.ReadInConfig(); err != nil {
 		// Type assertion to check specifically for FileNotFound
@@ -177,23 +121,110 @@ func loadConfig(path string) {
 		logrus.Infof("Configuration loaded successfully from %s", path)
 		logrus.Info("Configuration Key and Value are printed below")
 		logrus.Info("-------------------------")
-		for key, val := range viper.AllSettings() {
-			logrus.Infof("%s: %v", key, val)
+		keys := viper.AllKeys()
+		sort.Strings(keys)
+		for _, key := range keys {
+			logrus.Infof("%s: %v", key, redactSetting(key, viper.Get(key)))
+		}
+
+	}
+}
+
+// sensitiveSettings are the words that mark a setting as a credential, such as
+// jwt.secret or mail.smtp.password.
+var sensitiveSettings = []string{"password", "secret", "key"}
+
+// redactSetting hides the value of a credential so it never reaches the logs,
+// including credentials nested in lists such as database.replicas.
+func redactSetting(key string, val any) any {
+	name := strings.ToLower(key)
+	for _, word := range sensitiveSettings {
+		if strings.Contains(name, word) {
+			return "[REDACTED]"
+		}
+	}
+
+	switch val := val.(type) {
+	case map[string]any:
+		redacted := make(map[string]any, len(val))
+		for k, v := range val {
+			redacted[k] = redactSetting(k, v)
+		}
+		return redacted
+	case []any:
+		redacted := make([]any, len(val))
+		for i, v := range val {
+			redacted[i] = redactSetting("", v)
+		}
+		return redacted
+	}
+	return val
+}
+
+// Database Connection. The server only starts on a fully migrated schema;
+// apply migrations with `server migrate up`. An in-memory SQLite database
+// starts out empty, so it is migrated here.
+func setupDatabase() *gorm.DB {
+	db := database.GetInstance()
+	migrator := newMigrator(db)
+	if database.InMemory() {
+		if _, err := migrator.Up(context.Background()); err != nil {
+			logrus.Fatalf("Failed to migrate the in-memory database: %s", err)
 		}
+	}
+	if err := migrator.Check(context.Background()); err != nil {
+		logrus.Fatalf("Database schema is not up to date (%s); run `server migrate status` and `server migrate up`", err)
+	}
+	return db
+}
+
+// loginAttemptStore picks where failed-login counters are kept. The database
+// store is shared by every server instance; the memory store is per process.
+func loginAttemptStore(db *gorm.DB) repository.LoginAttemptRepositoryInterface {
+	switch store := viper.GetString("login_protection.store"); store {
+	case "", "database":
+		return repository.NewLoginAttemptRepository(db)
+	case "memory":
+		return repository.NewMemoryLoginAttemptRepository()
+	default:
+		logrus.Fatalf("Unknown login_protection.store %q (want database or memory)", store)
+		return nil
+	}
+}
 
+// rateLimitStore picks where rate limit buckets are kept. The memory store is
+// per process; the database store is shared by every server instance.
+func rateLimitStore(db *gorm.DB) repository.RateLimitRepositoryInterface {
+	switch store := viper.GetString("rate_limit.store"); store {
+	case "", "memory":
+		store := repository.NewMemoryRateLimitRepository()
+		go store.PruneEvery(context.Background(), repository.MemoryRateLimitPruneInterval)
+		return store
+	case "database":
+		store := repository.NewRateLimitRepository(db)
+		go store.PruneEvery(context.Background(), repository.RateLimitPruneInterval)
+		return store
+	default:
+		logrus.Fatalf("Unknown rate_limit.store %q (want memory or database)", store)
+		return nil
 	}
 }
 
-// Database Connection
-func setupDatabase(r *gin.Engine) {
-	database.GetInstance()
-	database.Migrate(
-		&model.User{},
-		&model.Book{},
-		&model.Address{},
-		&model.Cart{},
-		&model.CartItem{},
-		&model.Order{},
-		&model.OrderItem{},
-	)
+// bootstrapAdmin creates or promotes the configured account while the store has
+// no administrator yet. The password can be supplied through BOOTSTRAP_ADMIN_PASSWORD
+// instead of the config file.
+func bootstrapAdmin(authService *service.AuthService) {
+	email := viper.GetString("bootstrap_admin.email")
+	if email == "" {
+		return
+	}
+	_ = viper.BindEnv("bootstrap_admin.password", "BOOTSTRAP_ADMIN_PASSWORD")
+
+	created, err := authService.BootstrapAdmin(context.Background(), viper.GetString("bootstrap_admin.name"), email, viper.GetString("bootstrap_admin.password"))
+	if err != nil {
+		logrus.Fatalf("Failed to bootstrap admin %s: %s", email, err)
+	}
+	if created {
+		logrus.Infof("Bootstrapped admin account %s", email)
+	}
 }
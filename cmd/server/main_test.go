@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/beingaloksharma/book-backend/utils/token"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfig(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	t.Setenv("APP_PROFILE", "")
+	t.Setenv("JWT_SECRET", "")
+
+	// Case 1: The shipped config has no usable JWT secret
+	loadConfig("../../config/")
+	assert.NotEqual(t, "dev", viper.GetString("application.profile"))
+	assert.Error(t, token.Init())
+
+	// Case 2: The secret comes from the environment
+	t.Setenv("JWT_SECRET", "a-real-secret")
+	require.NoError(t, token.Init())
+	assert.Equal(t, "a-real-secret", viper.GetString("jwt.secret"))
+}
+
+func TestRedactSetting(t *testing.T) {
+	for _, key := range []string{"jwt.secret", "mail.smtp.password", "bootstrap_admin.password", "jwt.private_key_path"} {
+		assert.Equal(t, "[REDACTED]", redactSetting(key, "hunter2"), key)
+	}
+	assert.Equal(t, "prod", redactSetting("application.profile", "prod"))
+
+	replicas := redactSetting("database.replicas", []any{map[string]any{"host": "replica-1", "password": "hunter2"}})
+	assert.Equal(t, []any{map[string]any{"host": "replica-1", "password": "[REDACTED]"}}, replicas)
+}
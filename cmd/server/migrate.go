@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/beingaloksharma/book-backend/migrations"
+	"github.com/beingaloksharma/book-backend/utils/database"
+	"github.com/beingaloksharma/book-backend/utils/migrate"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const migrateUsage = `usage: server [-config-path dir] migrate <command>
+
+commands:
+  up             apply all pending migrations
+  down [n]       revert the latest n migrations (default 1)
+  status         list migrations and whether they are applied
+  create <name>  add empty up and down files for Postgres and SQLite`
+
+// newMigrator returns a migrator for the configured database with the
+// migrations built into the binary for its driver.
+func newMigrator(db *gorm.DB) *migrate.Migrator {
+	sqlDB, err := db.DB()
+	if err != nil {
+		logrus.Fatalf("Failed to get database connection: %s", err)
+	}
+	driver := db.Dialector.Name()
+	migrator, err := migrate.New(sqlDB, migrations.ForDriver(driver))
+	if err != nil {
+		logrus.Fatalf("Failed to load migrations: %s", err)
+	}
+	migrator.Dialect = driver
+	return migrator
+}
+
+// runMigrate handles `server migrate <command>`.
+func runMigrate(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		os.Exit(2)
+	}
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		done, err := newMigrator(database.GetInstance()).Up(ctx)
+		for _, m := range done {
+			logrus.Infof("Applied %06d_%s", m.Version, m.Name)
+		}
+		if err != nil {
+			logrus.Fatalf("Migration failed: %s", err)
+		}
+		if len(done) == 0 {
+			logrus.Info("Database schema is up to date")
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				logrus.Fatalf("Invalid number of migrations to revert: %q", args[1])
+			}
+			steps = n
+		}
+		done, err := newMigrator(database.GetInstance()).Down(ctx, steps)
+		for _, m := range done {
+			logrus.Infof("Reverted %06d_%s", m.Version, m.Name)
+		}
+		if err != nil {
+			logrus.Fatalf("Revert failed: %s", err)
+		}
+	case "status":
+		statuses, err := newMigrator(database.GetInstance()).Status(ctx)
+		if err != nil {
+			logrus.Fatalf("Failed to read migration status: %s", err)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tSTATE\tAPPLIED AT")
+		for _, s := range statuses {
+			appliedAt := "-"
+			if s.AppliedAt != nil {
+				appliedAt = s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%06d\t%s\t%s\t%s\n", s.Version, s.Name, s.State, appliedAt)
+		}
+		w.Flush()
+	case "create":
+		if len(args) < 2 {
+			logrus.Fatal("Missing migration name: migrate create <name>")
+		}
+		// Each migration is written for Postgres and for SQLite
+		for _, dir := range []string{"migrations", "migrations/sqlite"} {
+			paths, err := migrate.Create(dir, args[1])
+			if err != nil {
+				logrus.Fatalf("Failed to create migration: %s", err)
+			}
+			for _, path := range paths {
+				logrus.Infof("Created %s", path)
+			}
+		}
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		os.Exit(2)
+	}
+}
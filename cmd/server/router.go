@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+
+	"github.com/beingaloksharma/book-backend/internal/controller"
+	"github.com/beingaloksharma/book-backend/internal/middleware"
+	"github.com/beingaloksharma/book-backend/internal/model"
+	"github.com/beingaloksharma/book-backend/internal/repository"
+	"github.com/beingaloksharma/book-backend/internal/service"
+	"github.com/beingaloksharma/book-backend/utils/mail"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	swaggerFiles "github.com/swaggo/files"
+	ginSwagger "github.com/swaggo/gin-swagger"
+	"gorm.io/gorm"
+)
+
+// newRouter wires the repositories, services and controllers on db and
+// registers every route.
+func newRouter(db *gorm.DB) *gin.Engine {
+	r := gin.Default()
+	// X-Forwarded-For is only believed from the proxies listed here, so clients
+	// cannot pick the address their rate limits and login lockouts are kept under
+	if err := r.SetTrustedProxies(viper.GetStringSlice("server.trusted_proxies")); err != nil {
+		logrus.Fatalf("Invalid server.trusted_proxies: %s", err)
+	}
+
+	// Init Repositories
+	userRepo := repository.NewUserRepository(db)
+	bookRepo := repository.NewBookRepository(db)
+	bookSearchRepo := repository.NewBookSearchRepository(db)
+	cartRepo := repository.NewCartRepository(db)
+	orderRepo := repository.NewOrderRepository(db)
+	idempotencyRepo := repository.NewIdempotencyRepository(db)
+	refreshTokenRepo := repository.NewRefreshTokenRepository(db)
+	roleRepo := repository.NewRoleRepository(db)
+	oneTimeTokenRepo := repository.NewOneTimeTokenRepository(db)
+	auditRepo := repository.NewAuditRepository(db)
+	mfaRepo := repository.NewMFARepository(db)
+	txManager := repository.NewTransactionManager(db)
+
+	mailer, err := mail.NewSender()
+	if err != nil {
+		logrus.Fatalf("Failed to set up mail delivery: %s", err)
+	}
+
+	// Init Services
+	verificationService := service.NewVerificationService(userRepo, oneTimeTokenRepo, mailer)
+	loginGuard := service.NewLoginGuard(loginAttemptStore(db), auditRepo, userRepo, service.LoginPolicyFromConfig())
+	mfaService := service.NewMFAService(userRepo, mfaRepo)
+	authService := service.NewAuthService(userRepo, refreshTokenRepo, verificationService, loginGuard, mfaService)
+	userService := service.NewUserService(userRepo, roleRepo, refreshTokenRepo, txManager)
+	roleService := service.NewRoleService(roleRepo, userRepo)
+	profileService := service.NewProfileService(userRepo, refreshTokenRepo, verificationService, txManager)
+	passwordService := service.NewPasswordService(userRepo, oneTimeTokenRepo, refreshTokenRepo, mailer)
+	bookService := service.NewBookService(bookRepo, bookSearchRepo)
+	cartService := service.NewCartService(cartRepo, bookRepo)
+	orderService := service.NewOrderService(orderRepo, cartRepo, bookRepo, userRepo, txManager, roleRepo)
+	if err := roleService.SyncBuiltinRoles(context.Background()); err != nil {
+		logrus.Fatalf("Failed to set up built-in roles: %s", err)
+	}
+	bootstrapAdmin(authService)
+
+	// Init Controllers
+	authController := controller.NewAuthController(authService)
+	bookController := controller.NewBookController(bookService)
+	userController := controller.NewUserController(userService)
+	cartController := controller.NewCartController(cartService)
+	orderController := controller.NewOrderController(orderService)
+	adminController := controller.NewAdminController(userService, orderService)
+	roleController := controller.NewRoleController(roleService)
+	passwordController := controller.NewPasswordController(passwordService)
+	verificationController := controller.NewVerificationController(verificationService)
+	profileController := controller.NewProfileController(profileService)
+	securityController := controller.NewSecurityController(loginGuard)
+	mfaController := controller.NewMFAController(mfaService)
+	healthController := controller.NewHealthController(db)
+
+	authMiddleware := middleware.AuthMiddleware(refreshTokenRepo)
+	rateLimits := rateLimitStore(db)
+	limit := func(group string) gin.HandlerFunc {
+		return middleware.RateLimitMiddleware(rateLimits, group, middleware.RateLimitFromConfig(group))
+	}
+
+	// Routes
+	auth := r.Group("/auth")
+	auth.Use(limit("auth"))
+	{
+		auth.POST("/signup", authController.Signup)
+		auth.POST("/login", authController.Login)
+		auth.POST("/mfa/enroll", authController.EnrollMFA)
+		auth.POST("/mfa/verify", authController.VerifyMFA)
+		auth.POST("/refresh", authController.Refresh)
+		auth.POST("/logout", authController.Logout)
+		auth.POST("/logout-all", authMiddleware, authController.LogoutAll)
+		auth.POST("/password/forgot", passwordController.ForgotPassword)
+		auth.POST("/password/reset", passwordController.ResetPassword)
+		auth.GET("/verify", verificationController.VerifyEmail)
+		auth.POST("/verify/resend", verificationController.ResendVerification)
+	}
+
+	// Token verification keys for other services
+	r.GET("/.well-known/jwks.json", authController.JWKS)
+
+	// Database health for load balancers; the details are under /api/admin/health
+	r.GET("/health", limit("health"), healthController.Health)
+
+	// Swagger
+	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+
+	api := r.Group("/api")
+	api.Use(authMiddleware, limit("api"))
+	idempotent := middleware.IdempotencyMiddleware(idempotencyRepo, viper.GetDuration("idempotency.ttl"))
+
+	// Public/User Book Routes
+	api.GET("/books", bookController.ListBooks)
+	api.GET("/books/search", bookController.SearchBooks)
+	api.GET("/books/:id", bookController.GetBook)
+
+	// User Routes
+	api.GET("/profile", userController.GetProfile)
+	api.PATCH("/profile", profileController.UpdateProfile)
+	api.DELETE("/profile", profileController.DeleteAccount)
+	api.POST("/profile/password", profileController.ChangePassword)
+	api.POST("/mfa/enroll", mfaController.EnrollMFA)
+	api.POST("/mfa/confirm", mfaController.ConfirmMFA)
+	api.POST("/mfa/disable", mfaController.DisableMFA)
+	api.POST("/mfa/recovery-codes", mfaController.RegenerateRecoveryCodes)
+	api.POST("/addresses", userController.AddAddress)
+	api.GET("/addresses", userController.GetAddresses)
+	api.PUT("/addresses/:id", userController.UpdateAddress)
+	api.DELETE("/addresses/:id", userController.DeleteAddress)
+
+	// Cart Routes
+	api.POST("/cart", cartController.AddToCart)
+	api.GET("/cart", cartController.GetCart) // Review cart
+	api.DELETE("/cart", cartController.ClearCart)
+	api.PUT("/cart/items/:bookId", cartController.UpdateCartItem)
+	api.DELETE("/cart/items/:bookId", cartController.RemoveCartItem)
+
+	// Order Routes
+	api.POST("/orders", idempotent, orderController.PlaceOrder) // Make order
+	api.GET("/orders", orderController.GetOrders)
+	api.POST("/orders/:id/cancel", idempotent, orderController.CancelOrder)
+
+	// Admin Routes - each guarded by the permission it needs
+	admin := api.Group("/admin")
+	admin.Use(limit("admin")) // on top of the api limit
+	can := func(permission model.Permission) gin.HandlerFunc {
+		return middleware.RequirePermission(roleRepo, permission)
+	}
+	{
+		admin.POST("/books", can(model.PermissionBooksWrite), bookController.CreateBook)
+		admin.PUT("/books/:id", can(model.PermissionBooksWrite), bookController.UpdateBook)
+		admin.DELETE("/books/:id", can(model.PermissionBooksWrite), bookController.DeleteBook)
+		admin.GET("/profile", can(model.PermissionAdminAccess), userController.GetProfile) // reusing user profile for admin
+		admin.GET("/health", can(model.PermissionAdminAccess), healthController.HealthDetails)
+		admin.GET("/users", can(model.PermissionUsersRead), adminController.ListUsers)
+		admin.PATCH("/users/:id/role", can(model.PermissionUsersManage), adminController.UpdateUserRole)
+		admin.GET("/users/:id/role-history", can(model.PermissionUsersRead), adminController.GetUserRoleHistory)
+		admin.POST("/users/:id/unlock", can(model.PermissionUsersManage), securityController.UnlockUser)
+		admin.GET("/audit-events", can(model.PermissionUsersRead), securityController.ListAuditEvents)
+		admin.GET("/orders", can(model.PermissionOrdersReadAll), adminController.ListOrders)
+		admin.PATCH("/orders/:id/status", can(model.PermissionOrdersManage), adminController.UpdateOrderStatus)
+		admin.GET("/orders/:id/history", can(model.PermissionOrdersReadAll), adminController.GetOrderStatusHistory)
+		admin.GET("/permissions", can(model.PermissionRolesManage), roleController.ListPermissions)
+		admin.GET("/roles", can(model.PermissionRolesManage), roleController.ListRoles)
+		admin.POST("/roles", can(model.PermissionRolesManage), roleController.CreateRole)
+		admin.PUT("/roles/:name/permissions", can(model.PermissionRolesManage), roleController.SetRolePermissions)
+		admin.DELETE("/roles/:name", can(model.PermissionRolesManage), roleController.DeleteRole)
+	}
+
+	return r
+}
@@ -1,8 +1,12 @@
 package controller
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 
+	"github.com/beingaloksharma/book-backend/internal/identity"
+	"github.com/beingaloksharma/book-backend/internal/model"
 	"github.com/beingaloksharma/book-backend/internal/service"
 	"github.com/beingaloksharma/book-backend/utils/logger"
 	"github.com/gin-gonic/gin"
@@ -22,7 +26,7 @@ func NewAdminController(userService service.UserServiceInterface, orderService s
 
 // ListUsers godoc
 // @Summary List all users
-// @Description Get a list of all registered users (Admin only)
+// @Description Get a list of all registered users (requires users:read)
 // @Tags Admin
 // @Accept json
 // @Produce json
@@ -31,7 +35,7 @@ func NewAdminController(userService service.UserServiceInterface, orderService s
 // @Failure 500 {object} map[string]string
 // @Router /api/admin/users [get]
 func (c *AdminController) ListUsers(ctx *gin.Context) {
-	users, err := c.UserService.GetAllUsers()
+	users, err := c.UserService.GetAllUsers(ctx.Request.Context())
 	if err != nil {
 		logger.LogError(ctx, http.StatusInternalServerError, err, "Failed to fetch users")
 		return
@@ -41,7 +45,7 @@ func (c *AdminController) ListUsers(ctx *gin.Context) {
 
 // ListOrders godoc
 // @Summary List all orders
-// @Description Get a list of all orders (Admin only)
+// @Description Get a list of all orders (requires orders:read_all)
 // @Tags Admin
 // @Accept json
 // @Produce json
@@ -50,10 +54,191 @@ func (c *AdminController) ListUsers(ctx *gin.Context) {
 // @Failure 500 {object} map[string]string
 // @Router /api/admin/orders [get]
 func (c *AdminController) ListOrders(ctx *gin.Context) {
-	orders, err := c.OrderService.GetAllOrders()
+	orders, err := c.OrderService.GetAllOrders(ctx.Request.Context())
 	if err != nil {
 		logger.LogError(ctx, http.StatusInternalServerError, err, "Failed to fetch orders")
 		return
 	}
 	ctx.JSON(http.StatusOK, orders)
 }
+
+type UpdateOrderStatusRequest struct {
+	Status model.OrderStatus `json:"status" binding:"required"`
+	Note   string            `json:"note"`
+}
+
+// UpdateOrderStatus godoc
+// @Summary Update order status
+// @Description Move an order to a new status (PENDING -> PAID -> SHIPPED -> DELIVERED, with CANCELLED/REFUNDED branches) (requires orders:manage)
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Order ID"
+// @Param request body UpdateOrderStatusRequest true "Update Order Status Request"
+// @Success 200 {object} model.Order
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/orders/{id}/status [patch]
+func (c *AdminController) UpdateOrderStatus(ctx *gin.Context) {
+	principal, ok := identity.Require(ctx)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		logger.LogError(ctx, http.StatusBadRequest, err, "Invalid order ID")
+		return
+	}
+
+	var req UpdateOrderStatusRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		logger.LogError(ctx, http.StatusBadRequest, err, "Invalid request body")
+		return
+	}
+
+	order, err := c.OrderService.UpdateOrderStatus(ctx.Request.Context(), uint(id), req.Status, principal.UserID, req.Note)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrOrderNotFound):
+			logger.LogError(ctx, http.StatusNotFound, err, "Order not found")
+		case errors.Is(err, service.ErrInvalidOrderStatus):
+			logger.LogError(ctx, http.StatusBadRequest, err, err.Error())
+		case errors.Is(err, service.ErrInvalidStatusTransition):
+			logger.LogError(ctx, http.StatusConflict, err, err.Error())
+		default:
+			logger.LogError(ctx, http.StatusInternalServerError, err, "Failed to update order status")
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, order)
+}
+
+// GetOrderStatusHistory godoc
+// @Summary Get order status history
+// @Description Get every status change of an order with who made it and when (requires orders:read_all)
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Order ID"
+// @Success 200 {array} model.OrderStatusHistory
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/orders/{id}/history [get]
+func (c *AdminController) GetOrderStatusHistory(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		logger.LogError(ctx, http.StatusBadRequest, err, "Invalid order ID")
+		return
+	}
+
+	history, err := c.OrderService.GetOrderStatusHistory(ctx.Request.Context(), uint(id))
+	if err != nil {
+		if errors.Is(err, service.ErrOrderNotFound) {
+			logger.LogError(ctx, http.StatusNotFound, err, "Order not found")
+			return
+		}
+		logger.LogError(ctx, http.StatusInternalServerError, err, "Failed to fetch order history")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, history)
+}
+
+type UpdateUserRoleRequest struct {
+	Role model.Role `json:"role" binding:"required"`
+	Note string     `json:"note"`
+}
+
+// UpdateUserRole godoc
+// @Summary Change a user's role
+// @Description Move a user to any defined role. Every change is recorded; the last admin cannot be demoted. Callers other than admins can only assign and remove roles whose permissions they have, and need roles:manage to assign ADMIN or roles:manage (requires users:manage)
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Param request body UpdateUserRoleRequest true "Update User Role Request"
+// @Success 200 {object} model.User
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/users/{id}/role [patch]
+func (c *AdminController) UpdateUserRole(ctx *gin.Context) {
+	principal, ok := identity.Require(ctx)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		logger.LogError(ctx, http.StatusBadRequest, err, "Invalid user ID")
+		return
+	}
+
+	var req UpdateUserRoleRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		logger.LogError(ctx, http.StatusBadRequest, err, "Invalid request body")
+		return
+	}
+
+	user, err := c.UserService.ChangeRole(ctx.Request.Context(), uint(id), req.Role, principal, req.Note)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrUserNotFound):
+			logger.LogError(ctx, http.StatusNotFound, err, "User not found")
+		case errors.Is(err, service.ErrRoleEscalation):
+			logger.LogError(ctx, http.StatusForbidden, err, err.Error())
+		case errors.Is(err, service.ErrInvalidRole):
+			logger.LogError(ctx, http.StatusBadRequest, err, err.Error())
+		case errors.Is(err, service.ErrLastAdmin), errors.Is(err, service.ErrRoleConflict):
+			logger.LogError(ctx, http.StatusConflict, err, err.Error())
+		default:
+			logger.LogError(ctx, http.StatusInternalServerError, err, "Failed to update user role")
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, user)
+}
+
+// GetUserRoleHistory godoc
+// @Summary Get user role history
+// @Description Get every role change of a user with who made it and when (requires users:read)
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Success 200 {array} model.UserRoleHistory
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/users/{id}/role-history [get]
+func (c *AdminController) GetUserRoleHistory(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		logger.LogError(ctx, http.StatusBadRequest, err, "Invalid user ID")
+		return
+	}
+
+	history, err := c.UserService.GetRoleHistory(ctx.Request.Context(), uint(id))
+	if err != nil {
+		if errors.Is(err, service.ErrUserNotFound) {
+			logger.LogError(ctx, http.StatusNotFound, err, "User not found")
+			return
+		}
+		logger.LogError(ctx, http.StatusInternalServerError, err, "Failed to fetch role history")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, history)
+}
@@ -1,13 +1,18 @@
 package controller_test
 
 import (
+	"bytes"
 	"errors"
+	"fmt"
+	"github.com/stretchr/testify/mock"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/beingaloksharma/book-backend/internal/controller"
+	"github.com/beingaloksharma/book-backend/internal/identity"
 	"github.com/beingaloksharma/book-backend/internal/model"
+	"github.com/beingaloksharma/book-backend/internal/service"
 	"github.com/beingaloksharma/book-backend/internal/service/mocks"
 	"github.com/beingaloksharma/book-backend/utils/logger"
 	"github.com/gin-gonic/gin"
@@ -25,7 +30,7 @@ func TestAdminListUsers(t *testing.T) {
 	r := gin.Default()
 	r.GET("/admin/users", adminController.ListUsers)
 
-	mockUserService.On("GetAllUsers").Return([]model.User{}, nil)
+	mockUserService.On("GetAllUsers", mock.Anything).Return([]model.User{}, nil).Once()
 
 	req, _ := http.NewRequest("GET", "/admin/users", nil)
 	w := httptest.NewRecorder()
@@ -34,7 +39,7 @@ func TestAdminListUsers(t *testing.T) {
 	assert.Equal(t, http.StatusOK, w.Code)
 
 	// Case 2: Service Error
-	mockUserService.On("GetAllUsers").Return(nil, errors.New("failed"))
+	mockUserService.On("GetAllUsers", mock.Anything).Return(nil, errors.New("failed"))
 	w2 := httptest.NewRecorder()
 	r.ServeHTTP(w2, req)
 	assert.Equal(t, http.StatusInternalServerError, w2.Code)
@@ -49,7 +54,7 @@ func TestAdminListOrders(t *testing.T) {
 	r := gin.Default()
 	r.GET("/admin/orders", adminController.ListOrders)
 
-	mockOrderService.On("GetAllOrders").Return([]model.Order{}, nil)
+	mockOrderService.On("GetAllOrders", mock.Anything).Return([]model.Order{}, nil).Once()
 
 	req, _ := http.NewRequest("GET", "/admin/orders", nil)
 	w := httptest.NewRecorder()
@@ -58,8 +63,177 @@ func TestAdminListOrders(t *testing.T) {
 	assert.Equal(t, http.StatusOK, w.Code)
 
 	// Case 2: Service Error
-	mockOrderService.On("GetAllOrders").Return(nil, errors.New("failed"))
+	mockOrderService.On("GetAllOrders", mock.Anything).Return(nil, errors.New("failed"))
 	w2 := httptest.NewRecorder()
 	r.ServeHTTP(w2, req)
 	assert.Equal(t, http.StatusInternalServerError, w2.Code)
 }
+
+func TestAdminUpdateOrderStatus(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockUserService := new(mocks.MockUserService)
+	mockOrderService := new(mocks.MockOrderService)
+	adminController := controller.NewAdminController(mockUserService, mockOrderService)
+
+	r := gin.Default()
+	r.Use(func(c *gin.Context) {
+		identity.Set(c, identity.Principal{UserID: uint(9)})
+	})
+	r.PATCH("/admin/orders/:id/status", adminController.UpdateOrderStatus)
+
+	// Case 1: Success
+	mockOrderService.On("UpdateOrderStatus", mock.Anything, uint(1), model.OrderStatusShipped, uint(9), "tracking 123").
+		Return(&model.Order{Status: model.OrderStatusShipped}, nil).Once()
+
+	body := `{"status":"SHIPPED","note":"tracking 123"}`
+	req, _ := http.NewRequest("PATCH", "/admin/orders/1/status", bytes.NewBufferString(body))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Case 2: Illegal transition
+	mockOrderService.On("UpdateOrderStatus", mock.Anything, uint(1), model.OrderStatusPending, uint(9), "").
+		Return(nil, fmt.Errorf("%w: cannot move order from SHIPPED to PENDING", service.ErrInvalidStatusTransition)).Once()
+
+	req, _ = http.NewRequest("PATCH", "/admin/orders/1/status", bytes.NewBufferString(`{"status":"PENDING"}`))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "cannot move order from SHIPPED to PENDING")
+
+	// Case 3: Not found
+	mockOrderService.On("UpdateOrderStatus", mock.Anything, uint(2), model.OrderStatusPaid, uint(9), "").
+		Return(nil, service.ErrOrderNotFound).Once()
+
+	req, _ = http.NewRequest("PATCH", "/admin/orders/2/status", bytes.NewBufferString(`{"status":"PAID"}`))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// Case 4: Validation Error
+	req, _ = http.NewRequest("PATCH", "/admin/orders/1/status", bytes.NewBufferString(`{}`))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestAdminGetOrderStatusHistory(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockUserService := new(mocks.MockUserService)
+	mockOrderService := new(mocks.MockOrderService)
+	adminController := controller.NewAdminController(mockUserService, mockOrderService)
+
+	r := gin.Default()
+	r.GET("/admin/orders/:id/history", adminController.GetOrderStatusHistory)
+
+	mockOrderService.On("GetOrderStatusHistory", mock.Anything, uint(1)).Return([]model.OrderStatusHistory{}, nil).Once()
+
+	req, _ := http.NewRequest("GET", "/admin/orders/1/history", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	mockOrderService.On("GetOrderStatusHistory", mock.Anything, uint(2)).Return(nil, service.ErrOrderNotFound).Once()
+
+	req, _ = http.NewRequest("GET", "/admin/orders/2/history", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestAdminUpdateUserRole(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockUserService := new(mocks.MockUserService)
+	mockOrderService := new(mocks.MockOrderService)
+	adminController := controller.NewAdminController(mockUserService, mockOrderService)
+
+	actor := identity.Principal{UserID: uint(9), Role: model.RoleAdmin}
+	r := gin.Default()
+	r.Use(func(c *gin.Context) {
+		identity.Set(c, actor)
+	})
+	r.PATCH("/admin/users/:id/role", adminController.UpdateUserRole)
+
+	// Case 1: Success
+	mockUserService.On("ChangeRole", mock.Anything, uint(2), model.RoleAdmin, actor, "new store manager").
+		Return(&model.User{Role: model.RoleAdmin}, nil).Once()
+
+	req, _ := http.NewRequest("PATCH", "/admin/users/2/role", bytes.NewBufferString(`{"role":"ADMIN","note":"new store manager"}`))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"role":"ADMIN"`)
+
+	// Case 2: Unknown role
+	mockUserService.On("ChangeRole", mock.Anything, uint(2), model.Role("OWNER"), actor, "").
+		Return(nil, fmt.Errorf("%w: %q", service.ErrInvalidRole, "OWNER")).Once()
+
+	req, _ = http.NewRequest("PATCH", "/admin/users/2/role", bytes.NewBufferString(`{"role":"OWNER"}`))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Case 3: Demoting the last admin
+	mockUserService.On("ChangeRole", mock.Anything, uint(9), model.RoleUser, actor, "").
+		Return(nil, service.ErrLastAdmin).Once()
+
+	req, _ = http.NewRequest("PATCH", "/admin/users/9/role", bytes.NewBufferString(`{"role":"USER"}`))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	// Case 4: Not found
+	mockUserService.On("ChangeRole", mock.Anything, uint(3), model.RoleAdmin, actor, "").
+		Return(nil, service.ErrUserNotFound).Once()
+
+	req, _ = http.NewRequest("PATCH", "/admin/users/3/role", bytes.NewBufferString(`{"role":"ADMIN"}`))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// Case 5: Granting permissions the caller lacks
+	mockUserService.On("ChangeRole", mock.Anything, uint(2), model.RoleAdmin, actor, "").
+		Return(nil, service.ErrRoleEscalation).Once()
+
+	req, _ = http.NewRequest("PATCH", "/admin/users/2/role", bytes.NewBufferString(`{"role":"ADMIN"}`))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// Case 6: Validation Error
+	req, _ = http.NewRequest("PATCH", "/admin/users/2/role", bytes.NewBufferString(`{}`))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	mockUserService.AssertExpectations(t)
+}
+
+func TestAdminGetUserRoleHistory(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockUserService := new(mocks.MockUserService)
+	mockOrderService := new(mocks.MockOrderService)
+	adminController := controller.NewAdminController(mockUserService, mockOrderService)
+
+	r := gin.Default()
+	r.GET("/admin/users/:id/role-history", adminController.GetUserRoleHistory)
+
+	// Case 1: Success
+	mockUserService.On("GetRoleHistory", mock.Anything, uint(2)).Return([]model.UserRoleHistory{
+		{UserID: 2, FromRole: model.RoleUser, ToRole: model.RoleAdmin, ChangedBy: 9},
+	}, nil).Once()
+
+	req, _ := http.NewRequest("GET", "/admin/users/2/role-history", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"to_role":"ADMIN"`)
+
+	// Case 2: Not found
+	mockUserService.On("GetRoleHistory", mock.Anything, uint(3)).Return(nil, service.ErrUserNotFound).Once()
+
+	req, _ = http.NewRequest("GET", "/admin/users/3/role-history", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
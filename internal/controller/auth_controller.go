@@ -1,11 +1,16 @@
 package controller
 
 import (
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
-	"github.com/beingaloksharma/book-backend/internal/model"
+	"github.com/beingaloksharma/book-backend/internal/identity"
 	"github.com/beingaloksharma/book-backend/internal/service"
 	"github.com/beingaloksharma/book-backend/utils/logger"
+	"github.com/beingaloksharma/book-backend/utils/token"
 	"github.com/gin-gonic/gin"
 )
 
@@ -18,10 +23,9 @@ func NewAuthController(authService service.AuthServiceInterface) *AuthController
 }
 
 type SignupRequest struct {
-	Name     string     `json:"name" binding:"required"`
-	Email    string     `json:"email" binding:"required,email"`
-	Password string     `json:"password" binding:"required,min=6"`
-	Role     model.Role `json:"role"`
+	Name     string `json:"name" binding:"required"`
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required,min=6"`
 }
 
 type LoginRequest struct {
@@ -29,9 +33,18 @@ type LoginRequest struct {
 	Password string `json:"password" binding:"required"`
 }
 
+type MFAChallengeRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+}
+
+type VerifyMFARequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
 // Signup godoc
 // @Summary Register a new user
-// @Description Register a new user with name, email, password and role (USER/ADMIN)
+// @Description Register a new user with name, email and password. Accounts are always created with the USER role.
 // @Tags Auth
 // @Accept json
 // @Produce json
@@ -46,7 +59,7 @@ func (c *AuthController) Signup(ctx *gin.Context) {
 		return
 	}
 
-	if err := c.AuthService.Signup(req.Name, req.Email, req.Password, req.Role); err != nil {
+	if err := c.AuthService.Signup(ctx.Request.Context(), req.Name, req.Email, req.Password); err != nil {
 		logger.LogError(ctx, http.StatusBadRequest, err, "Signup failed")
 		return
 	}
@@ -54,16 +67,22 @@ func (c *AuthController) Signup(ctx *gin.Context) {
 	ctx.JSON(http.StatusCreated, gin.H{"message": "User created successfully"})
 }
 
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
 // Login godoc
 // @Summary Login user
-// @Description Login user and generate JWT token
+// @Description Login user and start a session: returns a short-lived JWT access token and a refresh token. Accounts with MFA, or whose role requires it, instead get an MFA challenge (service.MFAChallenge) to complete at /auth/mfa/verify.
 // @Tags Auth
 // @Accept json
 // @Produce json
 // @Param request body LoginRequest true "Login Request"
-// @Success 200 {object} map[string]string
+// @Success 200 {object} service.TokenPair
 // @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 429 {object} map[string]string
 // @Router /auth/login [post]
 func (c *AuthController) Login(ctx *gin.Context) {
 	var req LoginRequest
@@ -72,11 +91,209 @@ func (c *AuthController) Login(ctx *gin.Context) {
 		return
 	}
 
-	token, err := c.AuthService.Login(req.Email, req.Password)
+	result, err := c.AuthService.Login(ctx.Request.Context(), req.Email, req.Password, ctx.ClientIP())
+	if err != nil {
+		var throttled *service.LoginThrottledError
+		switch {
+		case errors.As(err, &throttled):
+			setRetryAfter(ctx, throttled.RetryAfter)
+			logger.LogError(ctx, http.StatusTooManyRequests, err, "Too many failed login attempts; try again later")
+		case errors.Is(err, service.ErrEmailNotVerified):
+			logger.LogError(ctx, http.StatusForbidden, err, "Verify your email address before logging in")
+		default:
+			logger.LogError(ctx, http.StatusUnauthorized, err, "Login failed")
+		}
+		return
+	}
+
+	if result.Challenge != nil {
+		ctx.JSON(http.StatusOK, result.Challenge)
+		return
+	}
+	ctx.JSON(http.StatusOK, result.Tokens)
+}
+
+// EnrollMFA godoc
+// @Summary Set up MFA during login
+// @Description For an account whose role requires MFA but has none yet: returns a new TOTP secret and otpauth URI for the MFA token from /auth/login. Confirm it with a code at /auth/mfa/verify.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body MFAChallengeRequest true "MFA Challenge"
+// @Success 200 {object} service.MFAEnrollment
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/mfa/enroll [post]
+func (c *AuthController) EnrollMFA(ctx *gin.Context) {
+	var req MFAChallengeRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		logger.LogError(ctx, http.StatusBadRequest, err, "Invalid request body")
+		return
+	}
+
+	enrollment, err := c.AuthService.BeginMFAEnrollment(ctx.Request.Context(), req.MFAToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidMFAChallenge), errors.Is(err, service.ErrUserNotFound):
+			logger.LogError(ctx, http.StatusUnauthorized, err, "Invalid or expired MFA token")
+		case errors.Is(err, service.ErrMFAAlreadyEnabled):
+			logger.LogError(ctx, http.StatusConflict, err, err.Error())
+		default:
+			logger.LogError(ctx, http.StatusInternalServerError, err, "Failed to set up MFA")
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, enrollment)
+}
+
+// VerifyMFA godoc
+// @Summary Complete login with a second factor
+// @Description Exchange the MFA token from /auth/login and a TOTP or recovery code for a session. If the login also finished MFA setup, the response carries the recovery codes, shown only this once.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body VerifyMFARequest true "Verify MFA Request"
+// @Success 200 {object} service.MFALoginResult
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/mfa/verify [post]
+func (c *AuthController) VerifyMFA(ctx *gin.Context) {
+	var req VerifyMFARequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		logger.LogError(ctx, http.StatusBadRequest, err, "Invalid request body")
+		return
+	}
+
+	result, err := c.AuthService.VerifyMFA(ctx.Request.Context(), req.MFAToken, req.Code, ctx.ClientIP())
+	if err != nil {
+		var throttled *service.LoginThrottledError
+		switch {
+		case errors.As(err, &throttled):
+			setRetryAfter(ctx, throttled.RetryAfter)
+			logger.LogError(ctx, http.StatusTooManyRequests, err, "Too many failed login attempts; try again later")
+		case errors.Is(err, service.ErrInvalidMFAChallenge):
+			logger.LogError(ctx, http.StatusUnauthorized, err, "Invalid or expired MFA token")
+		case errors.Is(err, service.ErrInvalidMFACode):
+			logger.LogError(ctx, http.StatusUnauthorized, err, "Invalid MFA code")
+		case errors.Is(err, service.ErrMFANotEnrolled):
+			logger.LogError(ctx, http.StatusBadRequest, err, "Set up MFA at /auth/mfa/enroll first")
+		default:
+			logger.LogError(ctx, http.StatusInternalServerError, err, "Failed to verify MFA code")
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, result)
+}
+
+// setRetryAfter tells the client how many seconds to wait before trying again.
+func setRetryAfter(ctx *gin.Context, wait time.Duration) {
+	ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+}
+
+// Refresh godoc
+// @Summary Refresh tokens
+// @Description Exchange a refresh token for a new access token and refresh token. Each refresh token works once; reusing one revokes the session.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body RefreshTokenRequest true "Refresh Token Request"
+// @Success 200 {object} service.TokenPair
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/refresh [post]
+func (c *AuthController) Refresh(ctx *gin.Context) {
+	var req RefreshTokenRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		logger.LogError(ctx, http.StatusBadRequest, err, "Invalid request body")
+		return
+	}
+
+	tokens, err := c.AuthService.Refresh(ctx.Request.Context(), req.RefreshToken)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidRefreshToken) || errors.Is(err, service.ErrRefreshTokenReused) {
+			logger.LogError(ctx, http.StatusUnauthorized, err, err.Error())
+			return
+		}
+		logger.LogError(ctx, http.StatusInternalServerError, err, "Failed to refresh token")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, tokens)
+}
+
+// Logout godoc
+// @Summary Logout
+// @Description End the session the refresh token belongs to; its access tokens stop working too
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body RefreshTokenRequest true "Refresh Token Request"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/logout [post]
+func (c *AuthController) Logout(ctx *gin.Context) {
+	var req RefreshTokenRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		logger.LogError(ctx, http.StatusBadRequest, err, "Invalid request body")
+		return
+	}
+
+	if err := c.AuthService.Logout(ctx.Request.Context(), req.RefreshToken); err != nil {
+		logger.LogError(ctx, http.StatusInternalServerError, err, "Failed to logout")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+// LogoutAll godoc
+// @Summary Logout everywhere
+// @Description End every session of the logged-in user
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/logout-all [post]
+func (c *AuthController) LogoutAll(ctx *gin.Context) {
+	principal, ok := identity.Require(ctx)
+	if !ok {
+		return
+	}
+
+	if err := c.AuthService.LogoutAll(ctx.Request.Context(), principal.UserID); err != nil {
+		logger.LogError(ctx, http.StatusInternalServerError, err, "Failed to logout")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Logged out of all sessions"})
+}
+
+// JWKS godoc
+// @Summary Token verification keys
+// @Description Public keys, as a JSON Web Key Set, that verify access tokens issued by this server
+// @Tags Auth
+// @Produce json
+// @Success 200 {object} token.JWKSet
+// @Failure 500 {object} map[string]string
+// @Router /.well-known/jwks.json [get]
+func (c *AuthController) JWKS(ctx *gin.Context) {
+	keys, err := token.PublicJWKS()
 	if err != nil {
-		logger.LogError(ctx, http.StatusUnauthorized, err, "Login failed")
+		logger.LogError(ctx, http.StatusInternalServerError, err, "Failed to load signing keys")
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{"token": token})
+	ctx.Header("Cache-Control", "public, max-age=300")
+	ctx.JSON(http.StatusOK, keys)
 }
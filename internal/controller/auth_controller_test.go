@@ -6,13 +6,18 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/beingaloksharma/book-backend/internal/controller"
-	"github.com/beingaloksharma/book-backend/internal/model"
+	"github.com/beingaloksharma/book-backend/internal/identity"
+	"github.com/beingaloksharma/book-backend/internal/service"
 	"github.com/beingaloksharma/book-backend/internal/service/mocks"
 	"github.com/beingaloksharma/book-backend/utils/logger"
+	"github.com/beingaloksharma/book-backend/utils/token"
 	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 func TestSignup(t *testing.T) {
@@ -26,7 +31,7 @@ func TestSignup(t *testing.T) {
 	r.POST("/signup", authController.Signup)
 
 	// Case 1: Success
-	mockService.On("Signup", "John", "john@example.com", "pass123", model.RoleUser).Return(nil).Once()
+	mockService.On("Signup", mock.Anything, "John", "john@example.com", "pass123").Return(nil).Once()
 
 	body := `{"name":"John", "email":"john@example.com", "password":"pass123", "role":"USER"}`
 	req, _ := http.NewRequest("POST", "/signup", bytes.NewBufferString(body))
@@ -44,13 +49,23 @@ func TestSignup(t *testing.T) {
 	assert.Equal(t, http.StatusBadRequest, w.Code)
 
 	// Case 3: Service Error
-	mockService.On("Signup", "John", "john@example.com", "pass123", model.RoleUser).Return(errors.New("failed")).Once()
+	mockService.On("Signup", mock.Anything, "John", "john@example.com", "pass123").Return(errors.New("failed")).Once()
 	body = `{"name":"John", "email":"john@example.com", "password":"pass123", "role":"USER"}`
 	req, _ = http.NewRequest("POST", "/signup", bytes.NewBufferString(body))
 	w = httptest.NewRecorder()
 	r.ServeHTTP(w, req)
 
 	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Case 4: A requested role is ignored
+	mockService.On("Signup", mock.Anything, "Mallory", "mallory@example.com", "pass123").Return(nil).Once()
+	body = `{"name":"Mallory", "email":"mallory@example.com", "password":"pass123", "role":"ADMIN"}`
+	req, _ = http.NewRequest("POST", "/signup", bytes.NewBufferString(body))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	mockService.AssertExpectations(t)
 }
 
 func TestLogin(t *testing.T) {
@@ -64,7 +79,12 @@ func TestLogin(t *testing.T) {
 	r.POST("/login", authController.Login)
 
 	// Case 1: Success
-	mockService.On("Login", "john@example.com", "pass123").Return("token123", nil).Once()
+	mockService.On("Login", mock.Anything, "john@example.com", "pass123", mock.Anything).Return(&service.LoginResult{Tokens: &service.TokenPair{
+		AccessToken:  "token123",
+		RefreshToken: "refresh123",
+		TokenType:    "Bearer",
+		ExpiresIn:    900,
+	}}, nil).Once()
 
 	body := `{"email":"john@example.com", "password":"pass123"}`
 	req, _ := http.NewRequest("POST", "/login", bytes.NewBufferString(body))
@@ -72,10 +92,11 @@ func TestLogin(t *testing.T) {
 	r.ServeHTTP(w, req)
 
 	assert.Equal(t, http.StatusOK, w.Code)
-	assert.Contains(t, w.Body.String(), "token123")
+	assert.Contains(t, w.Body.String(), `"token":"token123"`)
+	assert.Contains(t, w.Body.String(), `"refresh_token":"refresh123"`)
 
 	// Case 2: Unauthorized
-	mockService.On("Login", "john@example.com", "wrong").Return("", errors.New("invalid")).Once()
+	mockService.On("Login", mock.Anything, "john@example.com", "wrong", mock.Anything).Return(nil, errors.New("invalid")).Once()
 
 	body = `{"email":"john@example.com", "password":"wrong"}`
 	req, _ = http.NewRequest("POST", "/login", bytes.NewBufferString(body))
@@ -83,4 +104,205 @@ func TestLogin(t *testing.T) {
 	r.ServeHTTP(w, req)
 
 	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// Case 3: Email not verified yet
+	mockService.On("Login", mock.Anything, "new@example.com", "pass123", mock.Anything).Return(nil, service.ErrEmailNotVerified).Once()
+
+	body = `{"email":"new@example.com", "password":"pass123"}`
+	req, _ = http.NewRequest("POST", "/login", bytes.NewBufferString(body))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// Case 4: Throttled by the client address, with a hint when to retry
+	mockService.On("Login", mock.Anything, "john@example.com", "pass123", "10.0.0.1").Return(nil, &service.LoginThrottledError{RetryAfter: 89500 * time.Millisecond}).Once()
+
+	body = `{"email":"john@example.com", "password":"pass123"}`
+	req, _ = http.NewRequest("POST", "/login", bytes.NewBufferString(body))
+	req.RemoteAddr = "10.0.0.1:51234"
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "90", w.Header().Get("Retry-After"))
+
+	// Case 5: MFA accounts get a challenge instead of tokens
+	mockService.On("Login", mock.Anything, "admin@example.com", "pass123", mock.Anything).Return(&service.LoginResult{Challenge: &service.MFAChallenge{
+		MFARequired:    true,
+		ChallengeToken: "challenge123",
+		ExpiresIn:      300,
+	}}, nil).Once()
+
+	body = `{"email":"admin@example.com", "password":"pass123"}`
+	req, _ = http.NewRequest("POST", "/login", bytes.NewBufferString(body))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"mfa_required":true`)
+	assert.Contains(t, w.Body.String(), `"mfa_token":"challenge123"`)
+	assert.NotContains(t, w.Body.String(), `"token"`)
+	mockService.AssertExpectations(t)
+}
+
+func TestVerifyMFA(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger.Init()
+
+	mockService := new(mocks.MockAuthService)
+	authController := controller.NewAuthController(mockService)
+
+	r := gin.Default()
+	r.POST("/mfa/enroll", authController.EnrollMFA)
+	r.POST("/mfa/verify", authController.VerifyMFA)
+
+	send := func(path, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", path, bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// Case 1: Enrollment during login returns the new secret
+	mockService.On("BeginMFAEnrollment", mock.Anything, "challenge123").Return(&service.MFAEnrollment{Secret: "SECRET", URI: "otpauth://totp/x"}, nil).Once()
+
+	w := send("/mfa/enroll", `{"mfa_token":"challenge123"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"otpauth_uri":"otpauth://totp/x"`)
+
+	// Case 2: Expired challenge
+	mockService.On("BeginMFAEnrollment", mock.Anything, "expired").Return(nil, service.ErrInvalidMFAChallenge).Once()
+	assert.Equal(t, http.StatusUnauthorized, send("/mfa/enroll", `{"mfa_token":"expired"}`).Code)
+
+	// Case 3: A valid code completes the login
+	mockService.On("VerifyMFA", mock.Anything, "challenge123", "123456", mock.Anything).Return(&service.MFALoginResult{
+		TokenPair:     service.TokenPair{AccessToken: "token123", RefreshToken: "refresh123"},
+		RecoveryCodes: []string{"aaaa-bbbb-cccc-dddd"},
+	}, nil).Once()
+
+	w = send("/mfa/verify", `{"mfa_token":"challenge123","code":"123456"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"token":"token123"`)
+	assert.Contains(t, w.Body.String(), `"recovery_codes":["aaaa-bbbb-cccc-dddd"]`)
+
+	// Case 4: Wrong code
+	mockService.On("VerifyMFA", mock.Anything, "challenge123", "000000", mock.Anything).Return(nil, service.ErrInvalidMFACode).Once()
+	assert.Equal(t, http.StatusUnauthorized, send("/mfa/verify", `{"mfa_token":"challenge123","code":"000000"}`).Code)
+
+	// Case 5: Too many wrong codes
+	mockService.On("VerifyMFA", mock.Anything, "challenge123", "111111", mock.Anything).Return(nil, &service.LoginThrottledError{RetryAfter: time.Second}).Once()
+
+	w = send("/mfa/verify", `{"mfa_token":"challenge123","code":"111111"}`)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+
+	// Case 6: Missing code
+	assert.Equal(t, http.StatusBadRequest, send("/mfa/verify", `{"mfa_token":"challenge123"}`).Code)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestRefresh(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger.Init()
+
+	mockService := new(mocks.MockAuthService)
+	authController := controller.NewAuthController(mockService)
+
+	r := gin.Default()
+	r.POST("/refresh", authController.Refresh)
+
+	// Case 1: Success
+	mockService.On("Refresh", mock.Anything, "refresh123").Return(&service.TokenPair{AccessToken: "token456", RefreshToken: "refresh456"}, nil).Once()
+
+	req, _ := http.NewRequest("POST", "/refresh", bytes.NewBufferString(`{"refresh_token":"refresh123"}`))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "refresh456")
+
+	// Case 2: Reused token
+	mockService.On("Refresh", mock.Anything, "refresh123").Return(nil, service.ErrRefreshTokenReused).Once()
+
+	req, _ = http.NewRequest("POST", "/refresh", bytes.NewBufferString(`{"refresh_token":"refresh123"}`))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// Case 3: Validation Error
+	req, _ = http.NewRequest("POST", "/refresh", bytes.NewBufferString(`{}`))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestLogout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger.Init()
+
+	mockService := new(mocks.MockAuthService)
+	authController := controller.NewAuthController(mockService)
+
+	r := gin.Default()
+	r.Use(func(c *gin.Context) {
+		identity.Set(c, identity.Principal{UserID: uint(1)})
+	})
+	r.POST("/logout", authController.Logout)
+	r.POST("/logout-all", authController.LogoutAll)
+
+	// Case 1: Logout one session
+	mockService.On("Logout", mock.Anything, "refresh123").Return(nil).Once()
+
+	req, _ := http.NewRequest("POST", "/logout", bytes.NewBufferString(`{"refresh_token":"refresh123"}`))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Case 2: Validation Error
+	req, _ = http.NewRequest("POST", "/logout", bytes.NewBufferString(`{}`))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Case 3: Logout everywhere
+	mockService.On("LogoutAll", mock.Anything, uint(1)).Return(nil).Once()
+
+	req, _ = http.NewRequest("POST", "/logout-all", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Case 4: Service Error
+	mockService.On("LogoutAll", mock.Anything, uint(1)).Return(errors.New("db error")).Once()
+
+	req, _ = http.NewRequest("POST", "/logout-all", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestJWKS(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger.Init()
+	viper.Set("jwt.secret", "testsecret")
+	token.Init()
+
+	authController := controller.NewAuthController(new(mocks.MockAuthService))
+
+	r := gin.Default()
+	r.GET("/.well-known/jwks.json", authController.JWKS)
+
+	// Case 1: Shared secrets are never published
+	req, _ := http.NewRequest("GET", "/.well-known/jwks.json", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"keys":[]}`, w.Body.String())
+	assert.Equal(t, "public, max-age=300", w.Header().Get("Cache-Control"))
 }
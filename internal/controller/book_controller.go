@@ -3,7 +3,10 @@ package controller
 import (
 	"net/http"
 	"strconv"
+	"strings"
 
+	"github.com/beingaloksharma/book-backend/internal/model"
+	"github.com/beingaloksharma/book-backend/internal/repository"
 	"github.com/beingaloksharma/book-backend/internal/service"
 	"github.com/beingaloksharma/book-backend/utils/logger"
 	"github.com/gin-gonic/gin"
@@ -25,9 +28,36 @@ type BookRequest struct {
 	Stock       int     `json:"stock" binding:"required"`
 }
 
+type ListBooksQuery struct {
+	Page     int      `form:"page,default=1" binding:"min=1"`
+	Limit    int      `form:"limit,default=20" binding:"min=1,max=100"`
+	Author   string   `form:"author"`
+	MinPrice *float64 `form:"min_price" binding:"omitempty,min=0"`
+	MaxPrice *float64 `form:"max_price" binding:"omitempty,min=0"`
+	InStock  bool     `form:"in_stock"`
+	Sort     string   `form:"sort" binding:"omitempty,oneof=price title created_at"`
+	Order    string   `form:"order" binding:"omitempty,oneof=asc desc"`
+}
+
+type BookListResponse struct {
+	Data       []model.Book `json:"data"`
+	Pagination Pagination   `json:"pagination"`
+}
+
+type SearchBooksQuery struct {
+	Q     string `form:"q" binding:"required"`
+	Page  int    `form:"page,default=1" binding:"min=1"`
+	Limit int    `form:"limit,default=20" binding:"min=1,max=100"`
+}
+
+type BookSearchResponse struct {
+	Data       []repository.BookSearchResult `json:"data"`
+	Pagination Pagination                    `json:"pagination"`
+}
+
 // CreateBook godoc
 // @Summary Create a new book
-// @Description Create a new book (Admin only)
+// @Description Create a new book (requires books:write)
 // @Tags Admin
 // @Accept json
 // @Produce json
@@ -44,7 +74,7 @@ func (c *BookController) CreateBook(ctx *gin.Context) {
 		return
 	}
 
-	if err := c.BookService.CreateBook(req.Title, req.Author, req.Description, req.Price, req.Stock); err != nil {
+	if err := c.BookService.CreateBook(ctx.Request.Context(), req.Title, req.Author, req.Description, req.Price, req.Stock); err != nil {
 		logger.LogError(ctx, http.StatusInternalServerError, err, "Failed to create book")
 		return
 	}
@@ -54,7 +84,7 @@ func (c *BookController) CreateBook(ctx *gin.Context) {
 
 // UpdateBook godoc
 // @Summary Update a book
-// @Description Update an existing book (Admin only)
+// @Description Update an existing book (requires books:write)
 // @Tags Admin
 // @Accept json
 // @Produce json
@@ -79,7 +109,7 @@ func (c *BookController) UpdateBook(ctx *gin.Context) {
 		return
 	}
 
-	if err := c.BookService.UpdateBook(uint(id), req.Title, req.Author, req.Description, req.Price, req.Stock); err != nil {
+	if err := c.BookService.UpdateBook(ctx.Request.Context(), uint(id), req.Title, req.Author, req.Description, req.Price, req.Stock); err != nil {
 		logger.LogError(ctx, http.StatusInternalServerError, err, "Failed to update book")
 		return
 	}
@@ -89,7 +119,7 @@ func (c *BookController) UpdateBook(ctx *gin.Context) {
 
 // DeleteBook godoc
 // @Summary Delete a book
-// @Description Delete a book by ID (Admin only)
+// @Description Delete a book by ID (requires books:write)
 // @Tags Admin
 // @Accept json
 // @Produce json
@@ -107,7 +137,7 @@ func (c *BookController) DeleteBook(ctx *gin.Context) {
 		return
 	}
 
-	if err := c.BookService.DeleteBook(uint(id)); err != nil {
+	if err := c.BookService.DeleteBook(ctx.Request.Context(), uint(id)); err != nil {
 		logger.LogError(ctx, http.StatusInternalServerError, err, "Failed to delete book")
 		return
 	}
@@ -135,7 +165,7 @@ func (c *BookController) GetBook(ctx *gin.Context) {
 		return
 	}
 
-	book, err := c.BookService.GetBook(uint(id))
+	book, err := c.BookService.GetBook(ctx.Request.Context(), uint(id))
 	if err != nil {
 		logger.LogError(ctx, http.StatusNotFound, err, "Book not found")
 		return
@@ -145,21 +175,97 @@ func (c *BookController) GetBook(ctx *gin.Context) {
 }
 
 // ListBooks godoc
-// @Summary List all books
-// @Description Get a list of all available books
+// @Summary List books
+// @Description Get a paginated list of books, optionally filtered by author, price range and stock
 // @Tags Books
 // @Accept json
 // @Produce json
 // @Security BearerAuth
-// @Success 200 {array} model.Book
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Page size (max 100)" default(20)
+// @Param author query string false "Author (partial match)"
+// @Param min_price query number false "Minimum price"
+// @Param max_price query number false "Maximum price"
+// @Param in_stock query bool false "Only books in stock"
+// @Param sort query string false "Sort key" Enums(price, title, created_at)
+// @Param order query string false "Sort order" Enums(asc, desc)
+// @Success 200 {object} BookListResponse
+// @Failure 400 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /api/books [get]
 func (c *BookController) ListBooks(ctx *gin.Context) {
-	books, err := c.BookService.ListBooks()
+	var req ListBooksQuery
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		logger.LogError(ctx, http.StatusBadRequest, err, "Invalid query parameters")
+		return
+	}
+	if req.MinPrice != nil && req.MaxPrice != nil && *req.MinPrice > *req.MaxPrice {
+		logger.LogError(ctx, http.StatusBadRequest, nil, "min_price cannot be greater than max_price")
+		return
+	}
+
+	query := repository.BookQuery{
+		Author:   req.Author,
+		MinPrice: req.MinPrice,
+		MaxPrice: req.MaxPrice,
+		InStock:  req.InStock,
+		SortBy:   req.Sort,
+		Order:    req.Order,
+		Page:     req.Page,
+		Limit:    req.Limit,
+	}
+
+	books, total, err := c.BookService.ListBooks(ctx.Request.Context(), query)
 	if err != nil {
 		logger.LogError(ctx, http.StatusInternalServerError, err, "Failed to list books")
 		return
 	}
 
-	ctx.JSON(http.StatusOK, books)
+	ctx.JSON(http.StatusOK, BookListResponse{
+		Data:       books,
+		Pagination: newPagination(ctx, req.Page, req.Limit, total),
+	})
+}
+
+// SearchBooks godoc
+// @Summary Search books
+// @Description Full-text search over title, author and description, ranked by relevance with highlighted matches
+// @Tags Books
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param q query string true "Search text"
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Page size (max 100)" default(20)
+// @Success 200 {object} BookSearchResponse
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/books/search [get]
+func (c *BookController) SearchBooks(ctx *gin.Context) {
+	var req SearchBooksQuery
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		logger.LogError(ctx, http.StatusBadRequest, err, "Invalid query parameters")
+		return
+	}
+	if strings.TrimSpace(req.Q) == "" {
+		logger.LogError(ctx, http.StatusBadRequest, nil, "Search query is required")
+		return
+	}
+
+	query := repository.BookSearchQuery{
+		Text:  req.Q,
+		Page:  req.Page,
+		Limit: req.Limit,
+	}
+
+	results, total, err := c.BookService.SearchBooks(ctx.Request.Context(), query)
+	if err != nil {
+		logger.LogError(ctx, http.StatusInternalServerError, err, "Failed to search books")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, BookSearchResponse{
+		Data:       results,
+		Pagination: newPagination(ctx, req.Page, req.Limit, total),
+	})
 }
@@ -3,12 +3,14 @@ package controller_test
 import (
 	"bytes"
 	"errors"
+	"github.com/stretchr/testify/mock"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/beingaloksharma/book-backend/internal/controller"
 	"github.com/beingaloksharma/book-backend/internal/model"
+	"github.com/beingaloksharma/book-backend/internal/repository"
 	"github.com/beingaloksharma/book-backend/internal/service/mocks"
 	"github.com/beingaloksharma/book-backend/utils/logger"
 	"github.com/gin-gonic/gin"
@@ -26,7 +28,7 @@ func TestCreateBook(t *testing.T) {
 	r.POST("/books", bookController.CreateBook)
 
 	// Case 1: Success
-	mockService.On("CreateBook", "Go", "Google", "Desc", 10.0, 5).Return(nil).Once()
+	mockService.On("CreateBook", mock.Anything, "Go", "Google", "Desc", 10.0, 5).Return(nil).Once()
 
 	body := `{"title":"Go", "author":"Google", "description":"Desc", "price":10.0, "stock":5}`
 	req, _ := http.NewRequest("POST", "/books", bytes.NewBufferString(body))
@@ -42,7 +44,7 @@ func TestCreateBook(t *testing.T) {
 	assert.Equal(t, http.StatusBadRequest, w.Code)
 
 	// Case 3: Service Error
-	mockService.On("CreateBook", "Go", "Google", "Desc", 10.0, 5).Return(errors.New("failed")).Once()
+	mockService.On("CreateBook", mock.Anything, "Go", "Google", "Desc", 10.0, 5).Return(errors.New("failed")).Once()
 	req, _ = http.NewRequest("POST", "/books", bytes.NewBufferString(body))
 	w = httptest.NewRecorder()
 	r.ServeHTTP(w, req)
@@ -61,7 +63,7 @@ func TestGetBook(t *testing.T) {
 
 	// Case 1: Success
 	book := &model.Book{Title: "Go"}
-	mockService.On("GetBook", uint(1)).Return(book, nil).Once()
+	mockService.On("GetBook", mock.Anything, uint(1)).Return(book, nil).Once()
 
 	req, _ := http.NewRequest("GET", "/books/1", nil)
 	w := httptest.NewRecorder()
@@ -70,7 +72,7 @@ func TestGetBook(t *testing.T) {
 	assert.Equal(t, http.StatusOK, w.Code)
 
 	// Case 2: Not Found
-	mockService.On("GetBook", uint(2)).Return(nil, errors.New("not found")).Once()
+	mockService.On("GetBook", mock.Anything, uint(2)).Return(nil, errors.New("not found")).Once()
 	req, _ = http.NewRequest("GET", "/books/2", nil)
 	w = httptest.NewRecorder()
 	r.ServeHTTP(w, req)
@@ -85,13 +87,48 @@ func TestListBooks(t *testing.T) {
 	r := gin.Default()
 	r.GET("/books", bookController.ListBooks)
 
-	mockService.On("ListBooks").Return([]model.Book{{Title: "A"}}, nil)
+	// Case 1: Defaults
+	mockService.On("ListBooks", mock.Anything, repository.BookQuery{Page: 1, Limit: 20}).Return([]model.Book{{Title: "A"}}, int64(1), nil).Once()
 
 	req, _ := http.NewRequest("GET", "/books", nil)
 	w := httptest.NewRecorder()
 	r.ServeHTTP(w, req)
 
 	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), `"next"`)
+
+	// Case 2: Filters, sorting and next page link
+	minPrice := 5.0
+	query := repository.BookQuery{Author: "Pike", MinPrice: &minPrice, InStock: true, SortBy: "price", Order: "desc", Page: 1, Limit: 2}
+	mockService.On("ListBooks", mock.Anything, query).Return([]model.Book{{Title: "A"}, {Title: "B"}}, int64(5), nil).Once()
+
+	req, _ = http.NewRequest("GET", "/books?author=Pike&min_price=5&in_stock=true&sort=price&order=desc&limit=2", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"total":5`)
+	assert.Contains(t, w.Body.String(), `"total_pages":3`)
+	assert.Contains(t, w.Body.String(), "page=2")
+
+	// Case 3: Invalid sort key
+	req, _ = http.NewRequest("GET", "/books?sort=stock", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Case 4: Inverted price range
+	req, _ = http.NewRequest("GET", "/books?min_price=10&max_price=5", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Case 5: Service Error
+	mockService.On("ListBooks", mock.Anything, repository.BookQuery{Page: 1, Limit: 20}).Return(nil, int64(0), errors.New("failed")).Once()
+	req, _ = http.NewRequest("GET", "/books", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
 }
 
 func TestUpdateBook(t *testing.T) {
@@ -103,7 +140,7 @@ func TestUpdateBook(t *testing.T) {
 	r.PUT("/books/:id", bookController.UpdateBook)
 
 	// Update Success
-	mockService.On("UpdateBook", uint(1), "Go", "Google", "Desc", 10.0, 5).Return(nil)
+	mockService.On("UpdateBook", mock.Anything, uint(1), "Go", "Google", "Desc", 10.0, 5).Return(nil).Once()
 
 	body := `{"title":"Go", "author":"Google", "description":"Desc", "price":10.0, "stock":5}`
 	req, _ := http.NewRequest("PUT", "/books/1", bytes.NewBufferString(body))
@@ -119,7 +156,7 @@ func TestUpdateBook(t *testing.T) {
 	assert.Equal(t, http.StatusBadRequest, w2.Code)
 
 	// Update Fail (Service)
-	mockService.On("UpdateBook", uint(1), "Go", "Google", "Desc", 10.0, 5).Return(errors.New("failed"))
+	mockService.On("UpdateBook", mock.Anything, uint(1), "Go", "Google", "Desc", 10.0, 5).Return(errors.New("failed"))
 	req3, _ := http.NewRequest("PUT", "/books/1", bytes.NewBufferString(body))
 	w3 := httptest.NewRecorder()
 	r.ServeHTTP(w3, req3)
@@ -135,7 +172,7 @@ func TestDeleteBook(t *testing.T) {
 	r.DELETE("/books/:id", bookController.DeleteBook)
 
 	// Delete Success
-	mockService.On("DeleteBook", uint(1)).Return(nil)
+	mockService.On("DeleteBook", mock.Anything, uint(1)).Return(nil)
 
 	req, _ := http.NewRequest("DELETE", "/books/1", nil)
 	w := httptest.NewRecorder()
@@ -144,9 +181,42 @@ func TestDeleteBook(t *testing.T) {
 	assert.Equal(t, http.StatusOK, w.Code)
 
 	// Delete Fail
-	mockService.On("DeleteBook", uint(2)).Return(errors.New("failed"))
+	mockService.On("DeleteBook", mock.Anything, uint(2)).Return(errors.New("failed"))
 	req2, _ := http.NewRequest("DELETE", "/books/2", nil)
 	w2 := httptest.NewRecorder()
 	r.ServeHTTP(w2, req2)
 	assert.Equal(t, http.StatusInternalServerError, w2.Code)
 }
+
+func TestSearchBooks(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockService := new(mocks.MockBookService)
+	bookController := controller.NewBookController(mockService)
+
+	r := gin.Default()
+	r.GET("/books/search", bookController.SearchBooks)
+
+	// Case 1: Success
+	results := []repository.BookSearchResult{{Book: model.Book{Title: "Go"}, Rank: 1, TitleHighlight: "<mark>Go</mark>"}}
+	mockService.On("SearchBooks", mock.Anything, repository.BookSearchQuery{Text: "go", Page: 1, Limit: 20}).Return(results, int64(1), nil).Once()
+
+	req, _ := http.NewRequest("GET", "/books/search?q=go", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "title_highlight")
+
+	// Case 2: Missing query
+	req, _ = http.NewRequest("GET", "/books/search?q=%20", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Case 3: Service Error
+	mockService.On("SearchBooks", mock.Anything, repository.BookSearchQuery{Text: "go", Page: 1, Limit: 20}).Return(nil, int64(0), errors.New("failed")).Once()
+	req, _ = http.NewRequest("GET", "/books/search?q=go", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
@@ -1,8 +1,11 @@
 package controller
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 
+	"github.com/beingaloksharma/book-backend/internal/identity"
 	"github.com/beingaloksharma/book-backend/internal/service"
 	"github.com/beingaloksharma/book-backend/utils/logger"
 	"github.com/gin-gonic/gin"
@@ -18,12 +21,12 @@ func NewCartController(cartService service.CartServiceInterface) *CartController
 
 type AddToCartRequest struct {
 	BookID   uint `json:"book_id" binding:"required"`
-	Quantity int  `json:"quantity" binding:"required"`
+	Quantity int  `json:"quantity" binding:"required,min=1"`
 }
 
 // AddToCart godoc
 // @Summary Add item to cart
-// @Description Add a book to user's cart
+// @Description Add a book to user's cart. The resulting quantity may not exceed the book's stock.
 // @Tags Cart
 // @Accept json
 // @Produce json
@@ -31,32 +34,33 @@ type AddToCartRequest struct {
 // @Param request body AddToCartRequest true "Add To Cart Request"
 // @Success 200 {object} map[string]string
 // @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /api/cart [post]
 func (c *CartController) AddToCart(ctx *gin.Context) {
-	userID, _ := ctx.Get("user_id")
-	var req AddToCartRequest
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		logger.LogError(ctx, http.StatusBadRequest, err, "Invalid request body")
+	principal, ok := identity.Require(ctx)
+	if !ok {
 		return
 	}
 
-	// Cast float64 (from JWT claims usually) to uint
-	// JWT claims are often float64 in generic maps
-	var uid uint
-	switch v := userID.(type) {
-	case float64:
-		uid = uint(v)
-	case uint:
-		uid = v
-	default:
-		// Handle other cases or error
-		logger.LogError(ctx, http.StatusInternalServerError, nil, "Invalid user ID")
+	var req AddToCartRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		logger.LogError(ctx, http.StatusBadRequest, err, "Invalid request body")
 		return
 	}
 
-	if err := c.CartService.AddToCart(uid, req.BookID, req.Quantity); err != nil {
-		logger.LogError(ctx, http.StatusInternalServerError, err, "Failed to add item to cart")
+	if err := c.CartService.AddToCart(ctx.Request.Context(), principal.UserID, req.BookID, req.Quantity); err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidQuantity):
+			logger.LogError(ctx, http.StatusBadRequest, err, "Invalid quantity")
+		case errors.Is(err, service.ErrBookNotFound):
+			logger.LogError(ctx, http.StatusNotFound, err, "Book not found")
+		case errors.Is(err, service.ErrInsufficientStock):
+			logger.LogError(ctx, http.StatusConflict, err, "Not enough stock")
+		default:
+			logger.LogError(ctx, http.StatusInternalServerError, err, "Failed to add item to cart")
+		}
 		return
 	}
 
@@ -65,34 +69,142 @@ func (c *CartController) AddToCart(ctx *gin.Context) {
 
 // GetCart godoc
 // @Summary Get user cart
-// @Description Get current user's cart
+// @Description Get current user's cart priced at current prices, with price-change and stock flags per line
 // @Tags Cart
 // @Accept json
 // @Produce json
 // @Security BearerAuth
-// @Success 200 {object} model.Cart
-// @Failure 404 {object} map[string]string
+// @Success 200 {object} model.CartSummary
 // @Failure 500 {object} map[string]string
 // @Router /api/cart [get]
 func (c *CartController) GetCart(ctx *gin.Context) {
-	userID, _ := ctx.Get("user_id")
-
-	var uid uint
-	switch v := userID.(type) {
-	case float64:
-		uid = uint(v)
-	case uint:
-		uid = v
-	default:
-		logger.LogError(ctx, http.StatusInternalServerError, nil, "Invalid user ID")
+	principal, ok := identity.Require(ctx)
+	if !ok {
 		return
 	}
 
-	cart, err := c.CartService.GetCart(uid)
+	cart, err := c.CartService.GetCart(ctx.Request.Context(), principal.UserID)
 	if err != nil {
-		logger.LogError(ctx, http.StatusNotFound, err, "Cart not found")
+		logger.LogError(ctx, http.StatusInternalServerError, err, "Failed to fetch cart")
 		return
 	}
 
 	ctx.JSON(http.StatusOK, cart)
 }
+
+type UpdateCartItemRequest struct {
+	Quantity int `json:"quantity" binding:"required,min=1"`
+}
+
+// UpdateCartItem godoc
+// @Summary Set cart item quantity
+// @Description Set the quantity of a book already in the user's cart
+// @Tags Cart
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param bookId path int true "Book ID"
+// @Param request body UpdateCartItemRequest true "Update Cart Item Request"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/cart/items/{bookId} [put]
+func (c *CartController) UpdateCartItem(ctx *gin.Context) {
+	principal, ok := identity.Require(ctx)
+	if !ok {
+		return
+	}
+
+	bookID, err := strconv.ParseUint(ctx.Param("bookId"), 10, 32)
+	if err != nil {
+		logger.LogError(ctx, http.StatusBadRequest, err, "Invalid book ID")
+		return
+	}
+
+	var req UpdateCartItemRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		logger.LogError(ctx, http.StatusBadRequest, err, "Invalid request body")
+		return
+	}
+
+	if err := c.CartService.UpdateCartItem(ctx.Request.Context(), principal.UserID, uint(bookID), req.Quantity); err != nil {
+		switch {
+		case errors.Is(err, service.ErrCartItemNotFound):
+			logger.LogError(ctx, http.StatusNotFound, err, "Item not in cart")
+		case errors.Is(err, service.ErrInvalidQuantity):
+			logger.LogError(ctx, http.StatusBadRequest, err, "Invalid quantity")
+		case errors.Is(err, service.ErrBookNotFound):
+			logger.LogError(ctx, http.StatusNotFound, err, "Book not found")
+		case errors.Is(err, service.ErrInsufficientStock):
+			logger.LogError(ctx, http.StatusConflict, err, "Not enough stock")
+		default:
+			logger.LogError(ctx, http.StatusInternalServerError, err, "Failed to update cart item")
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Cart item updated"})
+}
+
+// RemoveCartItem godoc
+// @Summary Remove item from cart
+// @Description Remove a book from the user's cart
+// @Tags Cart
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param bookId path int true "Book ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/cart/items/{bookId} [delete]
+func (c *CartController) RemoveCartItem(ctx *gin.Context) {
+	principal, ok := identity.Require(ctx)
+	if !ok {
+		return
+	}
+
+	bookID, err := strconv.ParseUint(ctx.Param("bookId"), 10, 32)
+	if err != nil {
+		logger.LogError(ctx, http.StatusBadRequest, err, "Invalid book ID")
+		return
+	}
+
+	if err := c.CartService.RemoveCartItem(ctx.Request.Context(), principal.UserID, uint(bookID)); err != nil {
+		if errors.Is(err, service.ErrCartItemNotFound) {
+			logger.LogError(ctx, http.StatusNotFound, err, "Item not in cart")
+			return
+		}
+		logger.LogError(ctx, http.StatusInternalServerError, err, "Failed to remove cart item")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Item removed from cart"})
+}
+
+// ClearCart godoc
+// @Summary Clear cart
+// @Description Remove every item from the user's cart
+// @Tags Cart
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/cart [delete]
+func (c *CartController) ClearCart(ctx *gin.Context) {
+	principal, ok := identity.Require(ctx)
+	if !ok {
+		return
+	}
+
+	if err := c.CartService.ClearCart(ctx.Request.Context(), principal.UserID); err != nil {
+		logger.LogError(ctx, http.StatusInternalServerError, err, "Failed to clear cart")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Cart cleared"})
+}
@@ -3,12 +3,15 @@ package controller_test
 import (
 	"bytes"
 	"errors"
+	"github.com/stretchr/testify/mock"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/beingaloksharma/book-backend/internal/controller"
+	"github.com/beingaloksharma/book-backend/internal/identity"
 	"github.com/beingaloksharma/book-backend/internal/model"
+	"github.com/beingaloksharma/book-backend/internal/service"
 	"github.com/beingaloksharma/book-backend/internal/service/mocks"
 	"github.com/beingaloksharma/book-backend/utils/logger"
 	"github.com/gin-gonic/gin"
@@ -24,12 +27,12 @@ func TestAddToCart(t *testing.T) {
 
 	r := gin.Default()
 	r.Use(func(c *gin.Context) {
-		c.Set("user_id", uint(1))
+		identity.Set(c, identity.Principal{UserID: uint(1)})
 	})
 	r.POST("/cart", cartController.AddToCart)
 
 	// Case 1: Success
-	mockService.On("AddToCart", uint(1), uint(10), 2).Return(nil)
+	mockService.On("AddToCart", mock.Anything, uint(1), uint(10), 2).Return(nil).Once()
 
 	body := `{"book_id": 10, "quantity": 2}`
 	req, _ := http.NewRequest("POST", "/cart", bytes.NewBufferString(body))
@@ -45,11 +48,24 @@ func TestAddToCart(t *testing.T) {
 	assert.Equal(t, http.StatusBadRequest, w2.Code)
 
 	// Case 3: Service Error
-	mockService.On("AddToCart", uint(1), uint(10), 2).Return(errors.New("failed"))
+	mockService.On("AddToCart", mock.Anything, uint(1), uint(10), 2).Return(errors.New("failed"))
 	req3, _ := http.NewRequest("POST", "/cart", bytes.NewBufferString(body))
 	w3 := httptest.NewRecorder()
 	r.ServeHTTP(w3, req3)
 	assert.Equal(t, http.StatusInternalServerError, w3.Code)
+
+	// Case 4: Non-positive quantity
+	req4, _ := http.NewRequest("POST", "/cart", bytes.NewBufferString(`{"book_id": 10, "quantity": -1}`))
+	w4 := httptest.NewRecorder()
+	r.ServeHTTP(w4, req4)
+	assert.Equal(t, http.StatusBadRequest, w4.Code)
+
+	// Case 5: More than the available stock
+	mockService.On("AddToCart", mock.Anything, uint(1), uint(11), 5).Return(service.ErrInsufficientStock).Once()
+	req5, _ := http.NewRequest("POST", "/cart", bytes.NewBufferString(`{"book_id": 11, "quantity": 5}`))
+	w5 := httptest.NewRecorder()
+	r.ServeHTTP(w5, req5)
+	assert.Equal(t, http.StatusConflict, w5.Code)
 }
 
 func TestGetCart(t *testing.T) {
@@ -59,28 +75,142 @@ func TestGetCart(t *testing.T) {
 
 	r := gin.Default()
 	r.Use(func(c *gin.Context) {
-		c.Set("user_id", uint(1))
+		identity.Set(c, identity.Principal{UserID: uint(1)})
 	})
 	r.GET("/cart", cartController.GetCart)
 
-	mockService.On("GetCart", uint(1)).Return(&model.Cart{}, nil)
+	mockService.On("GetCart", mock.Anything, uint(1)).Return(&model.CartSummary{
+		Items:    []model.CartLine{{BookID: 10, Quantity: 2, UnitPrice: 12.5, LineTotal: 25, PriceChanged: true}},
+		Subtotal: 25,
+	}, nil)
 
 	req, _ := http.NewRequest("GET", "/cart", nil)
 	w := httptest.NewRecorder()
 	r.ServeHTTP(w, req)
 
 	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"subtotal":25`)
+	assert.Contains(t, w.Body.String(), `"price_changed":true`)
 
-	// Case 2: Cart not found
-	mockService.On("GetCart", uint(2)).Return(nil, errors.New("not found"))
+	// Case 2: Service Error
+	mockService.On("GetCart", mock.Anything, uint(2)).Return(nil, errors.New("db error"))
 	r2 := gin.Default()
 	r2.Use(func(c *gin.Context) {
-		c.Set("user_id", uint(2))
+		identity.Set(c, identity.Principal{UserID: uint(2)})
 	})
 	r2.GET("/cart", cartController.GetCart)
 
 	req2, _ := http.NewRequest("GET", "/cart", nil)
 	w2 := httptest.NewRecorder()
 	r2.ServeHTTP(w2, req2)
+	assert.Equal(t, http.StatusInternalServerError, w2.Code)
+}
+
+func TestUpdateCartItem(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger.Init()
+
+	mockService := new(mocks.MockCartService)
+	cartController := controller.NewCartController(mockService)
+
+	r := gin.Default()
+	r.Use(func(c *gin.Context) {
+		identity.Set(c, identity.Principal{UserID: uint(1)})
+	})
+	r.PUT("/cart/items/:bookId", cartController.UpdateCartItem)
+
+	// Case 1: Success
+	mockService.On("UpdateCartItem", mock.Anything, uint(1), uint(10), 3).Return(nil).Once()
+	req, _ := http.NewRequest("PUT", "/cart/items/10", bytes.NewBufferString(`{"quantity": 3}`))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Case 2: Zero quantity
+	req2, _ := http.NewRequest("PUT", "/cart/items/10", bytes.NewBufferString(`{"quantity": 0}`))
+	w2 := httptest.NewRecorder()
+	r.ServeHTTP(w2, req2)
+	assert.Equal(t, http.StatusBadRequest, w2.Code)
+
+	// Case 3: Invalid book ID
+	req3, _ := http.NewRequest("PUT", "/cart/items/abc", bytes.NewBufferString(`{"quantity": 3}`))
+	w3 := httptest.NewRecorder()
+	r.ServeHTTP(w3, req3)
+	assert.Equal(t, http.StatusBadRequest, w3.Code)
+
+	// Case 4: Book not in cart
+	mockService.On("UpdateCartItem", mock.Anything, uint(1), uint(11), 3).Return(service.ErrCartItemNotFound).Once()
+	req4, _ := http.NewRequest("PUT", "/cart/items/11", bytes.NewBufferString(`{"quantity": 3}`))
+	w4 := httptest.NewRecorder()
+	r.ServeHTTP(w4, req4)
+	assert.Equal(t, http.StatusNotFound, w4.Code)
+
+	// Case 5: More than the available stock
+	mockService.On("UpdateCartItem", mock.Anything, uint(1), uint(10), 50).Return(service.ErrInsufficientStock).Once()
+	req5, _ := http.NewRequest("PUT", "/cart/items/10", bytes.NewBufferString(`{"quantity": 50}`))
+	w5 := httptest.NewRecorder()
+	r.ServeHTTP(w5, req5)
+	assert.Equal(t, http.StatusConflict, w5.Code)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestRemoveCartItem(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger.Init()
+
+	mockService := new(mocks.MockCartService)
+	cartController := controller.NewCartController(mockService)
+
+	r := gin.Default()
+	r.Use(func(c *gin.Context) {
+		identity.Set(c, identity.Principal{UserID: uint(1)})
+	})
+	r.DELETE("/cart/items/:bookId", cartController.RemoveCartItem)
+
+	// Case 1: Success
+	mockService.On("RemoveCartItem", mock.Anything, uint(1), uint(10)).Return(nil).Once()
+	req, _ := http.NewRequest("DELETE", "/cart/items/10", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Case 2: Book not in cart
+	mockService.On("RemoveCartItem", mock.Anything, uint(1), uint(11)).Return(service.ErrCartItemNotFound).Once()
+	req2, _ := http.NewRequest("DELETE", "/cart/items/11", nil)
+	w2 := httptest.NewRecorder()
+	r.ServeHTTP(w2, req2)
 	assert.Equal(t, http.StatusNotFound, w2.Code)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestClearCart(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger.Init()
+
+	mockService := new(mocks.MockCartService)
+	cartController := controller.NewCartController(mockService)
+
+	r := gin.Default()
+	r.Use(func(c *gin.Context) {
+		identity.Set(c, identity.Principal{UserID: uint(1)})
+	})
+	r.DELETE("/cart", cartController.ClearCart)
+
+	// Case 1: Success
+	mockService.On("ClearCart", mock.Anything, uint(1)).Return(nil).Once()
+	req, _ := http.NewRequest("DELETE", "/cart", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Case 2: Service Error
+	mockService.On("ClearCart", mock.Anything, uint(1)).Return(errors.New("db error")).Once()
+	req2, _ := http.NewRequest("DELETE", "/cart", nil)
+	w2 := httptest.NewRecorder()
+	r.ServeHTTP(w2, req2)
+	assert.Equal(t, http.StatusInternalServerError, w2.Code)
+
+	mockService.AssertExpectations(t)
 }
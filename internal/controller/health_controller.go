@@ -0,0 +1,138 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/beingaloksharma/book-backend/utils/database"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// DefaultHealthCacheTTL is how long a health check is reused, so that polling
+// /health cannot flood the databases with pings.
+const DefaultHealthCacheTTL = 5 * time.Second
+
+type HealthController struct {
+	DB       *gorm.DB
+	CacheTTL time.Duration
+
+	mu         sync.Mutex
+	checkedAt  time.Time
+	databases  []database.PoolStatus
+	refreshing chan struct{} // closed when the running check finishes
+}
+
+func NewHealthController(db *gorm.DB) *HealthController {
+	return &HealthController{DB: db, CacheTTL: DefaultHealthCacheTTL}
+}
+
+// HealthStatus is ok when every database answers, degraded when only a read
+// replica does not, and down when the primary does not.
+type HealthStatus struct {
+	Status string `json:"status" example:"ok"`
+}
+
+// HealthResponse is the health status with the state of each database.
+type HealthResponse struct {
+	Status    string                `json:"status" example:"ok"`
+	Databases []database.PoolStatus `json:"databases"`
+}
+
+// Health godoc
+// @Summary Health check
+// @Description Reports whether the databases answer, for load balancers. Answers 503 when the primary is down. The result is reused for a few seconds.
+// @Tags Health
+// @Produce json
+// @Success 200 {object} HealthStatus
+// @Failure 503 {object} HealthStatus
+// @Failure 429 {object} map[string]string
+// @Router /health [get]
+func (c *HealthController) Health(ctx *gin.Context) {
+	response := c.check(ctx)
+	ctx.Header("Cache-Control", "no-store")
+	ctx.JSON(healthCode(response.Status), HealthStatus{Status: response.Status})
+}
+
+// HealthDetails godoc
+// @Summary Database health details
+// @Description Reports whether the primary database and every read replica answer, with their connection pool statistics. Answers 503 when the primary is down. The result is reused for a few seconds (requires admin:access)
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} HealthResponse
+// @Failure 503 {object} HealthResponse
+// @Router /api/admin/health [get]
+func (c *HealthController) HealthDetails(ctx *gin.Context) {
+	response := c.check(ctx)
+	ctx.Header("Cache-Control", "no-store")
+	ctx.JSON(healthCode(response.Status), response)
+}
+
+// check reports the health of the databases from the last check while it is
+// fresh, and otherwise from a new one.
+func (c *HealthController) check(ctx *gin.Context) HealthResponse {
+	databases := c.poolStatuses(ctx.Request.Context())
+
+	response := HealthResponse{Status: "ok", Databases: databases}
+	if len(databases) == 0 {
+		response.Status = "down"
+	}
+	for i, pool := range databases {
+		switch {
+		case pool.Up:
+		case i == 0:
+			response.Status = "down"
+		case response.Status == "ok":
+			response.Status = "degraded"
+		}
+	}
+	return response
+}
+
+// poolStatuses returns the cached check, or starts a new one when it is stale.
+// Only one check runs at a time and concurrent callers wait for it. The check
+// does not depend on the caller staying connected, so one that gives up cannot
+// make the cached result fail.
+func (c *HealthController) poolStatuses(ctx context.Context) []database.PoolStatus {
+	c.mu.Lock()
+	if c.databases != nil && time.Since(c.checkedAt) < c.CacheTTL {
+		defer c.mu.Unlock()
+		return c.databases
+	}
+	done := c.refreshing
+	if done == nil {
+		done = make(chan struct{})
+		c.refreshing = done
+		go c.refresh(context.WithoutCancel(ctx), done)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.databases
+}
+
+func (c *HealthController) refresh(ctx context.Context, done chan struct{}) {
+	databases := database.Check(ctx, c.DB)
+
+	c.mu.Lock()
+	c.databases = databases
+	c.checkedAt = time.Now()
+	c.refreshing = nil
+	c.mu.Unlock()
+	close(done)
+}
+
+func healthCode(status string) int {
+	if status == "down" {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusOK
+}
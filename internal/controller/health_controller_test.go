@@ -0,0 +1,76 @@
+package controller_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/beingaloksharma/book-backend/internal/controller"
+	"github.com/beingaloksharma/book-backend/utils/database"
+	"github.com/beingaloksharma/book-backend/utils/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger.Init()
+	viper.Set("database.driver", database.SQLite)
+	defer viper.Set("database.driver", nil)
+
+	db, err := database.Open()
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+
+	health := controller.NewHealthController(db)
+	r := gin.Default()
+	r.GET("/health", health.Health)
+	r.GET("/admin/health", health.HealthDetails)
+	get := func(path string, v any) int {
+		req, _ := http.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), v))
+		return w.Code
+	}
+
+	// Case 1: Up, with the details kept off the public route
+	var public map[string]any
+	assert.Equal(t, http.StatusOK, get("/health", &public))
+	assert.Equal(t, map[string]any{"status": "ok"}, public)
+
+	var details controller.HealthResponse
+	assert.Equal(t, http.StatusOK, get("/admin/health", &details))
+	assert.Equal(t, "ok", details.Status)
+	require.Len(t, details.Databases, 1)
+	assert.True(t, details.Databases[0].Up)
+
+	// Case 2: A client that goes away does not fail the check for everyone
+	health.CacheTTL = 0
+	gone, cancel := context.WithCancel(context.Background())
+	cancel()
+	req, _ := http.NewRequestWithContext(gone, "GET", "/health", nil)
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	health.CacheTTL = time.Hour
+	assert.Equal(t, http.StatusOK, get("/health", &public))
+	assert.Equal(t, map[string]any{"status": "ok"}, public)
+
+	// Case 3: A recent check is reused instead of pinging again
+	require.NoError(t, sqlDB.Close())
+	assert.Equal(t, http.StatusOK, get("/health", &public))
+
+	// Case 4: Once it is stale, the primary is found down without saying why
+	health.CacheTTL = 0
+	assert.Equal(t, http.StatusServiceUnavailable, get("/health", &public))
+	assert.Equal(t, map[string]any{"status": "down"}, public)
+
+	assert.Equal(t, http.StatusServiceUnavailable, get("/admin/health", &details))
+	assert.Equal(t, "down", details.Status)
+	assert.Equal(t, "unavailable", details.Databases[0].Error)
+}
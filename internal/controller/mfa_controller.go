@@ -0,0 +1,172 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/beingaloksharma/book-backend/internal/identity"
+	"github.com/beingaloksharma/book-backend/internal/service"
+	"github.com/beingaloksharma/book-backend/utils/logger"
+	"github.com/gin-gonic/gin"
+)
+
+type MFAController struct {
+	MFAService service.MFAServiceInterface
+}
+
+func NewMFAController(mfaService service.MFAServiceInterface) *MFAController {
+	return &MFAController{MFAService: mfaService}
+}
+
+type MFACodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// EnrollMFA godoc
+// @Summary Start MFA setup
+// @Description Get a new TOTP secret and otpauth URI for an authenticator app. MFA is turned on once a code is confirmed at /api/mfa/confirm.
+// @Tags MFA
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} service.MFAEnrollment
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/mfa/enroll [post]
+func (c *MFAController) EnrollMFA(ctx *gin.Context) {
+	principal, ok := identity.Require(ctx)
+	if !ok {
+		return
+	}
+
+	enrollment, err := c.MFAService.BeginEnrollment(ctx.Request.Context(), principal.UserID)
+	if err != nil {
+		c.handleError(ctx, err, "Failed to set up MFA")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, enrollment)
+}
+
+// ConfirmMFA godoc
+// @Summary Confirm MFA setup
+// @Description Turn MFA on with a code from the authenticator app. Returns the recovery codes, shown only this once.
+// @Tags MFA
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body MFACodeRequest true "MFA Code"
+// @Success 200 {object} RecoveryCodesResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/mfa/confirm [post]
+func (c *MFAController) ConfirmMFA(ctx *gin.Context) {
+	principal, ok := identity.Require(ctx)
+	if !ok {
+		return
+	}
+
+	var req MFACodeRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		logger.LogError(ctx, http.StatusBadRequest, err, "Invalid request body")
+		return
+	}
+
+	codes, err := c.MFAService.ConfirmEnrollment(ctx.Request.Context(), principal.UserID, req.Code)
+	if err != nil {
+		c.handleError(ctx, err, "Failed to confirm MFA")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// DisableMFA godoc
+// @Summary Turn MFA off
+// @Description Turn MFA off with a current TOTP or recovery code. Not allowed for roles that require MFA.
+// @Tags MFA
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body MFACodeRequest true "MFA Code"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/mfa/disable [post]
+func (c *MFAController) DisableMFA(ctx *gin.Context) {
+	principal, ok := identity.Require(ctx)
+	if !ok {
+		return
+	}
+
+	var req MFACodeRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		logger.LogError(ctx, http.StatusBadRequest, err, "Invalid request body")
+		return
+	}
+
+	if err := c.MFAService.Disable(ctx.Request.Context(), principal.UserID, req.Code); err != nil {
+		c.handleError(ctx, err, "Failed to disable MFA")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "MFA disabled"})
+}
+
+// RegenerateRecoveryCodes godoc
+// @Summary Replace recovery codes
+// @Description Replace all recovery codes, used or not, after checking a current TOTP or recovery code. The new codes are shown only this once.
+// @Tags MFA
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body MFACodeRequest true "MFA Code"
+// @Success 200 {object} RecoveryCodesResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/mfa/recovery-codes [post]
+func (c *MFAController) RegenerateRecoveryCodes(ctx *gin.Context) {
+	principal, ok := identity.Require(ctx)
+	if !ok {
+		return
+	}
+
+	var req MFACodeRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		logger.LogError(ctx, http.StatusBadRequest, err, "Invalid request body")
+		return
+	}
+
+	codes, err := c.MFAService.RegenerateRecoveryCodes(ctx.Request.Context(), principal.UserID, req.Code)
+	if err != nil {
+		c.handleError(ctx, err, "Failed to replace recovery codes")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+func (c *MFAController) handleError(ctx *gin.Context, err error, msg string) {
+	switch {
+	case errors.Is(err, service.ErrUserNotFound):
+		logger.LogError(ctx, http.StatusNotFound, err, "User not found")
+	case errors.Is(err, service.ErrInvalidMFACode):
+		logger.LogError(ctx, http.StatusUnauthorized, err, "Invalid MFA code")
+	case errors.Is(err, service.ErrMFANotEnrolled):
+		logger.LogError(ctx, http.StatusBadRequest, err, err.Error())
+	case errors.Is(err, service.ErrMFAAlreadyEnabled):
+		logger.LogError(ctx, http.StatusConflict, err, err.Error())
+	case errors.Is(err, service.ErrMFARequired):
+		logger.LogError(ctx, http.StatusForbidden, err, err.Error())
+	default:
+		logger.LogError(ctx, http.StatusInternalServerError, err, msg)
+	}
+}
@@ -0,0 +1,107 @@
+package controller_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/beingaloksharma/book-backend/internal/controller"
+	"github.com/beingaloksharma/book-backend/internal/identity"
+	"github.com/beingaloksharma/book-backend/internal/service"
+	"github.com/beingaloksharma/book-backend/internal/service/mocks"
+	"github.com/beingaloksharma/book-backend/utils/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestMFASetup(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger.Init()
+	mockService := new(mocks.MockMFAService)
+	mfaController := controller.NewMFAController(mockService)
+
+	r := gin.Default()
+	r.Use(func(c *gin.Context) {
+		identity.Set(c, identity.Principal{UserID: uint(1)})
+	})
+	r.POST("/mfa/enroll", mfaController.EnrollMFA)
+	r.POST("/mfa/confirm", mfaController.ConfirmMFA)
+
+	send := func(path, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", path, bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// Case 1: Enroll
+	mockService.On("BeginEnrollment", mock.Anything, uint(1)).Return(&service.MFAEnrollment{Secret: "SECRET", URI: "otpauth://totp/x"}, nil).Once()
+
+	w := send("/mfa/enroll", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"secret":"SECRET"`)
+
+	// Case 2: Already enabled
+	mockService.On("BeginEnrollment", mock.Anything, uint(1)).Return(nil, service.ErrMFAAlreadyEnabled).Once()
+	assert.Equal(t, http.StatusConflict, send("/mfa/enroll", "").Code)
+
+	// Case 3: Confirm returns the recovery codes
+	mockService.On("ConfirmEnrollment", mock.Anything, uint(1), "123456").Return([]string{"aaaa-bbbb-cccc-dddd"}, nil).Once()
+
+	w = send("/mfa/confirm", `{"code":"123456"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"recovery_codes":["aaaa-bbbb-cccc-dddd"]`)
+
+	// Case 4: Confirm with a wrong code
+	mockService.On("ConfirmEnrollment", mock.Anything, uint(1), "000000").Return(nil, service.ErrInvalidMFACode).Once()
+	assert.Equal(t, http.StatusUnauthorized, send("/mfa/confirm", `{"code":"000000"}`).Code)
+
+	// Case 5: Confirm before enrolling
+	mockService.On("ConfirmEnrollment", mock.Anything, uint(1), "123456").Return(nil, service.ErrMFANotEnrolled).Once()
+	assert.Equal(t, http.StatusBadRequest, send("/mfa/confirm", `{"code":"123456"}`).Code)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestMFAManage(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger.Init()
+	mockService := new(mocks.MockMFAService)
+	mfaController := controller.NewMFAController(mockService)
+
+	r := gin.Default()
+	r.Use(func(c *gin.Context) {
+		identity.Set(c, identity.Principal{UserID: uint(1)})
+	})
+	r.POST("/mfa/disable", mfaController.DisableMFA)
+	r.POST("/mfa/recovery-codes", mfaController.RegenerateRecoveryCodes)
+
+	send := func(path, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", path, bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// Case 1: Disable
+	mockService.On("Disable", mock.Anything, uint(1), "123456").Return(nil).Once()
+	assert.Equal(t, http.StatusOK, send("/mfa/disable", `{"code":"123456"}`).Code)
+
+	// Case 2: Disable refused for roles that require MFA
+	mockService.On("Disable", mock.Anything, uint(1), "654321").Return(service.ErrMFARequired).Once()
+	assert.Equal(t, http.StatusForbidden, send("/mfa/disable", `{"code":"654321"}`).Code)
+
+	// Case 3: Missing code
+	assert.Equal(t, http.StatusBadRequest, send("/mfa/disable", `{}`).Code)
+
+	// Case 4: New recovery codes
+	mockService.On("RegenerateRecoveryCodes", mock.Anything, uint(1), "123456").Return([]string{"eeee-ffff-gggg-hhhh"}, nil).Once()
+
+	w := send("/mfa/recovery-codes", `{"code":"123456"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "eeee-ffff-gggg-hhhh")
+
+	mockService.AssertExpectations(t)
+}
@@ -1,8 +1,12 @@
 package controller
 
 import (
+	"errors"
+	"io"
 	"net/http"
+	"strconv"
 
+	"github.com/beingaloksharma/book-backend/internal/identity"
 	"github.com/beingaloksharma/book-backend/internal/service"
 	"github.com/beingaloksharma/book-backend/utils/logger"
 	"github.com/gin-gonic/gin"
@@ -16,43 +20,56 @@ func NewOrderController(orderService service.OrderServiceInterface) *OrderContro
 	return &OrderController{OrderService: orderService}
 }
 
+// PlaceOrderRequest selects the shipping address; omit address_id to use the
+// user's default shipping address.
 type PlaceOrderRequest struct {
-	AddressID uint `json:"address_id" binding:"required"`
+	AddressID uint `json:"address_id"`
 }
 
 // PlaceOrder godoc
 // @Summary Place an order
-// @Description Place an order from the user's cart
+// @Description Place an order from the user's cart. Without address_id the default shipping address is used.
 // @Tags Order
 // @Accept json
 // @Produce json
 // @Security BearerAuth
-// @Param request body PlaceOrderRequest true "Place Order Request"
+// @Param Idempotency-Key header string false "Unique key that makes retries safe"
+// @Param request body PlaceOrderRequest false "Place Order Request"
 // @Success 200 {object} map[string]string
 // @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 422 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /api/orders [post]
 func (c *OrderController) PlaceOrder(ctx *gin.Context) {
-	userID, _ := ctx.Get("user_id")
-	var req PlaceOrderRequest
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		logger.LogError(ctx, http.StatusBadRequest, err, "Invalid request body")
+	principal, ok := identity.Require(ctx)
+	if !ok {
 		return
 	}
 
-	var uid uint
-	switch v := userID.(type) {
-	case float64:
-		uid = uint(v)
-	case uint:
-		uid = v
-	default:
-		logger.LogError(ctx, http.StatusInternalServerError, nil, "Invalid user ID")
-		return
+	// The body is optional
+	var req PlaceOrderRequest
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+			logger.LogError(ctx, http.StatusBadRequest, err, "Invalid request body")
+			return
+		}
 	}
 
-	if err := c.OrderService.PlaceOrder(uid, req.AddressID); err != nil {
-		logger.LogError(ctx, http.StatusBadRequest, err, "Failed to place order")
+	// Only a rejected order is a 400; the idempotency middleware stores it for
+	// the key, while a 500 frees the key for a retry
+	if err := c.OrderService.PlaceOrder(ctx.Request.Context(), principal.UserID, req.AddressID); err != nil {
+		switch {
+		case errors.Is(err, service.ErrAddressNotFound):
+			logger.LogError(ctx, http.StatusBadRequest, err, "Address not found")
+		case errors.Is(err, service.ErrEmailNotVerified):
+			logger.LogError(ctx, http.StatusForbidden, err, "Verify your email address before placing orders")
+		case errors.Is(err, service.ErrEmptyCart), errors.Is(err, service.ErrInsufficientStock), errors.Is(err, service.ErrBookNotFound):
+			logger.LogError(ctx, http.StatusBadRequest, err, err.Error())
+		default:
+			logger.LogError(ctx, http.StatusInternalServerError, err, "Failed to place order")
+		}
 		return
 	}
 
@@ -70,20 +87,12 @@ func (c *OrderController) PlaceOrder(ctx *gin.Context) {
 // @Failure 500 {object} map[string]string
 // @Router /api/orders [get]
 func (c *OrderController) GetOrders(ctx *gin.Context) {
-	userID, _ := ctx.Get("user_id")
-
-	var uid uint
-	switch v := userID.(type) {
-	case float64:
-		uid = uint(v)
-	case uint:
-		uid = v
-	default:
-		logger.LogError(ctx, http.StatusInternalServerError, nil, "Invalid user ID")
+	principal, ok := identity.Require(ctx)
+	if !ok {
 		return
 	}
 
-	orders, err := c.OrderService.GetOrders(uid)
+	orders, err := c.OrderService.GetOrders(ctx.Request.Context(), principal.UserID)
 	if err != nil {
 		logger.LogError(ctx, http.StatusInternalServerError, err, "Failed to fetch orders")
 		return
@@ -91,3 +100,60 @@ func (c *OrderController) GetOrders(ctx *gin.Context) {
 
 	ctx.JSON(http.StatusOK, orders)
 }
+
+type CancelOrderRequest struct {
+	Reason string `json:"reason"`
+}
+
+// CancelOrder godoc
+// @Summary Cancel an order
+// @Description Cancel an order that has not shipped yet and return its items to stock. Users may cancel their own orders; roles with orders:manage may cancel any order.
+// @Tags Order
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Order ID"
+// @Param Idempotency-Key header string false "Unique key that makes retries safe"
+// @Param request body CancelOrderRequest false "Cancel Order Request"
+// @Success 200 {object} model.Order
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/orders/{id}/cancel [post]
+func (c *OrderController) CancelOrder(ctx *gin.Context) {
+	principal, ok := identity.Require(ctx)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		logger.LogError(ctx, http.StatusBadRequest, err, "Invalid order ID")
+		return
+	}
+
+	// The body is optional
+	var req CancelOrderRequest
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+			logger.LogError(ctx, http.StatusBadRequest, err, "Invalid request body")
+			return
+		}
+	}
+
+	order, err := c.OrderService.CancelOrder(ctx.Request.Context(), uint(id), principal, req.Reason)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrOrderNotFound):
+			logger.LogError(ctx, http.StatusNotFound, err, "Order not found")
+		case errors.Is(err, service.ErrInvalidStatusTransition):
+			logger.LogError(ctx, http.StatusConflict, err, err.Error())
+		default:
+			logger.LogError(ctx, http.StatusInternalServerError, err, "Failed to cancel order")
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, order)
+}
@@ -3,16 +3,23 @@ package controller_test
 import (
 	"bytes"
 	"errors"
+	"github.com/stretchr/testify/mock"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/beingaloksharma/book-backend/internal/controller"
+	"github.com/beingaloksharma/book-backend/internal/identity"
+	"github.com/beingaloksharma/book-backend/internal/middleware"
 	"github.com/beingaloksharma/book-backend/internal/model"
+	repomocks "github.com/beingaloksharma/book-backend/internal/repository/mocks"
+	"github.com/beingaloksharma/book-backend/internal/service"
 	"github.com/beingaloksharma/book-backend/internal/service/mocks"
 	"github.com/beingaloksharma/book-backend/utils/logger"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
 )
 
 func TestPlaceOrder(t *testing.T) {
@@ -24,12 +31,12 @@ func TestPlaceOrder(t *testing.T) {
 
 	r := gin.Default()
 	r.Use(func(c *gin.Context) {
-		c.Set("user_id", uint(1))
+		identity.Set(c, identity.Principal{UserID: uint(1)})
 	})
 	r.POST("/orders", orderController.PlaceOrder)
 
 	// Case 1: Success
-	mockService.On("PlaceOrder", uint(1), uint(10)).Return(nil)
+	mockService.On("PlaceOrder", mock.Anything, uint(1), uint(10)).Return(nil).Once()
 
 	body := `{"address_id": 10}`
 	req, _ := http.NewRequest("POST", "/orders", bytes.NewBufferString(body))
@@ -39,17 +46,95 @@ func TestPlaceOrder(t *testing.T) {
 	assert.Equal(t, http.StatusOK, w.Code)
 
 	// Case 2: Validation Error
-	req2, _ := http.NewRequest("POST", "/orders", bytes.NewBufferString(`{}`))
+	req2, _ := http.NewRequest("POST", "/orders", bytes.NewBufferString(`{"address_id": "ten"}`))
 	w2 := httptest.NewRecorder()
 	r.ServeHTTP(w2, req2)
 	assert.Equal(t, http.StatusBadRequest, w2.Code)
 
 	// Case 3: Service Error
-	mockService.On("PlaceOrder", uint(1), uint(10)).Return(errors.New("failed"))
+	mockService.On("PlaceOrder", mock.Anything, uint(1), uint(10)).Return(errors.New("failed")).Once()
 	req3, _ := http.NewRequest("POST", "/orders", bytes.NewBufferString(body))
 	w3 := httptest.NewRecorder()
 	r.ServeHTTP(w3, req3)
 	assert.Equal(t, http.StatusInternalServerError, w3.Code)
+
+	// Case 4: Address not owned by the user
+	mockService.On("PlaceOrder", mock.Anything, uint(1), uint(99)).Return(service.ErrAddressNotFound).Once()
+	req4, _ := http.NewRequest("POST", "/orders", bytes.NewBufferString(`{"address_id": 99}`))
+	w4 := httptest.NewRecorder()
+	r.ServeHTTP(w4, req4)
+	assert.Equal(t, http.StatusBadRequest, w4.Code)
+	assert.Contains(t, w4.Body.String(), "Address not found")
+
+	// Case 5: No address_id falls back to the default address
+	mockService.On("PlaceOrder", mock.Anything, uint(1), uint(0)).Return(nil).Twice()
+	req5, _ := http.NewRequest("POST", "/orders", bytes.NewBufferString(`{}`))
+	w5 := httptest.NewRecorder()
+	r.ServeHTTP(w5, req5)
+	assert.Equal(t, http.StatusOK, w5.Code)
+
+	req6, _ := http.NewRequest("POST", "/orders", nil)
+	w6 := httptest.NewRecorder()
+	r.ServeHTTP(w6, req6)
+	assert.Equal(t, http.StatusOK, w6.Code)
+
+	// Case 6: Email not verified yet
+	mockService.On("PlaceOrder", mock.Anything, uint(1), uint(1)).Return(service.ErrEmailNotVerified).Once()
+	req7, _ := http.NewRequest("POST", "/orders", bytes.NewBufferString(`{"address_id": 1}`))
+	w7 := httptest.NewRecorder()
+	r.ServeHTTP(w7, req7)
+	assert.Equal(t, http.StatusForbidden, w7.Code)
+
+	// Case 7: Rejected orders
+	for _, err := range []error{service.ErrEmptyCart, service.ErrInsufficientStock, service.ErrBookNotFound} {
+		mockService.On("PlaceOrder", mock.Anything, uint(1), uint(10)).Return(err).Once()
+		req, _ := http.NewRequest("POST", "/orders", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, err.Error())
+	}
+	mockService.AssertExpectations(t)
+}
+
+func TestPlaceOrder_IdempotentRetry(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger.Init()
+
+	mockService := new(mocks.MockOrderService)
+	mockKeys := new(repomocks.MockIdempotencyRepository)
+	orderController := controller.NewOrderController(mockService)
+
+	r := gin.Default()
+	r.Use(func(c *gin.Context) {
+		identity.Set(c, identity.Principal{UserID: uint(1)})
+	})
+	r.POST("/orders", middleware.IdempotencyMiddleware(mockKeys, time.Hour), orderController.PlaceOrder)
+	send := func() *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/orders", bytes.NewBufferString(`{"address_id": 10}`))
+		req.Header.Set(middleware.IdempotencyKeyHeader, "order-1")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	mockKeys.On("FindByKey", mock.Anything, uint(1), "order-1").Return(nil, gorm.ErrRecordNotFound).Twice()
+	mockKeys.On("Create", mock.Anything, mock.AnythingOfType("*model.IdempotencyKey")).Run(func(args mock.Arguments) {
+		args.Get(1).(*model.IdempotencyKey).ID = 7
+	}).Return(true, nil).Twice()
+
+	// Case 1: A database failure is not stored for the key
+	mockService.On("PlaceOrder", mock.Anything, uint(1), uint(10)).Return(errors.New("connection reset")).Once()
+	mockKeys.On("Delete", mock.Anything, uint(7)).Return(nil).Once()
+	assert.Equal(t, http.StatusInternalServerError, send().Code)
+
+	// Case 2: So retrying with the same key places the order
+	mockService.On("PlaceOrder", mock.Anything, uint(1), uint(10)).Return(nil).Once()
+	mockKeys.On("SaveResponse", mock.Anything, mock.MatchedBy(func(record *model.IdempotencyKey) bool {
+		return record.ResponseCode == http.StatusOK
+	})).Return(nil).Once()
+	assert.Equal(t, http.StatusOK, send().Code)
+
+	mockService.AssertExpectations(t)
+	mockKeys.AssertExpectations(t)
 }
 
 func TestGetOrders(t *testing.T) {
@@ -59,11 +144,11 @@ func TestGetOrders(t *testing.T) {
 
 	r := gin.Default()
 	r.Use(func(c *gin.Context) {
-		c.Set("user_id", uint(1))
+		identity.Set(c, identity.Principal{UserID: uint(1)})
 	})
 	r.GET("/orders", orderController.GetOrders)
 
-	mockService.On("GetOrders", uint(1)).Return([]model.Order{}, nil)
+	mockService.On("GetOrders", mock.Anything, uint(1)).Return([]model.Order{}, nil).Once()
 
 	req, _ := http.NewRequest("GET", "/orders", nil)
 	w := httptest.NewRecorder()
@@ -72,9 +157,56 @@ func TestGetOrders(t *testing.T) {
 	assert.Equal(t, http.StatusOK, w.Code)
 
 	// Case 2: Service Error
-	mockService.On("GetOrders", uint(1)).Return(nil, errors.New("failed"))
+	mockService.On("GetOrders", mock.Anything, uint(1)).Return(nil, errors.New("failed"))
 
 	w2 := httptest.NewRecorder()
 	r.ServeHTTP(w2, req)
 	assert.Equal(t, http.StatusInternalServerError, w2.Code)
 }
+
+func TestCancelOrder(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mockService := new(mocks.MockOrderService)
+	orderController := controller.NewOrderController(mockService)
+
+	newRouter := func(userID uint, role string) *gin.Engine {
+		r := gin.Default()
+		r.Use(func(c *gin.Context) {
+			identity.Set(c, identity.Principal{UserID: userID, Role: model.Role(role)})
+		})
+		r.POST("/orders/:id/cancel", orderController.CancelOrder)
+		return r
+	}
+
+	// Case 1: Owner without a body
+	mockService.On("CancelOrder", mock.Anything, uint(7), identity.Principal{UserID: 1, Role: "USER"}, "").Return(&model.Order{Status: model.OrderStatusCancelled}, nil).Once()
+
+	req, _ := http.NewRequest("POST", "/orders/7/cancel", nil)
+	w := httptest.NewRecorder()
+	newRouter(1, "USER").ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Case 2: Someone else, with a reason; the service decides what they may cancel
+	mockService.On("CancelOrder", mock.Anything, uint(7), identity.Principal{UserID: 2, Role: "ADMIN"}, "out of print").Return(&model.Order{Status: model.OrderStatusCancelled}, nil).Once()
+
+	req, _ = http.NewRequest("POST", "/orders/7/cancel", bytes.NewBufferString(`{"reason":"out of print"}`))
+	w = httptest.NewRecorder()
+	newRouter(2, "ADMIN").ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Case 3: Not cancellable anymore
+	mockService.On("CancelOrder", mock.Anything, uint(8), identity.Principal{UserID: 1, Role: "USER"}, "").Return(nil, service.ErrInvalidStatusTransition).Once()
+
+	req, _ = http.NewRequest("POST", "/orders/8/cancel", nil)
+	w = httptest.NewRecorder()
+	newRouter(1, "USER").ServeHTTP(w, req)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	// Case 4: Someone else's order
+	mockService.On("CancelOrder", mock.Anything, uint(9), identity.Principal{UserID: 1, Role: "USER"}, "").Return(nil, service.ErrOrderNotFound).Once()
+
+	req, _ = http.NewRequest("POST", "/orders/9/cancel", nil)
+	w = httptest.NewRecorder()
+	newRouter(1, "USER").ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
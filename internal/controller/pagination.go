@@ -0,0 +1,42 @@
+package controller
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Pagination struct {
+	Page       int    `json:"page"`
+	Limit      int    `json:"limit"`
+	Total      int64  `json:"total"`
+	TotalPages int    `json:"total_pages"`
+	Next       string `json:"next,omitempty"`
+	Prev       string `json:"prev,omitempty"`
+}
+
+// newPagination builds the pagination block for a listing, carrying the
+// request's other query parameters over into the next/prev links.
+func newPagination(ctx *gin.Context, page, limit int, total int64) Pagination {
+	totalPages := int((total + int64(limit) - 1) / int64(limit))
+	p := Pagination{
+		Page:       page,
+		Limit:      limit,
+		Total:      total,
+		TotalPages: totalPages,
+	}
+	if page < totalPages {
+		p.Next = pageLink(ctx, page+1, limit)
+	}
+	if page > 1 && page-1 <= totalPages {
+		p.Prev = pageLink(ctx, page-1, limit)
+	}
+	return p
+}
+
+func pageLink(ctx *gin.Context, page, limit int) string {
+	values := ctx.Request.URL.Query()
+	values.Set("page", strconv.Itoa(page))
+	values.Set("limit", strconv.Itoa(limit))
+	return ctx.Request.URL.Path + "?" + values.Encode()
+}
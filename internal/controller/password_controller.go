@@ -0,0 +1,82 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/beingaloksharma/book-backend/internal/service"
+	"github.com/beingaloksharma/book-backend/utils/logger"
+	"github.com/gin-gonic/gin"
+)
+
+type PasswordController struct {
+	PasswordService service.PasswordServiceInterface
+}
+
+func NewPasswordController(passwordService service.PasswordServiceInterface) *PasswordController {
+	return &PasswordController{PasswordService: passwordService}
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=6"`
+}
+
+// ForgotPassword godoc
+// @Summary Request a password reset
+// @Description Email a single-use password reset link. The response is the same whether or not the email belongs to an account.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body ForgotPasswordRequest true "Forgot Password Request"
+// @Success 202 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Router /auth/password/forgot [post]
+func (c *PasswordController) ForgotPassword(ctx *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		logger.LogError(ctx, http.StatusBadRequest, err, "Invalid request body")
+		return
+	}
+
+	// Failures are only logged; answering differently would reveal which emails have accounts
+	if err := c.PasswordService.ForgotPassword(ctx.Request.Context(), req.Email); err != nil {
+		ctx.Error(err)
+	}
+
+	ctx.JSON(http.StatusAccepted, gin.H{"message": "If an account exists for that email, a password reset link has been sent"})
+}
+
+// ResetPassword godoc
+// @Summary Reset password
+// @Description Set a new password with the token from the reset email. The token works once, and every session of the account is logged out.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body ResetPasswordRequest true "Reset Password Request"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/password/reset [post]
+func (c *PasswordController) ResetPassword(ctx *gin.Context) {
+	var req ResetPasswordRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		logger.LogError(ctx, http.StatusBadRequest, err, "Invalid request body")
+		return
+	}
+
+	if err := c.PasswordService.ResetPassword(ctx.Request.Context(), req.Token, req.Password); err != nil {
+		if errors.Is(err, service.ErrInvalidResetToken) {
+			logger.LogError(ctx, http.StatusBadRequest, err, "Invalid or expired reset token")
+			return
+		}
+		logger.LogError(ctx, http.StatusInternalServerError, err, "Failed to reset password")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Password has been reset"})
+}
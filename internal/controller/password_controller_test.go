@@ -0,0 +1,87 @@
+package controller_test
+
+import (
+	"bytes"
+	"errors"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/beingaloksharma/book-backend/internal/controller"
+	"github.com/beingaloksharma/book-backend/internal/service"
+	"github.com/beingaloksharma/book-backend/internal/service/mocks"
+	"github.com/beingaloksharma/book-backend/utils/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForgotPassword(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger.Init()
+
+	mockService := new(mocks.MockPasswordService)
+	passwordController := controller.NewPasswordController(mockService)
+
+	r := gin.Default()
+	r.POST("/auth/password/forgot", passwordController.ForgotPassword)
+
+	send := func(body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/auth/password/forgot", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// Case 1: Known and unknown emails get the same answer
+	mockService.On("ForgotPassword", mock.Anything, "john@example.com").Return(nil).Once()
+	mockService.On("ForgotPassword", mock.Anything, "nobody@example.com").Return(nil).Once()
+
+	known := send(`{"email":"john@example.com"}`)
+	unknown := send(`{"email":"nobody@example.com"}`)
+	assert.Equal(t, http.StatusAccepted, known.Code)
+	assert.Equal(t, known.Body.String(), unknown.Body.String())
+
+	// Case 2: Delivery failures are not revealed either
+	mockService.On("ForgotPassword", mock.Anything, "john@example.com").Return(errors.New("smtp down")).Once()
+
+	failed := send(`{"email":"john@example.com"}`)
+	assert.Equal(t, http.StatusAccepted, failed.Code)
+	assert.Equal(t, known.Body.String(), failed.Body.String())
+
+	// Case 3: Invalid email
+	assert.Equal(t, http.StatusBadRequest, send(`{"email":"not-an-email"}`).Code)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestResetPassword(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger.Init()
+
+	mockService := new(mocks.MockPasswordService)
+	passwordController := controller.NewPasswordController(mockService)
+
+	r := gin.Default()
+	r.POST("/auth/password/reset", passwordController.ResetPassword)
+
+	send := func(body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/auth/password/reset", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// Case 1: Success
+	mockService.On("ResetPassword", mock.Anything, "reset-1", "new-secret").Return(nil).Once()
+	assert.Equal(t, http.StatusOK, send(`{"token":"reset-1","password":"new-secret"}`).Code)
+
+	// Case 2: Spent or expired token
+	mockService.On("ResetPassword", mock.Anything, "reset-1", "new-secret").Return(service.ErrInvalidResetToken).Once()
+	assert.Equal(t, http.StatusBadRequest, send(`{"token":"reset-1","password":"new-secret"}`).Code)
+
+	// Case 3: Password too short
+	assert.Equal(t, http.StatusBadRequest, send(`{"token":"reset-1","password":"123"}`).Code)
+
+	mockService.AssertExpectations(t)
+}
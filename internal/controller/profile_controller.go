@@ -0,0 +1,140 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/beingaloksharma/book-backend/internal/identity"
+	"github.com/beingaloksharma/book-backend/internal/service"
+	"github.com/beingaloksharma/book-backend/utils/logger"
+	"github.com/gin-gonic/gin"
+)
+
+type ProfileController struct {
+	ProfileService service.ProfileServiceInterface
+}
+
+func NewProfileController(profileService service.ProfileServiceInterface) *ProfileController {
+	return &ProfileController{ProfileService: profileService}
+}
+
+type UpdateProfileRequest struct {
+	Name  *string `json:"name" binding:"omitempty,min=1"`
+	Email *string `json:"email" binding:"omitempty,email"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,min=6"`
+}
+
+// UpdateProfile godoc
+// @Summary Update user profile
+// @Description Change the name and/or email of the logged-in user. A new email address has to be verified again through the link sent to it.
+// @Tags User
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body UpdateProfileRequest true "Update Profile Request"
+// @Success 200 {object} model.User
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/profile [patch]
+func (c *ProfileController) UpdateProfile(ctx *gin.Context) {
+	principal, ok := identity.Require(ctx)
+	if !ok {
+		return
+	}
+
+	var req UpdateProfileRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		logger.LogError(ctx, http.StatusBadRequest, err, "Invalid request body")
+		return
+	}
+
+	user, err := c.ProfileService.UpdateProfile(ctx.Request.Context(), principal.UserID, service.ProfileChanges{Name: req.Name, Email: req.Email})
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrUserNotFound):
+			logger.LogError(ctx, http.StatusNotFound, err, "User not found")
+		case errors.Is(err, service.ErrEmailTaken):
+			logger.LogError(ctx, http.StatusConflict, err, "Email address is already in use")
+		default:
+			logger.LogError(ctx, http.StatusInternalServerError, err, "Failed to update profile")
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, user)
+}
+
+// ChangePassword godoc
+// @Summary Change password
+// @Description Change the password of the logged-in user. Every session is logged out, including the current one.
+// @Tags User
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body ChangePasswordRequest true "Change Password Request"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/profile/password [post]
+func (c *ProfileController) ChangePassword(ctx *gin.Context) {
+	principal, ok := identity.Require(ctx)
+	if !ok {
+		return
+	}
+
+	var req ChangePasswordRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		logger.LogError(ctx, http.StatusBadRequest, err, "Invalid request body")
+		return
+	}
+
+	if err := c.ProfileService.ChangePassword(ctx.Request.Context(), principal.UserID, req.CurrentPassword, req.NewPassword); err != nil {
+		if errors.Is(err, service.ErrIncorrectPassword) {
+			logger.LogError(ctx, http.StatusForbidden, err, "Current password is incorrect")
+			return
+		}
+		logger.LogError(ctx, http.StatusInternalServerError, err, "Failed to change password")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Password changed; please log in again"})
+}
+
+// DeleteAccount godoc
+// @Summary Delete account
+// @Description Close the account of the logged-in user. Personal data is erased and every session ends; past orders are kept for accounting.
+// @Tags User
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/profile [delete]
+func (c *ProfileController) DeleteAccount(ctx *gin.Context) {
+	principal, ok := identity.Require(ctx)
+	if !ok {
+		return
+	}
+
+	if err := c.ProfileService.DeleteAccount(ctx.Request.Context(), principal.UserID); err != nil {
+		switch {
+		case errors.Is(err, service.ErrUserNotFound):
+			logger.LogError(ctx, http.StatusNotFound, err, "User not found")
+		case errors.Is(err, service.ErrLastAdmin):
+			logger.LogError(ctx, http.StatusConflict, err, "Cannot delete the last admin")
+		default:
+			logger.LogError(ctx, http.StatusInternalServerError, err, "Failed to delete account")
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Account deleted"})
+}
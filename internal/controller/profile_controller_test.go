@@ -0,0 +1,124 @@
+package controller_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/beingaloksharma/book-backend/internal/controller"
+	"github.com/beingaloksharma/book-backend/internal/identity"
+	"github.com/beingaloksharma/book-backend/internal/model"
+	"github.com/beingaloksharma/book-backend/internal/service"
+	"github.com/beingaloksharma/book-backend/internal/service/mocks"
+	"github.com/beingaloksharma/book-backend/utils/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestUpdateProfile(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger.Init()
+	mockService := new(mocks.MockProfileService)
+	profileController := controller.NewProfileController(mockService)
+
+	r := gin.Default()
+	r.Use(func(c *gin.Context) {
+		identity.Set(c, identity.Principal{UserID: uint(1)})
+	})
+	r.PATCH("/profile", profileController.UpdateProfile)
+
+	send := func(body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("PATCH", "/profile", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// Case 1: Success, fields left out are not changed; the service gets the request's context
+	requestCtx := mock.MatchedBy(func(ctx context.Context) bool {
+		p, ok := identity.FromContext(ctx)
+		return ok && p.UserID == 1
+	})
+	mockService.On("UpdateProfile", requestCtx, uint(1), mock.MatchedBy(func(c service.ProfileChanges) bool {
+		return c.Name != nil && *c.Name == "Johnny" && c.Email == nil
+	})).Return(&model.User{Name: "Johnny"}, nil).Once()
+
+	w := send(`{"name":"Johnny"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "Johnny")
+
+	// Case 2: Email already in use
+	mockService.On("UpdateProfile", mock.Anything, uint(1), mock.Anything).Return(nil, service.ErrEmailTaken).Once()
+	assert.Equal(t, http.StatusConflict, send(`{"email":"jane@example.com"}`).Code)
+
+	// Case 3: Invalid email
+	assert.Equal(t, http.StatusBadRequest, send(`{"email":"not-an-email"}`).Code)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestChangePassword(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger.Init()
+	mockService := new(mocks.MockProfileService)
+	profileController := controller.NewProfileController(mockService)
+
+	r := gin.Default()
+	r.Use(func(c *gin.Context) {
+		identity.Set(c, identity.Principal{UserID: uint(1)})
+	})
+	r.POST("/profile/password", profileController.ChangePassword)
+
+	send := func(body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/profile/password", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// Case 1: Success
+	mockService.On("ChangePassword", mock.Anything, uint(1), "old-secret", "new-secret").Return(nil).Once()
+	assert.Equal(t, http.StatusOK, send(`{"current_password":"old-secret","new_password":"new-secret"}`).Code)
+
+	// Case 2: Wrong current password
+	mockService.On("ChangePassword", mock.Anything, uint(1), "wrong", "new-secret").Return(service.ErrIncorrectPassword).Once()
+	assert.Equal(t, http.StatusForbidden, send(`{"current_password":"wrong","new_password":"new-secret"}`).Code)
+
+	// Case 3: New password too short
+	assert.Equal(t, http.StatusBadRequest, send(`{"current_password":"old-secret","new_password":"123"}`).Code)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestDeleteAccount(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger.Init()
+	mockService := new(mocks.MockProfileService)
+	profileController := controller.NewProfileController(mockService)
+
+	r := gin.Default()
+	r.Use(func(c *gin.Context) {
+		identity.Set(c, identity.Principal{UserID: uint(1)})
+	})
+	r.DELETE("/profile", profileController.DeleteAccount)
+
+	send := func() *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("DELETE", "/profile", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// Case 1: Success
+	mockService.On("DeleteAccount", mock.Anything, uint(1)).Return(nil).Once()
+	assert.Equal(t, http.StatusOK, send().Code)
+
+	// Case 2: Last admin
+	mockService.On("DeleteAccount", mock.Anything, uint(1)).Return(service.ErrLastAdmin).Once()
+	assert.Equal(t, http.StatusConflict, send().Code)
+
+	mockService.AssertExpectations(t)
+}
@@ -0,0 +1,168 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/beingaloksharma/book-backend/internal/model"
+	"github.com/beingaloksharma/book-backend/internal/service"
+	"github.com/beingaloksharma/book-backend/utils/logger"
+	"github.com/gin-gonic/gin"
+)
+
+type RoleController struct {
+	RoleService service.RoleServiceInterface
+}
+
+func NewRoleController(roleService service.RoleServiceInterface) *RoleController {
+	return &RoleController{RoleService: roleService}
+}
+
+type CreateRoleRequest struct {
+	Name        model.Role         `json:"name" binding:"required"`
+	Description string             `json:"description"`
+	Permissions []model.Permission `json:"permissions"`
+}
+
+type SetRolePermissionsRequest struct {
+	Permissions []model.Permission `json:"permissions" binding:"required"`
+}
+
+// ListPermissions godoc
+// @Summary List permissions
+// @Description Get every permission a role can grant (requires roles:manage)
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} string
+// @Router /api/admin/permissions [get]
+func (c *RoleController) ListPermissions(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, model.AllPermissions)
+}
+
+// ListRoles godoc
+// @Summary List roles
+// @Description Get every role with the permissions it grants (requires roles:manage)
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} model.RoleDefinition
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/roles [get]
+func (c *RoleController) ListRoles(ctx *gin.Context) {
+	roles, err := c.RoleService.ListRoles(ctx.Request.Context())
+	if err != nil {
+		logger.LogError(ctx, http.StatusInternalServerError, err, "Failed to fetch roles")
+		return
+	}
+	ctx.JSON(http.StatusOK, roles)
+}
+
+// CreateRole godoc
+// @Summary Create a role
+// @Description Define a role such as INVENTORY_MANAGER with a set of permissions (requires roles:manage)
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body CreateRoleRequest true "Create Role Request"
+// @Success 201 {object} model.RoleDefinition
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/roles [post]
+func (c *RoleController) CreateRole(ctx *gin.Context) {
+	var req CreateRoleRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		logger.LogError(ctx, http.StatusBadRequest, err, "Invalid request body")
+		return
+	}
+
+	role, err := c.RoleService.CreateRole(ctx.Request.Context(), req.Name, req.Description, req.Permissions)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidRole), errors.Is(err, service.ErrInvalidPermission):
+			logger.LogError(ctx, http.StatusBadRequest, err, err.Error())
+		case errors.Is(err, service.ErrRoleExists):
+			logger.LogError(ctx, http.StatusConflict, err, "Role already exists")
+		default:
+			logger.LogError(ctx, http.StatusInternalServerError, err, "Failed to create role")
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, role)
+}
+
+// SetRolePermissions godoc
+// @Summary Set role permissions
+// @Description Replace the permissions a role grants; ADMIN always has every permission (requires roles:manage)
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param name path string true "Role name"
+// @Param request body SetRolePermissionsRequest true "Set Role Permissions Request"
+// @Success 200 {object} model.RoleDefinition
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/roles/{name}/permissions [put]
+func (c *RoleController) SetRolePermissions(ctx *gin.Context) {
+	var req SetRolePermissionsRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		logger.LogError(ctx, http.StatusBadRequest, err, "Invalid request body")
+		return
+	}
+
+	role, err := c.RoleService.SetRolePermissions(ctx.Request.Context(), roleParam(ctx), req.Permissions)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidPermission):
+			logger.LogError(ctx, http.StatusBadRequest, err, err.Error())
+		case errors.Is(err, service.ErrRoleNotFound):
+			logger.LogError(ctx, http.StatusNotFound, err, "Role not found")
+		case errors.Is(err, service.ErrBuiltinRole):
+			logger.LogError(ctx, http.StatusConflict, err, err.Error())
+		default:
+			logger.LogError(ctx, http.StatusInternalServerError, err, "Failed to update role")
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, role)
+}
+
+// DeleteRole godoc
+// @Summary Delete a role
+// @Description Delete a custom role that no user holds (requires roles:manage)
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param name path string true "Role name"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/roles/{name} [delete]
+func (c *RoleController) DeleteRole(ctx *gin.Context) {
+	if err := c.RoleService.DeleteRole(ctx.Request.Context(), roleParam(ctx)); err != nil {
+		switch {
+		case errors.Is(err, service.ErrRoleNotFound):
+			logger.LogError(ctx, http.StatusNotFound, err, "Role not found")
+		case errors.Is(err, service.ErrBuiltinRole), errors.Is(err, service.ErrRoleInUse):
+			logger.LogError(ctx, http.StatusConflict, err, err.Error())
+		default:
+			logger.LogError(ctx, http.StatusInternalServerError, err, "Failed to delete role")
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Role deleted successfully"})
+}
+
+func roleParam(ctx *gin.Context) model.Role {
+	return model.Role(strings.ToUpper(ctx.Param("name")))
+}
@@ -0,0 +1,134 @@
+package controller_test
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/beingaloksharma/book-backend/internal/controller"
+	"github.com/beingaloksharma/book-backend/internal/model"
+	"github.com/beingaloksharma/book-backend/internal/service"
+	"github.com/beingaloksharma/book-backend/internal/service/mocks"
+	"github.com/beingaloksharma/book-backend/utils/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateRole(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger.Init()
+
+	mockService := new(mocks.MockRoleService)
+	roleController := controller.NewRoleController(mockService)
+
+	r := gin.Default()
+	r.POST("/admin/roles", roleController.CreateRole)
+
+	perms := []model.Permission{model.PermissionBooksWrite}
+	role := &model.RoleDefinition{Name: "INVENTORY_MANAGER", Permissions: []model.RolePermission{{Role: "INVENTORY_MANAGER", Permission: model.PermissionBooksWrite}}}
+
+	// Case 1: Success
+	mockService.On("CreateRole", mock.Anything, model.Role("INVENTORY_MANAGER"), "Stock", perms).Return(role, nil).Once()
+
+	body := `{"name":"INVENTORY_MANAGER","description":"Stock","permissions":["books:write"]}`
+	req, _ := http.NewRequest("POST", "/admin/roles", bytes.NewBufferString(body))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Contains(t, w.Body.String(), `"permissions":["books:write"]`)
+
+	// Case 2: Missing name
+	req, _ = http.NewRequest("POST", "/admin/roles", bytes.NewBufferString(`{"permissions":[]}`))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Case 3: Unknown permission
+	mockService.On("CreateRole", mock.Anything, model.Role("SUPPORT"), "", []model.Permission{"books:burn"}).
+		Return(nil, fmt.Errorf("%w: %q", service.ErrInvalidPermission, "books:burn")).Once()
+
+	req, _ = http.NewRequest("POST", "/admin/roles", bytes.NewBufferString(`{"name":"SUPPORT","permissions":["books:burn"]}`))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Case 4: Already exists
+	mockService.On("CreateRole", mock.Anything, model.Role("SUPPORT"), "", []model.Permission(nil)).Return(nil, service.ErrRoleExists).Once()
+
+	req, _ = http.NewRequest("POST", "/admin/roles", bytes.NewBufferString(`{"name":"SUPPORT"}`))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestSetRolePermissions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger.Init()
+
+	mockService := new(mocks.MockRoleService)
+	roleController := controller.NewRoleController(mockService)
+
+	r := gin.Default()
+	r.PUT("/admin/roles/:name/permissions", roleController.SetRolePermissions)
+
+	// Case 1: Success, name is case-insensitive
+	perms := []model.Permission{model.PermissionOrdersReadAll}
+	mockService.On("SetRolePermissions", mock.Anything, model.Role("SUPPORT"), perms).Return(&model.RoleDefinition{Name: "SUPPORT"}, nil).Once()
+
+	req, _ := http.NewRequest("PUT", "/admin/roles/support/permissions", bytes.NewBufferString(`{"permissions":["orders:read_all"]}`))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Case 2: ADMIN cannot be edited
+	mockService.On("SetRolePermissions", mock.Anything, model.RoleAdmin, []model.Permission{}).Return(nil, service.ErrBuiltinRole).Once()
+
+	req, _ = http.NewRequest("PUT", "/admin/roles/ADMIN/permissions", bytes.NewBufferString(`{"permissions":[]}`))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	// Case 3: Unknown role
+	mockService.On("SetRolePermissions", mock.Anything, model.Role("MISSING"), perms).Return(nil, service.ErrRoleNotFound).Once()
+
+	req, _ = http.NewRequest("PUT", "/admin/roles/MISSING/permissions", bytes.NewBufferString(`{"permissions":["orders:read_all"]}`))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestDeleteRole(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger.Init()
+
+	mockService := new(mocks.MockRoleService)
+	roleController := controller.NewRoleController(mockService)
+
+	r := gin.Default()
+	r.DELETE("/admin/roles/:name", roleController.DeleteRole)
+
+	// Case 1: Success
+	mockService.On("DeleteRole", mock.Anything, model.Role("SUPPORT")).Return(nil).Once()
+
+	req, _ := http.NewRequest("DELETE", "/admin/roles/SUPPORT", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Case 2: Still assigned
+	mockService.On("DeleteRole", mock.Anything, model.Role("SUPPORT")).Return(service.ErrRoleInUse).Once()
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	mockService.AssertExpectations(t)
+}
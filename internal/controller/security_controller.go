@@ -0,0 +1,87 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/beingaloksharma/book-backend/internal/identity"
+	"github.com/beingaloksharma/book-backend/internal/service"
+	"github.com/beingaloksharma/book-backend/utils/logger"
+	"github.com/gin-gonic/gin"
+)
+
+type SecurityController struct {
+	Guard service.LoginGuardInterface
+}
+
+func NewSecurityController(guard service.LoginGuardInterface) *SecurityController {
+	return &SecurityController{Guard: guard}
+}
+
+type AuditEventsQuery struct {
+	Limit int `form:"limit,default=50" binding:"min=1,max=500"`
+}
+
+// UnlockUser godoc
+// @Summary Unlock a user account
+// @Description Lift the login lockout and backoff of an account after failed login attempts. The unlock is recorded as an audit event (requires users:manage)
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/users/{id}/unlock [post]
+func (c *SecurityController) UnlockUser(ctx *gin.Context) {
+	principal, ok := identity.Require(ctx)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		logger.LogError(ctx, http.StatusBadRequest, err, "Invalid user ID")
+		return
+	}
+
+	if err := c.Guard.Unlock(ctx.Request.Context(), uint(id), principal.UserID); err != nil {
+		if errors.Is(err, service.ErrUserNotFound) {
+			logger.LogError(ctx, http.StatusNotFound, err, "User not found")
+			return
+		}
+		logger.LogError(ctx, http.StatusInternalServerError, err, "Failed to unlock user")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "User unlocked"})
+}
+
+// ListAuditEvents godoc
+// @Summary List audit events
+// @Description Get the latest security events, such as account and address lockouts and unlocks, newest first (requires users:read)
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param limit query int false "Number of events (max 500)" default(50)
+// @Success 200 {array} model.AuditEvent
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/admin/audit-events [get]
+func (c *SecurityController) ListAuditEvents(ctx *gin.Context) {
+	var req AuditEventsQuery
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		logger.LogError(ctx, http.StatusBadRequest, err, "Invalid query parameters")
+		return
+	}
+
+	events, err := c.Guard.ListAuditEvents(ctx.Request.Context(), req.Limit)
+	if err != nil {
+		logger.LogError(ctx, http.StatusInternalServerError, err, "Failed to fetch audit events")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, events)
+}
@@ -0,0 +1,86 @@
+package controller_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/beingaloksharma/book-backend/internal/controller"
+	"github.com/beingaloksharma/book-backend/internal/identity"
+	"github.com/beingaloksharma/book-backend/internal/model"
+	"github.com/beingaloksharma/book-backend/internal/service"
+	"github.com/beingaloksharma/book-backend/internal/service/mocks"
+	"github.com/beingaloksharma/book-backend/utils/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestUnlockUser(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger.Init()
+	mockGuard := new(mocks.MockLoginGuard)
+	securityController := controller.NewSecurityController(mockGuard)
+
+	r := gin.Default()
+	r.Use(func(c *gin.Context) {
+		identity.Set(c, identity.Principal{UserID: uint(9), Role: model.RoleAdmin})
+	})
+	r.POST("/admin/users/:id/unlock", securityController.UnlockUser)
+
+	send := func(url string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", url, nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// Case 1: Success is attributed to the admin
+	mockGuard.On("Unlock", mock.Anything, uint(1), uint(9)).Return(nil).Once()
+	assert.Equal(t, http.StatusOK, send("/admin/users/1/unlock").Code)
+
+	// Case 2: Unknown user
+	mockGuard.On("Unlock", mock.Anything, uint(2), uint(9)).Return(service.ErrUserNotFound).Once()
+	assert.Equal(t, http.StatusNotFound, send("/admin/users/2/unlock").Code)
+
+	// Case 3: Invalid ID
+	assert.Equal(t, http.StatusBadRequest, send("/admin/users/abc/unlock").Code)
+
+	mockGuard.AssertExpectations(t)
+}
+
+func TestListAuditEvents(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger.Init()
+	mockGuard := new(mocks.MockLoginGuard)
+	securityController := controller.NewSecurityController(mockGuard)
+
+	r := gin.Default()
+	r.Use(func(c *gin.Context) {
+		identity.Set(c, identity.Principal{UserID: uint(9), Role: model.RoleAdmin})
+	})
+	r.GET("/admin/audit-events", securityController.ListAuditEvents)
+
+	send := func(url string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", url, nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// Case 1: Default limit
+	mockGuard.On("ListAuditEvents", mock.Anything, 50).Return([]model.AuditEvent{{ID: 1, Type: model.AuditAccountLocked, Subject: "john@example.com"}}, nil).Once()
+
+	w := send("/admin/audit-events")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "account_locked")
+
+	// Case 2: Explicit limit
+	mockGuard.On("ListAuditEvents", mock.Anything, 10).Return([]model.AuditEvent{}, nil).Once()
+	assert.Equal(t, http.StatusOK, send("/admin/audit-events?limit=10").Code)
+
+	// Case 3: Limit out of range
+	assert.Equal(t, http.StatusBadRequest, send("/admin/audit-events?limit=1000").Code)
+
+	mockGuard.AssertExpectations(t)
+}
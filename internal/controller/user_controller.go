@@ -1,8 +1,12 @@
 package controller
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 
+	"github.com/beingaloksharma/book-backend/internal/identity"
+	"github.com/beingaloksharma/book-backend/internal/model"
 	"github.com/beingaloksharma/book-backend/internal/service"
 	"github.com/beingaloksharma/book-backend/utils/logger"
 	"github.com/gin-gonic/gin"
@@ -28,22 +32,18 @@ func NewUserController(userService service.UserServiceInterface) *UserController
 // @Failure 500 {object} map[string]string
 // @Router /api/profile [get]
 func (c *UserController) GetProfile(ctx *gin.Context) {
-	userID, _ := ctx.Get("user_id")
-
-	var uid uint
-	switch v := userID.(type) {
-	case float64:
-		uid = uint(v)
-	case uint:
-		uid = v
-	default:
-		logger.LogError(ctx, http.StatusInternalServerError, nil, "Invalid user ID")
+	principal, ok := identity.Require(ctx)
+	if !ok {
 		return
 	}
 
-	user, err := c.UserService.GetProfile(uid)
+	user, err := c.UserService.GetProfile(ctx.Request.Context(), principal.UserID)
 	if err != nil {
-		logger.LogError(ctx, http.StatusNotFound, err, "User not found")
+		if errors.Is(err, service.ErrUserNotFound) {
+			logger.LogError(ctx, http.StatusNotFound, err, "User not found")
+			return
+		}
+		logger.LogError(ctx, http.StatusInternalServerError, err, "Failed to get profile")
 		return
 	}
 
@@ -71,25 +71,18 @@ type AddressRequest struct {
 // @Failure 500 {object} map[string]string
 // @Router /api/addresses [post]
 func (c *UserController) AddAddress(ctx *gin.Context) {
-	userID, _ := ctx.Get("user_id")
-	var req AddressRequest
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		logger.LogError(ctx, http.StatusBadRequest, err, "Invalid request body")
+	principal, ok := identity.Require(ctx)
+	if !ok {
 		return
 	}
 
-	var uid uint
-	switch v := userID.(type) {
-	case float64:
-		uid = uint(v)
-	case uint:
-		uid = v
-	default:
-		logger.LogError(ctx, http.StatusInternalServerError, nil, "Invalid user ID")
+	var req AddressRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		logger.LogError(ctx, http.StatusBadRequest, err, "Invalid request body")
 		return
 	}
 
-	if err := c.UserService.AddAddress(uid, req.Street, req.City, req.State, req.ZipCode, req.Country); err != nil {
+	if err := c.UserService.AddAddress(ctx.Request.Context(), principal.UserID, req.Street, req.City, req.State, req.ZipCode, req.Country); err != nil {
 		logger.LogError(ctx, http.StatusInternalServerError, err, "Failed to add address")
 		return
 	}
@@ -108,20 +101,12 @@ func (c *UserController) AddAddress(ctx *gin.Context) {
 // @Failure 500 {object} map[string]string
 // @Router /api/addresses [get]
 func (c *UserController) GetAddresses(ctx *gin.Context) {
-	userID, _ := ctx.Get("user_id")
-
-	var uid uint
-	switch v := userID.(type) {
-	case float64:
-		uid = uint(v)
-	case uint:
-		uid = v
-	default:
-		logger.LogError(ctx, http.StatusInternalServerError, nil, "Invalid user ID")
+	principal, ok := identity.Require(ctx)
+	if !ok {
 		return
 	}
 
-	addresses, err := c.UserService.GetAddresses(uid)
+	addresses, err := c.UserService.GetAddresses(ctx.Request.Context(), principal.UserID)
 	if err != nil {
 		logger.LogError(ctx, http.StatusInternalServerError, err, "Failed to fetch addresses")
 		return
@@ -129,3 +114,103 @@ func (c *UserController) GetAddresses(ctx *gin.Context) {
 
 	ctx.JSON(http.StatusOK, addresses)
 }
+
+type UpdateAddressRequest struct {
+	Street            string `json:"street" binding:"required"`
+	City              string `json:"city" binding:"required"`
+	State             string `json:"state" binding:"required"`
+	ZipCode           string `json:"zip_code" binding:"required"`
+	Country           string `json:"country" binding:"required"`
+	IsDefaultShipping bool   `json:"is_default_shipping"`
+	IsDefaultBilling  bool   `json:"is_default_billing"`
+}
+
+// UpdateAddress godoc
+// @Summary Update an address
+// @Description Replace one of the user's addresses. Setting a default flag clears it on the user's other addresses.
+// @Tags User
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Address ID"
+// @Param request body UpdateAddressRequest true "Update Address Request"
+// @Success 200 {object} model.Address
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/addresses/{id} [put]
+func (c *UserController) UpdateAddress(ctx *gin.Context) {
+	principal, ok := identity.Require(ctx)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		logger.LogError(ctx, http.StatusBadRequest, err, "Invalid address ID")
+		return
+	}
+
+	var req UpdateAddressRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		logger.LogError(ctx, http.StatusBadRequest, err, "Invalid request body")
+		return
+	}
+
+	address, err := c.UserService.UpdateAddress(ctx.Request.Context(), principal.UserID, uint(id), model.Address{
+		Street:            req.Street,
+		City:              req.City,
+		State:             req.State,
+		ZipCode:           req.ZipCode,
+		Country:           req.Country,
+		IsDefaultShipping: req.IsDefaultShipping,
+		IsDefaultBilling:  req.IsDefaultBilling,
+	})
+	if err != nil {
+		if errors.Is(err, service.ErrAddressNotFound) {
+			logger.LogError(ctx, http.StatusNotFound, err, "Address not found")
+			return
+		}
+		logger.LogError(ctx, http.StatusInternalServerError, err, "Failed to update address")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, address)
+}
+
+// DeleteAddress godoc
+// @Summary Delete an address
+// @Description Delete one of the user's addresses. Orders keep their shipping address snapshot.
+// @Tags User
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Address ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/addresses/{id} [delete]
+func (c *UserController) DeleteAddress(ctx *gin.Context) {
+	principal, ok := identity.Require(ctx)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		logger.LogError(ctx, http.StatusBadRequest, err, "Invalid address ID")
+		return
+	}
+
+	if err := c.UserService.DeleteAddress(ctx.Request.Context(), principal.UserID, uint(id)); err != nil {
+		if errors.Is(err, service.ErrAddressNotFound) {
+			logger.LogError(ctx, http.StatusNotFound, err, "Address not found")
+			return
+		}
+		logger.LogError(ctx, http.StatusInternalServerError, err, "Failed to delete address")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Address deleted successfully"})
+}
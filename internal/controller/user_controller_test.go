@@ -3,12 +3,15 @@ package controller_test
 import (
 	"bytes"
 	"errors"
+	"github.com/stretchr/testify/mock"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/beingaloksharma/book-backend/internal/controller"
+	"github.com/beingaloksharma/book-backend/internal/identity"
 	"github.com/beingaloksharma/book-backend/internal/model"
+	"github.com/beingaloksharma/book-backend/internal/service"
 	"github.com/beingaloksharma/book-backend/internal/service/mocks"
 	"github.com/beingaloksharma/book-backend/utils/logger"
 	"github.com/gin-gonic/gin"
@@ -24,12 +27,12 @@ func TestGetProfile(t *testing.T) {
 
 	r := gin.Default()
 	r.Use(func(c *gin.Context) {
-		c.Set("user_id", uint(1))
+		identity.Set(c, identity.Principal{UserID: uint(1)})
 	})
 	r.GET("/profile", userController.GetProfile)
 
 	// Case 1: Success
-	mockService.On("GetProfile", uint(1)).Return(&model.User{Name: "John"}, nil)
+	mockService.On("GetProfile", mock.Anything, uint(1)).Return(&model.User{Name: "John"}, nil)
 
 	req, _ := http.NewRequest("GET", "/profile", nil)
 	w := httptest.NewRecorder()
@@ -45,16 +48,24 @@ func TestGetProfile_Error(t *testing.T) {
 
 	r := gin.Default()
 	r.Use(func(c *gin.Context) {
-		c.Set("user_id", uint(1))
+		identity.Set(c, identity.Principal{UserID: uint(1)})
 	})
 	r.GET("/profile", userController.GetProfile)
 
-	mockService.On("GetProfile", uint(1)).Return(nil, errors.New("failed"))
+	mockService.On("GetProfile", mock.Anything, uint(1)).Return(nil, errors.New("failed")).Once()
 
 	req, _ := http.NewRequest("GET", "/profile", nil)
 	w := httptest.NewRecorder()
 	r.ServeHTTP(w, req)
 	assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+	// Case 2: The account no longer exists
+	mockService.On("GetProfile", mock.Anything, uint(1)).Return(nil, service.ErrUserNotFound).Once()
+
+	req, _ = http.NewRequest("GET", "/profile", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
 }
 
 func TestAddAddress(t *testing.T) {
@@ -66,12 +77,12 @@ func TestAddAddress(t *testing.T) {
 
 	r := gin.Default()
 	r.Use(func(c *gin.Context) {
-		c.Set("user_id", uint(1))
+		identity.Set(c, identity.Principal{UserID: uint(1)})
 	})
 	r.POST("/addresses", userController.AddAddress)
 
 	// Case 1: Success
-	mockService.On("AddAddress", uint(1), "Street", "City", "State", "Zip", "Country").Return(nil).Once()
+	mockService.On("AddAddress", mock.Anything, uint(1), "Street", "City", "State", "Zip", "Country").Return(nil).Once()
 
 	body := `{"street":"Street", "city":"City", "state":"State", "zip_code":"Zip", "country":"Country"}`
 	req, _ := http.NewRequest("POST", "/addresses", bytes.NewBufferString(body))
@@ -87,7 +98,7 @@ func TestAddAddress(t *testing.T) {
 	assert.Equal(t, http.StatusBadRequest, w2.Code)
 
 	// Case 3: Service Error
-	mockService.On("AddAddress", uint(1), "Street", "City", "State", "Zip", "Country").Return(errors.New("failed")).Once()
+	mockService.On("AddAddress", mock.Anything, uint(1), "Street", "City", "State", "Zip", "Country").Return(errors.New("failed")).Once()
 	req3, _ := http.NewRequest("POST", "/addresses", bytes.NewBufferString(body))
 	w3 := httptest.NewRecorder()
 	r.ServeHTTP(w3, req3)
@@ -101,11 +112,11 @@ func TestGetAddresses(t *testing.T) {
 
 	r := gin.Default()
 	r.Use(func(c *gin.Context) {
-		c.Set("user_id", uint(1))
+		identity.Set(c, identity.Principal{UserID: uint(1)})
 	})
 	r.GET("/addresses", userController.GetAddresses)
 
-	mockService.On("GetAddresses", uint(1)).Return([]model.Address{}, nil)
+	mockService.On("GetAddresses", mock.Anything, uint(1)).Return([]model.Address{}, nil)
 
 	req, _ := http.NewRequest("GET", "/addresses", nil)
 	w := httptest.NewRecorder()
@@ -113,3 +124,86 @@ func TestGetAddresses(t *testing.T) {
 
 	assert.Equal(t, http.StatusOK, w.Code)
 }
+
+func TestUpdateAddress(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger.Init()
+
+	mockService := new(mocks.MockUserService)
+	userController := controller.NewUserController(mockService)
+
+	r := gin.Default()
+	r.Use(func(c *gin.Context) {
+		identity.Set(c, identity.Principal{UserID: uint(1)})
+	})
+	r.PUT("/addresses/:id", userController.UpdateAddress)
+
+	changes := model.Address{Street: "Street", City: "City", State: "State", ZipCode: "Zip", Country: "Country", IsDefaultShipping: true}
+	body := `{"street":"Street", "city":"City", "state":"State", "zip_code":"Zip", "country":"Country", "is_default_shipping": true}`
+
+	// Case 1: Success
+	mockService.On("UpdateAddress", mock.Anything, uint(1), uint(5), changes).Return(&model.Address{UserID: 1, City: "City", IsDefaultShipping: true}, nil).Once()
+	req, _ := http.NewRequest("PUT", "/addresses/5", bytes.NewBufferString(body))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"is_default_shipping":true`)
+
+	// Case 2: Validation Error
+	req2, _ := http.NewRequest("PUT", "/addresses/5", bytes.NewBufferString(`{"city":"City"}`))
+	w2 := httptest.NewRecorder()
+	r.ServeHTTP(w2, req2)
+	assert.Equal(t, http.StatusBadRequest, w2.Code)
+
+	// Case 3: Invalid ID
+	req3, _ := http.NewRequest("PUT", "/addresses/abc", bytes.NewBufferString(body))
+	w3 := httptest.NewRecorder()
+	r.ServeHTTP(w3, req3)
+	assert.Equal(t, http.StatusBadRequest, w3.Code)
+
+	// Case 4: Address of another user
+	mockService.On("UpdateAddress", mock.Anything, uint(1), uint(6), changes).Return(nil, service.ErrAddressNotFound).Once()
+	req4, _ := http.NewRequest("PUT", "/addresses/6", bytes.NewBufferString(body))
+	w4 := httptest.NewRecorder()
+	r.ServeHTTP(w4, req4)
+	assert.Equal(t, http.StatusNotFound, w4.Code)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestDeleteAddress(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger.Init()
+
+	mockService := new(mocks.MockUserService)
+	userController := controller.NewUserController(mockService)
+
+	r := gin.Default()
+	r.Use(func(c *gin.Context) {
+		identity.Set(c, identity.Principal{UserID: uint(1)})
+	})
+	r.DELETE("/addresses/:id", userController.DeleteAddress)
+
+	// Case 1: Success
+	mockService.On("DeleteAddress", mock.Anything, uint(1), uint(5)).Return(nil).Once()
+	req, _ := http.NewRequest("DELETE", "/addresses/5", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Case 2: Address of another user
+	mockService.On("DeleteAddress", mock.Anything, uint(1), uint(6)).Return(service.ErrAddressNotFound).Once()
+	req2, _ := http.NewRequest("DELETE", "/addresses/6", nil)
+	w2 := httptest.NewRecorder()
+	r.ServeHTTP(w2, req2)
+	assert.Equal(t, http.StatusNotFound, w2.Code)
+
+	// Case 3: Service Error
+	mockService.On("DeleteAddress", mock.Anything, uint(1), uint(7)).Return(errors.New("db error")).Once()
+	req3, _ := http.NewRequest("DELETE", "/addresses/7", nil)
+	w3 := httptest.NewRecorder()
+	r.ServeHTTP(w3, req3)
+	assert.Equal(t, http.StatusInternalServerError, w3.Code)
+
+	mockService.AssertExpectations(t)
+}
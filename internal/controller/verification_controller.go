@@ -0,0 +1,76 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/beingaloksharma/book-backend/internal/service"
+	"github.com/beingaloksharma/book-backend/utils/logger"
+	"github.com/gin-gonic/gin"
+)
+
+type VerificationController struct {
+	VerificationService service.VerificationServiceInterface
+}
+
+func NewVerificationController(verificationService service.VerificationServiceInterface) *VerificationController {
+	return &VerificationController{VerificationService: verificationService}
+}
+
+type ResendVerificationRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// VerifyEmail godoc
+// @Summary Verify email address
+// @Description Confirm the email address of an account with the token from the verification email.
+// @Tags Auth
+// @Produce json
+// @Param token query string true "Verification token"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/verify [get]
+func (c *VerificationController) VerifyEmail(ctx *gin.Context) {
+	verifyToken := ctx.Query("token")
+	if verifyToken == "" {
+		logger.LogError(ctx, http.StatusBadRequest, errors.New("token is required"), "Missing verification token")
+		return
+	}
+
+	if err := c.VerificationService.VerifyEmail(ctx.Request.Context(), verifyToken); err != nil {
+		if errors.Is(err, service.ErrInvalidVerificationToken) {
+			logger.LogError(ctx, http.StatusBadRequest, err, "Invalid or expired verification token")
+			return
+		}
+		logger.LogError(ctx, http.StatusInternalServerError, err, "Failed to verify email")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "Email address verified"})
+}
+
+// ResendVerification godoc
+// @Summary Resend verification email
+// @Description Email a new verification link to an unverified account. The response is the same whether or not the email belongs to an account.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body ResendVerificationRequest true "Resend Verification Request"
+// @Success 202 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Router /auth/verify/resend [post]
+func (c *VerificationController) ResendVerification(ctx *gin.Context) {
+	var req ResendVerificationRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		logger.LogError(ctx, http.StatusBadRequest, err, "Invalid request body")
+		return
+	}
+
+	// Failures are only logged; answering differently would reveal which emails have accounts
+	if err := c.VerificationService.ResendVerification(ctx.Request.Context(), req.Email); err != nil {
+		ctx.Error(err)
+	}
+
+	ctx.JSON(http.StatusAccepted, gin.H{"message": "If an unverified account exists for that email, a verification link has been sent"})
+}
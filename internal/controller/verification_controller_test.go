@@ -0,0 +1,80 @@
+package controller_test
+
+import (
+	"bytes"
+	"errors"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/beingaloksharma/book-backend/internal/controller"
+	"github.com/beingaloksharma/book-backend/internal/service"
+	"github.com/beingaloksharma/book-backend/internal/service/mocks"
+	"github.com/beingaloksharma/book-backend/utils/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyEmail(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger.Init()
+
+	mockService := new(mocks.MockVerificationService)
+	verificationController := controller.NewVerificationController(mockService)
+
+	r := gin.Default()
+	r.GET("/auth/verify", verificationController.VerifyEmail)
+
+	send := func(url string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", url, nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// Case 1: Success
+	mockService.On("VerifyEmail", mock.Anything, "verify-1").Return(nil).Once()
+	assert.Equal(t, http.StatusOK, send("/auth/verify?token=verify-1").Code)
+
+	// Case 2: Spent or expired token
+	mockService.On("VerifyEmail", mock.Anything, "verify-1").Return(service.ErrInvalidVerificationToken).Once()
+	assert.Equal(t, http.StatusBadRequest, send("/auth/verify?token=verify-1").Code)
+
+	// Case 3: Missing token
+	assert.Equal(t, http.StatusBadRequest, send("/auth/verify").Code)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestResendVerification(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger.Init()
+
+	mockService := new(mocks.MockVerificationService)
+	verificationController := controller.NewVerificationController(mockService)
+
+	r := gin.Default()
+	r.POST("/auth/verify/resend", verificationController.ResendVerification)
+
+	send := func(body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/auth/verify/resend", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// Case 1: Success and failure get the same answer
+	mockService.On("ResendVerification", mock.Anything, "john@example.com").Return(nil).Once()
+	mockService.On("ResendVerification", mock.Anything, "jane@example.com").Return(errors.New("smtp down")).Once()
+
+	sent := send(`{"email":"john@example.com"}`)
+	failed := send(`{"email":"jane@example.com"}`)
+	assert.Equal(t, http.StatusAccepted, sent.Code)
+	assert.Equal(t, sent.Body.String(), failed.Body.String())
+
+	// Case 2: Invalid email
+	assert.Equal(t, http.StatusBadRequest, send(`{"email":"not-an-email"}`).Code)
+
+	mockService.AssertExpectations(t)
+}
@@ -0,0 +1,80 @@
+package identity
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/beingaloksharma/book-backend/internal/model"
+	"github.com/beingaloksharma/book-backend/utils/logger"
+	"github.com/beingaloksharma/book-backend/utils/token"
+	"github.com/gin-gonic/gin"
+)
+
+const ginKey = "principal"
+
+type contextKey struct{}
+
+// Principal is the authenticated caller of a request, built from a validated
+// access token.
+type Principal struct {
+	UserID    uint
+	Role      model.Role
+	SessionID string
+	TokenID   string
+}
+
+// FromClaims builds the principal for a validated access token.
+func FromClaims(claims *token.Claims) (Principal, error) {
+	userID, err := claims.UserID()
+	if err != nil {
+		return Principal{}, err
+	}
+	return Principal{
+		UserID:    userID,
+		Role:      model.Role(claims.Role),
+		SessionID: claims.SessionID,
+		TokenID:   claims.ID,
+	}, nil
+}
+
+func (p Principal) IsAdmin() bool {
+	return p.Role == model.RoleAdmin
+}
+
+// NewContext returns a copy of ctx carrying the principal.
+func NewContext(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, contextKey{}, p)
+}
+
+// FromContext returns the principal carried by ctx, if any.
+func FromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(contextKey{}).(Principal)
+	return p, ok
+}
+
+// Set attaches the principal to the request, both on the gin context and on the
+// request's context.Context so code below the handlers can read it.
+func Set(c *gin.Context, p Principal) {
+	c.Set(ginKey, p)
+	c.Request = c.Request.WithContext(NewContext(c.Request.Context(), p))
+}
+
+// Current returns the principal AuthMiddleware attached to the request.
+func Current(c *gin.Context) (Principal, bool) {
+	v, exists := c.Get(ginKey)
+	if !exists {
+		return Principal{}, false
+	}
+	p, ok := v.(Principal)
+	return p, ok
+}
+
+// Require returns the request's principal, answering 401 when there is none.
+func Require(c *gin.Context) (Principal, bool) {
+	p, ok := Current(c)
+	if !ok {
+		logger.LogError(c, http.StatusUnauthorized, nil, "Authentication required")
+		c.Abort()
+	}
+	return p, ok
+}
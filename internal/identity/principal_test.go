@@ -0,0 +1,67 @@
+package identity_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/beingaloksharma/book-backend/internal/identity"
+	"github.com/beingaloksharma/book-backend/internal/model"
+	"github.com/beingaloksharma/book-backend/utils/logger"
+	"github.com/beingaloksharma/book-backend/utils/token"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromClaims(t *testing.T) {
+	claims := &token.Claims{
+		Role:             "ADMIN",
+		SessionID:        "session-1",
+		RegisteredClaims: jwt.RegisteredClaims{Subject: "42", ID: "token-1"},
+	}
+
+	principal, err := identity.FromClaims(claims)
+	assert.NoError(t, err)
+	assert.Equal(t, identity.Principal{UserID: 42, Role: model.RoleAdmin, SessionID: "session-1", TokenID: "token-1"}, principal)
+	assert.True(t, principal.IsAdmin())
+
+	claims.Subject = "not-a-number"
+	_, err = identity.FromClaims(claims)
+	assert.Error(t, err)
+}
+
+func TestPrincipalOnRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger.Init()
+
+	r := gin.Default()
+	r.GET("/anonymous", func(c *gin.Context) {
+		if _, ok := identity.Require(c); ok {
+			c.Status(http.StatusOK)
+		}
+	})
+	r.GET("/me", func(c *gin.Context) {
+		identity.Set(c, identity.Principal{UserID: 7, Role: model.RoleUser})
+
+		current, ok := identity.Current(c)
+		assert.True(t, ok)
+		fromContext, ok := identity.FromContext(c.Request.Context())
+		assert.True(t, ok)
+		assert.Equal(t, current, fromContext)
+		assert.False(t, current.IsAdmin())
+		c.Status(http.StatusOK)
+	})
+
+	// Case 1: No principal
+	req, _ := http.NewRequest("GET", "/anonymous", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// Case 2: Principal visible through gin and the request context
+	req, _ = http.NewRequest("GET", "/me", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
@@ -4,12 +4,17 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/beingaloksharma/book-backend/internal/identity"
+	"github.com/beingaloksharma/book-backend/internal/model"
+	"github.com/beingaloksharma/book-backend/internal/repository"
 	"github.com/beingaloksharma/book-backend/utils/logger"
 	"github.com/beingaloksharma/book-backend/utils/token"
 	"github.com/gin-gonic/gin"
 )
 
-func AuthMiddleware() gin.HandlerFunc {
+// AuthMiddleware accepts a bearer access token only while the session it was
+// issued for is still active, so logging out takes effect immediately.
+func AuthMiddleware(sessions repository.RefreshTokenRepositoryInterface) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -32,17 +37,49 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		c.Set("user_id", claims["user_id"])
-		c.Set("role", claims["role"])
+		principal, err := identity.FromClaims(claims)
+		if err != nil {
+			logger.LogError(c, http.StatusUnauthorized, err, "Invalid or expired token")
+			c.Abort()
+			return
+		}
+		active, err := sessions.IsFamilyActive(c.Request.Context(), principal.SessionID)
+		if err != nil {
+			logger.LogError(c, http.StatusInternalServerError, err, "Failed to check session")
+			c.Abort()
+			return
+		}
+		if !active {
+			logger.LogError(c, http.StatusUnauthorized, nil, "Session has been logged out")
+			c.Abort()
+			return
+		}
+
+		identity.Set(c, principal)
 		c.Next()
 	}
 }
 
-func RoleMiddleware(requiredRole string) gin.HandlerFunc {
+// RequirePermission lets the request through only when the caller's role grants
+// permission. Roles and their permissions live in the database, so changes made
+// by an admin apply to the next request. The role itself comes from the access
+// token; changing a user's role ends their sessions, which AuthMiddleware then
+// refuses. Must run after AuthMiddleware.
+func RequirePermission(roles repository.RoleRepositoryInterface, permission model.Permission) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		role, exists := c.Get("role")
-		if !exists || role != requiredRole {
-			logger.LogError(c, http.StatusForbidden, nil, "Forbidden: insufficient permissions")
+		principal, ok := identity.Require(c)
+		if !ok {
+			return
+		}
+
+		allowed, err := roles.HasPermission(c.Request.Context(), principal.Role, permission)
+		if err != nil {
+			logger.LogError(c, http.StatusInternalServerError, err, "Failed to check permissions")
+			c.Abort()
+			return
+		}
+		if !allowed {
+			logger.LogError(c, http.StatusForbidden, nil, "Forbidden: missing permission "+string(permission))
 			c.Abort()
 			return
 		}
@@ -0,0 +1,168 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/beingaloksharma/book-backend/internal/identity"
+	"github.com/beingaloksharma/book-backend/internal/model"
+	"github.com/beingaloksharma/book-backend/internal/repository"
+	"github.com/beingaloksharma/book-backend/utils/logger"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+	DefaultIdempotencyKeyTTL = 24 * time.Hour
+)
+
+// responseRecorder tees everything the handler writes so it can be stored.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// IdempotencyMiddleware makes a mutating route safe to retry. When the request
+// carries an Idempotency-Key header, the first response for that key is stored
+// per user and replayed for later requests with the same key and body; reusing a
+// key with a different body is rejected. Requests without the header pass through.
+// Must run after AuthMiddleware.
+func IdempotencyMiddleware(repo repository.IdempotencyRepositoryInterface, ttl time.Duration) gin.HandlerFunc {
+	if ttl <= 0 {
+		ttl = DefaultIdempotencyKeyTTL
+	}
+
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			logger.LogError(c, http.StatusBadRequest, nil, "Idempotency-Key is too long")
+			c.Abort()
+			return
+		}
+
+		principal, ok := identity.Require(c)
+		if !ok {
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			logger.LogError(c, http.StatusBadRequest, err, "Failed to read request body")
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		fingerprint := requestFingerprint(c.Request.Method, c.Request.URL.Path, body)
+
+		existing, err := repo.FindByKey(c.Request.Context(), principal.UserID, key)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			logger.LogError(c, http.StatusInternalServerError, err, "Failed to check idempotency key")
+			c.Abort()
+			return
+		}
+		if existing != nil && time.Now().After(existing.ExpiresAt) {
+			if err := repo.Delete(c.Request.Context(), existing.ID); err != nil {
+				logger.LogError(c, http.StatusInternalServerError, err, "Failed to expire idempotency key")
+				c.Abort()
+				return
+			}
+			existing = nil
+		}
+		if existing != nil {
+			replay(c, existing, fingerprint)
+			return
+		}
+
+		record := &model.IdempotencyKey{
+			UserID:      principal.UserID,
+			Key:         key,
+			Fingerprint: fingerprint,
+			ExpiresAt:   time.Now().Add(ttl),
+		}
+		claimed, err := repo.Create(c.Request.Context(), record)
+		if err != nil {
+			logger.LogError(c, http.StatusInternalServerError, err, "Failed to store idempotency key")
+			c.Abort()
+			return
+		}
+		if !claimed {
+			// Another request claimed the key between our lookup and insert
+			logger.LogError(c, http.StatusConflict, nil, "A request with this Idempotency-Key is already in progress")
+			c.Abort()
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		// The outcome is recorded even if the client has gone away meanwhile;
+		// otherwise the key would stay claimed with no response until it expires
+		ctx := context.WithoutCancel(c.Request.Context())
+
+		// Server errors are not final; free the key so the client can retry
+		status := recorder.Status()
+		if status >= http.StatusInternalServerError {
+			if err := repo.Delete(ctx, record.ID); err != nil {
+				c.Error(err)
+			}
+			return
+		}
+
+		record.ResponseCode = status
+		record.ResponseBody = recorder.body.Bytes()
+		if err := repo.SaveResponse(ctx, record); err != nil {
+			c.Error(err)
+		}
+	}
+}
+
+func replay(c *gin.Context, record *model.IdempotencyKey, fingerprint string) {
+	if record.Fingerprint != fingerprint {
+		logger.LogError(c, http.StatusUnprocessableEntity, nil, "Idempotency-Key was already used with a different request")
+		c.Abort()
+		return
+	}
+	if record.ResponseCode == 0 {
+		logger.LogError(c, http.StatusConflict, nil, "A request with this Idempotency-Key is already in progress")
+		c.Abort()
+		return
+	}
+
+	c.Header(IdempotentReplayedHeader, "true")
+	c.Data(record.ResponseCode, "application/json; charset=utf-8", record.ResponseBody)
+	c.Abort()
+}
+
+func requestFingerprint(method, path string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method))
+	h.Write([]byte{0})
+	h.Write([]byte(path))
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
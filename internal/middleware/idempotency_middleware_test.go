@@ -0,0 +1,175 @@
+package middleware_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/beingaloksharma/book-backend/internal/identity"
+	"github.com/beingaloksharma/book-backend/internal/middleware"
+	"github.com/beingaloksharma/book-backend/internal/model"
+	"github.com/beingaloksharma/book-backend/internal/repository/mocks"
+	"github.com/beingaloksharma/book-backend/utils/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+func TestIdempotencyMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger.Init()
+
+	mockRepo := new(mocks.MockIdempotencyRepository)
+	calls := 0
+
+	r := gin.Default()
+	r.Use(func(c *gin.Context) {
+		identity.Set(c, identity.Principal{UserID: uint(1)})
+	})
+	r.POST("/orders", middleware.IdempotencyMiddleware(mockRepo, time.Hour), func(c *gin.Context) {
+		calls++
+		c.JSON(http.StatusCreated, gin.H{"message": "Order placed successfully"})
+	})
+
+	send := func(key, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/orders", bytes.NewBufferString(body))
+		if key != "" {
+			req.Header.Set(middleware.IdempotencyKeyHeader, key)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// Case 1: No header passes straight through
+	w := send("", `{"address_id":1}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, 1, calls)
+
+	// Case 2: First use stores the response
+	var stored *model.IdempotencyKey
+	mockRepo.On("FindByKey", mock.Anything, uint(1), "abc").Return(nil, gorm.ErrRecordNotFound).Once()
+	mockRepo.On("Create", mock.Anything, mock.AnythingOfType("*model.IdempotencyKey")).Run(func(args mock.Arguments) {
+		stored = args.Get(1).(*model.IdempotencyKey)
+		stored.ID = 7
+	}).Return(true, nil).Once()
+	mockRepo.On("SaveResponse", mock.Anything, mock.MatchedBy(func(rec *model.IdempotencyKey) bool {
+		return rec.ResponseCode == http.StatusCreated && bytes.Contains(rec.ResponseBody, []byte("Order placed"))
+	})).Return(nil).Once()
+
+	w = send("abc", `{"address_id":1}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, 2, calls)
+
+	// Case 3: Retry replays without running the handler
+	mockRepo.On("FindByKey", mock.Anything, uint(1), "abc").Return(stored, nil).Once()
+
+	w = send("abc", `{"address_id":1}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "true", w.Header().Get(middleware.IdempotentReplayedHeader))
+	assert.Contains(t, w.Body.String(), "Order placed successfully")
+	assert.Equal(t, 2, calls)
+
+	// Case 4: Same key, different body
+	mockRepo.On("FindByKey", mock.Anything, uint(1), "abc").Return(stored, nil).Once()
+
+	w = send("abc", `{"address_id":2}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Equal(t, 2, calls)
+
+	// Case 5: Original request still in flight
+	inFlight := *stored
+	inFlight.ResponseCode = 0
+	mockRepo.On("FindByKey", mock.Anything, uint(1), "abc").Return(&inFlight, nil).Once()
+
+	w = send("abc", `{"address_id":1}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	// Case 6: Lost the race to claim the key
+	mockRepo.On("FindByKey", mock.Anything, uint(1), "race").Return(nil, gorm.ErrRecordNotFound).Once()
+	mockRepo.On("Create", mock.Anything, mock.AnythingOfType("*model.IdempotencyKey")).Return(false, nil).Once()
+
+	w = send("race", `{"address_id":1}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestIdempotencyMiddleware_ReleasesKeyOnServerError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockRepo := new(mocks.MockIdempotencyRepository)
+
+	r := gin.Default()
+	r.Use(func(c *gin.Context) {
+		identity.Set(c, identity.Principal{UserID: uint(1)})
+	})
+	r.POST("/orders", middleware.IdempotencyMiddleware(mockRepo, time.Hour), func(c *gin.Context) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "boom"})
+	})
+
+	// Expired key is dropped and the request is processed again
+	expired := &model.IdempotencyKey{Model: gorm.Model{ID: 3}, ExpiresAt: time.Now().Add(-time.Minute)}
+	mockRepo.On("FindByKey", mock.Anything, uint(1), "abc").Return(expired, nil).Once()
+	mockRepo.On("Delete", mock.Anything, uint(3)).Return(nil).Once()
+	mockRepo.On("Create", mock.Anything, mock.AnythingOfType("*model.IdempotencyKey")).Run(func(args mock.Arguments) {
+		args.Get(1).(*model.IdempotencyKey).ID = 4
+	}).Return(true, nil).Once()
+	mockRepo.On("Delete", mock.Anything, uint(4)).Return(nil).Once()
+
+	req, _ := http.NewRequest("POST", "/orders", bytes.NewBufferString(`{}`))
+	req.Header.Set(middleware.IdempotencyKeyHeader, "abc")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestIdempotencyMiddleware_OutlivesCanceledRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockRepo := new(mocks.MockIdempotencyRepository)
+	status := http.StatusCreated
+	var cancel context.CancelFunc
+
+	r := gin.Default()
+	r.Use(func(c *gin.Context) {
+		identity.Set(c, identity.Principal{UserID: uint(1)})
+	})
+	r.POST("/orders", middleware.IdempotencyMiddleware(mockRepo, time.Hour), func(c *gin.Context) {
+		cancel() // the client disconnects while the order is being placed
+		c.JSON(status, gin.H{})
+	})
+	send := func(key string) {
+		ctx, stop := context.WithCancel(context.Background())
+		cancel = stop
+		req, _ := http.NewRequestWithContext(ctx, "POST", "/orders", bytes.NewBufferString(`{}`))
+		req.Header.Set(middleware.IdempotencyKeyHeader, key)
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	live := mock.MatchedBy(func(ctx context.Context) bool { return ctx.Err() == nil })
+
+	// Case 1: The response is still stored
+	mockRepo.On("FindByKey", mock.Anything, uint(1), "abc").Return(nil, gorm.ErrRecordNotFound).Once()
+	mockRepo.On("Create", mock.Anything, mock.AnythingOfType("*model.IdempotencyKey")).Return(true, nil).Once()
+	mockRepo.On("SaveResponse", live, mock.AnythingOfType("*model.IdempotencyKey")).Return(nil).Once()
+
+	send("abc")
+
+	// Case 2: A failed request still frees its key
+	status = http.StatusInternalServerError
+	mockRepo.On("FindByKey", mock.Anything, uint(1), "def").Return(nil, gorm.ErrRecordNotFound).Once()
+	mockRepo.On("Create", mock.Anything, mock.AnythingOfType("*model.IdempotencyKey")).Run(func(args mock.Arguments) {
+		args.Get(1).(*model.IdempotencyKey).ID = 5
+	}).Return(true, nil).Once()
+	mockRepo.On("Delete", live, uint(5)).Return(nil).Once()
+
+	send("def")
+
+	mockRepo.AssertExpectations(t)
+}
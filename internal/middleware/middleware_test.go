@@ -1,14 +1,21 @@
 package middleware_test
 
 import (
+	"errors"
+	"github.com/stretchr/testify/mock"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/beingaloksharma/book-backend/internal/identity"
 	"github.com/beingaloksharma/book-backend/internal/middleware"
+	"github.com/beingaloksharma/book-backend/internal/model"
+	"github.com/beingaloksharma/book-backend/internal/repository/mocks"
 	"github.com/beingaloksharma/book-backend/utils/logger"
 	"github.com/beingaloksharma/book-backend/utils/token"
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 )
@@ -19,9 +26,13 @@ func TestAuthMiddleware(t *testing.T) {
 	viper.Set("jwt.secret", "testsecret")
 	token.Init()
 
+	sessions := new(mocks.MockRefreshTokenRepository)
+
 	r := gin.Default()
-	r.Use(middleware.AuthMiddleware())
+	r.Use(middleware.AuthMiddleware(sessions))
+	var seen identity.Principal
 	r.GET("/protected", func(c *gin.Context) {
+		seen, _ = identity.Current(c)
 		c.Status(http.StatusOK)
 	})
 
@@ -46,49 +57,86 @@ func TestAuthMiddleware(t *testing.T) {
 	assert.Equal(t, http.StatusUnauthorized, w.Code)
 
 	// Case 4: Valid Token
-	validToken, _ := token.GenerateToken(1, "USER")
+	sessions.On("IsFamilyActive", mock.Anything, "session-1").Return(true, nil).Once()
+	validToken, _ := token.GenerateToken(1, "USER", "session-1")
 	req, _ = http.NewRequest("GET", "/protected", nil)
 	req.Header.Set("Authorization", "Bearer "+validToken)
 	w = httptest.NewRecorder()
 	r.ServeHTTP(w, req)
 	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, uint(1), seen.UserID)
+	assert.Equal(t, "session-1", seen.SessionID)
+	assert.NotEmpty(t, seen.TokenID)
+
+	// Case 5: Session logged out
+	sessions.On("IsFamilyActive", mock.Anything, "session-1").Return(false, nil).Once()
+	req, _ = http.NewRequest("GET", "/protected", nil)
+	req.Header.Set("Authorization", "Bearer "+validToken)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// Case 6: Token without a session
+	noSession := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": 1,
+		"role":    "USER",
+		"exp":     time.Now().Add(time.Hour).Unix(),
+	})
+	noSessionToken, _ := noSession.SignedString([]byte("testsecret"))
+	req, _ = http.NewRequest("GET", "/protected", nil)
+	req.Header.Set("Authorization", "Bearer "+noSessionToken)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	sessions.AssertExpectations(t)
 }
 
-func TestRoleMiddleware(t *testing.T) {
+func TestRequirePermission(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	logger.Init()
-	viper.Set("jwt.secret", "testsecret")
-	token.Init()
 
-	r := gin.Default()
-	// Mock Auth Middleware setting context
-	r.Use(func(c *gin.Context) {
-		tokenStr := c.GetHeader("X-Token")
-		if tokenStr != "" {
-			claims, _ := token.ValidateToken(tokenStr)
-			c.Set("user_id", claims["user_id"])
-			c.Set("role", claims["role"])
-		}
-		c.Next()
-	})
+	roles := new(mocks.MockRoleRepository)
 
-	r.GET("/admin", middleware.RoleMiddleware("ADMIN"), func(c *gin.Context) {
-		c.Status(http.StatusOK)
-	})
+	newRouter := func(role model.Role) *gin.Engine {
+		r := gin.Default()
+		r.Use(func(c *gin.Context) {
+			identity.Set(c, identity.Principal{UserID: 1, Role: role})
+		})
+		r.POST("/admin/books", middleware.RequirePermission(roles, model.PermissionBooksWrite), func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+		return r
+	}
 
-	// Case 1: Forbidden (User trying to access Admin)
-	userToken, _ := token.GenerateToken(1, "USER")
-	req, _ := http.NewRequest("GET", "/admin", nil)
-	req.Header.Set("X-Token", userToken)
+	// Case 1: Role without the permission
+	roles.On("HasPermission", mock.Anything, model.RoleUser, model.PermissionBooksWrite).Return(false, nil).Once()
+	req, _ := http.NewRequest("POST", "/admin/books", nil)
 	w := httptest.NewRecorder()
-	r.ServeHTTP(w, req)
+	newRouter(model.RoleUser).ServeHTTP(w, req)
 	assert.Equal(t, http.StatusForbidden, w.Code)
 
-	// Case 2: Allowed (Admin accessing Admin)
-	adminToken, _ := token.GenerateToken(2, "ADMIN")
-	req, _ = http.NewRequest("GET", "/admin", nil)
-	req.Header.Set("X-Token", adminToken)
+	// Case 2: Custom role granted the permission
+	roles.On("HasPermission", mock.Anything, model.Role("INVENTORY_MANAGER"), model.PermissionBooksWrite).Return(true, nil).Once()
+	req, _ = http.NewRequest("POST", "/admin/books", nil)
 	w = httptest.NewRecorder()
-	r.ServeHTTP(w, req)
+	newRouter("INVENTORY_MANAGER").ServeHTTP(w, req)
 	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Case 3: Lookup failure
+	roles.On("HasPermission", mock.Anything, model.RoleAdmin, model.PermissionBooksWrite).Return(false, errors.New("db down")).Once()
+	req, _ = http.NewRequest("POST", "/admin/books", nil)
+	w = httptest.NewRecorder()
+	newRouter(model.RoleAdmin).ServeHTTP(w, req)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+	// Case 4: No principal
+	r := gin.Default()
+	r.POST("/admin/books", middleware.RequirePermission(roles, model.PermissionBooksWrite))
+	req, _ = http.NewRequest("POST", "/admin/books", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	roles.AssertExpectations(t)
 }
@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/beingaloksharma/book-backend/internal/identity"
+	"github.com/beingaloksharma/book-backend/internal/repository"
+	"github.com/beingaloksharma/book-backend/utils/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+)
+
+const (
+	RateLimitLimitHeader     = "RateLimit-Limit"
+	RateLimitRemainingHeader = "RateLimit-Remaining"
+	RateLimitResetHeader     = "RateLimit-Reset"
+)
+
+// RateLimit is a token bucket: clients may make Requests per Period on
+// average, and up to Burst at once after a quiet spell. Zero Requests means
+// no limit.
+type RateLimit struct {
+	Requests int
+	Period   time.Duration
+	Burst    int
+}
+
+// defaultRateLimits apply to route groups with no rate_limit entry.
+var defaultRateLimits = map[string]RateLimit{
+	"auth":   {Requests: 20, Period: time.Minute, Burst: 10},
+	"api":    {Requests: 300, Period: time.Minute, Burst: 100},
+	"admin":  {Requests: 120, Period: time.Minute, Burst: 30},
+	"health": {Requests: 60, Period: time.Minute, Burst: 10},
+}
+
+// RateLimitFromConfig reads the limit of a route group from
+// rate_limit.groups.<group>. Setting rate_limit.enabled to false turns every
+// limit off.
+func RateLimitFromConfig(group string) RateLimit {
+	if viper.IsSet("rate_limit.enabled") && !viper.GetBool("rate_limit.enabled") {
+		return RateLimit{}
+	}
+
+	limit := defaultRateLimits[group]
+	prefix := "rate_limit.groups." + group + "."
+	if viper.IsSet(prefix + "requests") {
+		limit.Requests = viper.GetInt(prefix + "requests")
+	}
+	if viper.IsSet(prefix + "period") {
+		limit.Period = viper.GetDuration(prefix + "period")
+	}
+	if viper.IsSet(prefix + "burst") {
+		limit.Burst = viper.GetInt(prefix + "burst")
+	}
+	if limit.Period <= 0 {
+		limit.Period = time.Minute
+	}
+	if limit.Burst <= 0 {
+		limit.Burst = limit.Requests
+	}
+	return limit
+}
+
+// RateLimitMiddleware limits each client of a route group to limit. Clients
+// are told apart by user when the request is authenticated, so it should run
+// after AuthMiddleware where there is one, and by address otherwise. Every
+// response carries the RateLimit-* headers; requests over the limit get 429
+// with Retry-After. If the store fails, requests are let through.
+func RateLimitMiddleware(store repository.RateLimitRepositoryInterface, group string, limit RateLimit) gin.HandlerFunc {
+	if limit.Requests <= 0 {
+		return func(c *gin.Context) { c.Next() }
+	}
+	rate := float64(limit.Requests) / limit.Period.Seconds() // tokens per second
+
+	return func(c *gin.Context) {
+		bucket, allowed, err := store.Take(c.Request.Context(), group+":"+rateLimitClient(c), rate, limit.Burst)
+		if err != nil {
+			c.Error(err)
+			c.Next()
+			return
+		}
+
+		c.Header(RateLimitLimitHeader, strconv.Itoa(limit.Burst))
+		c.Header(RateLimitRemainingHeader, strconv.Itoa(int(bucket.Tokens)))
+		c.Header(RateLimitResetHeader, strconv.Itoa(secondsUntil(float64(limit.Burst)-bucket.Tokens, rate)))
+		if !allowed {
+			c.Header("Retry-After", strconv.Itoa(secondsUntil(1-bucket.Tokens, rate)))
+			logger.LogError(c, http.StatusTooManyRequests, nil, "Too many requests; try again later")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+func rateLimitClient(c *gin.Context) string {
+	if principal, ok := identity.Current(c); ok {
+		return "user:" + strconv.FormatUint(uint64(principal.UserID), 10)
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// secondsUntil is how many whole seconds it takes to earn tokens at rate.
+func secondsUntil(tokens, rate float64) int {
+	if tokens <= 0 {
+		return 0
+	}
+	return int(math.Ceil(tokens / rate))
+}
@@ -0,0 +1,114 @@
+package middleware_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/beingaloksharma/book-backend/internal/identity"
+	"github.com/beingaloksharma/book-backend/internal/middleware"
+	"github.com/beingaloksharma/book-backend/internal/repository"
+	"github.com/beingaloksharma/book-backend/internal/repository/mocks"
+	"github.com/beingaloksharma/book-backend/utils/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRateLimitMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger.Init()
+
+	limit := middleware.RateLimit{Requests: 60, Period: time.Minute, Burst: 2}
+	r := gin.Default()
+	r.Use(func(c *gin.Context) {
+		if c.GetHeader("X-User") != "" {
+			identity.Set(c, identity.Principal{UserID: 7})
+		}
+	})
+	r.Use(middleware.RateLimitMiddleware(repository.NewMemoryRateLimitRepository(), "api", limit))
+	r.GET("/books", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	send := func(ip string, user bool) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/books", nil)
+		req.RemoteAddr = ip + ":40000"
+		if user {
+			req.Header.Set("X-User", "7")
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// Case 1: Requests within the burst pass and report what is left
+	w := send("10.0.0.1", false)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", w.Header().Get(middleware.RateLimitLimitHeader))
+	assert.Equal(t, "1", w.Header().Get(middleware.RateLimitRemainingHeader))
+	assert.Equal(t, "1", w.Header().Get(middleware.RateLimitResetHeader))
+	assert.Equal(t, http.StatusOK, send("10.0.0.1", false).Code)
+
+	// Case 2: Over the limit
+	w = send("10.0.0.1", false)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "0", w.Header().Get(middleware.RateLimitRemainingHeader))
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+
+	// Case 3: Other addresses have their own bucket
+	assert.Equal(t, http.StatusOK, send("10.0.0.2", false).Code)
+
+	// Case 4: Authenticated requests are limited per user, wherever they come from
+	assert.Equal(t, http.StatusOK, send("10.0.0.1", true).Code)
+	assert.Equal(t, http.StatusOK, send("10.0.0.3", true).Code)
+	assert.Equal(t, http.StatusTooManyRequests, send("10.0.0.4", true).Code)
+}
+
+func TestRateLimitMiddlewareStoreFailure(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger.Init()
+
+	store := new(mocks.MockRateLimitRepository)
+	store.On("Take", mock.Anything, "auth:ip:10.0.0.1", mock.Anything, 10).Return(nil, false, errors.New("db down")).Once()
+
+	r := gin.Default()
+	r.Use(middleware.RateLimitMiddleware(store, "auth", middleware.RateLimit{Requests: 10, Period: time.Minute, Burst: 10}))
+	r.GET("/login", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	// Requests are let through when the store is unavailable
+	req, _ := http.NewRequest("GET", "/login", nil)
+	req.RemoteAddr = "10.0.0.1:40000"
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get(middleware.RateLimitLimitHeader))
+	store.AssertExpectations(t)
+}
+
+func TestRateLimitFromConfig(t *testing.T) {
+	// Case 1: Defaults
+	assert.Equal(t, middleware.RateLimit{Requests: 20, Period: time.Minute, Burst: 10}, middleware.RateLimitFromConfig("auth"))
+
+	// Case 2: Configured, with the burst defaulting to the request count
+	viper.Set("rate_limit.groups.api.requests", 50)
+	viper.Set("rate_limit.groups.api.period", "10s")
+	viper.Set("rate_limit.groups.api.burst", 0)
+	defer func() {
+		viper.Set("rate_limit.groups.api.requests", nil)
+		viper.Set("rate_limit.groups.api.period", nil)
+		viper.Set("rate_limit.groups.api.burst", nil)
+	}()
+	assert.Equal(t, middleware.RateLimit{Requests: 50, Period: 10 * time.Second, Burst: 50}, middleware.RateLimitFromConfig("api"))
+
+	// Case 3: Turned off
+	viper.Set("rate_limit.enabled", false)
+	defer viper.Set("rate_limit.enabled", nil)
+	assert.Zero(t, middleware.RateLimitFromConfig("api").Requests)
+}
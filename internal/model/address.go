@@ -4,10 +4,23 @@ import "gorm.io/gorm"
 
 type Address struct {
 	gorm.Model
-	UserID  uint   `json:"user_id"`
-	Street  string `json:"street"`
-	City    string `json:"city"`
-	State   string `json:"state"`
-	ZipCode string `json:"zip_code"`
-	Country string `json:"country"`
+	UserID            uint   `json:"user_id"`
+	Street            string `json:"street"`
+	City              string `json:"city"`
+	State             string `json:"state"`
+	ZipCode           string `json:"zip_code"`
+	Country           string `json:"country"`
+	IsDefaultShipping bool   `json:"is_default_shipping"`
+	IsDefaultBilling  bool   `json:"is_default_billing"`
+}
+
+// Snapshot copies the address into an immutable ShippingAddress.
+func (a Address) Snapshot() ShippingAddress {
+	return ShippingAddress{
+		Street:  a.Street,
+		City:    a.City,
+		State:   a.State,
+		ZipCode: a.ZipCode,
+		Country: a.Country,
+	}
 }
@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+type AuditEventType string
+
+const (
+	AuditAccountLocked   AuditEventType = "account_locked"
+	AuditAccountUnlocked AuditEventType = "account_unlocked"
+	AuditIPLocked        AuditEventType = "ip_locked"
+)
+
+// AuditEvent records a security-relevant event. UserID is the account it is
+// about, if known; ActorID is the admin who caused it, if any.
+type AuditEvent struct {
+	ID        uint           `json:"id" gorm:"primaryKey"`
+	Type      AuditEventType `json:"type" gorm:"size:64;index"`
+	UserID    *uint          `json:"user_id,omitempty" gorm:"index"`
+	ActorID   *uint          `json:"actor_id,omitempty"`
+	Subject   string         `json:"subject"` // Email or client address the event is about
+	IP        string         `json:"ip,omitempty"`
+	Detail    string         `json:"detail,omitempty"`
+	CreatedAt time.Time      `json:"created_at" gorm:"index"`
+}
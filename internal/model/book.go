@@ -9,4 +9,6 @@ type Book struct {
 	Price       float64 `json:"price"`
 	Stock       int     `json:"stock"`
 	Description string  `json:"description"`
+	// SearchVector is maintained by Postgres; title outranks author, which outranks description.
+	SearchVector string `json:"-" gorm:"->:false;type:tsvector GENERATED ALWAYS AS (setweight(to_tsvector('english', coalesce(title, '')), 'A') || setweight(to_tsvector('english', coalesce(author, '')), 'B') || setweight(to_tsvector('english', coalesce(description, '')), 'C')) STORED;index:idx_books_search_vector,type:gin"`
 }
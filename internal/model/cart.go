@@ -14,4 +14,33 @@ type CartItem struct {
 	BookID   uint `json:"book_id"`
 	Book     Book `json:"book"`
 	Quantity int  `json:"quantity"`
+	// PriceAtAdd is the book price when the user last added or changed the item.
+	PriceAtAdd float64 `json:"price_at_add"`
+}
+
+// CartSummary is the priced view of a cart against current prices and stock.
+type CartSummary struct {
+	CartID    uint       `json:"cart_id"`
+	Items     []CartLine `json:"items"`
+	ItemCount int        `json:"item_count"`
+	Subtotal  float64    `json:"subtotal"`
+	// Checkoutable is false while any line is unavailable, out of stock or exceeds the available stock.
+	Checkoutable bool `json:"checkoutable"`
+}
+
+type CartLine struct {
+	BookID            uint    `json:"book_id"`
+	Title             string  `json:"title"`
+	Author            string  `json:"author"`
+	Quantity          int     `json:"quantity"`
+	UnitPrice         float64 `json:"unit_price"`
+	LineTotal         float64 `json:"line_total"`
+	PriceAtAdd        float64 `json:"price_at_add"`
+	PriceChanged      bool    `json:"price_changed"`
+	Available         int     `json:"available"`
+	OutOfStock        bool    `json:"out_of_stock"`
+	InsufficientStock bool    `json:"insufficient_stock"`
+	// Unavailable lines are for books removed from the catalog; they have no
+	// price and are left out of the item count and subtotal.
+	Unavailable bool `json:"unavailable"`
 }
@@ -0,0 +1,19 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// IdempotencyKey stores the outcome of a mutating request so a retry carrying the
+// same Idempotency-Key header can be answered without repeating it.
+type IdempotencyKey struct {
+	gorm.Model
+	UserID       uint      `json:"user_id" gorm:"uniqueIndex:idx_idempotency_user_key"`
+	Key          string    `json:"key" gorm:"column:idempotency_key;size:255;uniqueIndex:idx_idempotency_user_key"`
+	Fingerprint  string    `json:"-"`
+	ResponseCode int       `json:"response_code"` // Zero while the original request is in flight
+	ResponseBody []byte    `json:"-"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+// LoginAttempt counts the recent failed logins of one subject: an account
+// ("account:<email>") or a client address ("ip:<address>").
+type LoginAttempt struct {
+	Subject     string     `json:"subject" gorm:"primaryKey;size:320"`
+	Failures    int        `json:"failures"`
+	LastFailure time.Time  `json:"last_failure"`
+	LockedUntil *time.Time `json:"locked_until"`
+}
@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+// MFARecoveryCode is a single-use code that stands in for a TOTP code when the
+// user has lost their authenticator. Only the hash is stored.
+type MFARecoveryCode struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"index"`
+	CodeHash  string     `json:"-" gorm:"size:64;index"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
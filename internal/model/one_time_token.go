@@ -0,0 +1,25 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type TokenPurpose string
+
+const (
+	TokenPurposePasswordReset     TokenPurpose = "password_reset"
+	TokenPurposeEmailVerification TokenPurpose = "email_verification"
+)
+
+// OneTimeToken is a single-use, expiring token sent to a user by email, such as
+// a password reset link. Only the hash is stored.
+type OneTimeToken struct {
+	gorm.Model
+	UserID    uint         `json:"user_id" gorm:"index"`
+	Purpose   TokenPurpose `json:"purpose" gorm:"size:32;index"`
+	TokenHash string       `json:"-" gorm:"size:64;uniqueIndex"`
+	ExpiresAt time.Time    `json:"expires_at"`
+	UsedAt    *time.Time   `json:"used_at"`
+}
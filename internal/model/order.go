@@ -6,17 +6,59 @@ type OrderStatus string
 
 const (
 	OrderStatusPending   OrderStatus = "PENDING"
-	OrderStatusCompleted OrderStatus = "COMPLETED"
+	OrderStatusPaid      OrderStatus = "PAID"
+	OrderStatusShipped   OrderStatus = "SHIPPED"
+	OrderStatusDelivered OrderStatus = "DELIVERED"
 	OrderStatusCancelled OrderStatus = "CANCELLED"
+	OrderStatusRefunded  OrderStatus = "REFUNDED"
 )
 
+// orderTransitions lists the statuses each status may move to. CANCELLED and
+// REFUNDED are terminal.
+var orderTransitions = map[OrderStatus][]OrderStatus{
+	OrderStatusPending:   {OrderStatusPaid, OrderStatusCancelled},
+	OrderStatusPaid:      {OrderStatusShipped, OrderStatusCancelled},
+	OrderStatusShipped:   {OrderStatusDelivered, OrderStatusRefunded},
+	OrderStatusDelivered: {OrderStatusRefunded},
+}
+
+// IsValid reports whether s is a known order status.
+func (s OrderStatus) IsValid() bool {
+	switch s {
+	case OrderStatusPending, OrderStatusPaid, OrderStatusShipped, OrderStatusDelivered, OrderStatusCancelled, OrderStatusRefunded:
+		return true
+	}
+	return false
+}
+
+// CanTransitionTo reports whether an order in status s may move to next.
+func (s OrderStatus) CanTransitionTo(next OrderStatus) bool {
+	for _, allowed := range orderTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
 type Order struct {
 	gorm.Model
-	UserID    uint        `json:"user_id"`
-	AddressID uint        `json:"address_id"`
-	Amount    float64     `json:"amount"`
-	Status    OrderStatus `json:"status" gorm:"default:'PENDING'"`
-	Items     []OrderItem `json:"items"`
+	UserID          uint            `json:"user_id"`
+	AddressID       uint            `json:"address_id"`
+	ShippingAddress ShippingAddress `json:"shipping_address" gorm:"embedded;embeddedPrefix:shipping_"`
+	Amount          float64         `json:"amount"`
+	Status          OrderStatus     `json:"status" gorm:"default:'PENDING'"`
+	Items           []OrderItem     `json:"items"`
+}
+
+// ShippingAddress is the copy of an Address taken when the order is placed, so
+// later edits to the address book do not rewrite order history.
+type ShippingAddress struct {
+	Street  string `json:"street"`
+	City    string `json:"city"`
+	State   string `json:"state"`
+	ZipCode string `json:"zip_code"`
+	Country string `json:"country"`
 }
 
 type OrderItem struct {
@@ -27,3 +69,13 @@ type OrderItem struct {
 	Quantity int     `json:"quantity"`
 	Price    float64 `json:"price"` // Captured price at time of order
 }
+
+// OrderStatusHistory records a single status change of an order; CreatedAt is when it happened.
+type OrderStatusHistory struct {
+	gorm.Model
+	OrderID    uint        `json:"order_id" gorm:"index"`
+	FromStatus OrderStatus `json:"from_status"`
+	ToStatus   OrderStatus `json:"to_status"`
+	ChangedBy  uint        `json:"changed_by"`
+	Note       string      `json:"note"`
+}
@@ -0,0 +1,76 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Permission is a single capability checked by RequirePermission, named
+// resource:action.
+type Permission string
+
+const (
+	PermissionAdminAccess   Permission = "admin:access"
+	PermissionBooksWrite    Permission = "books:write"
+	PermissionOrdersReadAll Permission = "orders:read_all"
+	PermissionOrdersManage  Permission = "orders:manage"
+	PermissionUsersRead     Permission = "users:read"
+	PermissionUsersManage   Permission = "users:manage"
+	PermissionRolesManage   Permission = "roles:manage"
+)
+
+// AllPermissions lists every permission the server checks; the built-in ADMIN
+// role always holds all of them.
+var AllPermissions = []Permission{
+	PermissionAdminAccess,
+	PermissionBooksWrite,
+	PermissionOrdersReadAll,
+	PermissionOrdersManage,
+	PermissionUsersRead,
+	PermissionUsersManage,
+	PermissionRolesManage,
+}
+
+// IsValid reports whether p is a known permission.
+func (p Permission) IsValid() bool {
+	for _, known := range AllPermissions {
+		if p == known {
+			return true
+		}
+	}
+	return false
+}
+
+// RoleDefinition is a role users can be given and the permissions it grants.
+// ADMIN and USER are built in; admins can define further roles.
+type RoleDefinition struct {
+	Name        Role             `json:"name" gorm:"primaryKey;size:64"`
+	Description string           `json:"description"`
+	Permissions []RolePermission `json:"permissions" gorm:"foreignKey:Role;references:Name;constraint:OnDelete:CASCADE"`
+	CreatedAt   time.Time        `json:"created_at"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+}
+
+func (RoleDefinition) TableName() string {
+	return "roles"
+}
+
+// Grants reports whether the role holds permission.
+func (r *RoleDefinition) Grants(permission Permission) bool {
+	for _, p := range r.Permissions {
+		if p.Permission == permission {
+			return true
+		}
+	}
+	return false
+}
+
+type RolePermission struct {
+	Role       Role       `gorm:"primaryKey;size:64"`
+	Permission Permission `gorm:"primaryKey;size:64"`
+}
+
+// MarshalJSON renders a granted permission as its bare name.
+func (p RolePermission) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.Permission)
+}
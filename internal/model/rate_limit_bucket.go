@@ -0,0 +1,34 @@
+package model
+
+import (
+	"math"
+	"time"
+)
+
+// RateLimitBucket is the token bucket of one client of a route group, keyed
+// "<group>:user:<id>" or "<group>:ip:<address>".
+type RateLimitBucket struct {
+	Subject    string    `json:"subject" gorm:"primaryKey;size:320"`
+	Tokens     float64   `json:"tokens"`
+	RefilledAt time.Time `json:"refilled_at"`
+	// FullAt is when the bucket will have refilled completely at the rate and
+	// burst of the group that last drew from it. A new bucket starts full, so
+	// one past FullAt can be forgotten.
+	FullAt time.Time `json:"full_at" gorm:"index"`
+}
+
+// Take adds the tokens earned since the last refill, at rate per second up to
+// burst, then spends one if there is one. It reports whether it spent one.
+func (b *RateLimitBucket) Take(now time.Time, rate float64, burst int) bool {
+	if elapsed := now.Sub(b.RefilledAt).Seconds(); elapsed > 0 {
+		b.Tokens = math.Min(float64(burst), b.Tokens+elapsed*rate)
+		b.RefilledAt = now
+	}
+	allowed := b.Tokens >= 1
+	if allowed {
+		b.Tokens--
+	}
+	missing := float64(burst) - b.Tokens
+	b.FullAt = b.RefilledAt.Add(time.Duration(missing / rate * float64(time.Second)))
+	return allowed
+}
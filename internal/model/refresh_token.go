@@ -0,0 +1,21 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RefreshToken is one link in a login session's chain of refresh tokens. Only the
+// hash is stored. Tokens sharing a FamilyID belong to the same session: each
+// refresh rotates the current token into a new one, and revoking the family
+// ends the session.
+type RefreshToken struct {
+	gorm.Model
+	UserID    uint       `json:"user_id" gorm:"index"`
+	FamilyID  string     `json:"family_id" gorm:"size:64;index"`
+	TokenHash string     `json:"-" gorm:"size:64;uniqueIndex"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RotatedAt *time.Time `json:"rotated_at"` // Set once the token has been exchanged for its successor
+	RevokedAt *time.Time `json:"revoked_at"`
+}
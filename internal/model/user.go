@@ -1,6 +1,10 @@
 package model
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type Role string
 
@@ -9,10 +13,42 @@ const (
 	RoleUser  Role = "USER"
 )
 
+// IsBuiltin reports whether r is one of the roles the server relies on, which
+// cannot be deleted.
+func (r Role) IsBuiltin() bool {
+	return r == RoleAdmin || r == RoleUser
+}
+
 type User struct {
 	gorm.Model
-	Name     string `json:"name"`
-	Email    string `json:"email" gorm:"unique"`
-	Password string `json:"-"`
-	Role     Role   `json:"role" gorm:"default:'USER'"`
+	Name       string     `json:"name"`
+	Email      string     `json:"email" gorm:"unique"`
+	Password   string     `json:"-"`
+	Role       Role       `json:"role" gorm:"default:'USER'"`
+	VerifiedAt *time.Time `json:"verified_at"` // When the email address was confirmed
+	// TOTP second factor. The secret is set at enrollment and only counts once
+	// MFAEnabledAt is set, after the user proved their app produces codes.
+	MFASecret    string     `json:"-"`
+	MFAEnabledAt *time.Time `json:"mfa_enabled_at"`
+	MFALastStep  int64      `json:"-"` // Last TOTP time step accepted, so each code works once
+}
+
+func (u *User) IsVerified() bool {
+	return u.VerifiedAt != nil
+}
+
+func (u *User) MFAEnabled() bool {
+	return u.MFAEnabledAt != nil
+}
+
+// UserRoleHistory records a single role change of a user; CreatedAt is when it
+// happened. ChangedBy is zero for changes made by the server itself, such as
+// bootstrapping the first admin.
+type UserRoleHistory struct {
+	gorm.Model
+	UserID    uint   `json:"user_id" gorm:"index"`
+	FromRole  Role   `json:"from_role"`
+	ToRole    Role   `json:"to_role"`
+	ChangedBy uint   `json:"changed_by"`
+	Note      string `json:"note"`
 }
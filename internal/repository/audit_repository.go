@@ -0,0 +1,29 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/beingaloksharma/book-backend/internal/model"
+	"gorm.io/gorm"
+)
+
+type AuditRepository struct {
+	DB *gorm.DB
+}
+
+func NewAuditRepository(db *gorm.DB) *AuditRepository {
+	return &AuditRepository{DB: db}
+}
+
+func (r *AuditRepository) Record(ctx context.Context, event *model.AuditEvent) error {
+	return withContext(ctx, r.DB).Create(event).Error
+}
+
+// FindRecent returns the latest events, newest first.
+func (r *AuditRepository) FindRecent(ctx context.Context, limit int) ([]model.AuditEvent, error) {
+	var events []model.AuditEvent
+	if err := withContext(ctx, r.DB).Order("created_at DESC, id DESC").Limit(limit).Find(&events).Error; err != nil {
+		return nil, err
+	}
+	return events, nil
+}
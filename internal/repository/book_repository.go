@@ -1,43 +1,164 @@
 package repository
 
 import (
+	"context"
+	"strings"
+
 	"github.com/beingaloksharma/book-backend/internal/model"
-	"github.com/beingaloksharma/book-backend/utils/database"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Catalog listing page size bounds.
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
 )
 
+// bookSortColumns whitelists the sort keys accepted by FindByQuery.
+var bookSortColumns = map[string]string{
+	"price":      "price",
+	"title":      "title",
+	"created_at": "created_at",
+}
+
+// BookQuery holds the filters, sort key and page requested for a catalog listing.
+type BookQuery struct {
+	Author   string
+	MinPrice *float64
+	MaxPrice *float64
+	InStock  bool
+	SortBy   string
+	Order    string
+	Page     int
+	Limit    int
+}
+
+// Normalize clamps the page and limit to valid values and defaults the sort order.
+func (q *BookQuery) Normalize() {
+	normalizePage(&q.Page, &q.Limit)
+	if q.Order != "desc" {
+		q.Order = "asc"
+	}
+}
+
+// likeEscaper escapes the LIKE wildcards, with backslash as the escape character.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// containsPattern is a LIKE pattern, for use with ESCAPE '\', that matches
+// values containing s literally.
+func containsPattern(s string) string {
+	return "%" + likeEscaper.Replace(s) + "%"
+}
+
+func normalizePage(page, limit *int) {
+	if *page < 1 {
+		*page = 1
+	}
+	if *limit < 1 {
+		*limit = DefaultPageSize
+	}
+	if *limit > MaxPageSize {
+		*limit = MaxPageSize
+	}
+}
+
 type BookRepository struct {
 	DB *gorm.DB
 }
 
-func NewBookRepository() *BookRepository {
-	return &BookRepository{DB: database.GetInstance()}
+func NewBookRepository(db *gorm.DB) *BookRepository {
+	return &BookRepository{DB: db}
 }
 
-func (r *BookRepository) CreateBook(book *model.Book) error {
-	return r.DB.Create(book).Error
+func (r *BookRepository) CreateBook(ctx context.Context, book *model.Book) error {
+	return withContext(ctx, r.DB).Create(book).Error
 }
 
-func (r *BookRepository) UpdateBook(book *model.Book) error {
-	return r.DB.Save(book).Error
+func (r *BookRepository) UpdateBook(ctx context.Context, book *model.Book) error {
+	return withContext(ctx, r.DB).Save(book).Error
 }
 
-func (r *BookRepository) DeleteBook(id uint) error {
-	return r.DB.Delete(&model.Book{}, id).Error
+func (r *BookRepository) DeleteBook(ctx context.Context, id uint) error {
+	return withContext(ctx, r.DB).Delete(&model.Book{}, id).Error
+}
+
+func (r *BookRepository) FindByID(ctx context.Context, id uint) (*model.Book, error) {
+	var book model.Book
+	if err := withContext(ctx, r.DB).First(&book, id).Error; err != nil {
+		return nil, err
+	}
+	return &book, nil
 }
 
-func (r *BookRepository) FindByID(id uint) (*model.Book, error) {
+// FindByIDForUpdate loads the book and locks its row until the surrounding
+// transaction ends.
+func (r *BookRepository) FindByIDForUpdate(ctx context.Context, id uint) (*model.Book, error) {
 	var book model.Book
-	if err := r.DB.First(&book, id).Error; err != nil {
+	if err := withContext(ctx, r.DB).Clauses(clause.Locking{Strength: "UPDATE"}).First(&book, id).Error; err != nil {
 		return nil, err
 	}
 	return &book, nil
 }
 
-func (r *BookRepository) FindAll() ([]model.Book, error) {
+// AdjustStock adds delta, which may be negative, to the book's stock. Books
+// removed from the catalog are included, so their stock can still be restored.
+func (r *BookRepository) AdjustStock(ctx context.Context, id uint, delta int) error {
+	result := withContext(ctx, r.DB).Unscoped().Model(&model.Book{}).
+		Where("id = ?", id).
+		Update("stock", gorm.Expr("stock + ?", delta))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *BookRepository) FindAll(ctx context.Context) ([]model.Book, error) {
 	var books []model.Book
-	if err := r.DB.Find(&books).Error; err != nil {
+	if err := fromReplica(withContext(ctx, r.DB)).Find(&books).Error; err != nil {
 		return nil, err
 	}
 	return books, nil
 }
+
+func (r *BookRepository) FindByQuery(ctx context.Context, query BookQuery) ([]model.Book, int64, error) {
+	query.Normalize()
+
+	db := fromReplica(withContext(ctx, r.DB)).Model(&model.Book{})
+	if query.Author != "" {
+		like := "ILIKE"
+		if isSQLite(r.DB) {
+			like = "LIKE" // already case-insensitive
+		}
+		db = db.Where("author "+like+" ? ESCAPE '\\'", containsPattern(query.Author))
+	}
+	if query.MinPrice != nil {
+		db = db.Where("price >= ?", *query.MinPrice)
+	}
+	if query.MaxPrice != nil {
+		db = db.Where("price <= ?", *query.MaxPrice)
+	}
+	if query.InStock {
+		db = db.Where("stock > ?", 0)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// Always tie-break on id so pages are stable
+	if column, ok := bookSortColumns[query.SortBy]; ok {
+		db = db.Order(column + " " + query.Order)
+	}
+	db = db.Order("id " + query.Order)
+
+	var books []model.Book
+	if err := db.Limit(query.Limit).Offset((query.Page - 1) * query.Limit).Find(&books).Error; err != nil {
+		return nil, 0, err
+	}
+	return books, total, nil
+}
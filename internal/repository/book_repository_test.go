@@ -1,6 +1,7 @@
 package repository_test
 
 import (
+	"context"
 	"regexp"
 	"testing"
 	"time"
@@ -10,6 +11,7 @@ import (
 	"github.com/beingaloksharma/book-backend/internal/repository"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
 )
 
 func TestFindBookByID(t *testing.T) {
@@ -24,11 +26,17 @@ func TestFindBookByID(t *testing.T) {
 		WithArgs(id, 1). // ID and Limit
 		WillReturnRows(rows)
 
-	book, err := repo.FindByID(id)
+	book, err := repo.FindByID(context.Background(), id)
 	require.NoError(t, err)
 	require.NotNil(t, book)
 	assert.Equal(t, "Go", book.Title)
 
+	// A cancelled context stops the query before it is sent
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = repo.FindByID(ctx, id)
+	assert.ErrorIs(t, err, context.Canceled)
+
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
@@ -43,13 +51,75 @@ func TestFindAllBooks(t *testing.T) {
 	mock.ExpectQuery(`SELECT .* FROM "books"`).
 		WillReturnRows(rows)
 
-	books, err := repo.FindAll()
+	books, err := repo.FindAll(context.Background())
 	require.NoError(t, err)
 	assert.Len(t, books, 2)
 
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
+func TestFindBooksByQuery(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := &repository.BookRepository{DB: db}
+
+	minPrice := 10.0
+	query := repository.BookQuery{
+		Author:   "Pike",
+		MinPrice: &minPrice,
+		InStock:  true,
+		SortBy:   "price",
+		Order:    "desc",
+		Page:     2,
+		Limit:    10,
+	}
+
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "books" WHERE author ILIKE \$1 ESCAPE '\\' AND price >= .* AND stock > .*`).
+		WithArgs("%Pike%", minPrice, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(12))
+
+	rows := sqlmock.NewRows([]string{"id", "created_at", "updated_at", "deleted_at", "title", "author", "price", "stock"}).
+		AddRow(11, time.Now(), time.Now(), nil, "Go", "Rob Pike", 15.0, 3).
+		AddRow(12, time.Now(), time.Now(), nil, "Plan 9", "Rob Pike", 12.0, 1)
+	mock.ExpectQuery(`SELECT \* FROM "books" WHERE .* ORDER BY price desc,id desc LIMIT .* OFFSET .*`).
+		WithArgs("%Pike%", minPrice, 0, 10, 10).
+		WillReturnRows(rows)
+
+	books, total, err := repo.FindByQuery(context.Background(), query)
+	require.NoError(t, err)
+	assert.Len(t, books, 2)
+	assert.Equal(t, int64(12), total)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFindBooksByQuery_EscapesWildcards(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := &repository.BookRepository{DB: db}
+
+	// The author is matched literally, wildcards and escape character included
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "books" WHERE author ILIKE \$1 ESCAPE '\\'`).
+		WithArgs(`%100\% O\_Brien\\%`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery(`SELECT \* FROM "books"`).
+		WithArgs(`%100\% O\_Brien\\%`, repository.DefaultPageSize).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	_, total, err := repo.FindByQuery(context.Background(), repository.BookQuery{Author: `100% O_Brien\`})
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), total)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBookQueryNormalize(t *testing.T) {
+	query := repository.BookQuery{Page: 0, Limit: 1000, Order: "sideways"}
+	query.Normalize()
+
+	assert.Equal(t, 1, query.Page)
+	assert.Equal(t, repository.MaxPageSize, query.Limit)
+	assert.Equal(t, "asc", query.Order)
+}
+
 func TestCreateBook(t *testing.T) {
 	db, mock := NewMockDB()
 	repo := &repository.BookRepository{DB: db}
@@ -62,7 +132,7 @@ func TestCreateBook(t *testing.T) {
 		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 	mock.ExpectCommit()
 
-	err := repo.CreateBook(book)
+	err := repo.CreateBook(context.Background(), book)
 	assert.NoError(t, err)
 
 	assert.NoError(t, mock.ExpectationsWereMet())
@@ -82,7 +152,7 @@ func TestUpdateBook(t *testing.T) {
 		WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
 
-	err := repo.UpdateBook(book)
+	err := repo.UpdateBook(context.Background(), book)
 	assert.NoError(t, err)
 
 	assert.NoError(t, mock.ExpectationsWereMet())
@@ -100,8 +170,75 @@ func TestDeleteBook(t *testing.T) {
 		WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
 
-	err := repo.DeleteBook(id)
+	err := repo.DeleteBook(context.Background(), id)
+	assert.NoError(t, err)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFindBookByIDForUpdate(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := &repository.BookRepository{DB: db}
+
+	mock.ExpectQuery(`SELECT .* FROM "books" WHERE "books"."id" = .* AND "books"."deleted_at" IS NULL .* FOR UPDATE`).
+		WithArgs(100, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "stock"}).AddRow(100, "Go Book", 8))
+
+	book, err := repo.FindByIDForUpdate(context.Background(), 100)
+	require.NoError(t, err)
+	assert.Equal(t, 8, book.Stock)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAdjustStock(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := &repository.BookRepository{DB: db}
+
+	// Case 1: Stock is changed in place, including for removed books
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "books" SET "stock"=stock + $1,"updated_at"=$2 WHERE id = $3`)).
+		WithArgs(-2, sqlmock.AnyArg(), 100).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err := repo.AdjustStock(context.Background(), 100, -2)
 	assert.NoError(t, err)
 
+	// Case 2: No such book
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "books" SET "stock"=`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	err = repo.AdjustStock(context.Background(), 404, 1)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSearchBooksRanked(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := &repository.BookSearchRepository{DB: db}
+
+	mock.ExpectQuery(`SELECT count\(\*\) FROM books, websearch_to_tsquery\('english', .*\) AS q`).
+		WithArgs("golang").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+	rows := sqlmock.NewRows([]string{"id", "title", "author", "rank", "title_highlight", "snippet"}).
+		AddRow(1, "Golang", "Google", 0.6, "\x02Golang\x03", "Learn <b>\x02golang\x03</b>")
+	mock.ExpectQuery(`SELECT books.\*,.*ts_rank.*ORDER BY rank DESC`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "golang", repository.DefaultPageSize, 0).
+		WillReturnRows(rows)
+
+	results, total, err := repo.Search(context.Background(), repository.BookSearchQuery{Text: "golang"})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, int64(1), total)
+	assert.Equal(t, "Golang", results[0].Book.Title)
+	assert.Equal(t, 0.6, results[0].Rank)
+	assert.Equal(t, "<mark>Golang</mark>", results[0].TitleHighlight)
+	assert.Equal(t, "Learn &lt;b&gt;<mark>golang</mark>&lt;/b&gt;", results[0].Snippet) // book text is escaped
+
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
@@ -0,0 +1,171 @@
+package repository
+
+import (
+	"context"
+	"html"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/beingaloksharma/book-backend/internal/model"
+)
+
+// Field weights mirror the Postgres ts_rank defaults for the A, B and C labels
+// assigned to title, author and description.
+const (
+	titleWeight       = 1.0
+	authorWeight      = 0.4
+	descriptionWeight = 0.2
+)
+
+// InMemoryBookSearchRepository is a BookSearchRepositoryInterface backed by a slice.
+// It approximates the Postgres search (exact, case-insensitive word matches, all
+// terms required) and is intended for unit tests and local development.
+type InMemoryBookSearchRepository struct {
+	mu    sync.RWMutex
+	books []model.Book
+}
+
+func NewInMemoryBookSearchRepository(books ...model.Book) *InMemoryBookSearchRepository {
+	return &InMemoryBookSearchRepository{books: books}
+}
+
+// Index adds or replaces a book in the search index.
+func (r *InMemoryBookSearchRepository) Index(book model.Book) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.books {
+		if r.books[i].ID == book.ID {
+			r.books[i] = book
+			return
+		}
+	}
+	r.books = append(r.books, book)
+}
+
+func (r *InMemoryBookSearchRepository) Search(ctx context.Context, query BookSearchQuery) ([]BookSearchResult, int64, error) {
+	query.Normalize()
+	terms := searchTerms(query.Text)
+
+	r.mu.RLock()
+	var matches []BookSearchResult
+	for _, book := range r.books {
+		if len(terms) == 0 {
+			break
+		}
+		rank, ok := rankBook(book, terms)
+		if !ok {
+			continue
+		}
+		matches = append(matches, BookSearchResult{
+			Book:           book,
+			Rank:           rank,
+			TitleHighlight: highlight(book.Title, terms),
+			Snippet:        highlight(book.Description, terms),
+		})
+	}
+	r.mu.RUnlock()
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Rank != matches[j].Rank {
+			return matches[i].Rank > matches[j].Rank
+		}
+		return matches[i].Book.ID < matches[j].Book.ID
+	})
+
+	total := int64(len(matches))
+	start := (query.Page - 1) * query.Limit
+	if start >= len(matches) {
+		return []BookSearchResult{}, total, nil
+	}
+	end := start + query.Limit
+	if end > len(matches) {
+		end = len(matches)
+	}
+	return matches[start:end], total, nil
+}
+
+// rankBook scores a book against every term and reports false if any term is missing.
+func rankBook(book model.Book, terms []string) (float64, bool) {
+	title, author, description := words(book.Title), words(book.Author), words(book.Description)
+	rank := 0.0
+	for _, term := range terms {
+		score := 0.0
+		if title[term] {
+			score += titleWeight
+		}
+		if author[term] {
+			score += authorWeight
+		}
+		if description[term] {
+			score += descriptionWeight
+		}
+		if score == 0 {
+			return 0, false
+		}
+		rank += score
+	}
+	return rank, true
+}
+
+func searchTerms(text string) []string {
+	seen := map[string]bool{}
+	var terms []string
+	for _, field := range strings.FieldsFunc(strings.ToLower(text), isWordSeparator) {
+		if !seen[field] {
+			seen[field] = true
+			terms = append(terms, field)
+		}
+	}
+	return terms
+}
+
+func words(text string) map[string]bool {
+	set := map[string]bool{}
+	for _, field := range strings.FieldsFunc(strings.ToLower(text), isWordSeparator) {
+		set[field] = true
+	}
+	return set
+}
+
+// highlight HTML-escapes text and wraps every word matching one of terms in the
+// highlight markers.
+func highlight(text string, terms []string) string {
+	match := map[string]bool{}
+	for _, term := range terms {
+		match[term] = true
+	}
+
+	var b strings.Builder
+	start := -1
+	flush := func(end int) {
+		word := text[start:end]
+		if match[strings.ToLower(word)] {
+			b.WriteString(HighlightStart + html.EscapeString(word) + HighlightStop)
+		} else {
+			b.WriteString(html.EscapeString(word))
+		}
+		start = -1
+	}
+	for i, r := range text {
+		if isWordSeparator(r) {
+			if start >= 0 {
+				flush(i)
+			}
+			b.WriteString(html.EscapeString(string(r)))
+			continue
+		}
+		if start < 0 {
+			start = i
+		}
+	}
+	if start >= 0 {
+		flush(len(text))
+	}
+	return b.String()
+}
+
+func isWordSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+}
@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"context"
+	"html"
+	"strings"
+
+	"github.com/beingaloksharma/book-backend/internal/model"
+	"gorm.io/gorm"
+)
+
+// Highlight markers wrapped around matched terms in search snippets. The rest
+// of a snippet is HTML-escaped, so the markers are its only markup.
+const (
+	HighlightStart = "<mark>"
+	HighlightStop  = "</mark>"
+)
+
+// Control characters ts_headline marks matches with, since its markers cannot
+// be told apart from the same text in a book.
+const (
+	headlineStart = "\x02"
+	headlineStop  = "\x03"
+)
+
+// BookSearchQuery holds the free-text query and page requested for a catalog search.
+type BookSearchQuery struct {
+	Text  string
+	Page  int
+	Limit int
+}
+
+// Normalize clamps the page and limit to valid values.
+func (q *BookSearchQuery) Normalize() {
+	normalizePage(&q.Page, &q.Limit)
+}
+
+// BookSearchResult is a matched book with its relevance rank and highlighted fragments.
+type BookSearchResult struct {
+	Book           model.Book `json:"book"`
+	Rank           float64    `json:"rank"`
+	TitleHighlight string     `json:"title_highlight"`
+	Snippet        string     `json:"snippet"`
+}
+
+// BookSearchRepository searches the catalog using the Postgres search_vector column on books.
+// On SQLite, which has no search_vector, it ranks matching books the way
+// InMemoryBookSearchRepository does.
+type BookSearchRepository struct {
+	DB *gorm.DB
+}
+
+func NewBookSearchRepository(db *gorm.DB) *BookSearchRepository {
+	return &BookSearchRepository{DB: db}
+}
+
+type bookSearchRow struct {
+	model.Book
+	Rank           float64
+	TitleHighlight string
+	Snippet        string
+}
+
+func (r *BookSearchRepository) Search(ctx context.Context, query BookSearchQuery) ([]BookSearchResult, int64, error) {
+	query.Normalize()
+	if isSQLite(r.DB) {
+		return r.searchWithoutFullText(ctx, query)
+	}
+
+	var total int64
+	if err := fromReplica(withContext(ctx, r.DB)).Raw(`SELECT count(*) FROM books, websearch_to_tsquery('english', ?) AS q
+		WHERE books.deleted_at IS NULL AND books.search_vector @@ q`, query.Text).
+		Scan(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	headline := "StartSel=" + headlineStart + ", StopSel=" + headlineStop
+	var rows []bookSearchRow
+	if err := fromReplica(withContext(ctx, r.DB)).Raw(`SELECT books.*,
+			ts_rank(books.search_vector, q) AS rank,
+			ts_headline('english', books.title, q, ?) AS title_highlight,
+			ts_headline('english', coalesce(books.description, ''), q, ?) AS snippet
+		FROM books, websearch_to_tsquery('english', ?) AS q
+		WHERE books.deleted_at IS NULL AND books.search_vector @@ q
+		ORDER BY rank DESC, books.id
+		LIMIT ? OFFSET ?`,
+		headline+", HighlightAll=true", headline+", MaxWords=35, MinWords=15",
+		query.Text, query.Limit, (query.Page-1)*query.Limit).
+		Scan(&rows).Error; err != nil {
+		return nil, 0, err
+	}
+
+	results := make([]BookSearchResult, 0, len(rows))
+	for _, row := range rows {
+		results = append(results, BookSearchResult{
+			Book:           row.Book,
+			Rank:           row.Rank,
+			TitleHighlight: fromHeadline(row.TitleHighlight),
+			Snippet:        fromHeadline(row.Snippet),
+		})
+	}
+	return results, total, nil
+}
+
+// fromHeadline escapes a ts_headline fragment and swaps its match markers for
+// the highlight markers.
+func fromHeadline(fragment string) string {
+	return strings.NewReplacer(headlineStart, HighlightStart, headlineStop, HighlightStop).Replace(html.EscapeString(fragment))
+}
+
+// searchWithoutFullText loads the books containing every term of the query
+// and ranks them in memory.
+func (r *BookSearchRepository) searchWithoutFullText(ctx context.Context, query BookSearchQuery) ([]BookSearchResult, int64, error) {
+	terms := searchTerms(query.Text)
+	if len(terms) == 0 {
+		return []BookSearchResult{}, 0, nil
+	}
+
+	db := withContext(ctx, r.DB)
+	for _, term := range terms {
+		// Terms are letters and digits only, so need no escaping
+		pattern := "%" + term + "%"
+		db = db.Where("title LIKE ? OR author LIKE ? OR description LIKE ?", pattern, pattern, pattern)
+	}
+	var books []model.Book
+	if err := db.Find(&books).Error; err != nil {
+		return nil, 0, err
+	}
+	return NewInMemoryBookSearchRepository(books...).Search(ctx, query)
+}
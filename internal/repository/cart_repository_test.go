@@ -1,6 +1,7 @@
 package repository_test
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -36,7 +37,7 @@ func TestFindCartByUserID(t *testing.T) {
 		WithArgs(1).
 		WillReturnRows(sqlmock.NewRows([]string{"id", "cart_id"}))
 
-	cart, err := repo.FindCartByUserID(userID)
+	cart, err := repo.FindCartByUserID(context.Background(), userID)
 	require.NoError(t, err)
 	assert.Equal(t, userID, cart.UserID)
 
@@ -55,7 +56,7 @@ func TestCreateCart(t *testing.T) {
 		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 	mock.ExpectCommit()
 
-	err := repo.CreateCart(cart)
+	err := repo.CreateCart(context.Background(), cart)
 	assert.NoError(t, err)
 }
 
@@ -63,15 +64,15 @@ func TestAddItem(t *testing.T) {
 	db, mock := NewMockDB()
 	repo := &repository.CartRepository{DB: db}
 
-	item := &model.CartItem{CartID: 1, BookID: 2, Quantity: 1}
+	item := &model.CartItem{CartID: 1, BookID: 2, Quantity: 1, PriceAtAdd: 9.99}
 
 	mock.ExpectBegin()
 	mock.ExpectQuery(`INSERT INTO "cart_items"`).
-		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), item.CartID, item.BookID, item.Quantity).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), item.CartID, item.BookID, item.Quantity, item.PriceAtAdd).
 		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 	mock.ExpectCommit()
 
-	err := repo.AddItem(item)
+	err := repo.AddItem(context.Background(), item)
 	assert.NoError(t, err)
 }
 
@@ -89,7 +90,7 @@ func TestFindItem(t *testing.T) {
 		WithArgs(cartID, bookID, 1).
 		WillReturnRows(rows)
 
-	item, err := repo.FindItem(cartID, bookID)
+	item, err := repo.FindItem(context.Background(), cartID, bookID)
 	require.NoError(t, err)
 	assert.Equal(t, 5, item.Quantity)
 }
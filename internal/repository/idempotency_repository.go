@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/beingaloksharma/book-backend/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type IdempotencyRepository struct {
+	DB *gorm.DB
+}
+
+func NewIdempotencyRepository(db *gorm.DB) *IdempotencyRepository {
+	return &IdempotencyRepository{DB: db}
+}
+
+func (r *IdempotencyRepository) FindByKey(ctx context.Context, userID uint, key string) (*model.IdempotencyKey, error) {
+	var record model.IdempotencyKey
+	if err := withContext(ctx, r.DB).Where("user_id = ? AND idempotency_key = ?", userID, key).First(&record).Error; err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// Create inserts the record unless the user already holds the key, reporting
+// whether this call claimed it.
+func (r *IdempotencyRepository) Create(ctx context.Context, record *model.IdempotencyKey) (bool, error) {
+	result := withContext(ctx, r.DB).Clauses(clause.OnConflict{DoNothing: true}).Create(record)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *IdempotencyRepository) SaveResponse(ctx context.Context, record *model.IdempotencyKey) error {
+	return withContext(ctx, r.DB).Model(record).Updates(map[string]interface{}{
+		"response_code": record.ResponseCode,
+		"response_body": record.ResponseBody,
+	}).Error
+}
+
+// Delete removes the record permanently so the key can be claimed again.
+func (r *IdempotencyRepository) Delete(ctx context.Context, id uint) error {
+	return withContext(ctx, r.DB).Unscoped().Delete(&model.IdempotencyKey{}, id).Error
+}
@@ -0,0 +1,57 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/beingaloksharma/book-backend/internal/model"
+	"github.com/beingaloksharma/book-backend/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindIdempotencyKey(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := &repository.IdempotencyRepository{DB: db}
+
+	rows := sqlmock.NewRows([]string{"id", "user_id", "idempotency_key", "response_code"}).
+		AddRow(1, 1, "abc", 200)
+
+	mock.ExpectQuery(`SELECT .* FROM "idempotency_keys" WHERE .*user_id = .* AND idempotency_key =`).
+		WithArgs(1, "abc", 1).
+		WillReturnRows(rows)
+
+	record, err := repo.FindByKey(context.Background(), 1, "abc")
+	require.NoError(t, err)
+	assert.Equal(t, 200, record.ResponseCode)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateIdempotencyKey(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := &repository.IdempotencyRepository{DB: db}
+
+	// Case 1: Key claimed
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "idempotency_keys" .* ON CONFLICT DO NOTHING`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	claimed, err := repo.Create(context.Background(), &model.IdempotencyKey{UserID: 1, Key: "abc"})
+	require.NoError(t, err)
+	assert.True(t, claimed)
+
+	// Case 2: Key already held
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "idempotency_keys" .* ON CONFLICT DO NOTHING`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectCommit()
+
+	claimed, err = repo.Create(context.Background(), &model.IdempotencyKey{UserID: 1, Key: "abc"})
+	require.NoError(t, err)
+	assert.False(t, claimed)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -1,37 +1,123 @@
 package repository
 
-import "github.com/beingaloksharma/book-backend/internal/model"
+import (
+	"context"
+	"time"
+
+	"github.com/beingaloksharma/book-backend/internal/model"
+)
 
 type UserRepositoryInterface interface {
-	CreateUser(user *model.User) error
-	FindByEmail(email string) (*model.User, error)
-	FindByID(id uint) (*model.User, error)
-	AddAddress(address *model.Address) error
-	GetAddresses(userID uint) ([]model.Address, error)
-	FindAllUsers() ([]model.User, error)
+	CreateUser(ctx context.Context, user *model.User) error
+	FindByEmail(ctx context.Context, email string) (*model.User, error)
+	FindByID(ctx context.Context, id uint) (*model.User, error)
+	AddAddress(ctx context.Context, address *model.Address) error
+	GetAddresses(ctx context.Context, userID uint) ([]model.Address, error)
+	FindAddress(ctx context.Context, userID, addressID uint) (*model.Address, error)
+	UpdateAddress(ctx context.Context, address *model.Address) error
+	DeleteAddress(ctx context.Context, userID, addressID uint) error
+	FindAllUsers(ctx context.Context) ([]model.User, error)
+	UpdateRole(ctx context.Context, userID uint, from, to model.Role, history *model.UserRoleHistory) error
+	FindRoleHistory(ctx context.Context, userID uint) ([]model.UserRoleHistory, error)
+	CountByRole(ctx context.Context, role model.Role) (int64, error)
+	CountByRoleForUpdate(ctx context.Context, role model.Role) (int64, error)
+	UpdatePassword(ctx context.Context, userID uint, passwordHash string) error
+	MarkVerified(ctx context.Context, userID uint) error
+	UpdateProfile(ctx context.Context, user *model.User) error
+	Anonymize(ctx context.Context, userID uint) error
 }
 
 type BookRepositoryInterface interface {
-	CreateBook(book *model.Book) error
-	UpdateBook(book *model.Book) error
-	DeleteBook(id uint) error
-	FindByID(id uint) (*model.Book, error)
-	FindAll() ([]model.Book, error)
+	CreateBook(ctx context.Context, book *model.Book) error
+	UpdateBook(ctx context.Context, book *model.Book) error
+	DeleteBook(ctx context.Context, id uint) error
+	FindByID(ctx context.Context, id uint) (*model.Book, error)
+	FindByIDForUpdate(ctx context.Context, id uint) (*model.Book, error)
+	AdjustStock(ctx context.Context, id uint, delta int) error
+	FindAll(ctx context.Context) ([]model.Book, error)
+	FindByQuery(ctx context.Context, query BookQuery) ([]model.Book, int64, error)
+}
+
+type BookSearchRepositoryInterface interface {
+	Search(ctx context.Context, query BookSearchQuery) ([]BookSearchResult, int64, error)
 }
 
 type CartRepositoryInterface interface {
-	FindCartByUserID(userID uint) (*model.Cart, error)
-	CreateCart(cart *model.Cart) error
-	AddItem(item *model.CartItem) error
-	UpdateItem(item *model.CartItem) error
-	RemoveItem(itemID uint) error
-	ClearCart(cartID uint) error
-	FindItem(cartID, bookID uint) (*model.CartItem, error)
+	FindCartByUserID(ctx context.Context, userID uint) (*model.Cart, error)
+	CreateCart(ctx context.Context, cart *model.Cart) error
+	AddItem(ctx context.Context, item *model.CartItem) error
+	UpdateItem(ctx context.Context, item *model.CartItem) error
+	RemoveItem(ctx context.Context, itemID uint) error
+	ClearCart(ctx context.Context, cartID uint) error
+	FindItem(ctx context.Context, cartID, bookID uint) (*model.CartItem, error)
 }
 
 type OrderRepositoryInterface interface {
-	CreateOrder(order *model.Order) error
-	FindByUserID(userID uint) ([]model.Order, error)
-	FindAllOrders() ([]model.Order, error)
-	PlaceOrderTransaction(order *model.Order, cartItems []model.CartItem, cartID uint) error
+	CreateOrder(ctx context.Context, order *model.Order) error
+	FindByUserID(ctx context.Context, userID uint) ([]model.Order, error)
+	FindAllOrders(ctx context.Context) ([]model.Order, error)
+	FindByID(ctx context.Context, id uint) (*model.Order, error)
+	FindByIDForUpdate(ctx context.Context, id uint) (*model.Order, error)
+	UpdateStatus(ctx context.Context, orderID uint, from, to model.OrderStatus, history *model.OrderStatusHistory) error
+	FindStatusHistory(ctx context.Context, orderID uint) ([]model.OrderStatusHistory, error)
+}
+
+type IdempotencyRepositoryInterface interface {
+	FindByKey(ctx context.Context, userID uint, key string) (*model.IdempotencyKey, error)
+	Create(ctx context.Context, record *model.IdempotencyKey) (bool, error)
+	SaveResponse(ctx context.Context, record *model.IdempotencyKey) error
+	Delete(ctx context.Context, id uint) error
+}
+
+type RefreshTokenRepositoryInterface interface {
+	Create(ctx context.Context, token *model.RefreshToken) error
+	FindByHash(ctx context.Context, hash string) (*model.RefreshToken, error)
+	Rotate(ctx context.Context, current, next *model.RefreshToken) error
+	RevokeFamily(ctx context.Context, familyID string) error
+	RevokeAllForUser(ctx context.Context, userID uint) error
+	IsFamilyActive(ctx context.Context, familyID string) (bool, error)
+}
+
+type RoleRepositoryInterface interface {
+	FindAll(ctx context.Context) ([]model.RoleDefinition, error)
+	FindByName(ctx context.Context, name model.Role) (*model.RoleDefinition, error)
+	Create(ctx context.Context, role *model.RoleDefinition) error
+	SetPermissions(ctx context.Context, name model.Role, permissions []model.Permission) error
+	Delete(ctx context.Context, name model.Role) error
+	HasPermission(ctx context.Context, role model.Role, permission model.Permission) (bool, error)
+}
+
+type OneTimeTokenRepositoryInterface interface {
+	Issue(ctx context.Context, token *model.OneTimeToken) error
+	FindByHash(ctx context.Context, purpose model.TokenPurpose, hash string) (*model.OneTimeToken, error)
+	Consume(ctx context.Context, token *model.OneTimeToken) error
+}
+
+type LoginAttemptRepositoryInterface interface {
+	Find(ctx context.Context, subject string) (*model.LoginAttempt, error)
+	RecordFailure(ctx context.Context, subject string, window time.Duration) (*model.LoginAttempt, error)
+	Lock(ctx context.Context, subject string, until time.Time) error
+	Reset(ctx context.Context, subject string) error
+}
+
+type RateLimitRepositoryInterface interface {
+	Take(ctx context.Context, subject string, rate float64, burst int) (*model.RateLimitBucket, bool, error)
+}
+
+type AuditRepositoryInterface interface {
+	Record(ctx context.Context, event *model.AuditEvent) error
+	FindRecent(ctx context.Context, limit int) ([]model.AuditEvent, error)
+}
+
+type MFARepositoryInterface interface {
+	SetSecret(ctx context.Context, userID uint, secret string) error
+	Enable(ctx context.Context, userID uint, step int64, codes []model.MFARecoveryCode) error
+	Disable(ctx context.Context, userID uint) error
+	AcceptStep(ctx context.Context, userID uint, step int64) error
+	ConsumeRecoveryCode(ctx context.Context, userID uint, codeHash string) error
+	ReplaceRecoveryCodes(ctx context.Context, userID uint, codes []model.MFARecoveryCode) error
+}
+
+type TransactionManagerInterface interface {
+	WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error
 }
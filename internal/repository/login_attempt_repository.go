@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/beingaloksharma/book-backend/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LoginAttemptRepository keeps failed-login counters in the database, so every
+// server instance sees the same counts.
+type LoginAttemptRepository struct {
+	DB *gorm.DB
+}
+
+func NewLoginAttemptRepository(db *gorm.DB) *LoginAttemptRepository {
+	return &LoginAttemptRepository{DB: db}
+}
+
+func (r *LoginAttemptRepository) Find(ctx context.Context, subject string) (*model.LoginAttempt, error) {
+	var attempt model.LoginAttempt
+	if err := withContext(ctx, r.DB).Where("subject = ?", subject).First(&attempt).Error; err != nil {
+		return nil, err
+	}
+	return &attempt, nil
+}
+
+// RecordFailure counts one more failure for subject. The count starts over
+// when the previous failure is older than window.
+func (r *LoginAttemptRepository) RecordFailure(ctx context.Context, subject string, window time.Duration) (*model.LoginAttempt, error) {
+	now := time.Now()
+	// A single upsert so concurrent failures are all counted, the first ones
+	// included
+	err := withContext(ctx, r.DB).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "subject"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"failures":     gorm.Expr("CASE WHEN login_attempts.last_failure < ? THEN 1 ELSE login_attempts.failures + 1 END", now.Add(-window)),
+			"last_failure": now,
+		}),
+	}).Create(&model.LoginAttempt{Subject: subject, Failures: 1, LastFailure: now}).Error
+	if err != nil {
+		return nil, err
+	}
+	return r.Find(ctx, subject)
+}
+
+func (r *LoginAttemptRepository) Lock(ctx context.Context, subject string, until time.Time) error {
+	return withContext(ctx, r.DB).Model(&model.LoginAttempt{}).Where("subject = ?", subject).Update("locked_until", until).Error
+}
+
+// Reset forgets the failures and any lockout of subject.
+func (r *LoginAttemptRepository) Reset(ctx context.Context, subject string) error {
+	return withContext(ctx, r.DB).Where("subject = ?", subject).Delete(&model.LoginAttempt{}).Error
+}
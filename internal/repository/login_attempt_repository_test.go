@@ -0,0 +1,99 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/beingaloksharma/book-backend/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestLoginAttemptRecordFailure(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := &repository.LoginAttemptRepository{DB: db}
+	columns := []string{"subject", "failures", "last_failure", "locked_until"}
+
+	// Case 1: One upsert creates the counter or increments it in place
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "login_attempts" \("subject","failures","last_failure","locked_until"\) VALUES \(\$1,\$2,\$3,\$4\) ` +
+		`ON CONFLICT \("subject"\) DO UPDATE SET "failures"=CASE WHEN login_attempts.last_failure < \$5 THEN 1 ELSE login_attempts.failures \+ 1 END,"last_failure"=\$6`).
+		WithArgs("account:john@example.com", 1, sqlmock.AnyArg(), nil, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery(`SELECT \* FROM "login_attempts" WHERE subject = \$1`).
+		WithArgs("account:john@example.com", 1).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow("account:john@example.com", 3, time.Now(), nil))
+
+	attempt, err := repo.RecordFailure(context.Background(), "account:john@example.com", time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, 3, attempt.Failures)
+
+	// Case 2: Failure to write
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "login_attempts"`).
+		WillReturnError(errors.New("db down"))
+	mock.ExpectRollback()
+
+	_, err = repo.RecordFailure(context.Background(), "ip:10.0.0.1", time.Hour)
+	assert.Error(t, err)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLoginAttemptLockAndReset(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := &repository.LoginAttemptRepository{DB: db}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "login_attempts" SET "locked_until"=\$1 WHERE subject = \$2`).
+		WithArgs(sqlmock.AnyArg(), "account:john@example.com").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	require.NoError(t, repo.Lock(context.Background(), "account:john@example.com", time.Now().Add(time.Minute)))
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM "login_attempts" WHERE subject = \$1`).
+		WithArgs("account:john@example.com").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	require.NoError(t, repo.Reset(context.Background(), "account:john@example.com"))
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMemoryLoginAttemptRepository(t *testing.T) {
+	repo := repository.NewMemoryLoginAttemptRepository()
+
+	// Case 1: Unknown subject
+	_, err := repo.Find(context.Background(), "account:john@example.com")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	// Case 2: Failures add up within the window
+	_, err = repo.RecordFailure(context.Background(), "account:john@example.com", time.Hour)
+	require.NoError(t, err)
+	attempt, err := repo.RecordFailure(context.Background(), "account:john@example.com", time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, 2, attempt.Failures)
+
+	// Case 3: Failures older than the window are forgotten
+	time.Sleep(2 * time.Millisecond)
+	attempt, err = repo.RecordFailure(context.Background(), "account:john@example.com", time.Millisecond)
+	require.NoError(t, err)
+	assert.Equal(t, 1, attempt.Failures)
+
+	// Case 4: Lock and reset
+	until := time.Now().Add(time.Minute)
+	require.NoError(t, repo.Lock(context.Background(), "account:john@example.com", until))
+	attempt, err = repo.Find(context.Background(), "account:john@example.com")
+	require.NoError(t, err)
+	assert.True(t, attempt.LockedUntil.Equal(until))
+
+	require.NoError(t, repo.Reset(context.Background(), "account:john@example.com"))
+	_, err = repo.Find(context.Background(), "account:john@example.com")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
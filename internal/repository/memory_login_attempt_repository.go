@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/beingaloksharma/book-backend/internal/model"
+	"gorm.io/gorm"
+)
+
+// maxMemoryLoginAttempts is how many subjects the in-memory store holds before
+// it drops the ones whose failures have aged out.
+const maxMemoryLoginAttempts = 10000
+
+// MemoryLoginAttemptRepository keeps failed-login counters in process memory.
+// It suits a single server instance; counts are lost on restart.
+type MemoryLoginAttemptRepository struct {
+	mu       sync.Mutex
+	attempts map[string]model.LoginAttempt
+}
+
+func NewMemoryLoginAttemptRepository() *MemoryLoginAttemptRepository {
+	return &MemoryLoginAttemptRepository{attempts: make(map[string]model.LoginAttempt)}
+}
+
+func (r *MemoryLoginAttemptRepository) Find(ctx context.Context, subject string) (*model.LoginAttempt, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	attempt, ok := r.attempts[subject]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &attempt, nil
+}
+
+func (r *MemoryLoginAttemptRepository) RecordFailure(ctx context.Context, subject string, window time.Duration) (*model.LoginAttempt, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	if len(r.attempts) >= maxMemoryLoginAttempts {
+		r.prune(now.Add(-window))
+	}
+
+	attempt, ok := r.attempts[subject]
+	if !ok || attempt.LastFailure.Before(now.Add(-window)) {
+		attempt.Subject = subject
+		attempt.Failures = 0
+	}
+	attempt.Failures++
+	attempt.LastFailure = now
+	r.attempts[subject] = attempt
+	return &attempt, nil
+}
+
+func (r *MemoryLoginAttemptRepository) Lock(ctx context.Context, subject string, until time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if attempt, ok := r.attempts[subject]; ok {
+		attempt.LockedUntil = &until
+		r.attempts[subject] = attempt
+	}
+	return nil
+}
+
+func (r *MemoryLoginAttemptRepository) Reset(ctx context.Context, subject string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.attempts, subject)
+	return nil
+}
+
+// prune drops subjects that failed last before cutoff and are not locked.
+func (r *MemoryLoginAttemptRepository) prune(cutoff time.Time) {
+	now := time.Now()
+	for subject, attempt := range r.attempts {
+		locked := attempt.LockedUntil != nil && attempt.LockedUntil.After(now)
+		if !locked && attempt.LastFailure.Before(cutoff) {
+			delete(r.attempts, subject)
+		}
+	}
+}
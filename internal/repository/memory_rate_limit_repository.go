@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/beingaloksharma/book-backend/internal/model"
+)
+
+// MemoryRateLimitPruneInterval is how often PruneEvery is meant to run for the
+// server's store.
+const MemoryRateLimitPruneInterval = time.Minute
+
+// MemoryRateLimitRepository keeps rate limit buckets in process memory. Each
+// server instance limits on its own; buckets are lost on restart. Buckets that
+// have refilled are only dropped by Prune, so run PruneEvery alongside it.
+type MemoryRateLimitRepository struct {
+	mu      sync.Mutex
+	buckets map[string]model.RateLimitBucket
+}
+
+func NewMemoryRateLimitRepository() *MemoryRateLimitRepository {
+	return &MemoryRateLimitRepository{buckets: make(map[string]model.RateLimitBucket)}
+}
+
+func (r *MemoryRateLimitRepository) Take(ctx context.Context, subject string, rate float64, burst int) (*model.RateLimitBucket, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	bucket, ok := r.buckets[subject]
+	if !ok {
+		bucket = model.RateLimitBucket{Subject: subject, Tokens: float64(burst), RefilledAt: now}
+	}
+
+	allowed := bucket.Take(now, rate, burst)
+	r.buckets[subject] = bucket
+	return &bucket, allowed, nil
+}
+
+// Prune drops the buckets that are full by now; a new bucket starts full, so
+// forgetting them changes nothing. It reports how many it dropped.
+func (r *MemoryRateLimitRepository) Prune(now time.Time) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	pruned := 0
+	for subject, bucket := range r.buckets {
+		if !bucket.FullAt.After(now) {
+			delete(r.buckets, subject)
+			pruned++
+		}
+	}
+	return pruned
+}
+
+// PruneEvery calls Prune every interval until ctx is done.
+func (r *MemoryRateLimitRepository) PruneEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			r.Prune(now)
+		}
+	}
+}
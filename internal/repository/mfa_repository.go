@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/beingaloksharma/book-backend/internal/model"
+	"gorm.io/gorm"
+)
+
+// MFARepository stores the TOTP state kept on users and their recovery codes.
+type MFARepository struct {
+	DB *gorm.DB
+}
+
+func NewMFARepository(db *gorm.DB) *MFARepository {
+	return &MFARepository{DB: db}
+}
+
+// SetSecret starts an enrollment with a new secret. It does not turn MFA on.
+func (r *MFARepository) SetSecret(ctx context.Context, userID uint, secret string) error {
+	return withContext(ctx, r.DB).Model(&model.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"mfa_secret":     secret,
+		"mfa_enabled_at": nil,
+		"mfa_last_step":  0,
+	}).Error
+}
+
+// Enable turns MFA on, records step as used and replaces the recovery codes.
+func (r *MFARepository) Enable(ctx context.Context, userID uint, step int64, codes []model.MFARecoveryCode) error {
+	return withContext(ctx, r.DB).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&model.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"mfa_enabled_at": time.Now(),
+			"mfa_last_step":  step,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return replaceRecoveryCodes(tx, userID, codes)
+	})
+}
+
+// Disable turns MFA off and forgets the secret and recovery codes.
+func (r *MFARepository) Disable(ctx context.Context, userID uint) error {
+	return withContext(ctx, r.DB).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&model.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"mfa_secret":     "",
+			"mfa_enabled_at": nil,
+			"mfa_last_step":  0,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&model.MFARecoveryCode{}).Error
+	})
+}
+
+// AcceptStep records a TOTP time step as used. It returns ErrTokenAlreadyUsed
+// if that step or a later one was accepted before.
+func (r *MFARepository) AcceptStep(ctx context.Context, userID uint, step int64) error {
+	result := withContext(ctx, r.DB).Model(&model.User{}).
+		Where("id = ? AND mfa_last_step < ?", userID, step).
+		Update("mfa_last_step", step)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrTokenAlreadyUsed
+	}
+	return nil
+}
+
+// ConsumeRecoveryCode marks one of the user's unused codes as used. It returns
+// gorm.ErrRecordNotFound if no unused code has that hash.
+func (r *MFARepository) ConsumeRecoveryCode(ctx context.Context, userID uint, codeHash string) error {
+	result := withContext(ctx, r.DB).Model(&model.MFARecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *MFARepository) ReplaceRecoveryCodes(ctx context.Context, userID uint, codes []model.MFARecoveryCode) error {
+	return withContext(ctx, r.DB).Transaction(func(tx *gorm.DB) error {
+		return replaceRecoveryCodes(tx, userID, codes)
+	})
+}
+
+func replaceRecoveryCodes(tx *gorm.DB, userID uint, codes []model.MFARecoveryCode) error {
+	if err := tx.Where("user_id = ?", userID).Delete(&model.MFARecoveryCode{}).Error; err != nil {
+		return err
+	}
+	if len(codes) == 0 {
+		return nil
+	}
+	return tx.Create(&codes).Error
+}
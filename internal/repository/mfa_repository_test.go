@@ -0,0 +1,82 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/beingaloksharma/book-backend/internal/model"
+	"github.com/beingaloksharma/book-backend/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestMFAEnable(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := &repository.MFARepository{DB: db}
+
+	codes := []model.MFARecoveryCode{{UserID: 1, CodeHash: "h1"}, {UserID: 1, CodeHash: "h2"}}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "users" SET "mfa_enabled_at"=\$1,"mfa_last_step"=\$2,"updated_at"=\$3 WHERE id = \$4`).
+		WithArgs(sqlmock.AnyArg(), int64(42), sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM "mfa_recovery_codes" WHERE user_id = \$1`).
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`INSERT INTO "mfa_recovery_codes"`).
+		WithArgs(1, "h1", nil, sqlmock.AnyArg(), 1, "h2", nil, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+	mock.ExpectCommit()
+
+	assert.NoError(t, repo.Enable(context.Background(), 1, 42, codes))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMFAAcceptStep(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := &repository.MFARepository{DB: db}
+
+	// Case 1: A newer step is recorded
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "users" SET "mfa_last_step"=\$1,"updated_at"=\$2 WHERE \(id = \$3 AND mfa_last_step < \$4\)`).
+		WithArgs(int64(100), sqlmock.AnyArg(), 1, int64(100)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	assert.NoError(t, repo.AcceptStep(context.Background(), 1, 100))
+
+	// Case 2: A step already used is refused
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "users" SET "mfa_last_step"`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	assert.ErrorIs(t, repo.AcceptStep(context.Background(), 1, 100), repository.ErrTokenAlreadyUsed)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMFAConsumeRecoveryCode(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := &repository.MFARepository{DB: db}
+
+	// Case 1: Unused code is marked used
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "mfa_recovery_codes" SET "used_at"=\$1 WHERE user_id = \$2 AND code_hash = \$3 AND used_at IS NULL`).
+		WithArgs(sqlmock.AnyArg(), 1, "hash").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	assert.NoError(t, repo.ConsumeRecoveryCode(context.Background(), 1, "hash"))
+
+	// Case 2: Unknown or used code
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "mfa_recovery_codes"`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	assert.ErrorIs(t, repo.ConsumeRecoveryCode(context.Background(), 1, "hash"), gorm.ErrRecordNotFound)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
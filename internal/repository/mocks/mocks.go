@@ -1,7 +1,10 @@
 package mocks
 
 import (
+	"context"
+
 	"github.com/beingaloksharma/book-backend/internal/model"
+	"github.com/beingaloksharma/book-backend/internal/repository"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -10,100 +13,168 @@ type MockUserRepository struct {
 	mock.Mock
 }
 
-func (m *MockUserRepository) CreateUser(user *model.User) error {
-	args := m.Called(user)
+func (m *MockUserRepository) CreateUser(ctx context.Context, user *model.User) error {
+	args := m.Called(ctx, user)
 	return args.Error(0)
 }
-func (m *MockUserRepository) FindByEmail(email string) (*model.User, error) {
-	args := m.Called(email)
+func (m *MockUserRepository) FindByEmail(ctx context.Context, email string) (*model.User, error) {
+	args := m.Called(ctx, email)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*model.User), args.Error(1)
 }
-func (m *MockUserRepository) FindByID(id uint) (*model.User, error) {
-	args := m.Called(id)
+func (m *MockUserRepository) FindByID(ctx context.Context, id uint) (*model.User, error) {
+	args := m.Called(ctx, id)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*model.User), args.Error(1)
 }
-func (m *MockUserRepository) AddAddress(address *model.Address) error {
-	args := m.Called(address)
+func (m *MockUserRepository) AddAddress(ctx context.Context, address *model.Address) error {
+	args := m.Called(ctx, address)
 	return args.Error(0)
 }
-func (m *MockUserRepository) GetAddresses(userID uint) ([]model.Address, error) {
-	args := m.Called(userID)
+func (m *MockUserRepository) GetAddresses(ctx context.Context, userID uint) ([]model.Address, error) {
+	args := m.Called(ctx, userID)
 	return args.Get(0).([]model.Address), args.Error(1)
 }
-func (m *MockUserRepository) FindAllUsers() ([]model.User, error) {
-	args := m.Called()
+func (m *MockUserRepository) FindAddress(ctx context.Context, userID, addressID uint) (*model.Address, error) {
+	args := m.Called(ctx, userID, addressID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Address), args.Error(1)
+}
+func (m *MockUserRepository) UpdateAddress(ctx context.Context, address *model.Address) error {
+	args := m.Called(ctx, address)
+	return args.Error(0)
+}
+func (m *MockUserRepository) DeleteAddress(ctx context.Context, userID, addressID uint) error {
+	args := m.Called(ctx, userID, addressID)
+	return args.Error(0)
+}
+func (m *MockUserRepository) FindAllUsers(ctx context.Context) ([]model.User, error) {
+	args := m.Called(ctx)
 	return args.Get(0).([]model.User), args.Error(1)
 }
+func (m *MockUserRepository) UpdateRole(ctx context.Context, userID uint, from, to model.Role, history *model.UserRoleHistory) error {
+	args := m.Called(ctx, userID, from, to, history)
+	return args.Error(0)
+}
+func (m *MockUserRepository) FindRoleHistory(ctx context.Context, userID uint) ([]model.UserRoleHistory, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.UserRoleHistory), args.Error(1)
+}
+func (m *MockUserRepository) UpdatePassword(ctx context.Context, userID uint, passwordHash string) error {
+	args := m.Called(ctx, userID, passwordHash)
+	return args.Error(0)
+}
+func (m *MockUserRepository) MarkVerified(ctx context.Context, userID uint) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+func (m *MockUserRepository) UpdateProfile(ctx context.Context, user *model.User) error {
+	args := m.Called(ctx, user)
+	return args.Error(0)
+}
+func (m *MockUserRepository) Anonymize(ctx context.Context, userID uint) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+func (m *MockUserRepository) CountByRole(ctx context.Context, role model.Role) (int64, error) {
+	args := m.Called(ctx, role)
+	return args.Get(0).(int64), args.Error(1)
+}
+func (m *MockUserRepository) CountByRoleForUpdate(ctx context.Context, role model.Role) (int64, error) {
+	args := m.Called(ctx, role)
+	return args.Get(0).(int64), args.Error(1)
+}
 
 // MockBookRepository
 type MockBookRepository struct {
 	mock.Mock
 }
 
-func (m *MockBookRepository) CreateBook(book *model.Book) error {
-	args := m.Called(book)
+func (m *MockBookRepository) CreateBook(ctx context.Context, book *model.Book) error {
+	args := m.Called(ctx, book)
 	return args.Error(0)
 }
-func (m *MockBookRepository) UpdateBook(book *model.Book) error {
-	args := m.Called(book)
+func (m *MockBookRepository) UpdateBook(ctx context.Context, book *model.Book) error {
+	args := m.Called(ctx, book)
 	return args.Error(0)
 }
-func (m *MockBookRepository) DeleteBook(id uint) error {
-	args := m.Called(id)
+func (m *MockBookRepository) DeleteBook(ctx context.Context, id uint) error {
+	args := m.Called(ctx, id)
 	return args.Error(0)
 }
-func (m *MockBookRepository) FindByID(id uint) (*model.Book, error) {
-	args := m.Called(id)
+func (m *MockBookRepository) FindByID(ctx context.Context, id uint) (*model.Book, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Book), args.Error(1)
+}
+func (m *MockBookRepository) FindByIDForUpdate(ctx context.Context, id uint) (*model.Book, error) {
+	args := m.Called(ctx, id)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*model.Book), args.Error(1)
 }
-func (m *MockBookRepository) FindAll() ([]model.Book, error) {
-	args := m.Called()
+func (m *MockBookRepository) AdjustStock(ctx context.Context, id uint, delta int) error {
+	args := m.Called(ctx, id, delta)
+	return args.Error(0)
+}
+func (m *MockBookRepository) FindAll(ctx context.Context) ([]model.Book, error) {
+	args := m.Called(ctx)
 	return args.Get(0).([]model.Book), args.Error(1)
 }
+func (m *MockBookRepository) FindByQuery(ctx context.Context, query repository.BookQuery) ([]model.Book, int64, error) {
+	args := m.Called(ctx, query)
+	if args.Get(0) == nil {
+		return nil, 0, args.Error(2)
+	}
+	return args.Get(0).([]model.Book), args.Get(1).(int64), args.Error(2)
+}
 
 // MockCartRepository
 type MockCartRepository struct {
 	mock.Mock
 }
 
-func (m *MockCartRepository) FindCartByUserID(userID uint) (*model.Cart, error) {
-	args := m.Called(userID)
+func (m *MockCartRepository) FindCartByUserID(ctx context.Context, userID uint) (*model.Cart, error) {
+	args := m.Called(ctx, userID)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*model.Cart), args.Error(1)
 }
-func (m *MockCartRepository) CreateCart(cart *model.Cart) error {
-	args := m.Called(cart)
+func (m *MockCartRepository) CreateCart(ctx context.Context, cart *model.Cart) error {
+	args := m.Called(ctx, cart)
 	return args.Error(0)
 }
-func (m *MockCartRepository) AddItem(item *model.CartItem) error {
-	args := m.Called(item)
+func (m *MockCartRepository) AddItem(ctx context.Context, item *model.CartItem) error {
+	args := m.Called(ctx, item)
 	return args.Error(0)
 }
-func (m *MockCartRepository) UpdateItem(item *model.CartItem) error {
-	args := m.Called(item)
+func (m *MockCartRepository) UpdateItem(ctx context.Context, item *model.CartItem) error {
+	args := m.Called(ctx, item)
 	return args.Error(0)
 }
-func (m *MockCartRepository) RemoveItem(itemID uint) error {
-	args := m.Called(itemID)
+func (m *MockCartRepository) RemoveItem(ctx context.Context, itemID uint) error {
+	args := m.Called(ctx, itemID)
 	return args.Error(0)
 }
-func (m *MockCartRepository) ClearCart(cartID uint) error {
-	args := m.Called(cartID)
+func (m *MockCartRepository) ClearCart(ctx context.Context, cartID uint) error {
+	args := m.Called(ctx, cartID)
 	return args.Error(0)
 }
-func (m *MockCartRepository) FindItem(cartID, bookID uint) (*model.CartItem, error) {
-	args := m.Called(cartID, bookID)
+func (m *MockCartRepository) FindItem(ctx context.Context, cartID, bookID uint) (*model.CartItem, error) {
+	args := m.Called(ctx, cartID, bookID)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
@@ -115,22 +186,231 @@ type MockOrderRepository struct {
 	mock.Mock
 }
 
-func (m *MockOrderRepository) CreateOrder(order *model.Order) error {
-	args := m.Called(order)
+func (m *MockOrderRepository) CreateOrder(ctx context.Context, order *model.Order) error {
+	args := m.Called(ctx, order)
 	return args.Error(0)
 }
-func (m *MockOrderRepository) FindByUserID(userID uint) ([]model.Order, error) {
-	args := m.Called(userID)
+func (m *MockOrderRepository) FindByUserID(ctx context.Context, userID uint) ([]model.Order, error) {
+	args := m.Called(ctx, userID)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).([]model.Order), args.Error(1)
 }
-func (m *MockOrderRepository) FindAllOrders() ([]model.Order, error) {
-	args := m.Called()
+func (m *MockOrderRepository) FindAllOrders(ctx context.Context) ([]model.Order, error) {
+	args := m.Called(ctx)
 	return args.Get(0).([]model.Order), args.Error(1)
 }
-func (m *MockOrderRepository) PlaceOrderTransaction(order *model.Order, cartItems []model.CartItem, cartID uint) error {
-	args := m.Called(order, cartItems, cartID)
+func (m *MockOrderRepository) FindByID(ctx context.Context, id uint) (*model.Order, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Order), args.Error(1)
+}
+func (m *MockOrderRepository) FindByIDForUpdate(ctx context.Context, id uint) (*model.Order, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Order), args.Error(1)
+}
+func (m *MockOrderRepository) UpdateStatus(ctx context.Context, orderID uint, from, to model.OrderStatus, history *model.OrderStatusHistory) error {
+	args := m.Called(ctx, orderID, from, to, history)
+	return args.Error(0)
+}
+func (m *MockOrderRepository) FindStatusHistory(ctx context.Context, orderID uint) ([]model.OrderStatusHistory, error) {
+	args := m.Called(ctx, orderID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.OrderStatusHistory), args.Error(1)
+}
+
+// MockIdempotencyRepository
+type MockIdempotencyRepository struct {
+	mock.Mock
+}
+
+func (m *MockIdempotencyRepository) FindByKey(ctx context.Context, userID uint, key string) (*model.IdempotencyKey, error) {
+	args := m.Called(ctx, userID, key)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.IdempotencyKey), args.Error(1)
+}
+func (m *MockIdempotencyRepository) Create(ctx context.Context, record *model.IdempotencyKey) (bool, error) {
+	args := m.Called(ctx, record)
+	return args.Bool(0), args.Error(1)
+}
+func (m *MockIdempotencyRepository) SaveResponse(ctx context.Context, record *model.IdempotencyKey) error {
+	args := m.Called(ctx, record)
+	return args.Error(0)
+}
+func (m *MockIdempotencyRepository) Delete(ctx context.Context, id uint) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+// MockRefreshTokenRepository
+type MockRefreshTokenRepository struct {
+	mock.Mock
+}
+
+func (m *MockRefreshTokenRepository) Create(ctx context.Context, token *model.RefreshToken) error {
+	args := m.Called(ctx, token)
+	return args.Error(0)
+}
+func (m *MockRefreshTokenRepository) FindByHash(ctx context.Context, hash string) (*model.RefreshToken, error) {
+	args := m.Called(ctx, hash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.RefreshToken), args.Error(1)
+}
+func (m *MockRefreshTokenRepository) Rotate(ctx context.Context, current, next *model.RefreshToken) error {
+	args := m.Called(ctx, current, next)
+	return args.Error(0)
+}
+func (m *MockRefreshTokenRepository) RevokeFamily(ctx context.Context, familyID string) error {
+	args := m.Called(ctx, familyID)
 	return args.Error(0)
 }
+func (m *MockRefreshTokenRepository) RevokeAllForUser(ctx context.Context, userID uint) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+func (m *MockRefreshTokenRepository) IsFamilyActive(ctx context.Context, familyID string) (bool, error) {
+	args := m.Called(ctx, familyID)
+	return args.Bool(0), args.Error(1)
+}
+
+// MockRoleRepository
+type MockRoleRepository struct {
+	mock.Mock
+}
+
+func (m *MockRoleRepository) FindAll(ctx context.Context) ([]model.RoleDefinition, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.RoleDefinition), args.Error(1)
+}
+func (m *MockRoleRepository) FindByName(ctx context.Context, name model.Role) (*model.RoleDefinition, error) {
+	args := m.Called(ctx, name)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.RoleDefinition), args.Error(1)
+}
+func (m *MockRoleRepository) Create(ctx context.Context, role *model.RoleDefinition) error {
+	args := m.Called(ctx, role)
+	return args.Error(0)
+}
+func (m *MockRoleRepository) SetPermissions(ctx context.Context, name model.Role, permissions []model.Permission) error {
+	args := m.Called(ctx, name, permissions)
+	return args.Error(0)
+}
+func (m *MockRoleRepository) Delete(ctx context.Context, name model.Role) error {
+	args := m.Called(ctx, name)
+	return args.Error(0)
+}
+func (m *MockRoleRepository) HasPermission(ctx context.Context, role model.Role, permission model.Permission) (bool, error) {
+	args := m.Called(ctx, role, permission)
+	return args.Bool(0), args.Error(1)
+}
+
+// MockOneTimeTokenRepository
+type MockOneTimeTokenRepository struct {
+	mock.Mock
+}
+
+func (m *MockOneTimeTokenRepository) Issue(ctx context.Context, token *model.OneTimeToken) error {
+	args := m.Called(ctx, token)
+	return args.Error(0)
+}
+func (m *MockOneTimeTokenRepository) FindByHash(ctx context.Context, purpose model.TokenPurpose, hash string) (*model.OneTimeToken, error) {
+	args := m.Called(ctx, purpose, hash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.OneTimeToken), args.Error(1)
+}
+func (m *MockOneTimeTokenRepository) Consume(ctx context.Context, token *model.OneTimeToken) error {
+	args := m.Called(ctx, token)
+	return args.Error(0)
+}
+
+// MockAuditRepository
+type MockAuditRepository struct {
+	mock.Mock
+}
+
+func (m *MockAuditRepository) Record(ctx context.Context, event *model.AuditEvent) error {
+	args := m.Called(ctx, event)
+	return args.Error(0)
+}
+func (m *MockAuditRepository) FindRecent(ctx context.Context, limit int) ([]model.AuditEvent, error) {
+	args := m.Called(ctx, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.AuditEvent), args.Error(1)
+}
+
+// MockMFARepository
+type MockMFARepository struct {
+	mock.Mock
+}
+
+func (m *MockMFARepository) SetSecret(ctx context.Context, userID uint, secret string) error {
+	args := m.Called(ctx, userID, secret)
+	return args.Error(0)
+}
+func (m *MockMFARepository) Enable(ctx context.Context, userID uint, step int64, codes []model.MFARecoveryCode) error {
+	args := m.Called(ctx, userID, step, codes)
+	return args.Error(0)
+}
+func (m *MockMFARepository) Disable(ctx context.Context, userID uint) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+func (m *MockMFARepository) AcceptStep(ctx context.Context, userID uint, step int64) error {
+	args := m.Called(ctx, userID, step)
+	return args.Error(0)
+}
+func (m *MockMFARepository) ConsumeRecoveryCode(ctx context.Context, userID uint, codeHash string) error {
+	args := m.Called(ctx, userID, codeHash)
+	return args.Error(0)
+}
+func (m *MockMFARepository) ReplaceRecoveryCodes(ctx context.Context, userID uint, codes []model.MFARecoveryCode) error {
+	args := m.Called(ctx, userID, codes)
+	return args.Error(0)
+}
+
+// MockRateLimitRepository
+type MockRateLimitRepository struct {
+	mock.Mock
+}
+
+func (m *MockRateLimitRepository) Take(ctx context.Context, subject string, rate float64, burst int) (*model.RateLimitBucket, bool, error) {
+	args := m.Called(ctx, subject, rate, burst)
+	if args.Get(0) == nil {
+		return nil, args.Bool(1), args.Error(2)
+	}
+	return args.Get(0).(*model.RateLimitBucket), args.Bool(1), args.Error(2)
+}
+
+// MockTransactionManager runs fn unless an error is set for the transaction
+type MockTransactionManager struct {
+	mock.Mock
+}
+
+func (m *MockTransactionManager) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	args := m.Called(ctx)
+	if err := args.Error(0); err != nil {
+		return err
+	}
+	return fn(ctx)
+}
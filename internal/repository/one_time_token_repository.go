@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/beingaloksharma/book-backend/internal/model"
+	"gorm.io/gorm"
+)
+
+type OneTimeTokenRepository struct {
+	DB *gorm.DB
+}
+
+func NewOneTimeTokenRepository(db *gorm.DB) *OneTimeTokenRepository {
+	return &OneTimeTokenRepository{DB: db}
+}
+
+// Issue stores token and retires every unused token the user already had for
+// the same purpose, so only the latest link works.
+func (r *OneTimeTokenRepository) Issue(ctx context.Context, token *model.OneTimeToken) error {
+	return withContext(ctx, r.DB).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&model.OneTimeToken{}).
+			Where("user_id = ? AND purpose = ? AND used_at IS NULL", token.UserID, token.Purpose).
+			Update("used_at", time.Now()).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(token).Error
+	})
+}
+
+func (r *OneTimeTokenRepository) FindByHash(ctx context.Context, purpose model.TokenPurpose, hash string) (*model.OneTimeToken, error) {
+	var token model.OneTimeToken
+	if err := withContext(ctx, r.DB).Where("purpose = ? AND token_hash = ?", purpose, hash).First(&token).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// Consume marks the token used. It returns ErrTokenAlreadyUsed if another
+// request used it first.
+func (r *OneTimeTokenRepository) Consume(ctx context.Context, token *model.OneTimeToken) error {
+	now := time.Now()
+	result := withContext(ctx, r.DB).Model(&model.OneTimeToken{}).
+		Where("id = ? AND used_at IS NULL", token.ID).
+		Update("used_at", now)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrTokenAlreadyUsed
+	}
+	token.UsedAt = &now
+	return nil
+}
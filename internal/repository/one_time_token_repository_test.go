@@ -0,0 +1,60 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/beingaloksharma/book-backend/internal/model"
+	"github.com/beingaloksharma/book-backend/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIssueOneTimeToken(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := &repository.OneTimeTokenRepository{DB: db}
+
+	token := &model.OneTimeToken{UserID: 1, Purpose: model.TokenPurposePasswordReset, TokenHash: "hash", ExpiresAt: time.Now().Add(time.Hour)}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "one_time_tokens" SET "used_at"=\$1,"updated_at"=\$2 WHERE \(user_id = \$3 AND purpose = \$4 AND used_at IS NULL\)`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 1, model.TokenPurposePasswordReset).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "one_time_tokens"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+	mock.ExpectCommit()
+
+	require.NoError(t, repo.Issue(context.Background(), token))
+	assert.Equal(t, uint(5), token.ID)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestConsumeOneTimeToken(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := &repository.OneTimeTokenRepository{DB: db}
+
+	token := &model.OneTimeToken{}
+	token.ID = 5
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "one_time_tokens" SET "used_at"=\$1,"updated_at"=\$2 WHERE \(id = \$3 AND used_at IS NULL\)`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	require.NoError(t, repo.Consume(context.Background(), token))
+	assert.NotNil(t, token.UsedAt)
+
+	// Another request got there first
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "one_time_tokens" SET "used_at"=`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	assert.ErrorIs(t, repo.Consume(context.Background(), &model.OneTimeToken{}), repository.ErrTokenAlreadyUsed)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -1,10 +1,9 @@
 package repository
 
 import (
-	"errors"
+	"context"
 
 	"github.com/beingaloksharma/book-backend/internal/model"
-	"github.com/beingaloksharma/book-backend/utils/database"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
@@ -13,75 +12,73 @@ type OrderRepository struct {
 	DB *gorm.DB
 }
 
-func NewOrderRepository() *OrderRepository {
-	return &OrderRepository{DB: database.GetInstance()}
+func NewOrderRepository(db *gorm.DB) *OrderRepository {
+	return &OrderRepository{DB: db}
 }
 
-func (r *OrderRepository) CreateOrder(order *model.Order) error {
-	return r.DB.Create(order).Error
+func (r *OrderRepository) CreateOrder(ctx context.Context, order *model.Order) error {
+	return withContext(ctx, r.DB).Create(order).Error
 }
 
-func (r *OrderRepository) FindByUserID(userID uint) ([]model.Order, error) {
+func (r *OrderRepository) FindByUserID(ctx context.Context, userID uint) ([]model.Order, error) {
 	var orders []model.Order
-	if err := r.DB.Where("user_id = ?", userID).Preload("Items.Book").Find(&orders).Error; err != nil {
+	if err := withContext(ctx, r.DB).Where("user_id = ?", userID).Preload("Items.Book").Find(&orders).Error; err != nil {
 		return nil, err
 	}
 	return orders, nil
 }
 
-func (r *OrderRepository) FindAllOrders() ([]model.Order, error) {
+func (r *OrderRepository) FindAllOrders(ctx context.Context) ([]model.Order, error) {
 	var orders []model.Order
-	if err := r.DB.Preload("Items.Book").Find(&orders).Error; err != nil {
+	if err := fromReplica(withContext(ctx, r.DB)).Preload("Items.Book").Find(&orders).Error; err != nil {
 		return nil, err
 	}
 	return orders, nil
 }
 
-func (r *OrderRepository) PlaceOrderTransaction(order *model.Order, cartItems []model.CartItem, cartID uint) error {
-	// Start Transaction
-	return r.DB.Transaction(func(tx *gorm.DB) error {
-		totalAmount := 0.0
-		var orderItems []model.OrderItem
-
-		for _, item := range cartItems {
-			// Lock book row for update to prevent race conditions
-			var book model.Book
-			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&book, item.BookID).Error; err != nil {
-				return err
-			}
-
-			if book.Stock < item.Quantity {
-				return errors.New("insufficient stock for book: " + book.Title)
-			}
-
-			// Deduct Stock
-			book.Stock -= item.Quantity
-			if err := tx.Save(&book).Error; err != nil {
-				return err
-			}
-
-			price := book.Price
-			totalAmount += price * float64(item.Quantity)
-			orderItems = append(orderItems, model.OrderItem{
-				BookID:   item.BookID,
-				Quantity: item.Quantity,
-				Price:    price,
-			})
-		}
+func (r *OrderRepository) FindByID(ctx context.Context, id uint) (*model.Order, error) {
+	var order model.Order
+	if err := withContext(ctx, r.DB).Preload("Items.Book").First(&order, id).Error; err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
 
-		order.Amount = totalAmount
-		order.Items = orderItems
+// FindByIDForUpdate loads the order with its items and locks the order row
+// until the surrounding transaction ends.
+func (r *OrderRepository) FindByIDForUpdate(ctx context.Context, id uint) (*model.Order, error) {
+	var order model.Order
+	if err := withContext(ctx, r.DB).Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Items").First(&order, id).Error; err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
 
-		// Create Order
-		if err := tx.Create(order).Error; err != nil {
-			return err
+// UpdateStatus moves the order from one status to another and records the change.
+// It returns ErrStatusConflict if the order is no longer in the from status.
+func (r *OrderRepository) UpdateStatus(ctx context.Context, orderID uint, from, to model.OrderStatus, history *model.OrderStatusHistory) error {
+	return withContext(ctx, r.DB).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.Order{}).
+			Where("id = ? AND status = ?", orderID, from).
+			Update("status", to)
+		if result.Error != nil {
+			return result.Error
 		}
-
-		// Clear Cart
-		if err := tx.Where("cart_id = ?", cartID).Delete(&model.CartItem{}).Error; err != nil {
-			return err
+		if result.RowsAffected == 0 {
+			return ErrStatusConflict
 		}
 
-		return nil
+		history.OrderID = orderID
+		history.FromStatus = from
+		history.ToStatus = to
+		return tx.Create(history).Error
 	})
 }
+
+func (r *OrderRepository) FindStatusHistory(ctx context.Context, orderID uint) ([]model.OrderStatusHistory, error) {
+	var history []model.OrderStatusHistory
+	if err := withContext(ctx, r.DB).Where("order_id = ?", orderID).Order("created_at, id").Find(&history).Error; err != nil {
+		return nil, err
+	}
+	return history, nil
+}
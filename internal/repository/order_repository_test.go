@@ -1,31 +1,34 @@
 package repository_test
 
 import (
-	"regexp"
+	"context"
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/beingaloksharma/book-backend/internal/model"
 	"github.com/beingaloksharma/book-backend/internal/repository"
+	"github.com/beingaloksharma/book-backend/utils/database"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
 )
 
 func TestCreateOrder(t *testing.T) {
 	db, mock := NewMockDB()
 	repo := &repository.OrderRepository{DB: db}
 
-	order := &model.Order{UserID: 1, Amount: 100.0}
+	order := &model.Order{UserID: 1, Amount: 100.0, ShippingAddress: model.ShippingAddress{Street: "1 Main St"}}
 
 	mock.ExpectBegin()
 	// Flexible query match
-	mock.ExpectQuery(`INSERT INTO "orders"`).
-		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+	mock.ExpectQuery(`INSERT INTO "orders" .*"shipping_street","shipping_city","shipping_state","shipping_zip_code","shipping_country"`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), "1 Main St", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
 		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 	mock.ExpectCommit()
 
-	err := repo.CreateOrder(order)
+	err := repo.CreateOrder(context.Background(), order)
 	assert.NoError(t, err)
 }
 
@@ -48,55 +51,97 @@ func TestFindOrdersByUserID(t *testing.T) {
 		WithArgs(1).
 		WillReturnRows(sqlmock.NewRows([]string{"id", "order_id", "book_id"}))
 
-	orders, err := repo.FindByUserID(userID)
+	orders, err := repo.FindByUserID(context.Background(), userID)
 	require.NoError(t, err)
 	assert.Len(t, orders, 1)
 }
 
-func TestPlaceOrderTransaction(t *testing.T) {
+func TestFindOrderByIDForUpdate(t *testing.T) {
 	db, mock := NewMockDB()
 	repo := &repository.OrderRepository{DB: db}
 
-	order := &model.Order{UserID: 1, Status: model.OrderStatusPending}
-	cartItems := []model.CartItem{
-		{
-			Model:    gorm.Model{ID: 10},
-			BookID:   100,
-			Quantity: 2,
-		},
-	}
-	cartID := uint(5)
+	mock.ExpectQuery(`SELECT .* FROM "orders" WHERE .*"id" = .* FOR UPDATE`).
+		WithArgs(1, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "status"}).AddRow(1, 1, "PENDING"))
+	mock.ExpectQuery(`SELECT .* FROM "order_items" WHERE "order_items"."order_id" =`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "order_id", "book_id", "quantity"}).
+			AddRow(1, 1, 200, 1).
+			AddRow(2, 1, 100, 2))
 
-	mock.ExpectBegin()
+	order, err := repo.FindByIDForUpdate(context.Background(), 1)
+	require.NoError(t, err)
+	assert.Equal(t, model.OrderStatusPending, order.Status)
+	assert.Len(t, order.Items, 2)
 
-	// 1. Lock Book
-	bookRows := sqlmock.NewRows([]string{"id", "title", "stock", "price"}).
-		AddRow(100, "Go Book", 10, 50.0)
-	mock.ExpectQuery(`SELECT .* FROM "books" WHERE .*"id" = .* FOR UPDATE`).
-		WithArgs(100, 1).
-		WillReturnRows(bookRows)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
 
-	// 2. Update Book Stock
-	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "books" SET`)).
-		WillReturnResult(sqlmock.NewResult(100, 1))
+func TestUpdateOrderStatus(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := &repository.OrderRepository{DB: db}
 
-	// 3. Create Order
-	mock.ExpectQuery(`INSERT INTO "orders"`).
-		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	history := &model.OrderStatusHistory{ChangedBy: 9}
 
-	// 4. Create Order Items (One item)
-	mock.ExpectQuery(`INSERT INTO "order_items"`).
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "orders" SET "status"=.* WHERE \(id = .* AND status = .*\)`).
+		WithArgs(model.OrderStatusPaid, sqlmock.AnyArg(), uint(1), model.OrderStatusPending).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "order_status_histories"`).
 		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
 
-	// 5. Delete Cart Items (Soft Delete -> UPDATE)
-	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "cart_items" SET "deleted_at"=`)).
-		WithArgs(sqlmock.AnyArg(), cartID).
-		WillReturnResult(sqlmock.NewResult(1, 1))
+	err := repo.UpdateStatus(context.Background(), 1, model.OrderStatusPending, model.OrderStatusPaid, history)
+	require.NoError(t, err)
+	assert.Equal(t, uint(1), history.OrderID)
+	assert.Equal(t, model.OrderStatusPaid, history.ToStatus)
 
-	mock.ExpectCommit()
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
 
-	err := repo.PlaceOrderTransaction(order, cartItems, cartID)
-	assert.NoError(t, err)
+func TestUpdateOrderStatus_Conflict(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := &repository.OrderRepository{DB: db}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "orders" SET "status"=`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	err := repo.UpdateStatus(context.Background(), 1, model.OrderStatusPending, model.OrderStatusPaid, &model.OrderStatusHistory{})
+	assert.ErrorIs(t, err, repository.ErrStatusConflict)
 
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
+
+func TestFindAllOrders_Replica(t *testing.T) {
+	db, primary := NewMockDB()
+	replicaDB, replica, err := sqlmock.New()
+	require.NoError(t, err)
+	require.NoError(t, db.Use(dbresolver.Register(dbresolver.Config{
+		Replicas: []gorm.Dialector{postgres.New(postgres.Config{Conn: replicaDB})},
+	}, database.ReplicaResolver)))
+	repo := &repository.OrderRepository{DB: db}
+
+	// Case 1: Listing every order reads from the replica
+	replica.ExpectQuery(`SELECT \* FROM "orders"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id"}).AddRow(1, 1))
+	replica.ExpectQuery(`SELECT \* FROM "order_items" WHERE "order_items"."order_id" =`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "order_id", "book_id"}))
+
+	orders, err := repo.FindAllOrders(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, orders, 1)
+
+	// Case 2: Other reads stay on the primary
+	primary.ExpectQuery(`SELECT .* FROM "orders" WHERE .*user_id =`).
+		WithArgs(uint(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id"}))
+
+	_, err = repo.FindByUserID(context.Background(), 1)
+	require.NoError(t, err)
+
+	assert.NoError(t, primary.ExpectationsWereMet())
+	assert.NoError(t, replica.ExpectationsWereMet())
+}
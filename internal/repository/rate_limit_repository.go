@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/beingaloksharma/book-backend/internal/model"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RateLimitPruneInterval is how often PruneEvery is meant to run for the
+// server's database store.
+const RateLimitPruneInterval = 5 * time.Minute
+
+// RateLimitRepository keeps rate limit buckets in the database, so every
+// server instance draws from the same buckets. Buckets that have refilled are
+// only deleted by Prune, so run PruneEvery alongside it.
+type RateLimitRepository struct {
+	DB *gorm.DB
+}
+
+func NewRateLimitRepository(db *gorm.DB) *RateLimitRepository {
+	return &RateLimitRepository{DB: db}
+}
+
+// Take spends a token from the bucket of subject. The row is locked while the
+// bucket is refilled, so concurrent requests cannot spend the same token.
+func (r *RateLimitRepository) Take(ctx context.Context, subject string, rate float64, burst int) (*model.RateLimitBucket, bool, error) {
+	var bucket model.RateLimitBucket
+	var allowed bool
+	err := withContext(ctx, r.DB).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		// A new client starts with a full bucket
+		full := model.RateLimitBucket{Subject: subject, Tokens: float64(burst), RefilledAt: now, FullAt: now}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&full).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("subject = ?", subject).First(&bucket).Error; err != nil {
+			return err
+		}
+
+		allowed = bucket.Take(now, rate, burst)
+		return tx.Model(&bucket).Updates(map[string]interface{}{
+			"tokens":      bucket.Tokens,
+			"refilled_at": bucket.RefilledAt,
+			"full_at":     bucket.FullAt,
+		}).Error
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return &bucket, allowed, nil
+}
+
+// Prune deletes the buckets that are full by now; a new bucket starts full, so
+// forgetting them changes nothing. It reports how many it deleted.
+func (r *RateLimitRepository) Prune(ctx context.Context, now time.Time) (int64, error) {
+	result := withContext(ctx, r.DB).Where("full_at <= ?", now).Delete(&model.RateLimitBucket{})
+	return result.RowsAffected, result.Error
+}
+
+// PruneEvery calls Prune every interval until ctx is done. Failures are logged
+// and left for the next run.
+func (r *RateLimitRepository) PruneEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if _, err := r.Prune(ctx, now); err != nil {
+				logrus.WithError(err).Warn("Failed to prune rate limit buckets")
+			}
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/beingaloksharma/book-backend/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimitTake(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := &repository.RateLimitRepository{DB: db}
+	columns := []string{"subject", "tokens", "refilled_at", "full_at"}
+
+	// Case 1: A token is spent from the locked bucket
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "rate_limit_buckets" .* ON CONFLICT DO NOTHING`).
+		WithArgs("api:user:1", 10.0, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT \* FROM "rate_limit_buckets" WHERE subject = \$1 .* FOR UPDATE`).
+		WithArgs("api:user:1", 1).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow("api:user:1", 3.0, time.Now(), time.Now()))
+	mock.ExpectExec(`UPDATE "rate_limit_buckets" SET "full_at"=\$1,"refilled_at"=\$2,"tokens"=\$3 WHERE "subject" = \$4`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), "api:user:1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	bucket, allowed, err := repo.Take(context.Background(), "api:user:1", 1, 10)
+	require.NoError(t, err)
+	assert.True(t, allowed)
+	assert.InDelta(t, 2.0, bucket.Tokens, 0.1)
+	assert.WithinDuration(t, time.Now().Add(8*time.Second), bucket.FullAt, time.Second)
+
+	// Case 2: An empty bucket refuses
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "rate_limit_buckets"`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT \* FROM "rate_limit_buckets"`).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow("api:user:1", 0.0, time.Now(), time.Now()))
+	mock.ExpectExec(`UPDATE "rate_limit_buckets"`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	_, allowed, err = repo.Take(context.Background(), "api:user:1", 0.001, 10)
+	require.NoError(t, err)
+	assert.False(t, allowed)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRateLimitPrune(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := &repository.RateLimitRepository{DB: db}
+	now := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM "rate_limit_buckets" WHERE full_at <= \$1`).
+		WithArgs(now).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectCommit()
+
+	pruned, err := repo.Prune(context.Background(), now)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), pruned)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMemoryRateLimitTake(t *testing.T) {
+	repo := repository.NewMemoryRateLimitRepository()
+
+	// Case 1: A new client starts with a full bucket
+	bucket, allowed, err := repo.Take(context.Background(), "auth:ip:10.0.0.1", 1, 2)
+	require.NoError(t, err)
+	assert.True(t, allowed)
+	assert.InDelta(t, 1.0, bucket.Tokens, 0.01)
+
+	_, allowed, _ = repo.Take(context.Background(), "auth:ip:10.0.0.1", 1, 2)
+	assert.True(t, allowed)
+
+	// Case 2: Empty until the bucket refills
+	bucket, allowed, _ = repo.Take(context.Background(), "auth:ip:10.0.0.1", 1, 2)
+	assert.False(t, allowed)
+	assert.Less(t, bucket.Tokens, 1.0)
+
+	// Case 3: Refilled at the rate
+	_, allowed, _ = repo.Take(context.Background(), "auth:ip:10.0.0.2", 100, 1)
+	assert.True(t, allowed)
+	time.Sleep(20 * time.Millisecond)
+	_, allowed, _ = repo.Take(context.Background(), "auth:ip:10.0.0.2", 100, 1)
+	assert.True(t, allowed)
+}
+
+func TestMemoryRateLimitPrune(t *testing.T) {
+	repo := repository.NewMemoryRateLimitRepository()
+
+	// Buckets of a slow group and a fast group, both just drawn from
+	_, _, _ = repo.Take(context.Background(), "auth:ip:10.0.0.1", 1.0/60, 10)
+	_, _, _ = repo.Take(context.Background(), "api:ip:10.0.0.1", 100, 10)
+
+	// Case 1: Nothing is full yet
+	assert.Equal(t, 0, repo.Prune(time.Now()))
+
+	// Case 2: Each bucket is kept until its own group's rate has refilled it
+	assert.Equal(t, 1, repo.Prune(time.Now().Add(time.Second)))
+	assert.Equal(t, 1, repo.Prune(time.Now().Add(2*time.Minute)))
+
+	// Case 3: A pruned bucket starts over full
+	bucket, allowed, err := repo.Take(context.Background(), "auth:ip:10.0.0.1", 1.0/60, 10)
+	require.NoError(t, err)
+	assert.True(t, allowed)
+	assert.InDelta(t, 9.0, bucket.Tokens, 0.01)
+}
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/beingaloksharma/book-backend/internal/model"
+	"gorm.io/gorm"
+)
+
+type RefreshTokenRepository struct {
+	DB *gorm.DB
+}
+
+func NewRefreshTokenRepository(db *gorm.DB) *RefreshTokenRepository {
+	return &RefreshTokenRepository{DB: db}
+}
+
+func (r *RefreshTokenRepository) Create(ctx context.Context, token *model.RefreshToken) error {
+	return withContext(ctx, r.DB).Create(token).Error
+}
+
+func (r *RefreshTokenRepository) FindByHash(ctx context.Context, hash string) (*model.RefreshToken, error) {
+	var token model.RefreshToken
+	if err := withContext(ctx, r.DB).Where("token_hash = ?", hash).First(&token).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// Rotate marks current as used and stores next in its place. It returns
+// ErrTokenAlreadyRotated if current was rotated or revoked in the meantime.
+func (r *RefreshTokenRepository) Rotate(ctx context.Context, current, next *model.RefreshToken) error {
+	return withContext(ctx, r.DB).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.RefreshToken{}).
+			Where("id = ? AND rotated_at IS NULL AND revoked_at IS NULL", current.ID).
+			Update("rotated_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrTokenAlreadyRotated
+		}
+		return tx.Create(next).Error
+	})
+}
+
+func (r *RefreshTokenRepository) RevokeFamily(ctx context.Context, familyID string) error {
+	return withContext(ctx, r.DB).Model(&model.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+}
+
+func (r *RefreshTokenRepository) RevokeAllForUser(ctx context.Context, userID uint) error {
+	return withContext(ctx, r.DB).Model(&model.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// IsFamilyActive reports whether the session still has a live refresh token,
+// i.e. it has neither been logged out nor expired.
+func (r *RefreshTokenRepository) IsFamilyActive(ctx context.Context, familyID string) (bool, error) {
+	var count int64
+	err := withContext(ctx, r.DB).Model(&model.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL AND expires_at > ?", familyID, time.Now()).
+		Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
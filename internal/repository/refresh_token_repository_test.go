@@ -0,0 +1,78 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/beingaloksharma/book-backend/internal/model"
+	"github.com/beingaloksharma/book-backend/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestRotateRefreshToken(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := &repository.RefreshTokenRepository{DB: db}
+
+	current := &model.RefreshToken{Model: gorm.Model{ID: 7}, FamilyID: "family-1"}
+	next := &model.RefreshToken{FamilyID: "family-1", TokenHash: "next-hash"}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "refresh_tokens" SET "rotated_at"=.*WHERE \(id = .* AND rotated_at IS NULL AND revoked_at IS NULL\)`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), uint(7)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "refresh_tokens"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(8))
+	mock.ExpectCommit()
+
+	require.NoError(t, repo.Rotate(context.Background(), current, next))
+	assert.Equal(t, uint(8), next.ID)
+
+	// Already rotated by a concurrent refresh
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "refresh_tokens" SET "rotated_at"=`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	err := repo.Rotate(context.Background(), current, &model.RefreshToken{})
+	assert.ErrorIs(t, err, repository.ErrTokenAlreadyRotated)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRevokeFamily(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := &repository.RefreshTokenRepository{DB: db}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "refresh_tokens" SET "revoked_at"=.*WHERE \(family_id = .* AND revoked_at IS NULL\)`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "family-1").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	require.NoError(t, repo.RevokeFamily(context.Background(), "family-1"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestIsFamilyActive(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := &repository.RefreshTokenRepository{DB: db}
+
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "refresh_tokens" WHERE \(family_id = \$1 AND revoked_at IS NULL AND expires_at > \$2\)`).
+		WithArgs("family-1", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+	active, err := repo.IsFamilyActive(context.Background(), "family-1")
+	require.NoError(t, err)
+	assert.True(t, active)
+
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "refresh_tokens"`).
+		WithArgs("family-2", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+	active, err = repo.IsFamilyActive(context.Background(), "family-2")
+	require.NoError(t, err)
+	assert.False(t, active)
+}
@@ -1 +1,38 @@
 package repository
+
+import (
+	"errors"
+
+	"github.com/beingaloksharma/book-backend/utils/database"
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+// ErrStatusConflict is returned when a conditional status update finds the row
+// already moved to a different status.
+var ErrStatusConflict = errors.New("status was changed concurrently")
+
+// ErrRoleConflict is returned when a conditional role update finds the user
+// already holding a different role.
+var ErrRoleConflict = errors.New("role was changed concurrently")
+
+// ErrTokenAlreadyRotated is returned when a refresh token has already been
+// exchanged or revoked by the time it is rotated.
+var ErrTokenAlreadyRotated = errors.New("refresh token was already used")
+
+// ErrTokenAlreadyUsed is returned when a one-time token was consumed by
+// another request first.
+var ErrTokenAlreadyUsed = errors.New("token was already used")
+
+// isSQLite reports whether db is a SQLite database, which lacks some of the
+// Postgres features the repositories use.
+func isSQLite(db *gorm.DB) bool {
+	return db.Dialector.Name() == "sqlite"
+}
+
+// fromReplica sends the reads of db to a read replica when any are configured.
+// It is for heavy reads that can be a little behind the primary; in a
+// transaction it has no effect.
+func fromReplica(db *gorm.DB) *gorm.DB {
+	return db.Clauses(dbresolver.Use(database.ReplicaResolver))
+}
@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/beingaloksharma/book-backend/internal/model"
+	"gorm.io/gorm"
+)
+
+type RoleRepository struct {
+	DB *gorm.DB
+}
+
+func NewRoleRepository(db *gorm.DB) *RoleRepository {
+	return &RoleRepository{DB: db}
+}
+
+func (r *RoleRepository) FindAll(ctx context.Context) ([]model.RoleDefinition, error) {
+	var roles []model.RoleDefinition
+	if err := withContext(ctx, r.DB).Preload("Permissions").Order("name").Find(&roles).Error; err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+func (r *RoleRepository) FindByName(ctx context.Context, name model.Role) (*model.RoleDefinition, error) {
+	var role model.RoleDefinition
+	if err := withContext(ctx, r.DB).Preload("Permissions").Where("name = ?", name).First(&role).Error; err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+// Create stores the role together with its permissions.
+func (r *RoleRepository) Create(ctx context.Context, role *model.RoleDefinition) error {
+	return withContext(ctx, r.DB).Create(role).Error
+}
+
+// SetPermissions replaces every permission the role grants.
+func (r *RoleRepository) SetPermissions(ctx context.Context, name model.Role, permissions []model.Permission) error {
+	return withContext(ctx, r.DB).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("role = ?", name).Delete(&model.RolePermission{}).Error; err != nil {
+			return err
+		}
+		if len(permissions) == 0 {
+			return nil
+		}
+
+		grants := make([]model.RolePermission, len(permissions))
+		for i, p := range permissions {
+			grants[i] = model.RolePermission{Role: name, Permission: p}
+		}
+		return tx.Create(&grants).Error
+	})
+}
+
+func (r *RoleRepository) Delete(ctx context.Context, name model.Role) error {
+	return withContext(ctx, r.DB).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("role = ?", name).Delete(&model.RolePermission{}).Error; err != nil {
+			return err
+		}
+		result := tx.Where("name = ?", name).Delete(&model.RoleDefinition{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+// HasPermission reports whether role grants permission.
+func (r *RoleRepository) HasPermission(ctx context.Context, role model.Role, permission model.Permission) (bool, error) {
+	var count int64
+	err := withContext(ctx, r.DB).Model(&model.RolePermission{}).
+		Where("role = ? AND permission = ?", role, permission).
+		Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
@@ -0,0 +1,105 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/beingaloksharma/book-backend/internal/model"
+	"github.com/beingaloksharma/book-backend/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestFindRoleByName(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := &repository.RoleRepository{DB: db}
+
+	mock.ExpectQuery(`SELECT \* FROM "roles" WHERE name = \$1`).
+		WithArgs(model.Role("SUPPORT_AGENT"), 1).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "description"}).AddRow("SUPPORT_AGENT", "Helps customers"))
+	mock.ExpectQuery(`SELECT \* FROM "role_permissions" WHERE "role_permissions"."role" = \$1`).
+		WithArgs("SUPPORT_AGENT").
+		WillReturnRows(sqlmock.NewRows([]string{"role", "permission"}).
+			AddRow("SUPPORT_AGENT", "orders:read_all").
+			AddRow("SUPPORT_AGENT", "users:read"))
+
+	role, err := repo.FindByName(context.Background(), "SUPPORT_AGENT")
+	require.NoError(t, err)
+	assert.Len(t, role.Permissions, 2)
+	assert.True(t, role.Grants(model.PermissionUsersRead))
+	assert.False(t, role.Grants(model.PermissionUsersManage))
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSetRolePermissions(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := &repository.RoleRepository{DB: db}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM "role_permissions" WHERE role = \$1`).
+		WithArgs(model.Role("SUPPORT_AGENT")).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(`INSERT INTO "role_permissions" \("role","permission"\) VALUES \(\$1,\$2\),\(\$3,\$4\)`).
+		WithArgs("SUPPORT_AGENT", "orders:read_all", "SUPPORT_AGENT", "orders:manage").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	err := repo.SetPermissions(context.Background(), "SUPPORT_AGENT", []model.Permission{model.PermissionOrdersReadAll, model.PermissionOrdersManage})
+	require.NoError(t, err)
+
+	// Clearing every permission only deletes
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM "role_permissions" WHERE role = \$1`).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	err = repo.SetPermissions(context.Background(), "SUPPORT_AGENT", nil)
+	require.NoError(t, err)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteRole(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := &repository.RoleRepository{DB: db}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM "role_permissions" WHERE role = \$1`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM "roles" WHERE name = \$1`).
+		WithArgs(model.Role("SUPPORT_AGENT")).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	require.NoError(t, repo.Delete(context.Background(), "SUPPORT_AGENT"))
+
+	// Unknown role
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM "role_permissions" WHERE role = \$1`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM "roles" WHERE name = \$1`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	assert.ErrorIs(t, repo.Delete(context.Background(), "MISSING"), gorm.ErrRecordNotFound)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHasPermission(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := &repository.RoleRepository{DB: db}
+
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "role_permissions" WHERE role = \$1 AND permission = \$2`).
+		WithArgs(model.RoleAdmin, model.PermissionBooksWrite).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+	allowed, err := repo.HasPermission(context.Background(), model.RoleAdmin, model.PermissionBooksWrite)
+	require.NoError(t, err)
+	assert.True(t, allowed)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
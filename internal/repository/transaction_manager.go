@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+type txKey struct{}
+
+// TransactionManager runs calls to several repositories in one database
+// transaction, which it hands to them through the context.
+type TransactionManager struct {
+	DB *gorm.DB
+}
+
+func NewTransactionManager(db *gorm.DB) *TransactionManager {
+	return &TransactionManager{DB: db}
+}
+
+// WithinTransaction runs fn in a transaction that is committed if fn returns
+// nil and rolled back otherwise. Repository calls made with the context passed
+// to fn take part in it. If ctx already carries a transaction, fn joins it.
+func (m *TransactionManager) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return fn(ctx)
+	}
+	return m.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(context.WithValue(ctx, txKey{}, tx))
+	})
+}
+
+// withContext returns the transaction ctx carries, or db otherwise, bound to ctx.
+func withContext(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if tx, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return tx.WithContext(ctx)
+	}
+	return db.WithContext(ctx)
+}
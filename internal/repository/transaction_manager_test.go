@@ -0,0 +1,65 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/beingaloksharma/book-backend/internal/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithinTransaction(t *testing.T) {
+	db, mock := NewMockDB()
+	txManager := repository.NewTransactionManager(db)
+	bookRepo := repository.NewBookRepository(db)
+	cartRepo := repository.NewCartRepository(db)
+
+	// Case 1: Calls to several repositories share one transaction
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "books" SET "stock"=`)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "cart_items" SET "deleted_at"=`)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err := txManager.WithinTransaction(context.Background(), func(ctx context.Context) error {
+		if err := bookRepo.AdjustStock(ctx, 100, -1); err != nil {
+			return err
+		}
+		return cartRepo.ClearCart(ctx, 5)
+	})
+	assert.NoError(t, err)
+
+	// Case 2: An error rolls back everything done so far
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "books" SET "stock"=`)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectRollback()
+
+	failure := errors.New("out of stock")
+	err = txManager.WithinTransaction(context.Background(), func(ctx context.Context) error {
+		if err := bookRepo.AdjustStock(ctx, 100, -1); err != nil {
+			return err
+		}
+		return failure
+	})
+	assert.ErrorIs(t, err, failure)
+
+	// Case 3: A nested call joins the outer transaction
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "cart_items" SET "deleted_at"=`)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err = txManager.WithinTransaction(context.Background(), func(ctx context.Context) error {
+		return txManager.WithinTransaction(ctx, func(ctx context.Context) error {
+			return cartRepo.ClearCart(ctx, 5)
+		})
+	})
+	assert.NoError(t, err)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -1,55 +1,211 @@
 package repository
 
 import (
+	"context"
+	"fmt"
+	"time"
+
 	"github.com/beingaloksharma/book-backend/internal/model"
-	"github.com/beingaloksharma/book-backend/utils/database"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type UserRepository struct {
 	DB *gorm.DB
 }
 
-func NewUserRepository() *UserRepository {
-	return &UserRepository{DB: database.GetInstance()}
+func NewUserRepository(db *gorm.DB) *UserRepository {
+	return &UserRepository{DB: db}
 }
 
-func (r *UserRepository) CreateUser(user *model.User) error {
-	return r.DB.Create(user).Error
+func (r *UserRepository) CreateUser(ctx context.Context, user *model.User) error {
+	return withContext(ctx, r.DB).Create(user).Error
 }
 
-func (r *UserRepository) FindByEmail(email string) (*model.User, error) {
+func (r *UserRepository) FindByEmail(ctx context.Context, email string) (*model.User, error) {
 	var user model.User
-	if err := r.DB.Where("email = ?", email).First(&user).Error; err != nil {
+	if err := withContext(ctx, r.DB).Where("email = ?", email).First(&user).Error; err != nil {
 		return nil, err
 	}
 	return &user, nil
 }
 
-func (r *UserRepository) FindByID(id uint) (*model.User, error) {
+func (r *UserRepository) FindByID(ctx context.Context, id uint) (*model.User, error) {
 	var user model.User
-	if err := r.DB.First(&user, id).Error; err != nil {
+	if err := withContext(ctx, r.DB).First(&user, id).Error; err != nil {
 		return nil, err
 	}
 	return &user, nil
 }
 
-func (r *UserRepository) AddAddress(address *model.Address) error {
-	return r.DB.Create(address).Error
+func (r *UserRepository) AddAddress(ctx context.Context, address *model.Address) error {
+	return withContext(ctx, r.DB).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(address).Error; err != nil {
+			return err
+		}
+		return clearOtherDefaults(tx, address)
+	})
 }
 
-func (r *UserRepository) GetAddresses(userID uint) ([]model.Address, error) {
+func (r *UserRepository) GetAddresses(ctx context.Context, userID uint) ([]model.Address, error) {
 	var addresses []model.Address
-	if err := r.DB.Where("user_id = ?", userID).Find(&addresses).Error; err != nil {
+	if err := withContext(ctx, r.DB).Where("user_id = ?", userID).Find(&addresses).Error; err != nil {
 		return nil, err
 	}
 	return addresses, nil
 }
 
-func (r *UserRepository) FindAllUsers() ([]model.User, error) {
+func (r *UserRepository) FindAddress(ctx context.Context, userID, addressID uint) (*model.Address, error) {
+	var address model.Address
+	if err := withContext(ctx, r.DB).Where("id = ? AND user_id = ?", addressID, userID).First(&address).Error; err != nil {
+		return nil, err
+	}
+	return &address, nil
+}
+
+func (r *UserRepository) UpdateAddress(ctx context.Context, address *model.Address) error {
+	return withContext(ctx, r.DB).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(address).Error; err != nil {
+			return err
+		}
+		return clearOtherDefaults(tx, address)
+	})
+}
+
+func (r *UserRepository) DeleteAddress(ctx context.Context, userID, addressID uint) error {
+	result := withContext(ctx, r.DB).Where("id = ? AND user_id = ?", addressID, userID).Delete(&model.Address{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *UserRepository) FindAllUsers(ctx context.Context) ([]model.User, error) {
 	var users []model.User
-	if err := r.DB.Find(&users).Error; err != nil {
+	if err := withContext(ctx, r.DB).Find(&users).Error; err != nil {
 		return nil, err
 	}
 	return users, nil
 }
+
+// UpdateRole moves the user from one role to another and records the change.
+// It returns ErrRoleConflict if the user no longer has the from role.
+func (r *UserRepository) UpdateRole(ctx context.Context, userID uint, from, to model.Role, history *model.UserRoleHistory) error {
+	return withContext(ctx, r.DB).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.User{}).
+			Where("id = ? AND role = ?", userID, from).
+			Update("role", to)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrRoleConflict
+		}
+
+		history.UserID = userID
+		history.FromRole = from
+		history.ToRole = to
+		return tx.Create(history).Error
+	})
+}
+
+func (r *UserRepository) FindRoleHistory(ctx context.Context, userID uint) ([]model.UserRoleHistory, error) {
+	var history []model.UserRoleHistory
+	if err := withContext(ctx, r.DB).Where("user_id = ?", userID).Order("created_at, id").Find(&history).Error; err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+func (r *UserRepository) CountByRole(ctx context.Context, role model.Role) (int64, error) {
+	var count int64
+	if err := withContext(ctx, r.DB).Model(&model.User{}).Where("role = ?", role).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// CountByRoleForUpdate counts the users with role and locks their rows until
+// the surrounding transaction ends, so that none of them can change role or be
+// deleted by another transaction until then.
+func (r *UserRepository) CountByRoleForUpdate(ctx context.Context, role model.Role) (int64, error) {
+	var ids []uint
+	// Postgres cannot lock the rows of an aggregate, so read their IDs instead
+	if err := withContext(ctx, r.DB).Model(&model.User{}).Clauses(clause.Locking{Strength: "UPDATE"}).Where("role = ?", role).Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+	return int64(len(ids)), nil
+}
+
+func (r *UserRepository) UpdatePassword(ctx context.Context, userID uint, passwordHash string) error {
+	result := withContext(ctx, r.DB).Model(&model.User{}).Where("id = ?", userID).Update("password", passwordHash)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *UserRepository) MarkVerified(ctx context.Context, userID uint) error {
+	return withContext(ctx, r.DB).Model(&model.User{}).Where("id = ?", userID).Update("verified_at", time.Now()).Error
+}
+
+// UpdateProfile saves the user's name, email and verification state.
+func (r *UserRepository) UpdateProfile(ctx context.Context, user *model.User) error {
+	return withContext(ctx, r.DB).Model(user).Select("name", "email", "verified_at").Updates(user).Error
+}
+
+// Anonymize closes an account: the user row keeps its ID for the orders that
+// reference it, but loses its personal data and is soft deleted. Addresses,
+// pending one-time tokens and MFA recovery codes are removed; orders keep their
+// own address snapshot.
+func (r *UserRepository) Anonymize(ctx context.Context, userID uint) error {
+	return withContext(ctx, r.DB).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"name":           "Deleted user",
+			"email":          fmt.Sprintf("deleted-%d@invalid", userID), // Frees the address for a new signup
+			"password":       "",
+			"verified_at":    nil,
+			"mfa_secret":     "",
+			"mfa_enabled_at": nil,
+			"mfa_last_step":  0,
+			"deleted_at":     time.Now(),
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&model.Address{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&model.OneTimeToken{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Where("user_id = ?", userID).Delete(&model.MFARecoveryCode{}).Error
+	})
+}
+
+// clearOtherDefaults keeps at most one default shipping and one default billing
+// address per user by unsetting the flags address has just claimed.
+func clearOtherDefaults(tx *gorm.DB, address *model.Address) error {
+	others := tx.Model(&model.Address{}).Where("user_id = ? AND id <> ?", address.UserID, address.ID)
+	if address.IsDefaultShipping {
+		if err := others.Session(&gorm.Session{}).Update("is_default_shipping", false).Error; err != nil {
+			return err
+		}
+	}
+	if address.IsDefaultBilling {
+		if err := others.Session(&gorm.Session{}).Update("is_default_billing", false).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package repository_test
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -43,7 +44,7 @@ func TestFindUserByEmail(t *testing.T) {
 		WithArgs(email, 1). // Email and Limit
 		WillReturnRows(rows)
 
-	user, err := repo.FindByEmail(email)
+	user, err := repo.FindByEmail(context.Background(), email)
 	require.NoError(t, err)
 	require.NotNil(t, user)
 	assert.Equal(t, email, user.Email)
@@ -63,7 +64,7 @@ func TestFindUserByID(t *testing.T) {
 		WithArgs(id, 1). // ID and Limit
 		WillReturnRows(rows)
 
-	user, err := repo.FindByID(id)
+	user, err := repo.FindByID(context.Background(), id)
 	require.NoError(t, err)
 	require.NotNil(t, user)
 	assert.Equal(t, id, user.ID)
@@ -80,11 +81,11 @@ func TestCreateUser(t *testing.T) {
 	mock.ExpectBegin()
 	// Flexible
 	mock.ExpectQuery(`INSERT INTO "users"`).
-		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
 		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 	mock.ExpectCommit()
 
-	err := repo.CreateUser(user)
+	err := repo.CreateUser(context.Background(), user)
 	assert.NoError(t, err)
 }
 
@@ -97,11 +98,11 @@ func TestAddAddress(t *testing.T) {
 	// Flexible
 	mock.ExpectBegin()
 	mock.ExpectQuery(`INSERT INTO "addresses"`).
-		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
 		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 	mock.ExpectCommit()
 
-	err := repo.AddAddress(addr)
+	err := repo.AddAddress(context.Background(), addr)
 	assert.NoError(t, err)
 }
 
@@ -115,11 +116,85 @@ func TestGetAddresses(t *testing.T) {
 		WithArgs(1).
 		WillReturnRows(rows)
 
-	addrs, err := repo.GetAddresses(1)
+	addrs, err := repo.GetAddresses(context.Background(), 1)
 	require.NoError(t, err)
 	assert.Len(t, addrs, 1)
 }
 
+func TestAddAddress_Default(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := &repository.UserRepository{DB: db}
+
+	addr := &model.Address{UserID: 1, City: "City", IsDefaultShipping: true}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "addresses"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mock.ExpectExec(`UPDATE "addresses" SET "is_default_shipping"=.*WHERE \(user_id = \$3 AND id <> \$4\)`).
+		WithArgs(false, sqlmock.AnyArg(), 1, 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err := repo.AddAddress(context.Background(), addr)
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFindAddress(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := &repository.UserRepository{DB: db}
+
+	rows := sqlmock.NewRows([]string{"id", "user_id", "city"}).AddRow(5, 1, "City")
+	mock.ExpectQuery(`SELECT .* FROM "addresses" WHERE \(id = \$1 AND user_id = \$2\)`).
+		WithArgs(5, 1, 1).
+		WillReturnRows(rows)
+
+	addr, err := repo.FindAddress(context.Background(), 1, 5)
+	require.NoError(t, err)
+	assert.Equal(t, "City", addr.City)
+}
+
+func TestUpdateAddress(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := &repository.UserRepository{DB: db}
+
+	addr := &model.Address{Model: gorm.Model{ID: 5}, UserID: 1, City: "City", IsDefaultBilling: true}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "addresses" SET .*"is_default_billing"=.*WHERE .*"id" = \$\d+`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE "addresses" SET "is_default_billing"=.*WHERE \(user_id = \$3 AND id <> \$4\)`).
+		WithArgs(false, sqlmock.AnyArg(), 1, 5).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	err := repo.UpdateAddress(context.Background(), addr)
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteAddress(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := &repository.UserRepository{DB: db}
+
+	// Soft delete scoped to the owner
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "addresses" SET "deleted_at"=.*WHERE \(id = \$2 AND user_id = \$3\)`).
+		WithArgs(sqlmock.AnyArg(), 5, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	require.NoError(t, repo.DeleteAddress(context.Background(), 1, 5))
+
+	// Nothing matched: not the user's address
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "addresses" SET "deleted_at"=`).
+		WithArgs(sqlmock.AnyArg(), 6, 1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	assert.ErrorIs(t, repo.DeleteAddress(context.Background(), 1, 6), gorm.ErrRecordNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestFindAllUsers(t *testing.T) {
 	db, mock := NewMockDB()
 	repo := &repository.UserRepository{DB: db}
@@ -129,7 +204,144 @@ func TestFindAllUsers(t *testing.T) {
 	mock.ExpectQuery(`SELECT .* FROM "users"`).
 		WillReturnRows(rows)
 
-	users, err := repo.FindAllUsers()
+	users, err := repo.FindAllUsers(context.Background())
 	require.NoError(t, err)
 	assert.Len(t, users, 1)
 }
+
+func TestUpdateRole(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := &repository.UserRepository{DB: db}
+
+	history := &model.UserRoleHistory{ChangedBy: 9}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "users" SET "role"=.* WHERE \(id = .* AND role = .*\)`).
+		WithArgs(model.RoleAdmin, sqlmock.AnyArg(), uint(2), model.RoleUser).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "user_role_histories"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	err := repo.UpdateRole(context.Background(), 2, model.RoleUser, model.RoleAdmin, history)
+	require.NoError(t, err)
+	assert.Equal(t, uint(2), history.UserID)
+	assert.Equal(t, model.RoleAdmin, history.ToRole)
+
+	// Role changed underneath us
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "users" SET "role"=`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	err = repo.UpdateRole(context.Background(), 2, model.RoleUser, model.RoleAdmin, &model.UserRoleHistory{})
+	assert.ErrorIs(t, err, repository.ErrRoleConflict)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCountByRole(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := &repository.UserRepository{DB: db}
+
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "users" WHERE role = \$1`).
+		WithArgs(model.RoleAdmin).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+
+	count, err := repo.CountByRole(context.Background(), model.RoleAdmin)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+}
+
+func TestCountByRoleForUpdate(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := &repository.UserRepository{DB: db}
+
+	mock.ExpectQuery(`SELECT "id" FROM "users" WHERE role = \$1 AND "users"."deleted_at" IS NULL FOR UPDATE`).
+		WithArgs(model.RoleAdmin).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(4))
+
+	count, err := repo.CountByRoleForUpdate(context.Background(), model.RoleAdmin)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdatePassword(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := &repository.UserRepository{DB: db}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "users" SET "password"=\$1,"updated_at"=\$2 WHERE id = \$3`).
+		WithArgs("new-hash", sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	require.NoError(t, repo.UpdatePassword(context.Background(), 1, "new-hash"))
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMarkVerified(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := &repository.UserRepository{DB: db}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "users" SET "verified_at"=\$1,"updated_at"=\$2 WHERE id = \$3`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	require.NoError(t, repo.MarkVerified(context.Background(), 1))
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateProfile(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := &repository.UserRepository{DB: db}
+
+	user := &model.User{Model: gorm.Model{ID: 1}, Name: "John", Email: "new@example.com"}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "users" SET .*"name"=.*"email"=.*"verified_at"=.* WHERE .*"id" = `).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	require.NoError(t, repo.UpdateProfile(context.Background(), user))
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAnonymize(t *testing.T) {
+	db, mock := NewMockDB()
+	repo := &repository.UserRepository{DB: db}
+
+	// Case 1: Personal data is replaced, addresses, pending tokens and recovery codes are removed
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "users" SET .*WHERE id = \$\d+ AND "users"."deleted_at" IS NULL`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM "addresses" WHERE user_id = \$1`).
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(`DELETE FROM "one_time_tokens" WHERE user_id = \$1`).
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM "mfa_recovery_codes" WHERE user_id = \$1`).
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	require.NoError(t, repo.Anonymize(context.Background(), 1))
+
+	// Case 2: Unknown or already deleted user
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "users" SET`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	assert.ErrorIs(t, repo.Anonymize(context.Background(), 2), gorm.ErrRecordNotFound)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -1,24 +1,73 @@
 package service
 
 import (
+	"context"
 	"errors"
+	"time"
 
 	"github.com/beingaloksharma/book-backend/internal/model"
 	"github.com/beingaloksharma/book-backend/internal/repository"
 	"github.com/beingaloksharma/book-backend/utils/crypto"
 	"github.com/beingaloksharma/book-backend/utils/token"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
 )
 
 type AuthService struct {
-	Repo repository.UserRepositoryInterface
+	Repo         repository.UserRepositoryInterface
+	TokenRepo    repository.RefreshTokenRepositoryInterface
+	Verification VerificationServiceInterface
+	Guard        LoginGuardInterface
+	MFA          MFAServiceInterface
 }
 
-func NewAuthService(repo repository.UserRepositoryInterface) *AuthService {
-	return &AuthService{Repo: repo}
+func NewAuthService(repo repository.UserRepositoryInterface, tokenRepo repository.RefreshTokenRepositoryInterface, verification VerificationServiceInterface, guard LoginGuardInterface, mfa MFAServiceInterface) *AuthService {
+	return &AuthService{
+		Repo:         repo,
+		TokenRepo:    tokenRepo,
+		Verification: verification,
+		Guard:        guard,
+		MFA:          mfa,
+	}
+}
+
+// TokenPair is what a client receives when a session starts or is refreshed.
+type TokenPair struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"` // Access token lifetime in seconds
+}
+
+// LoginResult is the outcome of a login with the right password: either a new
+// session, or a challenge to answer with a second factor first.
+type LoginResult struct {
+	Tokens    *TokenPair
+	Challenge *MFAChallenge
+}
+
+// MFAChallenge asks for a second factor. The token goes back with the code to
+// VerifyMFA; if EnrollmentRequired, the user has to set up MFA first through
+// BeginMFAEnrollment.
+type MFAChallenge struct {
+	MFARequired        bool   `json:"mfa_required"`
+	ChallengeToken     string `json:"mfa_token"`
+	ExpiresIn          int64  `json:"expires_in"` // Challenge lifetime in seconds
+	EnrollmentRequired bool   `json:"enrollment_required"`
+}
+
+// MFALoginResult completes a challenged login. RecoveryCodes are set when the
+// login also completed MFA enrollment; they are not shown again.
+type MFALoginResult struct {
+	TokenPair
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
 }
 
-func (s *AuthService) Signup(name, email, password string, role model.Role) error {
-	existing, _ := s.Repo.FindByEmail(email)
+// Signup registers a regular user and emails them a verification link. Admins
+// are created by promoting an existing user or through BootstrapAdmin, never
+// through signup.
+func (s *AuthService) Signup(ctx context.Context, name, email, password string) error {
+	existing, _ := s.Repo.FindByEmail(ctx, email)
 	if existing != nil {
 		return errors.New("user already exists")
 	}
@@ -28,30 +77,281 @@ func (s *AuthService) Signup(name, email, password string, role model.Role) erro
 		return err
 	}
 
-	// Validate Role
-	if role == "" {
-		role = model.RoleUser
-	}
-
 	user := &model.User{
 		Name:     name,
 		Email:    email,
 		Password: hashedPwd,
-		Role:     role,
+		Role:     model.RoleUser,
+	}
+
+	if err := s.Repo.CreateUser(ctx, user); err != nil {
+		return err
+	}
+
+	// The account exists either way; the user can ask for the link again
+	if err := s.Verification.SendVerification(ctx, user); err != nil {
+		logrus.WithError(err).WithField("user_id", user.ID).Warn("Failed to send verification email")
+	}
+	return nil
+}
+
+// BootstrapAdmin makes sure the store has an administrator. While no admin
+// exists, the account with the given email is promoted, and created first if
+// needed. Since anyone may sign up with that email first, an existing account
+// is only promoted if it is verified and password is its password; otherwise
+// ErrBootstrapAdminUnproven is returned. It reports whether an admin was
+// bootstrapped.
+func (s *AuthService) BootstrapAdmin(ctx context.Context, name, email, password string) (bool, error) {
+	admins, err := s.Repo.CountByRole(ctx, model.RoleAdmin)
+	if err != nil {
+		return false, err
+	}
+	if admins > 0 {
+		return false, nil
+	}
+
+	user, err := s.Repo.FindByEmail(ctx, email)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, err
+	}
+	if user != nil {
+		if !user.IsVerified() || !crypto.CheckPasswordHash(password, user.Password) {
+			return false, ErrBootstrapAdminUnproven
+		}
+	} else {
+		if password == "" {
+			return false, errors.New("a password is required to create the bootstrap admin")
+		}
+		hashedPwd, err := crypto.HashPassword(password)
+		if err != nil {
+			return false, err
+		}
+		// The operator chose this address, so it needs no verification
+		now := time.Now()
+		user = &model.User{Name: name, Email: email, Password: hashedPwd, Role: model.RoleUser, VerifiedAt: &now}
+		if err := s.Repo.CreateUser(ctx, user); err != nil {
+			return false, err
+		}
 	}
 
-	return s.Repo.CreateUser(user)
+	history := &model.UserRoleHistory{Note: "bootstrap admin"}
+	if err := s.Repo.UpdateRole(ctx, user.ID, user.Role, model.RoleAdmin, history); err != nil {
+		return false, err
+	}
+	return true, nil
 }
 
-func (s *AuthService) Login(email, password string) (string, error) {
-	user, err := s.Repo.FindByEmail(email)
+// Login checks the credentials and starts a new session, or returns an MFA
+// challenge if the account uses a second factor or its role requires one.
+// Repeated failures for the account or from the client address ip are
+// throttled by the Guard.
+func (s *AuthService) Login(ctx context.Context, email, password, ip string) (*LoginResult, error) {
+	if err := s.Guard.Check(ctx, email, ip); err != nil {
+		return nil, err
+	}
+
+	user, err := s.Repo.FindByEmail(ctx, email)
 	if err != nil {
-		return "", errors.New("invalid credentials")
+		return nil, s.loginFailed(ctx, email, ip)
 	}
 
 	if !crypto.CheckPasswordHash(password, user.Password) {
-		return "", errors.New("invalid credentials")
+		return nil, s.loginFailed(ctx, email, ip)
+	}
+	// With MFA the login only succeeds once VerifyMFA accepts a code, so the
+	// failures stay counted until then and wrong codes can still lock the account
+	needsMFA := user.MFAEnabled() || s.MFA.Required(user.Role)
+	if !needsMFA {
+		if err := s.Guard.RecordSuccess(ctx, email); err != nil {
+			logrus.WithError(err).Warn("Failed to reset login attempts")
+		}
+	}
+	if verificationRequired(VerificationPolicyLogin) && !user.IsVerified() {
+		return nil, ErrEmailNotVerified
+	}
+
+	if needsMFA {
+		challenge, err := token.GenerateChallengeToken(user.ID)
+		if err != nil {
+			return nil, err
+		}
+		return &LoginResult{Challenge: &MFAChallenge{
+			MFARequired:        true,
+			ChallengeToken:     challenge,
+			ExpiresIn:          int64(token.ChallengeTTL().Seconds()),
+			EnrollmentRequired: !user.MFAEnabled(),
+		}}, nil
+	}
+
+	pair, err := s.startSession(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	return &LoginResult{Tokens: pair}, nil
+}
+
+// BeginMFAEnrollment sets up MFA for a user whose role requires it but who has
+// none yet, during login. The code from the new secret then goes to VerifyMFA.
+func (s *AuthService) BeginMFAEnrollment(ctx context.Context, challengeToken string) (*MFAEnrollment, error) {
+	userID, err := token.ValidateChallengeToken(challengeToken)
+	if err != nil {
+		return nil, ErrInvalidMFAChallenge
+	}
+	return s.MFA.BeginEnrollment(ctx, userID)
+}
+
+// VerifyMFA answers a login challenge with a TOTP or recovery code and starts
+// the session. For a user still enrolling, the code confirms the enrollment.
+// Wrong codes count as failed logins.
+func (s *AuthService) VerifyMFA(ctx context.Context, challengeToken, code, ip string) (*MFALoginResult, error) {
+	userID, err := token.ValidateChallengeToken(challengeToken)
+	if err != nil {
+		return nil, ErrInvalidMFAChallenge
+	}
+	user, err := s.Repo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, ErrInvalidMFAChallenge
+	}
+	if err := s.Guard.Check(ctx, user.Email, ip); err != nil {
+		return nil, err
+	}
+
+	var recoveryCodes []string
+	if user.MFAEnabled() {
+		err = s.MFA.VerifyCode(ctx, user.ID, code)
+	} else {
+		recoveryCodes, err = s.MFA.ConfirmEnrollment(ctx, user.ID, code)
+	}
+	if errors.Is(err, ErrInvalidMFACode) {
+		if err := s.Guard.RecordFailure(ctx, user.Email, ip); err != nil {
+			logrus.WithError(err).Warn("Failed to record login attempt")
+		}
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Guard.RecordSuccess(ctx, user.Email); err != nil {
+		logrus.WithError(err).Warn("Failed to reset login attempts")
+	}
+
+	pair, err := s.startSession(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	return &MFALoginResult{TokenPair: *pair, RecoveryCodes: recoveryCodes}, nil
+}
+
+// startSession opens a new session for user and returns its first tokens.
+func (s *AuthService) startSession(ctx context.Context, user *model.User) (*TokenPair, error) {
+	familyID, err := token.NewSessionID()
+	if err != nil {
+		return nil, err
+	}
+	pair, record, err := s.issueTokens(user, familyID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.TokenRepo.Create(ctx, record); err != nil {
+		return nil, err
+	}
+	return pair, nil
+}
+
+// loginFailed counts the failure and returns the error for bad credentials.
+// Unknown accounts are counted too, so the answers look the same.
+func (s *AuthService) loginFailed(ctx context.Context, email, ip string) error {
+	if err := s.Guard.RecordFailure(ctx, email, ip); err != nil {
+		logrus.WithError(err).Warn("Failed to record login attempt")
+	}
+	return errors.New("invalid credentials")
+}
+
+// Refresh exchanges a refresh token for a new token pair in the same session.
+// Each refresh token works once; presenting one that was already exchanged
+// means it leaked, so the whole session is revoked.
+func (s *AuthService) Refresh(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	current, err := s.TokenRepo.FindByHash(ctx, token.HashRefreshToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, err
+	}
+	if current.RotatedAt != nil {
+		return nil, s.revokeReusedFamily(ctx, current.FamilyID)
+	}
+	if current.RevokedAt != nil || time.Now().After(current.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	// Reload the user so role changes take effect on refresh
+	user, err := s.Repo.FindByID(ctx, current.UserID)
+	if err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	pair, next, err := s.issueTokens(user, current.FamilyID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.TokenRepo.Rotate(ctx, current, next); err != nil {
+		if errors.Is(err, repository.ErrTokenAlreadyRotated) {
+			return nil, s.revokeReusedFamily(ctx, current.FamilyID)
+		}
+		return nil, err
+	}
+	return pair, nil
+}
+
+// Logout ends the session the refresh token belongs to. Unknown tokens are
+// ignored so logging out twice is harmless.
+func (s *AuthService) Logout(ctx context.Context, refreshToken string) error {
+	current, err := s.TokenRepo.FindByHash(ctx, token.HashRefreshToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	return s.TokenRepo.RevokeFamily(ctx, current.FamilyID)
+}
+
+// LogoutAll ends every session of the user.
+func (s *AuthService) LogoutAll(ctx context.Context, userID uint) error {
+	return s.TokenRepo.RevokeAllForUser(ctx, userID)
+}
+
+func (s *AuthService) revokeReusedFamily(ctx context.Context, familyID string) error {
+	if err := s.TokenRepo.RevokeFamily(ctx, familyID); err != nil {
+		return err
+	}
+	return ErrRefreshTokenReused
+}
+
+// issueTokens signs an access token for the session and builds the refresh
+// token record to persist alongside it.
+func (s *AuthService) issueTokens(user *model.User, familyID string) (*TokenPair, *model.RefreshToken, error) {
+	accessToken, err := token.GenerateToken(user.ID, string(user.Role), familyID)
+	if err != nil {
+		return nil, nil, err
+	}
+	refreshToken, hash, err := token.NewRefreshToken()
+	if err != nil {
+		return nil, nil, err
 	}
 
-	return token.GenerateToken(user.ID, string(user.Role))
+	record := &model.RefreshToken{
+		UserID:    user.ID,
+		FamilyID:  familyID,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(token.RefreshTokenTTL()),
+	}
+	pair := &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(token.AccessTokenTTL().Seconds()),
+	}
+	return pair, record, nil
 }
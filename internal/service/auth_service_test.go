@@ -1,12 +1,16 @@
 package service_test
 
 import (
+	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/beingaloksharma/book-backend/internal/model"
+	"github.com/beingaloksharma/book-backend/internal/repository"
 	"github.com/beingaloksharma/book-backend/internal/repository/mocks"
 	"github.com/beingaloksharma/book-backend/internal/service"
+	servicemocks "github.com/beingaloksharma/book-backend/internal/service/mocks"
 	"github.com/beingaloksharma/book-backend/utils/crypto"
 	"github.com/beingaloksharma/book-backend/utils/token"
 	"github.com/spf13/viper"
@@ -17,24 +21,36 @@ import (
 
 func TestSignup(t *testing.T) {
 	mockRepo := new(mocks.MockUserRepository)
-	authService := service.NewAuthService(mockRepo)
+	mockVerification := new(servicemocks.MockVerificationService)
+	authService := service.NewAuthService(mockRepo, new(mocks.MockRefreshTokenRepository), mockVerification, new(servicemocks.MockLoginGuard), new(servicemocks.MockMFAService))
 
-	// Case 1: Success
-	mockRepo.On("FindByEmail", "john@example.com").Return(nil, nil).Once()
-	mockRepo.On("CreateUser", mock.Anything).Return(nil).Once()
+	// Case 1: Success creates an unverified account and sends the verification email
+	mockRepo.On("FindByEmail", mock.Anything, "john@example.com").Return(nil, nil).Once()
+	mockRepo.On("CreateUser", mock.Anything, mock.MatchedBy(func(u *model.User) bool {
+		return u.Role == model.RoleUser && u.VerifiedAt == nil
+	})).Return(nil).Once()
+	mockVerification.On("SendVerification", mock.Anything, mock.AnythingOfType("*model.User")).Return(nil).Once()
 
-	err := authService.Signup("John", "john@example.com", "password123", model.RoleUser)
+	err := authService.Signup(context.Background(), "John", "john@example.com", "password123")
 	assert.NoError(t, err)
 
 	// Case 2: User exists
 	existingUser := &model.User{Email: "john@example.com"}
-	mockRepo.On("FindByEmail", "john@example.com").Return(existingUser, nil).Once()
+	mockRepo.On("FindByEmail", mock.Anything, "john@example.com").Return(existingUser, nil).Once()
 
-	err = authService.Signup("John", "john@example.com", "password123", model.RoleUser)
+	err = authService.Signup(context.Background(), "John", "john@example.com", "password123")
 	assert.Error(t, err)
 	assert.Equal(t, "user already exists", err.Error())
 
+	// Case 3: Failed delivery does not fail signup
+	mockRepo.On("FindByEmail", mock.Anything, "jane@example.com").Return(nil, nil).Once()
+	mockRepo.On("CreateUser", mock.Anything, mock.AnythingOfType("*model.User")).Return(nil).Once()
+	mockVerification.On("SendVerification", mock.Anything, mock.AnythingOfType("*model.User")).Return(errors.New("smtp down")).Once()
+
+	assert.NoError(t, authService.Signup(context.Background(), "Jane", "jane@example.com", "password123"))
+
 	mockRepo.AssertExpectations(t)
+	mockVerification.AssertExpectations(t)
 }
 
 func TestLogin(t *testing.T) {
@@ -43,7 +59,12 @@ func TestLogin(t *testing.T) {
 	token.Init()
 
 	mockRepo := new(mocks.MockUserRepository)
-	authService := service.NewAuthService(mockRepo)
+	mockTokenRepo := new(mocks.MockRefreshTokenRepository)
+	mockGuard := new(servicemocks.MockLoginGuard)
+	mockMFA := new(servicemocks.MockMFAService)
+	authService := service.NewAuthService(mockRepo, mockTokenRepo, new(servicemocks.MockVerificationService), mockGuard, mockMFA)
+	mockGuard.On("Check", mock.Anything, mock.Anything, "10.0.0.1").Return(nil)
+	mockMFA.On("Required", model.RoleUser).Return(false)
 
 	password := "password123"
 	hashedPwd, _ := crypto.HashPassword(password)
@@ -54,26 +75,327 @@ func TestLogin(t *testing.T) {
 		Role:     model.RoleUser,
 	}
 
-	// Case 1: Success
-	mockRepo.On("FindByEmail", "john@example.com").Return(user, nil).Once()
+	// Case 1: Success starts a session with a hashed refresh token
+	mockRepo.On("FindByEmail", mock.Anything, "john@example.com").Return(user, nil).Once()
+	mockTokenRepo.On("Create", mock.Anything, mock.AnythingOfType("*model.RefreshToken")).Return(nil).Once()
+	mockGuard.On("RecordSuccess", mock.Anything, "john@example.com").Return(nil).Once()
+
+	result, err := authService.Login(context.Background(), "john@example.com", password, "10.0.0.1")
+	assert.NoError(t, err)
+	assert.Nil(t, result.Challenge)
+	pair := result.Tokens
+	assert.NotEmpty(t, pair.AccessToken)
+	assert.NotEmpty(t, pair.RefreshToken)
+
+	stored := mockTokenRepo.Calls[0].Arguments.Get(1).(*model.RefreshToken)
+	assert.Equal(t, uint(1), stored.UserID)
+	assert.Equal(t, token.HashRefreshToken(pair.RefreshToken), stored.TokenHash)
+	assert.NotEqual(t, pair.RefreshToken, stored.TokenHash)
 
-	val, err := authService.Login("john@example.com", password)
+	claims, err := token.ValidateToken(pair.AccessToken)
 	assert.NoError(t, err)
-	assert.NotEmpty(t, val)
+	assert.Equal(t, stored.FamilyID, claims.SessionID)
 
 	// Case 2: User not found
-	mockRepo.On("FindByEmail", "unknown@example.com").Return(nil, errors.New("not found")).Once()
+	mockRepo.On("FindByEmail", mock.Anything, "unknown@example.com").Return(nil, errors.New("not found")).Once()
+	mockGuard.On("RecordFailure", mock.Anything, "unknown@example.com", "10.0.0.1").Return(nil).Once()
 
-	_, err = authService.Login("unknown@example.com", password)
+	_, err = authService.Login(context.Background(), "unknown@example.com", password, "10.0.0.1")
 	assert.Error(t, err)
 	assert.Equal(t, "invalid credentials", err.Error())
 
-	// Case 3: Wrong password
-	mockRepo.On("FindByEmail", "john@example.com").Return(user, nil).Once()
+	// Case 3: Wrong password is counted
+	mockRepo.On("FindByEmail", mock.Anything, "john@example.com").Return(user, nil).Once()
+	mockGuard.On("RecordFailure", mock.Anything, "john@example.com", "10.0.0.1").Return(nil).Once()
 
-	_, err = authService.Login("john@example.com", "wrongpass")
+	_, err = authService.Login(context.Background(), "john@example.com", "wrongpass", "10.0.0.1")
 	assert.Error(t, err)
 	assert.Equal(t, "invalid credentials", err.Error())
 
+	// Case 4: Unverified account is refused when the policy covers login
+	viper.Set("email_verification.require", "login")
+	defer viper.Set("email_verification.require", "")
+	mockRepo.On("FindByEmail", mock.Anything, "john@example.com").Return(user, nil).Once()
+	mockGuard.On("RecordSuccess", mock.Anything, "john@example.com").Return(nil).Once()
+
+	_, err = authService.Login(context.Background(), "john@example.com", password, "10.0.0.1")
+	assert.ErrorIs(t, err, service.ErrEmailNotVerified)
+
+	// Case 5: Throttled attempts never reach the password check
+	mockGuard.On("Check", mock.Anything, "locked@example.com", "10.0.0.2").Return(&service.LoginThrottledError{RetryAfter: time.Minute, Locked: true}).Once()
+
+	_, err = authService.Login(context.Background(), "locked@example.com", password, "10.0.0.2")
+	assert.ErrorIs(t, err, service.ErrTooManyLoginAttempts)
+
+	// Case 6: MFA users get a challenge instead of a session, and their
+	// failures are only cleared once the code is right
+	enabledAt := time.Now()
+	mfaUser := &model.User{Model: gorm.Model{ID: 2}, Email: "mfa@example.com", Password: hashedPwd, Role: model.RoleUser, VerifiedAt: &enabledAt, MFAEnabledAt: &enabledAt}
+	mockRepo.On("FindByEmail", mock.Anything, "mfa@example.com").Return(mfaUser, nil).Once()
+
+	result, err = authService.Login(context.Background(), "mfa@example.com", password, "10.0.0.1")
+	assert.NoError(t, err)
+	assert.Nil(t, result.Tokens)
+	assert.True(t, result.Challenge.MFARequired)
+	assert.False(t, result.Challenge.EnrollmentRequired)
+	userID, err := token.ValidateChallengeToken(result.Challenge.ChallengeToken)
+	assert.NoError(t, err)
+	assert.Equal(t, uint(2), userID)
+
+	// Case 7: Roles that require MFA must enroll before getting a session
+	admin := &model.User{Model: gorm.Model{ID: 3}, Email: "admin@example.com", Password: hashedPwd, Role: model.RoleAdmin, VerifiedAt: &enabledAt}
+	mockRepo.On("FindByEmail", mock.Anything, "admin@example.com").Return(admin, nil).Once()
+	mockMFA.On("Required", model.RoleAdmin).Return(true).Once()
+
+	result, err = authService.Login(context.Background(), "admin@example.com", password, "10.0.0.1")
+	assert.NoError(t, err)
+	assert.Nil(t, result.Tokens)
+	assert.True(t, result.Challenge.EnrollmentRequired)
+
+	mockRepo.AssertExpectations(t)
+	mockTokenRepo.AssertExpectations(t)
+	mockGuard.AssertExpectations(t)
+	mockMFA.AssertExpectations(t)
+}
+
+func TestBeginMFAEnrollment(t *testing.T) {
+	viper.Set("jwt.secret", "testsecret")
+	token.Init()
+
+	mockMFA := new(servicemocks.MockMFAService)
+	authService := service.NewAuthService(new(mocks.MockUserRepository), new(mocks.MockRefreshTokenRepository), new(servicemocks.MockVerificationService), new(servicemocks.MockLoginGuard), mockMFA)
+
+	// Case 1: A valid challenge starts enrollment for its user
+	challenge, _ := token.GenerateChallengeToken(3)
+	enrollment := &service.MFAEnrollment{Secret: "SECRET", URI: "otpauth://totp/x"}
+	mockMFA.On("BeginEnrollment", mock.Anything, uint(3)).Return(enrollment, nil).Once()
+
+	got, err := authService.BeginMFAEnrollment(context.Background(), challenge)
+	assert.NoError(t, err)
+	assert.Equal(t, enrollment, got)
+
+	// Case 2: Access tokens are not challenges
+	access, _ := token.GenerateToken(3, "ADMIN", "session")
+	_, err = authService.BeginMFAEnrollment(context.Background(), access)
+	assert.ErrorIs(t, err, service.ErrInvalidMFAChallenge)
+
+	mockMFA.AssertExpectations(t)
+}
+
+func TestVerifyMFA(t *testing.T) {
+	viper.Set("jwt.secret", "testsecret")
+	token.Init()
+
+	mockRepo := new(mocks.MockUserRepository)
+	mockTokenRepo := new(mocks.MockRefreshTokenRepository)
+	mockGuard := new(servicemocks.MockLoginGuard)
+	mockMFA := new(servicemocks.MockMFAService)
+	authService := service.NewAuthService(mockRepo, mockTokenRepo, new(servicemocks.MockVerificationService), mockGuard, mockMFA)
+
+	enabledAt := time.Now()
+	user := &model.User{Model: gorm.Model{ID: 2}, Email: "mfa@example.com", Role: model.RoleUser, MFAEnabledAt: &enabledAt}
+	challenge, _ := token.GenerateChallengeToken(2)
+	mockRepo.On("FindByID", mock.Anything, uint(2)).Return(user, nil)
+	mockGuard.On("Check", mock.Anything, "mfa@example.com", "10.0.0.1").Return(nil)
+
+	// Case 1: A correct code starts the session
+	mockMFA.On("VerifyCode", mock.Anything, uint(2), "123456").Return(nil).Once()
+	mockGuard.On("RecordSuccess", mock.Anything, "mfa@example.com").Return(nil).Once()
+	mockTokenRepo.On("Create", mock.Anything, mock.AnythingOfType("*model.RefreshToken")).Return(nil).Once()
+
+	result, err := authService.VerifyMFA(context.Background(), challenge, "123456", "10.0.0.1")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, result.AccessToken)
+	assert.Empty(t, result.RecoveryCodes)
+
+	// Case 2: A wrong code counts as a failed login
+	mockMFA.On("VerifyCode", mock.Anything, uint(2), "000000").Return(service.ErrInvalidMFACode).Once()
+	mockGuard.On("RecordFailure", mock.Anything, "mfa@example.com", "10.0.0.1").Return(nil).Once()
+
+	_, err = authService.VerifyMFA(context.Background(), challenge, "000000", "10.0.0.1")
+	assert.ErrorIs(t, err, service.ErrInvalidMFACode)
+
+	// Case 3: Enrolling users confirm their new secret and get recovery codes
+	admin := &model.User{Model: gorm.Model{ID: 3}, Email: "admin@example.com", Role: model.RoleAdmin}
+	adminChallenge, _ := token.GenerateChallengeToken(3)
+	mockRepo.On("FindByID", mock.Anything, uint(3)).Return(admin, nil).Once()
+	mockGuard.On("Check", mock.Anything, "admin@example.com", "10.0.0.1").Return(nil).Once()
+	mockMFA.On("ConfirmEnrollment", mock.Anything, uint(3), "654321").Return([]string{"aaaa-bbbb-cccc-dddd"}, nil).Once()
+	mockGuard.On("RecordSuccess", mock.Anything, "admin@example.com").Return(nil).Once()
+	mockTokenRepo.On("Create", mock.Anything, mock.AnythingOfType("*model.RefreshToken")).Return(nil).Once()
+
+	result, err = authService.VerifyMFA(context.Background(), adminChallenge, "654321", "10.0.0.1")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, result.RefreshToken)
+	assert.Equal(t, []string{"aaaa-bbbb-cccc-dddd"}, result.RecoveryCodes)
+
+	// Case 4: Invalid challenge
+	_, err = authService.VerifyMFA(context.Background(), "garbage", "123456", "10.0.0.1")
+	assert.ErrorIs(t, err, service.ErrInvalidMFAChallenge)
+
+	mockRepo.AssertExpectations(t)
+	mockTokenRepo.AssertExpectations(t)
+	mockGuard.AssertExpectations(t)
+	mockMFA.AssertExpectations(t)
+}
+
+func TestRefresh(t *testing.T) {
+	viper.Set("jwt.secret", "testsecret")
+	token.Init()
+
+	mockRepo := new(mocks.MockUserRepository)
+	mockTokenRepo := new(mocks.MockRefreshTokenRepository)
+	authService := service.NewAuthService(mockRepo, mockTokenRepo, new(servicemocks.MockVerificationService), new(servicemocks.MockLoginGuard), new(servicemocks.MockMFAService))
+
+	user := &model.User{Model: gorm.Model{ID: 1}, Role: model.RoleAdmin}
+	hash := token.HashRefreshToken("refresh-1")
+	current := &model.RefreshToken{Model: gorm.Model{ID: 7}, UserID: 1, FamilyID: "family-1", TokenHash: hash, ExpiresAt: time.Now().Add(time.Hour)}
+
+	// Case 1: Success rotates within the same family
+	mockTokenRepo.On("FindByHash", mock.Anything, hash).Return(current, nil).Once()
+	mockRepo.On("FindByID", mock.Anything, uint(1)).Return(user, nil).Once()
+	mockTokenRepo.On("Rotate", mock.Anything, current, mock.MatchedBy(func(next *model.RefreshToken) bool {
+		return next.FamilyID == "family-1" && next.UserID == 1 && next.TokenHash != hash
+	})).Return(nil).Once()
+
+	pair, err := authService.Refresh(context.Background(), "refresh-1")
+	assert.NoError(t, err)
+	assert.NotEqual(t, "refresh-1", pair.RefreshToken)
+	claims, err := token.ValidateToken(pair.AccessToken)
+	assert.NoError(t, err)
+	assert.Equal(t, "ADMIN", claims.Role)
+
+	// Case 2: Unknown token
+	mockTokenRepo.On("FindByHash", mock.Anything, token.HashRefreshToken("bogus")).Return(nil, gorm.ErrRecordNotFound).Once()
+
+	_, err = authService.Refresh(context.Background(), "bogus")
+	assert.ErrorIs(t, err, service.ErrInvalidRefreshToken)
+
+	// Case 3: Replaying a rotated token revokes the whole family
+	rotatedAt := time.Now()
+	rotated := *current
+	rotated.RotatedAt = &rotatedAt
+	mockTokenRepo.On("FindByHash", mock.Anything, hash).Return(&rotated, nil).Once()
+	mockTokenRepo.On("RevokeFamily", mock.Anything, "family-1").Return(nil).Once()
+
+	_, err = authService.Refresh(context.Background(), "refresh-1")
+	assert.ErrorIs(t, err, service.ErrRefreshTokenReused)
+
+	// Case 4: Losing a concurrent rotation counts as reuse too
+	mockTokenRepo.On("FindByHash", mock.Anything, hash).Return(current, nil).Once()
+	mockRepo.On("FindByID", mock.Anything, uint(1)).Return(user, nil).Once()
+	mockTokenRepo.On("Rotate", mock.Anything, current, mock.Anything).Return(repository.ErrTokenAlreadyRotated).Once()
+	mockTokenRepo.On("RevokeFamily", mock.Anything, "family-1").Return(nil).Once()
+
+	_, err = authService.Refresh(context.Background(), "refresh-1")
+	assert.ErrorIs(t, err, service.ErrRefreshTokenReused)
+
+	// Case 5: Expired token
+	expired := *current
+	expired.ExpiresAt = time.Now().Add(-time.Minute)
+	mockTokenRepo.On("FindByHash", mock.Anything, hash).Return(&expired, nil).Once()
+
+	_, err = authService.Refresh(context.Background(), "refresh-1")
+	assert.ErrorIs(t, err, service.ErrInvalidRefreshToken)
+
+	// Case 6: Revoked token (logged out)
+	revoked := *current
+	revoked.RevokedAt = &rotatedAt
+	mockTokenRepo.On("FindByHash", mock.Anything, hash).Return(&revoked, nil).Once()
+
+	_, err = authService.Refresh(context.Background(), "refresh-1")
+	assert.ErrorIs(t, err, service.ErrInvalidRefreshToken)
+
+	mockRepo.AssertExpectations(t)
+	mockTokenRepo.AssertExpectations(t)
+}
+
+func TestLogout(t *testing.T) {
+	mockRepo := new(mocks.MockUserRepository)
+	mockTokenRepo := new(mocks.MockRefreshTokenRepository)
+	authService := service.NewAuthService(mockRepo, mockTokenRepo, new(servicemocks.MockVerificationService), new(servicemocks.MockLoginGuard), new(servicemocks.MockMFAService))
+
+	hash := token.HashRefreshToken("refresh-1")
+
+	// Case 1: Revokes the session
+	mockTokenRepo.On("FindByHash", mock.Anything, hash).Return(&model.RefreshToken{FamilyID: "family-1"}, nil).Once()
+	mockTokenRepo.On("RevokeFamily", mock.Anything, "family-1").Return(nil).Once()
+	assert.NoError(t, authService.Logout(context.Background(), "refresh-1"))
+
+	// Case 2: Unknown token is ignored
+	mockTokenRepo.On("FindByHash", mock.Anything, hash).Return(nil, gorm.ErrRecordNotFound).Once()
+	assert.NoError(t, authService.Logout(context.Background(), "refresh-1"))
+
+	// Case 3: Logout everywhere
+	mockTokenRepo.On("RevokeAllForUser", mock.Anything, uint(1)).Return(nil).Once()
+	assert.NoError(t, authService.LogoutAll(context.Background(), 1))
+
+	mockTokenRepo.AssertExpectations(t)
+}
+
+func TestBootstrapAdmin(t *testing.T) {
+	mockRepo := new(mocks.MockUserRepository)
+	authService := service.NewAuthService(mockRepo, new(mocks.MockRefreshTokenRepository), new(servicemocks.MockVerificationService), new(servicemocks.MockLoginGuard), new(servicemocks.MockMFAService))
+
+	// Case 1: An admin already exists
+	mockRepo.On("CountByRole", mock.Anything, model.RoleAdmin).Return(int64(1), nil).Once()
+
+	created, err := authService.BootstrapAdmin(context.Background(), "Root", "root@example.com", "password123")
+	assert.NoError(t, err)
+	assert.False(t, created)
+
+	// Case 2: Existing verified user with the configured password is promoted
+	hashed, _ := crypto.HashPassword("password123")
+	now := time.Now()
+	existing := &model.User{Model: gorm.Model{ID: 4}, Email: "root@example.com", Password: hashed, Role: model.RoleUser, VerifiedAt: &now}
+	mockRepo.On("CountByRole", mock.Anything, model.RoleAdmin).Return(int64(0), nil).Once()
+	mockRepo.On("FindByEmail", mock.Anything, "root@example.com").Return(existing, nil).Once()
+	mockRepo.On("UpdateRole", mock.Anything, uint(4), model.RoleUser, model.RoleAdmin, mock.MatchedBy(func(h *model.UserRoleHistory) bool {
+		return h.ChangedBy == 0 && h.Note == "bootstrap admin"
+	})).Return(nil).Once()
+
+	created, err = authService.BootstrapAdmin(context.Background(), "Root", "root@example.com", "password123")
+	assert.NoError(t, err)
+	assert.True(t, created)
+
+	// Case 3: An account someone else registered under the email is not promoted
+	squatter := &model.User{Model: gorm.Model{ID: 6}, Email: "root@example.com", Password: hashed, Role: model.RoleUser}
+	for _, tc := range []struct {
+		user     *model.User
+		password string
+	}{
+		{squatter, "password123"}, // not verified
+		{existing, "wrong-password"},
+		{existing, ""},
+	} {
+		mockRepo.On("CountByRole", mock.Anything, model.RoleAdmin).Return(int64(0), nil).Once()
+		mockRepo.On("FindByEmail", mock.Anything, "root@example.com").Return(tc.user, nil).Once()
+
+		created, err = authService.BootstrapAdmin(context.Background(), "Root", "root@example.com", tc.password)
+		assert.ErrorIs(t, err, service.ErrBootstrapAdminUnproven)
+		assert.False(t, created)
+	}
+
+	// Case 4: Account is created, then promoted
+	mockRepo.On("CountByRole", mock.Anything, model.RoleAdmin).Return(int64(0), nil).Once()
+	mockRepo.On("FindByEmail", mock.Anything, "new@example.com").Return(nil, gorm.ErrRecordNotFound).Once()
+	mockRepo.On("CreateUser", mock.Anything, mock.MatchedBy(func(u *model.User) bool {
+		return u.Email == "new@example.com" && u.Role == model.RoleUser && u.Password != "password123" && u.IsVerified()
+	})).Run(func(args mock.Arguments) {
+		args.Get(1).(*model.User).ID = 5
+	}).Return(nil).Once()
+	mockRepo.On("UpdateRole", mock.Anything, uint(5), model.RoleUser, model.RoleAdmin, mock.Anything).Return(nil).Once()
+
+	created, err = authService.BootstrapAdmin(context.Background(), "Root", "new@example.com", "password123")
+	assert.NoError(t, err)
+	assert.True(t, created)
+
+	// Case 5: No password for a new account
+	mockRepo.On("CountByRole", mock.Anything, model.RoleAdmin).Return(int64(0), nil).Once()
+	mockRepo.On("FindByEmail", mock.Anything, "nopass@example.com").Return(nil, gorm.ErrRecordNotFound).Once()
+
+	_, err = authService.BootstrapAdmin(context.Background(), "Root", "nopass@example.com", "")
+	assert.Error(t, err)
+
 	mockRepo.AssertExpectations(t)
 }
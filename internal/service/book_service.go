@@ -1,21 +1,27 @@
 package service
 
 import (
+	"context"
 	"errors"
+	"strings"
 
 	"github.com/beingaloksharma/book-backend/internal/model"
 	"github.com/beingaloksharma/book-backend/internal/repository"
 )
 
 type BookService struct {
-	Repo repository.BookRepositoryInterface
+	Repo       repository.BookRepositoryInterface
+	SearchRepo repository.BookSearchRepositoryInterface
 }
 
-func NewBookService(repo repository.BookRepositoryInterface) *BookService {
-	return &BookService{Repo: repo}
+func NewBookService(repo repository.BookRepositoryInterface, searchRepo repository.BookSearchRepositoryInterface) *BookService {
+	return &BookService{
+		Repo:       repo,
+		SearchRepo: searchRepo,
+	}
 }
 
-func (s *BookService) CreateBook(title, author, description string, price float64, stock int) error {
+func (s *BookService) CreateBook(ctx context.Context, title, author, description string, price float64, stock int) error {
 	book := &model.Book{
 		Title:       title,
 		Author:      author,
@@ -23,11 +29,11 @@ func (s *BookService) CreateBook(title, author, description string, price float6
 		Price:       price,
 		Stock:       stock,
 	}
-	return s.Repo.CreateBook(book)
+	return s.Repo.CreateBook(ctx, book)
 }
 
-func (s *BookService) UpdateBook(id uint, title, author, description string, price float64, stock int) error {
-	book, err := s.Repo.FindByID(id)
+func (s *BookService) UpdateBook(ctx context.Context, id uint, title, author, description string, price float64, stock int) error {
+	book, err := s.Repo.FindByID(ctx, id)
 	if err != nil {
 		return errors.New("book not found")
 	}
@@ -38,17 +44,25 @@ func (s *BookService) UpdateBook(id uint, title, author, description string, pri
 	book.Price = price
 	book.Stock = stock
 
-	return s.Repo.UpdateBook(book)
+	return s.Repo.UpdateBook(ctx, book)
+}
+
+func (s *BookService) DeleteBook(ctx context.Context, id uint) error {
+	return s.Repo.DeleteBook(ctx, id)
 }
 
-func (s *BookService) DeleteBook(id uint) error {
-	return s.Repo.DeleteBook(id)
+func (s *BookService) GetBook(ctx context.Context, id uint) (*model.Book, error) {
+	return s.Repo.FindByID(ctx, id)
 }
 
-func (s *BookService) GetBook(id uint) (*model.Book, error) {
-	return s.Repo.FindByID(id)
+func (s *BookService) ListBooks(ctx context.Context, query repository.BookQuery) ([]model.Book, int64, error) {
+	return s.Repo.FindByQuery(ctx, query)
 }
 
-func (s *BookService) ListBooks() ([]model.Book, error) {
-	return s.Repo.FindAll()
+func (s *BookService) SearchBooks(ctx context.Context, query repository.BookSearchQuery) ([]repository.BookSearchResult, int64, error) {
+	query.Text = strings.TrimSpace(query.Text)
+	if query.Text == "" {
+		return nil, 0, errors.New("search query is required")
+	}
+	return s.SearchRepo.Search(ctx, query)
 }
@@ -1,23 +1,26 @@
 package service_test
 
 import (
+	"context"
 	"errors"
 	"testing"
 
 	"github.com/beingaloksharma/book-backend/internal/model"
+	"github.com/beingaloksharma/book-backend/internal/repository"
 	"github.com/beingaloksharma/book-backend/internal/repository/mocks"
 	"github.com/beingaloksharma/book-backend/internal/service"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
 )
 
 func TestCreateBook(t *testing.T) {
 	mockRepo := new(mocks.MockBookRepository)
-	bookService := service.NewBookService(mockRepo)
+	bookService := service.NewBookService(mockRepo, repository.NewInMemoryBookSearchRepository())
 
-	mockRepo.On("CreateBook", mock.AnythingOfType("*model.Book")).Return(nil)
+	mockRepo.On("CreateBook", mock.Anything, mock.AnythingOfType("*model.Book")).Return(nil)
 
-	err := bookService.CreateBook("Go", "Google", "Lang", 10.0, 5)
+	err := bookService.CreateBook(context.Background(), "Go", "Google", "Lang", 10.0, 5)
 	assert.NoError(t, err)
 
 	mockRepo.AssertExpectations(t)
@@ -25,20 +28,20 @@ func TestCreateBook(t *testing.T) {
 
 func TestGetBook(t *testing.T) {
 	mockRepo := new(mocks.MockBookRepository)
-	bookService := service.NewBookService(mockRepo)
+	bookService := service.NewBookService(mockRepo, repository.NewInMemoryBookSearchRepository())
 
 	// Success
 	book := &model.Book{Title: "Go"}
-	mockRepo.On("FindByID", uint(1)).Return(book, nil)
+	mockRepo.On("FindByID", mock.Anything, uint(1)).Return(book, nil)
 
-	result, err := bookService.GetBook(1)
+	result, err := bookService.GetBook(context.Background(), 1)
 	assert.NoError(t, err)
 	assert.Equal(t, "Go", result.Title)
 
 	// Error
-	mockRepo.On("FindByID", uint(2)).Return(nil, errors.New("not found"))
+	mockRepo.On("FindByID", mock.Anything, uint(2)).Return(nil, errors.New("not found"))
 
-	_, err = bookService.GetBook(2)
+	_, err = bookService.GetBook(context.Background(), 2)
 	assert.Error(t, err)
 
 	mockRepo.AssertExpectations(t)
@@ -46,14 +49,50 @@ func TestGetBook(t *testing.T) {
 
 func TestListBooks(t *testing.T) {
 	mockRepo := new(mocks.MockBookRepository)
-	bookService := service.NewBookService(mockRepo)
+	bookService := service.NewBookService(mockRepo, repository.NewInMemoryBookSearchRepository())
 
+	query := repository.BookQuery{Author: "Pike", Page: 1, Limit: 20}
 	books := []model.Book{{Title: "A"}, {Title: "B"}}
-	mockRepo.On("FindAll").Return(books, nil)
+	mockRepo.On("FindByQuery", mock.Anything, query).Return(books, int64(2), nil)
 
-	result, err := bookService.ListBooks()
+	result, total, err := bookService.ListBooks(context.Background(), query)
 	assert.NoError(t, err)
 	assert.Equal(t, 2, len(result))
+	assert.Equal(t, int64(2), total)
 
 	mockRepo.AssertExpectations(t)
 }
+
+func TestSearchBooks(t *testing.T) {
+	searchRepo := repository.NewInMemoryBookSearchRepository(
+		model.Book{Model: gorm.Model{ID: 1}, Title: "Cooking with Go", Author: "Jane Doe", Description: "Recipes for busy gophers."},
+		model.Book{Model: gorm.Model{ID: 2}, Title: "The Go Programming Language", Author: "Alan Donovan", Description: "The authoritative resource to writing clear and idiomatic Go."},
+		model.Book{Model: gorm.Model{ID: 3}, Title: "Rust in Action", Author: "Tim McNamara", Description: "Systems programming."},
+	)
+	bookService := service.NewBookService(new(mocks.MockBookRepository), searchRepo)
+
+	// Case 1: Title matches outrank description matches
+	results, total, err := bookService.SearchBooks(context.Background(), repository.BookSearchQuery{Text: "go programming"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	assert.Equal(t, uint(2), results[0].Book.ID)
+	assert.Equal(t, "The <mark>Go</mark> <mark>Programming</mark> Language", results[0].TitleHighlight)
+
+	// Case 2: Ranking across several matches
+	results, total, err = bookService.SearchBooks(context.Background(), repository.BookSearchQuery{Text: "go"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+	assert.Equal(t, uint(2), results[0].Book.ID)
+	assert.Greater(t, results[0].Rank, results[1].Rank)
+
+	// Case 3: Book text is escaped, leaving the markers as the only markup
+	searchRepo.Index(model.Book{Model: gorm.Model{ID: 4}, Title: "Go <script>alert(1)</script>", Description: "Fast & <b>safe</b> Go"})
+	results, _, err = bookService.SearchBooks(context.Background(), repository.BookSearchQuery{Text: "alert"})
+	assert.NoError(t, err)
+	assert.Equal(t, "Go &lt;script&gt;<mark>alert</mark>(1)&lt;/script&gt;", results[0].TitleHighlight)
+	assert.Equal(t, "Fast &amp; &lt;b&gt;safe&lt;/b&gt; Go", results[0].Snippet)
+
+	// Case 4: Empty query
+	_, _, err = bookService.SearchBooks(context.Background(), repository.BookSearchQuery{Text: "   "})
+	assert.Error(t, err)
+}
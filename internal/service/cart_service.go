@@ -1,7 +1,10 @@
 package service
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"math"
 
 	"github.com/beingaloksharma/book-backend/internal/model"
 	"github.com/beingaloksharma/book-backend/internal/repository"
@@ -20,19 +23,23 @@ func NewCartService(cartRepo repository.CartRepositoryInterface, bookRepo reposi
 	}
 }
 
-func (s *CartService) AddToCart(userID, bookID uint, quantity int) error {
+func (s *CartService) AddToCart(ctx context.Context, userID, bookID uint, quantity int) error {
+	if quantity <= 0 {
+		return ErrInvalidQuantity
+	}
+
 	// Check if book exists
-	_, err := s.BookRepo.FindByID(bookID)
+	book, err := s.BookRepo.FindByID(ctx, bookID)
 	if err != nil {
-		return errors.New("book not found")
+		return ErrBookNotFound
 	}
 
 	// Get or Create Cart
-	cart, err := s.CartRepo.FindCartByUserID(userID)
+	cart, err := s.CartRepo.FindCartByUserID(ctx, userID)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			cart = &model.Cart{UserID: userID}
-			if err := s.CartRepo.CreateCart(cart); err != nil {
+			if err := s.CartRepo.CreateCart(ctx, cart); err != nil {
 				return err
 			}
 		} else {
@@ -41,25 +48,160 @@ func (s *CartService) AddToCart(userID, bookID uint, quantity int) error {
 	}
 
 	// Check if item exists in cart
-	item, err := s.CartRepo.FindItem(cart.ID, bookID)
+	item, err := s.CartRepo.FindItem(ctx, cart.ID, bookID)
 	if err == nil {
 		// Update quantity
-		item.Quantity += quantity
-		if item.Quantity <= 0 {
-			return s.CartRepo.RemoveItem(item.ID)
+		if err := checkStock(book, item.Quantity+quantity); err != nil {
+			return err
 		}
-		return s.CartRepo.UpdateItem(item)
+		item.Quantity += quantity
+		item.PriceAtAdd = book.Price
+		return s.CartRepo.UpdateItem(ctx, item)
 	}
 
 	// Add new item
+	if err := checkStock(book, quantity); err != nil {
+		return err
+	}
 	newItem := &model.CartItem{
-		CartID:   cart.ID,
-		BookID:   bookID,
-		Quantity: quantity,
+		CartID:     cart.ID,
+		BookID:     bookID,
+		Quantity:   quantity,
+		PriceAtAdd: book.Price,
+	}
+	return s.CartRepo.AddItem(ctx, newItem)
+}
+
+// GetCart prices the user's cart at current book prices and flags lines whose
+// price moved or whose stock no longer covers the quantity, and lines whose book
+// was deleted. A user without a cart gets an empty summary.
+func (s *CartService) GetCart(ctx context.Context, userID uint) (*model.CartSummary, error) {
+	summary := &model.CartSummary{Items: []model.CartLine{}}
+
+	cart, err := s.CartRepo.FindCartByUserID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return summary, nil
+		}
+		return nil, err
+	}
+
+	summary.CartID = cart.ID
+	summary.Checkoutable = len(cart.Items) > 0
+	for _, item := range cart.Items {
+		// Deleted books are not preloaded
+		if item.Book.ID == 0 {
+			summary.Checkoutable = false
+			summary.Items = append(summary.Items, model.CartLine{
+				BookID:            item.BookID,
+				Quantity:          item.Quantity,
+				PriceAtAdd:        item.PriceAtAdd,
+				OutOfStock:        true,
+				InsufficientStock: true,
+				Unavailable:       true,
+			})
+			continue
+		}
+
+		line := model.CartLine{
+			BookID:     item.BookID,
+			Title:      item.Book.Title,
+			Author:     item.Book.Author,
+			Quantity:   item.Quantity,
+			UnitPrice:  item.Book.Price,
+			PriceAtAdd: item.PriceAtAdd,
+			Available:  item.Book.Stock,
+		}
+		// Items saved before prices were tracked have no PriceAtAdd
+		line.PriceChanged = item.PriceAtAdd != 0 && item.PriceAtAdd != item.Book.Price
+		line.OutOfStock = item.Book.Stock <= 0
+		line.InsufficientStock = item.Quantity > item.Book.Stock
+		line.LineTotal = roundCents(line.UnitPrice * float64(item.Quantity))
+
+		if line.InsufficientStock {
+			summary.Checkoutable = false
+		}
+		summary.ItemCount += item.Quantity
+		summary.Subtotal += line.LineTotal
+		summary.Items = append(summary.Items, line)
+	}
+	summary.Subtotal = roundCents(summary.Subtotal)
+
+	return summary, nil
+}
+
+// UpdateCartItem sets the quantity of a book already in the user's cart.
+func (s *CartService) UpdateCartItem(ctx context.Context, userID, bookID uint, quantity int) error {
+	if quantity <= 0 {
+		return ErrInvalidQuantity
+	}
+
+	item, err := s.findCartItem(ctx, userID, bookID)
+	if err != nil {
+		return err
+	}
+
+	book, err := s.BookRepo.FindByID(ctx, bookID)
+	if err != nil {
+		return ErrBookNotFound
+	}
+	if err := checkStock(book, quantity); err != nil {
+		return err
+	}
+
+	item.Quantity = quantity
+	item.PriceAtAdd = book.Price
+	return s.CartRepo.UpdateItem(ctx, item)
+}
+
+func (s *CartService) RemoveCartItem(ctx context.Context, userID, bookID uint) error {
+	item, err := s.findCartItem(ctx, userID, bookID)
+	if err != nil {
+		return err
+	}
+	return s.CartRepo.RemoveItem(ctx, item.ID)
+}
+
+// ClearCart empties the user's cart. A user without a cart has nothing to clear.
+func (s *CartService) ClearCart(ctx context.Context, userID uint) error {
+	cart, err := s.CartRepo.FindCartByUserID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	return s.CartRepo.ClearCart(ctx, cart.ID)
+}
+
+// findCartItem looks the item up through the user's own cart, so users can
+// only touch their own items.
+func (s *CartService) findCartItem(ctx context.Context, userID, bookID uint) (*model.CartItem, error) {
+	cart, err := s.CartRepo.FindCartByUserID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCartItemNotFound
+		}
+		return nil, err
+	}
+
+	item, err := s.CartRepo.FindItem(ctx, cart.ID, bookID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCartItemNotFound
+		}
+		return nil, err
+	}
+	return item, nil
+}
+
+func checkStock(book *model.Book, quantity int) error {
+	if quantity > book.Stock {
+		return fmt.Errorf("%w: only %d of %q available", ErrInsufficientStock, book.Stock, book.Title)
 	}
-	return s.CartRepo.AddItem(newItem)
+	return nil
 }
 
-func (s *CartService) GetCart(userID uint) (*model.Cart, error) {
-	return s.CartRepo.FindCartByUserID(userID)
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
 }
@@ -1,6 +1,7 @@
 package service_test
 
 import (
+	"context"
 	"errors"
 	"testing"
 
@@ -18,40 +19,52 @@ func TestAddToCart(t *testing.T) {
 	cartService := service.NewCartService(mockCartRepo, mockBookRepo)
 
 	// Case 1: Book Not Found
-	mockBookRepo.On("FindByID", uint(1)).Return(nil, errors.New("not found")).Once()
-	err := cartService.AddToCart(1, 1, 1)
+	mockBookRepo.On("FindByID", mock.Anything, uint(1)).Return(nil, errors.New("not found")).Once()
+	err := cartService.AddToCart(context.Background(), 1, 1, 1)
 	assert.Error(t, err)
 
 	// Case 2: Cart not found, create new cart
-	mockBookRepo.On("FindByID", uint(1)).Return(&model.Book{}, nil)
-	mockCartRepo.On("FindCartByUserID", uint(1)).Return(nil, gorm.ErrRecordNotFound).Once()
-	mockCartRepo.On("CreateCart", mock.AnythingOfType("*model.Cart")).Run(func(args mock.Arguments) {
-		cart := args.Get(0).(*model.Cart)
+	mockBookRepo.On("FindByID", mock.Anything, uint(1)).Return(&model.Book{Price: 9.99, Stock: 3}, nil)
+	mockCartRepo.On("FindCartByUserID", mock.Anything, uint(1)).Return(nil, gorm.ErrRecordNotFound).Once()
+	mockCartRepo.On("CreateCart", mock.Anything, mock.AnythingOfType("*model.Cart")).Run(func(args mock.Arguments) {
+		cart := args.Get(1).(*model.Cart)
 		cart.ID = 10 // simulate DB ID
 	}).Return(nil).Once()
 
 	// Then item check fails (new cart), so add item
-	mockCartRepo.On("FindItem", uint(10), uint(1)).Return(nil, errors.New("not found")).Once()
-	mockCartRepo.On("AddItem", mock.AnythingOfType("*model.CartItem")).Return(nil).Once()
+	mockCartRepo.On("FindItem", mock.Anything, uint(10), uint(1)).Return(nil, errors.New("not found")).Once()
+	mockCartRepo.On("AddItem", mock.Anything, mock.MatchedBy(func(item *model.CartItem) bool {
+		return item.Quantity == 1 && item.PriceAtAdd == 9.99
+	})).Return(nil).Once()
 
-	err = cartService.AddToCart(1, 1, 1)
+	err = cartService.AddToCart(context.Background(), 1, 1, 1)
 	assert.NoError(t, err)
 
 	// Case 3: Cart exists, item exists, update quantity
-	mockBookRepo.On("FindByID", uint(1)).Return(&model.Book{}, nil)
-	mockCartRepo.On("FindCartByUserID", uint(1)).Return(&model.Cart{
+	mockCartRepo.On("FindCartByUserID", mock.Anything, uint(1)).Return(&model.Cart{
 		Model:  gorm.Model{ID: 10},
 		UserID: 1,
 	}, nil).Once()
-	mockCartRepo.On("FindItem", uint(10), uint(1)).Return(&model.CartItem{
+	mockCartRepo.On("FindItem", mock.Anything, uint(10), uint(1)).Return(&model.CartItem{
 		Model:    gorm.Model{ID: 5},
 		Quantity: 1,
 	}, nil).Once()
-	mockCartRepo.On("UpdateItem", mock.AnythingOfType("*model.CartItem")).Return(nil).Once()
+	mockCartRepo.On("UpdateItem", mock.Anything, mock.AnythingOfType("*model.CartItem")).Return(nil).Once()
 
-	err = cartService.AddToCart(1, 1, 1) // +1 quantity
+	err = cartService.AddToCart(context.Background(), 1, 1, 1) // +1 quantity
 	assert.NoError(t, err)
 
+	// Case 4: Non-positive quantity
+	err = cartService.AddToCart(context.Background(), 1, 1, -1)
+	assert.ErrorIs(t, err, service.ErrInvalidQuantity)
+
+	// Case 5: Existing quantity plus the new one exceeds stock
+	mockCartRepo.On("FindCartByUserID", mock.Anything, uint(1)).Return(&model.Cart{Model: gorm.Model{ID: 10}, UserID: 1}, nil).Once()
+	mockCartRepo.On("FindItem", mock.Anything, uint(10), uint(1)).Return(&model.CartItem{Model: gorm.Model{ID: 5}, Quantity: 2}, nil).Once()
+
+	err = cartService.AddToCart(context.Background(), 1, 1, 2)
+	assert.ErrorIs(t, err, service.ErrInsufficientStock)
+
 	mockCartRepo.AssertExpectations(t)
 	mockBookRepo.AssertExpectations(t)
 }
@@ -61,12 +74,61 @@ func TestGetCart(t *testing.T) {
 	mockBookRepo := new(mocks.MockBookRepository)
 	cartService := service.NewCartService(mockCartRepo, mockBookRepo)
 
-	cart := &model.Cart{UserID: 1}
-	mockCartRepo.On("FindCartByUserID", uint(1)).Return(cart, nil)
+	// Case 1: Lines priced at current prices with stock and price flags
+	cart := &model.Cart{
+		Model:  gorm.Model{ID: 10},
+		UserID: 1,
+		Items: []model.CartItem{
+			{BookID: 1, Quantity: 2, PriceAtAdd: 10, Book: model.Book{Model: gorm.Model{ID: 1}, Title: "Same", Price: 10, Stock: 5}},
+			{BookID: 2, Quantity: 1, PriceAtAdd: 20, Book: model.Book{Model: gorm.Model{ID: 2}, Title: "Dearer", Price: 24.99, Stock: 1}},
+			{BookID: 3, Quantity: 3, PriceAtAdd: 5, Book: model.Book{Model: gorm.Model{ID: 3}, Title: "Gone", Price: 5, Stock: 0}},
+		},
+	}
+	mockCartRepo.On("FindCartByUserID", mock.Anything, uint(1)).Return(cart, nil).Once()
+
+	result, err := cartService.GetCart(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, uint(10), result.CartID)
+	assert.Len(t, result.Items, 3)
+	assert.Equal(t, 6, result.ItemCount)
+	assert.Equal(t, 59.99, result.Subtotal)
+	assert.False(t, result.Checkoutable)
+
+	assert.False(t, result.Items[0].PriceChanged)
+	assert.Equal(t, 20.0, result.Items[0].LineTotal)
+	assert.True(t, result.Items[1].PriceChanged)
+	assert.Equal(t, 24.99, result.Items[1].UnitPrice)
+	assert.False(t, result.Items[1].InsufficientStock)
+	assert.True(t, result.Items[2].OutOfStock)
+	assert.True(t, result.Items[2].InsufficientStock)
+
+	// Case 2: A deleted book is unavailable, not free
+	cart = &model.Cart{
+		Model:  gorm.Model{ID: 11},
+		UserID: 3,
+		Items: []model.CartItem{
+			{BookID: 1, Quantity: 2, PriceAtAdd: 10, Book: model.Book{Model: gorm.Model{ID: 1}, Title: "Same", Price: 10, Stock: 5}},
+			{BookID: 4, Quantity: 1, PriceAtAdd: 15},
+		},
+	}
+	mockCartRepo.On("FindCartByUserID", mock.Anything, uint(3)).Return(cart, nil).Once()
+
+	result, err = cartService.GetCart(context.Background(), 3)
+	assert.NoError(t, err)
+	assert.Len(t, result.Items, 2)
+	assert.Equal(t, 2, result.ItemCount)
+	assert.Equal(t, 20.0, result.Subtotal)
+	assert.False(t, result.Checkoutable)
+	assert.True(t, result.Items[1].Unavailable)
+	assert.Equal(t, 0.0, result.Items[1].LineTotal)
 
-	result, err := cartService.GetCart(1)
+	// Case 3: No cart yet
+	mockCartRepo.On("FindCartByUserID", mock.Anything, uint(2)).Return(nil, gorm.ErrRecordNotFound).Once()
+
+	result, err = cartService.GetCart(context.Background(), 2)
 	assert.NoError(t, err)
-	assert.Equal(t, uint(1), result.UserID)
+	assert.Empty(t, result.Items)
+	assert.Equal(t, 0.0, result.Subtotal)
 
 	mockCartRepo.AssertExpectations(t)
 }
@@ -76,9 +138,9 @@ func TestGetCart_Error(t *testing.T) {
 	mockBookRepo := new(mocks.MockBookRepository)
 	cartService := service.NewCartService(mockCartRepo, mockBookRepo)
 
-	mockCartRepo.On("FindCartByUserID", uint(1)).Return(nil, errors.New("db error"))
+	mockCartRepo.On("FindCartByUserID", mock.Anything, uint(1)).Return(nil, errors.New("db error"))
 
-	_, err := cartService.GetCart(1)
+	_, err := cartService.GetCart(context.Background(), 1)
 	assert.Error(t, err)
 }
 
@@ -87,10 +149,101 @@ func TestAddToCart_RepoError(t *testing.T) {
 	mockBookRepo := new(mocks.MockBookRepository)
 	cartService := service.NewCartService(mockCartRepo, mockBookRepo)
 
-	mockBookRepo.On("FindByID", uint(1)).Return(&model.Book{}, nil)
+	mockBookRepo.On("FindByID", mock.Anything, uint(1)).Return(&model.Book{}, nil)
 	// Fail finding cart
-	mockCartRepo.On("FindCartByUserID", uint(1)).Return(nil, errors.New("db error")) // Not ErrRecordNotFound
+	mockCartRepo.On("FindCartByUserID", mock.Anything, uint(1)).Return(nil, errors.New("db error")) // Not ErrRecordNotFound
 
-	err := cartService.AddToCart(1, 1, 1)
+	err := cartService.AddToCart(context.Background(), 1, 1, 1)
 	assert.Error(t, err)
 }
+
+func TestUpdateCartItem(t *testing.T) {
+	mockCartRepo := new(mocks.MockCartRepository)
+	mockBookRepo := new(mocks.MockBookRepository)
+	cartService := service.NewCartService(mockCartRepo, mockBookRepo)
+
+	cart := &model.Cart{Model: gorm.Model{ID: 10}, UserID: 1}
+
+	// Case 1: Success sets the absolute quantity
+	mockCartRepo.On("FindCartByUserID", mock.Anything, uint(1)).Return(cart, nil).Once()
+	mockCartRepo.On("FindItem", mock.Anything, uint(10), uint(3)).Return(&model.CartItem{Model: gorm.Model{ID: 5}, Quantity: 4, PriceAtAdd: 8}, nil).Once()
+	mockBookRepo.On("FindByID", mock.Anything, uint(3)).Return(&model.Book{Price: 9, Stock: 2}, nil)
+	mockCartRepo.On("UpdateItem", mock.Anything, mock.MatchedBy(func(item *model.CartItem) bool {
+		return item.ID == 5 && item.Quantity == 2 && item.PriceAtAdd == 9
+	})).Return(nil).Once()
+
+	err := cartService.UpdateCartItem(context.Background(), 1, 3, 2)
+	assert.NoError(t, err)
+
+	// Case 2: Book not in the user's cart
+	mockCartRepo.On("FindCartByUserID", mock.Anything, uint(1)).Return(cart, nil).Once()
+	mockCartRepo.On("FindItem", mock.Anything, uint(10), uint(4)).Return(nil, gorm.ErrRecordNotFound).Once()
+
+	err = cartService.UpdateCartItem(context.Background(), 1, 4, 2)
+	assert.ErrorIs(t, err, service.ErrCartItemNotFound)
+
+	// Case 3: User has no cart
+	mockCartRepo.On("FindCartByUserID", mock.Anything, uint(2)).Return(nil, gorm.ErrRecordNotFound).Once()
+
+	err = cartService.UpdateCartItem(context.Background(), 2, 3, 2)
+	assert.ErrorIs(t, err, service.ErrCartItemNotFound)
+
+	// Case 4: Non-positive quantity
+	err = cartService.UpdateCartItem(context.Background(), 1, 3, 0)
+	assert.ErrorIs(t, err, service.ErrInvalidQuantity)
+
+	// Case 5: More than the available stock
+	mockCartRepo.On("FindCartByUserID", mock.Anything, uint(1)).Return(cart, nil).Once()
+	mockCartRepo.On("FindItem", mock.Anything, uint(10), uint(3)).Return(&model.CartItem{Model: gorm.Model{ID: 5}, Quantity: 1}, nil).Once()
+
+	err = cartService.UpdateCartItem(context.Background(), 1, 3, 3)
+	assert.ErrorIs(t, err, service.ErrInsufficientStock)
+
+	mockCartRepo.AssertExpectations(t)
+}
+
+func TestRemoveCartItem(t *testing.T) {
+	mockCartRepo := new(mocks.MockCartRepository)
+	mockBookRepo := new(mocks.MockBookRepository)
+	cartService := service.NewCartService(mockCartRepo, mockBookRepo)
+
+	cart := &model.Cart{Model: gorm.Model{ID: 10}, UserID: 1}
+
+	// Case 1: Success
+	mockCartRepo.On("FindCartByUserID", mock.Anything, uint(1)).Return(cart, nil).Once()
+	mockCartRepo.On("FindItem", mock.Anything, uint(10), uint(3)).Return(&model.CartItem{Model: gorm.Model{ID: 5}}, nil).Once()
+	mockCartRepo.On("RemoveItem", mock.Anything, uint(5)).Return(nil).Once()
+
+	err := cartService.RemoveCartItem(context.Background(), 1, 3)
+	assert.NoError(t, err)
+
+	// Case 2: Book not in the user's cart
+	mockCartRepo.On("FindCartByUserID", mock.Anything, uint(1)).Return(cart, nil).Once()
+	mockCartRepo.On("FindItem", mock.Anything, uint(10), uint(4)).Return(nil, gorm.ErrRecordNotFound).Once()
+
+	err = cartService.RemoveCartItem(context.Background(), 1, 4)
+	assert.ErrorIs(t, err, service.ErrCartItemNotFound)
+
+	mockCartRepo.AssertExpectations(t)
+}
+
+func TestClearCart(t *testing.T) {
+	mockCartRepo := new(mocks.MockCartRepository)
+	mockBookRepo := new(mocks.MockBookRepository)
+	cartService := service.NewCartService(mockCartRepo, mockBookRepo)
+
+	// Case 1: Success
+	mockCartRepo.On("FindCartByUserID", mock.Anything, uint(1)).Return(&model.Cart{Model: gorm.Model{ID: 10}, UserID: 1}, nil).Once()
+	mockCartRepo.On("ClearCart", mock.Anything, uint(10)).Return(nil).Once()
+
+	err := cartService.ClearCart(context.Background(), 1)
+	assert.NoError(t, err)
+
+	// Case 2: No cart yet
+	mockCartRepo.On("FindCartByUserID", mock.Anything, uint(2)).Return(nil, gorm.ErrRecordNotFound).Once()
+
+	err = cartService.ClearCart(context.Background(), 2)
+	assert.NoError(t, err)
+
+	mockCartRepo.AssertExpectations(t)
+}
@@ -1,34 +1,97 @@
 package service
 
-import "github.com/beingaloksharma/book-backend/internal/model"
+import (
+	"context"
+
+	"github.com/beingaloksharma/book-backend/internal/identity"
+	"github.com/beingaloksharma/book-backend/internal/model"
+	"github.com/beingaloksharma/book-backend/internal/repository"
+)
 
 type AuthServiceInterface interface {
-	Signup(name, email, password string, role model.Role) error
-	Login(email, password string) (string, error)
+	Signup(ctx context.Context, name, email, password string) error
+	Login(ctx context.Context, email, password, ip string) (*LoginResult, error)
+	BeginMFAEnrollment(ctx context.Context, challengeToken string) (*MFAEnrollment, error)
+	VerifyMFA(ctx context.Context, challengeToken, code, ip string) (*MFALoginResult, error)
+	Refresh(ctx context.Context, refreshToken string) (*TokenPair, error)
+	Logout(ctx context.Context, refreshToken string) error
+	LogoutAll(ctx context.Context, userID uint) error
+}
+
+type PasswordServiceInterface interface {
+	ForgotPassword(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, resetToken, newPassword string) error
+}
+
+type MFAServiceInterface interface {
+	Required(role model.Role) bool
+	BeginEnrollment(ctx context.Context, userID uint) (*MFAEnrollment, error)
+	ConfirmEnrollment(ctx context.Context, userID uint, code string) ([]string, error)
+	VerifyCode(ctx context.Context, userID uint, code string) error
+	Disable(ctx context.Context, userID uint, code string) error
+	RegenerateRecoveryCodes(ctx context.Context, userID uint, code string) ([]string, error)
+}
+
+type LoginGuardInterface interface {
+	Check(ctx context.Context, email, ip string) error
+	RecordFailure(ctx context.Context, email, ip string) error
+	RecordSuccess(ctx context.Context, email string) error
+	Unlock(ctx context.Context, userID, unlockedBy uint) error
+	ListAuditEvents(ctx context.Context, limit int) ([]model.AuditEvent, error)
+}
+
+type VerificationServiceInterface interface {
+	SendVerification(ctx context.Context, user *model.User) error
+	VerifyEmail(ctx context.Context, verifyToken string) error
+	ResendVerification(ctx context.Context, email string) error
 }
 
 type UserServiceInterface interface {
-	GetProfile(userID uint) (*model.User, error)
-	AddAddress(userID uint, street, city, state, zip, country string) error
-	GetAddresses(userID uint) ([]model.Address, error)
-	GetAllUsers() ([]model.User, error)
+	GetProfile(ctx context.Context, userID uint) (*model.User, error)
+	AddAddress(ctx context.Context, userID uint, street, city, state, zip, country string) error
+	GetAddresses(ctx context.Context, userID uint) ([]model.Address, error)
+	UpdateAddress(ctx context.Context, userID, addressID uint, changes model.Address) (*model.Address, error)
+	DeleteAddress(ctx context.Context, userID, addressID uint) error
+	GetAllUsers(ctx context.Context) ([]model.User, error)
+	ChangeRole(ctx context.Context, userID uint, role model.Role, actor identity.Principal, note string) (*model.User, error)
+	GetRoleHistory(ctx context.Context, userID uint) ([]model.UserRoleHistory, error)
+}
+
+type ProfileServiceInterface interface {
+	UpdateProfile(ctx context.Context, userID uint, changes ProfileChanges) (*model.User, error)
+	ChangePassword(ctx context.Context, userID uint, current, newPassword string) error
+	DeleteAccount(ctx context.Context, userID uint) error
+}
+
+type RoleServiceInterface interface {
+	ListRoles(ctx context.Context) ([]model.RoleDefinition, error)
+	CreateRole(ctx context.Context, name model.Role, description string, permissions []model.Permission) (*model.RoleDefinition, error)
+	SetRolePermissions(ctx context.Context, name model.Role, permissions []model.Permission) (*model.RoleDefinition, error)
+	DeleteRole(ctx context.Context, name model.Role) error
 }
 
 type BookServiceInterface interface {
-	CreateBook(title, author, description string, price float64, stock int) error
-	UpdateBook(id uint, title, author, description string, price float64, stock int) error
-	DeleteBook(id uint) error
-	GetBook(id uint) (*model.Book, error)
-	ListBooks() ([]model.Book, error)
+	CreateBook(ctx context.Context, title, author, description string, price float64, stock int) error
+	UpdateBook(ctx context.Context, id uint, title, author, description string, price float64, stock int) error
+	DeleteBook(ctx context.Context, id uint) error
+	GetBook(ctx context.Context, id uint) (*model.Book, error)
+	ListBooks(ctx context.Context, query repository.BookQuery) ([]model.Book, int64, error)
+	SearchBooks(ctx context.Context, query repository.BookSearchQuery) ([]repository.BookSearchResult, int64, error)
 }
 
 type CartServiceInterface interface {
-	AddToCart(userID, bookID uint, quantity int) error
-	GetCart(userID uint) (*model.Cart, error)
+	AddToCart(ctx context.Context, userID, bookID uint, quantity int) error
+	GetCart(ctx context.Context, userID uint) (*model.CartSummary, error)
+	UpdateCartItem(ctx context.Context, userID, bookID uint, quantity int) error
+	RemoveCartItem(ctx context.Context, userID, bookID uint) error
+	ClearCart(ctx context.Context, userID uint) error
 }
 
 type OrderServiceInterface interface {
-	PlaceOrder(userID, addressID uint) error
-	GetOrders(userID uint) ([]model.Order, error)
-	GetAllOrders() ([]model.Order, error)
+	PlaceOrder(ctx context.Context, userID, addressID uint) error
+	GetOrders(ctx context.Context, userID uint) ([]model.Order, error)
+	GetAllOrders(ctx context.Context) ([]model.Order, error)
+	UpdateOrderStatus(ctx context.Context, orderID uint, status model.OrderStatus, changedBy uint, note string) (*model.Order, error)
+	GetOrderStatusHistory(ctx context.Context, orderID uint) ([]model.OrderStatusHistory, error)
+	CancelOrder(ctx context.Context, orderID uint, actor identity.Principal, reason string) (*model.Order, error)
 }
@@ -0,0 +1,241 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/beingaloksharma/book-backend/internal/model"
+	"github.com/beingaloksharma/book-backend/internal/repository"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+// LoginPolicy sets how failed logins are throttled. Accounts are slowed down
+// with an exponential delay and then locked; client addresses, which may be
+// shared, are only locked at a higher threshold.
+type LoginPolicy struct {
+	Window                  time.Duration // Failures older than this are forgotten
+	BackoffAfter            int           // Failures allowed before attempts are delayed
+	BackoffBase             time.Duration // First delay; it doubles with every further failure
+	BackoffMax              time.Duration
+	AccountLockoutThreshold int
+	IPLockoutThreshold      int
+	LockoutDuration         time.Duration
+}
+
+// LoginPolicyFromConfig reads the login_protection section, falling back to
+// defaults for anything unset.
+func LoginPolicyFromConfig() LoginPolicy {
+	policy := LoginPolicy{
+		Window:                  15 * time.Minute,
+		BackoffAfter:            3,
+		BackoffBase:             time.Second,
+		BackoffMax:              5 * time.Minute,
+		AccountLockoutThreshold: 10,
+		IPLockoutThreshold:      50,
+		LockoutDuration:         15 * time.Minute,
+	}
+	if d := viper.GetDuration("login_protection.window"); d > 0 {
+		policy.Window = d
+	}
+	if n := viper.GetInt("login_protection.backoff_after"); n > 0 {
+		policy.BackoffAfter = n
+	}
+	if d := viper.GetDuration("login_protection.backoff_base"); d > 0 {
+		policy.BackoffBase = d
+	}
+	if d := viper.GetDuration("login_protection.backoff_max"); d > 0 {
+		policy.BackoffMax = d
+	}
+	if n := viper.GetInt("login_protection.account_lockout_threshold"); n > 0 {
+		policy.AccountLockoutThreshold = n
+	}
+	if n := viper.GetInt("login_protection.ip_lockout_threshold"); n > 0 {
+		policy.IPLockoutThreshold = n
+	}
+	if d := viper.GetDuration("login_protection.lockout_duration"); d > 0 {
+		policy.LockoutDuration = d
+	}
+	return policy
+}
+
+// LoginThrottledError is returned while a login attempt has to wait. It
+// matches ErrTooManyLoginAttempts.
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+	Locked     bool // Locked out, rather than only backing off
+}
+
+func (e *LoginThrottledError) Error() string {
+	return fmt.Sprintf("%s; retry in %s", ErrTooManyLoginAttempts, e.RetryAfter.Round(time.Second))
+}
+
+func (e *LoginThrottledError) Unwrap() error {
+	return ErrTooManyLoginAttempts
+}
+
+type LoginGuard struct {
+	Attempts repository.LoginAttemptRepositoryInterface
+	Audit    repository.AuditRepositoryInterface
+	UserRepo repository.UserRepositoryInterface
+	Policy   LoginPolicy
+}
+
+func NewLoginGuard(attempts repository.LoginAttemptRepositoryInterface, audit repository.AuditRepositoryInterface, userRepo repository.UserRepositoryInterface, policy LoginPolicy) *LoginGuard {
+	return &LoginGuard{
+		Attempts: attempts,
+		Audit:    audit,
+		UserRepo: userRepo,
+		Policy:   policy,
+	}
+}
+
+// Check returns a LoginThrottledError if a login for email from ip has to wait.
+func (g *LoginGuard) Check(ctx context.Context, email, ip string) error {
+	now := time.Now()
+
+	account, err := g.find(ctx, accountSubject(email))
+	if err != nil {
+		return err
+	}
+	if wait := g.accountWait(account, now); wait > 0 {
+		return &LoginThrottledError{RetryAfter: wait, Locked: isLocked(account, now)}
+	}
+
+	address, err := g.find(ctx, ipSubject(ip))
+	if err != nil {
+		return err
+	}
+	if isLocked(address, now) {
+		return &LoginThrottledError{RetryAfter: address.LockedUntil.Sub(now), Locked: true}
+	}
+	return nil
+}
+
+// RecordFailure counts a failed login against both the account and the
+// client address, locking either once it reaches its threshold.
+func (g *LoginGuard) RecordFailure(ctx context.Context, email, ip string) error {
+	now := time.Now()
+
+	account, err := g.Attempts.RecordFailure(ctx, accountSubject(email), g.Policy.Window)
+	if err != nil {
+		return err
+	}
+	if account.Failures >= g.Policy.AccountLockoutThreshold && !isLocked(account, now) {
+		if err := g.lock(ctx, account, model.AuditAccountLocked, email, ip, now); err != nil {
+			return err
+		}
+	}
+
+	address, err := g.Attempts.RecordFailure(ctx, ipSubject(ip), g.Policy.Window)
+	if err != nil {
+		return err
+	}
+	if address.Failures >= g.Policy.IPLockoutThreshold && !isLocked(address, now) {
+		return g.lock(ctx, address, model.AuditIPLocked, ip, ip, now)
+	}
+	return nil
+}
+
+// RecordSuccess clears the account's failures. The client address keeps its
+// count, so one valid account does not unlock guessing at others.
+func (g *LoginGuard) RecordSuccess(ctx context.Context, email string) error {
+	return g.Attempts.Reset(ctx, accountSubject(email))
+}
+
+// Unlock lifts the lockout and backoff of an account on behalf of an admin.
+func (g *LoginGuard) Unlock(ctx context.Context, userID, unlockedBy uint) error {
+	user, err := g.UserRepo.FindByID(ctx, userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := g.Attempts.Reset(ctx, accountSubject(user.Email)); err != nil {
+		return err
+	}
+	return g.record(ctx, &model.AuditEvent{
+		Type:    model.AuditAccountUnlocked,
+		UserID:  &user.ID,
+		ActorID: &unlockedBy,
+		Subject: user.Email,
+	})
+}
+
+func (g *LoginGuard) ListAuditEvents(ctx context.Context, limit int) ([]model.AuditEvent, error) {
+	return g.Audit.FindRecent(ctx, limit)
+}
+
+// accountWait is how long the account has to wait before its next attempt.
+func (g *LoginGuard) accountWait(attempt *model.LoginAttempt, now time.Time) time.Duration {
+	if attempt == nil {
+		return 0
+	}
+	if isLocked(attempt, now) {
+		return attempt.LockedUntil.Sub(now)
+	}
+	if now.Sub(attempt.LastFailure) > g.Policy.Window || attempt.Failures < g.Policy.BackoffAfter {
+		return 0
+	}
+
+	delay := g.Policy.BackoffMax
+	if shift := attempt.Failures - g.Policy.BackoffAfter; shift < 32 {
+		delay = min(g.Policy.BackoffBase<<shift, g.Policy.BackoffMax)
+	}
+	return time.Until(attempt.LastFailure.Add(delay))
+}
+
+func (g *LoginGuard) lock(ctx context.Context, attempt *model.LoginAttempt, eventType model.AuditEventType, subject, ip string, now time.Time) error {
+	until := now.Add(g.Policy.LockoutDuration)
+	if err := g.Attempts.Lock(ctx, attempt.Subject, until); err != nil {
+		return err
+	}
+
+	event := &model.AuditEvent{
+		Type:    eventType,
+		Subject: subject,
+		IP:      ip,
+		Detail:  fmt.Sprintf("%d failed logins; locked until %s", attempt.Failures, until.UTC().Format(time.RFC3339)),
+	}
+	if eventType == model.AuditAccountLocked {
+		if user, err := g.UserRepo.FindByEmail(ctx, subject); err == nil {
+			event.UserID = &user.ID
+		}
+	}
+	return g.record(ctx, event)
+}
+
+func (g *LoginGuard) record(ctx context.Context, event *model.AuditEvent) error {
+	logrus.WithFields(logrus.Fields{
+		"event":   event.Type,
+		"subject": event.Subject,
+		"ip":      event.IP,
+	}).Warn("Security event")
+	return g.Audit.Record(ctx, event)
+}
+
+func (g *LoginGuard) find(ctx context.Context, subject string) (*model.LoginAttempt, error) {
+	attempt, err := g.Attempts.Find(ctx, subject)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return attempt, err
+}
+
+func isLocked(attempt *model.LoginAttempt, now time.Time) bool {
+	return attempt != nil && attempt.LockedUntil != nil && attempt.LockedUntil.After(now)
+}
+
+func accountSubject(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipSubject(ip string) string {
+	return "ip:" + ip
+}
@@ -0,0 +1,117 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/beingaloksharma/book-backend/internal/model"
+	"github.com/beingaloksharma/book-backend/internal/repository"
+	"github.com/beingaloksharma/book-backend/internal/repository/mocks"
+	"github.com/beingaloksharma/book-backend/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func testLoginPolicy() service.LoginPolicy {
+	return service.LoginPolicy{
+		Window:                  time.Hour,
+		BackoffAfter:            2,
+		BackoffBase:             time.Minute,
+		BackoffMax:              10 * time.Minute,
+		AccountLockoutThreshold: 4,
+		IPLockoutThreshold:      6,
+		LockoutDuration:         30 * time.Minute,
+	}
+}
+
+func TestLoginGuard_AccountBackoffAndLockout(t *testing.T) {
+	mockAudit := new(mocks.MockAuditRepository)
+	mockUserRepo := new(mocks.MockUserRepository)
+	guard := service.NewLoginGuard(repository.NewMemoryLoginAttemptRepository(), mockAudit, mockUserRepo, testLoginPolicy())
+
+	// Case 1: The first failures are free
+	require.NoError(t, guard.RecordFailure(context.Background(), "john@example.com", "10.0.0.1"))
+	assert.NoError(t, guard.Check(context.Background(), "john@example.com", "10.0.0.1"))
+
+	// Case 2: Reaching backoff_after delays the next attempt, for any spelling of the email
+	require.NoError(t, guard.RecordFailure(context.Background(), "john@example.com", "10.0.0.1"))
+
+	err := guard.Check(context.Background(), "John@Example.com", "10.0.0.9")
+	var throttled *service.LoginThrottledError
+	require.ErrorAs(t, err, &throttled)
+	assert.ErrorIs(t, err, service.ErrTooManyLoginAttempts)
+	assert.False(t, throttled.Locked)
+	assert.InDelta(t, time.Minute.Seconds(), throttled.RetryAfter.Seconds(), 1)
+
+	// Case 3: The delay doubles with every further failure
+	require.NoError(t, guard.RecordFailure(context.Background(), "john@example.com", "10.0.0.1"))
+
+	require.ErrorAs(t, guard.Check(context.Background(), "john@example.com", "10.0.0.1"), &throttled)
+	assert.InDelta(t, (2 * time.Minute).Seconds(), throttled.RetryAfter.Seconds(), 1)
+
+	// Case 4: The threshold locks the account and records an audit event
+	mockUserRepo.On("FindByEmail", mock.Anything, "john@example.com").Return(&model.User{Model: gorm.Model{ID: 1}}, nil).Once()
+	mockAudit.On("Record", mock.Anything, mock.MatchedBy(func(e *model.AuditEvent) bool {
+		return e.Type == model.AuditAccountLocked && e.Subject == "john@example.com" && e.IP == "10.0.0.1" && *e.UserID == 1
+	})).Return(nil).Once()
+	require.NoError(t, guard.RecordFailure(context.Background(), "john@example.com", "10.0.0.1"))
+
+	require.ErrorAs(t, guard.Check(context.Background(), "john@example.com", "10.0.0.1"), &throttled)
+	assert.True(t, throttled.Locked)
+	assert.InDelta(t, (30 * time.Minute).Seconds(), throttled.RetryAfter.Seconds(), 1)
+
+	// Case 5: Other accounts are unaffected
+	assert.NoError(t, guard.Check(context.Background(), "jane@example.com", "10.0.0.1"))
+
+	// Case 6: Admin unlock clears the account and is audited
+	mockUserRepo.On("FindByID", mock.Anything, uint(1)).Return(&model.User{Model: gorm.Model{ID: 1}, Email: "john@example.com"}, nil).Once()
+	mockAudit.On("Record", mock.Anything, mock.MatchedBy(func(e *model.AuditEvent) bool {
+		return e.Type == model.AuditAccountUnlocked && *e.UserID == 1 && *e.ActorID == 9
+	})).Return(nil).Once()
+	require.NoError(t, guard.Unlock(context.Background(), 1, 9))
+
+	assert.NoError(t, guard.Check(context.Background(), "john@example.com", "10.0.0.1"))
+
+	// Case 7: Unlocking an unknown user
+	mockUserRepo.On("FindByID", mock.Anything, uint(2)).Return(nil, gorm.ErrRecordNotFound).Once()
+	assert.ErrorIs(t, guard.Unlock(context.Background(), 2, 9), service.ErrUserNotFound)
+
+	mockAudit.AssertExpectations(t)
+	mockUserRepo.AssertExpectations(t)
+}
+
+func TestLoginGuard_SuccessResetsAccount(t *testing.T) {
+	guard := service.NewLoginGuard(repository.NewMemoryLoginAttemptRepository(), new(mocks.MockAuditRepository), new(mocks.MockUserRepository), testLoginPolicy())
+
+	require.NoError(t, guard.RecordFailure(context.Background(), "john@example.com", "10.0.0.1"))
+	require.NoError(t, guard.RecordFailure(context.Background(), "john@example.com", "10.0.0.1"))
+	assert.Error(t, guard.Check(context.Background(), "john@example.com", "10.0.0.1"))
+
+	require.NoError(t, guard.RecordSuccess(context.Background(), "john@example.com"))
+	assert.NoError(t, guard.Check(context.Background(), "john@example.com", "10.0.0.1"))
+}
+
+func TestLoginGuard_IPLockout(t *testing.T) {
+	mockAudit := new(mocks.MockAuditRepository)
+	guard := service.NewLoginGuard(repository.NewMemoryLoginAttemptRepository(), mockAudit, new(mocks.MockUserRepository), testLoginPolicy())
+
+	// Guessing one attempt per account from the same address
+	mockAudit.On("Record", mock.Anything, mock.MatchedBy(func(e *model.AuditEvent) bool {
+		return e.Type == model.AuditIPLocked && e.Subject == "10.0.0.1" && e.UserID == nil
+	})).Return(nil).Once()
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com", "d@example.com", "e@example.com", "f@example.com"} {
+		require.NoError(t, guard.RecordFailure(context.Background(), email, "10.0.0.1"))
+	}
+
+	var throttled *service.LoginThrottledError
+	require.ErrorAs(t, guard.Check(context.Background(), "g@example.com", "10.0.0.1"), &throttled)
+	assert.True(t, throttled.Locked)
+
+	// Other addresses can still log in
+	assert.NoError(t, guard.Check(context.Background(), "g@example.com", "10.0.0.2"))
+
+	mockAudit.AssertExpectations(t)
+}
@@ -0,0 +1,215 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/beingaloksharma/book-backend/internal/model"
+	"github.com/beingaloksharma/book-backend/internal/repository"
+	"github.com/beingaloksharma/book-backend/utils/token"
+	"github.com/beingaloksharma/book-backend/utils/totp"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+const (
+	// DefaultMFAIssuer names the account in authenticator apps.
+	DefaultMFAIssuer = "Book Store"
+
+	recoveryCodeCount = 10
+	totpSkew          = 1 // Accept codes one period early or late for clock drift
+)
+
+var recoveryEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// MFAEnrollment is what an authenticator app needs to start producing codes.
+type MFAEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"` // Render as a QR code
+}
+
+type MFAService struct {
+	UserRepo repository.UserRepositoryInterface
+	Repo     repository.MFARepositoryInterface
+}
+
+func NewMFAService(userRepo repository.UserRepositoryInterface, repo repository.MFARepositoryInterface) *MFAService {
+	return &MFAService{
+		UserRepo: userRepo,
+		Repo:     repo,
+	}
+}
+
+// Required reports whether accounts with role must use a second factor, as
+// set by mfa.required_roles. Admins need one unless configured otherwise.
+func (s *MFAService) Required(role model.Role) bool {
+	roles := []string{string(model.RoleAdmin)}
+	if viper.IsSet("mfa.required_roles") {
+		roles = viper.GetStringSlice("mfa.required_roles")
+	}
+	for _, r := range roles {
+		if strings.EqualFold(r, string(role)) {
+			return true
+		}
+	}
+	return false
+}
+
+// BeginEnrollment gives the user a new TOTP secret. MFA is not on until the
+// user confirms a code from it.
+func (s *MFAService) BeginEnrollment(ctx context.Context, userID uint) (*MFAEnrollment, error) {
+	user, err := s.findUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.MFAEnabled() {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Repo.SetSecret(ctx, user.ID, secret); err != nil {
+		return nil, err
+	}
+	return &MFAEnrollment{Secret: secret, URI: totp.URI(mfaIssuer(), user.Email, secret)}, nil
+}
+
+// ConfirmEnrollment turns MFA on once code shows the user's app is set up,
+// and returns the recovery codes. They are shown this once only.
+func (s *MFAService) ConfirmEnrollment(ctx context.Context, userID uint, code string) ([]string, error) {
+	user, err := s.findUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.MFAEnabled() {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	if user.MFASecret == "" {
+		return nil, ErrMFANotEnrolled
+	}
+
+	step, ok := totp.Validate(user.MFASecret, code, time.Now(), totpSkew)
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+	plain, codes, err := newRecoveryCodes(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Repo.Enable(ctx, user.ID, step, codes); err != nil {
+		return nil, err
+	}
+	return plain, nil
+}
+
+// VerifyCode checks a second factor: a TOTP code, each of which works once,
+// or an unused recovery code, which is used up.
+func (s *MFAService) VerifyCode(ctx context.Context, userID uint, code string) error {
+	user, err := s.findUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return s.verify(ctx, user, code)
+}
+
+// Disable turns MFA off. It takes a current code, and is refused for roles
+// that require MFA.
+func (s *MFAService) Disable(ctx context.Context, userID uint, code string) error {
+	user, err := s.findUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if s.Required(user.Role) {
+		return ErrMFARequired
+	}
+	if err := s.verify(ctx, user, code); err != nil {
+		return err
+	}
+	return s.Repo.Disable(ctx, user.ID)
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes, used or not.
+func (s *MFAService) RegenerateRecoveryCodes(ctx context.Context, userID uint, code string) ([]string, error) {
+	user, err := s.findUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.verify(ctx, user, code); err != nil {
+		return nil, err
+	}
+
+	plain, codes, err := newRecoveryCodes(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Repo.ReplaceRecoveryCodes(ctx, user.ID, codes); err != nil {
+		return nil, err
+	}
+	return plain, nil
+}
+
+func (s *MFAService) verify(ctx context.Context, user *model.User, code string) error {
+	if !user.MFAEnabled() {
+		return ErrMFANotEnrolled
+	}
+
+	if step, ok := totp.Validate(user.MFASecret, code, time.Now(), totpSkew); ok {
+		if err := s.Repo.AcceptStep(ctx, user.ID, step); err != nil {
+			if errors.Is(err, repository.ErrTokenAlreadyUsed) {
+				return ErrInvalidMFACode
+			}
+			return err
+		}
+		return nil
+	}
+
+	err := s.Repo.ConsumeRecoveryCode(ctx, user.ID, token.HashOpaqueToken(normalizeRecoveryCode(code)))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrInvalidMFACode
+	}
+	return err
+}
+
+func (s *MFAService) findUser(ctx context.Context, userID uint) (*model.User, error) {
+	user, err := s.UserRepo.FindByID(ctx, userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrUserNotFound
+	}
+	return user, err
+}
+
+// newRecoveryCodes returns codes to show the user, formatted xxxx-xxxx-xxxx-xxxx,
+// and the records to store in their place.
+func newRecoveryCodes(userID uint) ([]string, []model.MFARecoveryCode, error) {
+	plain := make([]string, 0, recoveryCodeCount)
+	codes := make([]model.MFARecoveryCode, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 10) // 80 bits
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		raw := strings.ToLower(recoveryEncoding.EncodeToString(b))
+		plain = append(plain, raw[0:4]+"-"+raw[4:8]+"-"+raw[8:12]+"-"+raw[12:16])
+		codes = append(codes, model.MFARecoveryCode{UserID: userID, CodeHash: token.HashOpaqueToken(raw)})
+	}
+	return plain, codes, nil
+}
+
+// normalizeRecoveryCode accepts a recovery code with any case and separators.
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+func mfaIssuer() string {
+	if issuer := viper.GetString("mfa.issuer"); issuer != "" {
+		return issuer
+	}
+	return DefaultMFAIssuer
+}
@@ -0,0 +1,158 @@
+package service_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/beingaloksharma/book-backend/internal/model"
+	"github.com/beingaloksharma/book-backend/internal/repository"
+	"github.com/beingaloksharma/book-backend/internal/repository/mocks"
+	"github.com/beingaloksharma/book-backend/internal/service"
+	"github.com/beingaloksharma/book-backend/utils/token"
+	"github.com/beingaloksharma/book-backend/utils/totp"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestMFARequired(t *testing.T) {
+	mfaService := service.NewMFAService(new(mocks.MockUserRepository), new(mocks.MockMFARepository))
+
+	// Case 1: Admins by default
+	assert.True(t, mfaService.Required(model.RoleAdmin))
+	assert.False(t, mfaService.Required(model.RoleUser))
+
+	// Case 2: Configured roles
+	viper.Set("mfa.required_roles", []string{"user", "ADMIN"})
+	assert.True(t, mfaService.Required(model.RoleUser))
+
+	// Case 3: An empty list turns the requirement off
+	viper.Set("mfa.required_roles", []string{})
+	defer viper.Set("mfa.required_roles", nil)
+	assert.False(t, mfaService.Required(model.RoleAdmin))
+}
+
+func TestMFAEnrollment(t *testing.T) {
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockRepo := new(mocks.MockMFARepository)
+	mfaService := service.NewMFAService(mockUserRepo, mockRepo)
+
+	user := &model.User{Model: gorm.Model{ID: 1}, Email: "john@example.com", Role: model.RoleUser}
+
+	// Case 1: Begin stores a new secret and returns the otpauth URI
+	mockUserRepo.On("FindByID", mock.Anything, uint(1)).Return(user, nil).Once()
+	mockRepo.On("SetSecret", mock.Anything, uint(1), mock.AnythingOfType("string")).Return(nil).Once()
+
+	enrollment, err := mfaService.BeginEnrollment(context.Background(), 1)
+	require.NoError(t, err)
+	assert.NotEmpty(t, enrollment.Secret)
+	assert.Contains(t, enrollment.URI, "secret="+enrollment.Secret)
+	assert.Contains(t, enrollment.URI, "issuer=Book%20Store")
+
+	// Case 2: Confirm with a wrong code
+	user.MFASecret = enrollment.Secret
+	mockUserRepo.On("FindByID", mock.Anything, uint(1)).Return(user, nil).Once()
+
+	_, err = mfaService.ConfirmEnrollment(context.Background(), 1, "000000x")
+	assert.ErrorIs(t, err, service.ErrInvalidMFACode)
+
+	// Case 3: Confirm with a current code turns MFA on and returns recovery codes
+	code, _ := totp.Code(enrollment.Secret, time.Now())
+	mockUserRepo.On("FindByID", mock.Anything, uint(1)).Return(user, nil).Once()
+	mockRepo.On("Enable", mock.Anything, uint(1), mock.AnythingOfType("int64"), mock.MatchedBy(func(codes []model.MFARecoveryCode) bool {
+		return len(codes) == 10 && codes[0].UserID == 1 && codes[0].CodeHash != ""
+	})).Return(nil).Once()
+
+	recovery, err := mfaService.ConfirmEnrollment(context.Background(), 1, code)
+	require.NoError(t, err)
+	assert.Len(t, recovery, 10)
+	assert.Len(t, recovery[0], 19)
+	stored := mockRepo.Calls[1].Arguments.Get(3).([]model.MFARecoveryCode)
+	assert.Equal(t, token.HashOpaqueToken(strings.ReplaceAll(recovery[0], "-", "")), stored[0].CodeHash)
+
+	// Case 4: Confirm before begin
+	mockUserRepo.On("FindByID", mock.Anything, uint(2)).Return(&model.User{Model: gorm.Model{ID: 2}}, nil).Once()
+
+	_, err = mfaService.ConfirmEnrollment(context.Background(), 2, code)
+	assert.ErrorIs(t, err, service.ErrMFANotEnrolled)
+
+	// Case 5: Already enabled
+	enabledAt := time.Now()
+	mockUserRepo.On("FindByID", mock.Anything, uint(3)).Return(&model.User{Model: gorm.Model{ID: 3}, MFAEnabledAt: &enabledAt}, nil).Once()
+
+	_, err = mfaService.BeginEnrollment(context.Background(), 3)
+	assert.ErrorIs(t, err, service.ErrMFAAlreadyEnabled)
+
+	mockUserRepo.AssertExpectations(t)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestMFAVerifyCode(t *testing.T) {
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockRepo := new(mocks.MockMFARepository)
+	mfaService := service.NewMFAService(mockUserRepo, mockRepo)
+
+	secret, _ := totp.GenerateSecret()
+	enabledAt := time.Now()
+	user := &model.User{Model: gorm.Model{ID: 1}, Role: model.RoleUser, MFASecret: secret, MFAEnabledAt: &enabledAt}
+	mockUserRepo.On("FindByID", mock.Anything, uint(1)).Return(user, nil)
+	code, _ := totp.Code(secret, time.Now())
+
+	// Case 1: A current TOTP code
+	mockRepo.On("AcceptStep", mock.Anything, uint(1), mock.AnythingOfType("int64")).Return(nil).Once()
+	assert.NoError(t, mfaService.VerifyCode(context.Background(), 1, code))
+
+	// Case 2: The same code again is refused
+	mockRepo.On("AcceptStep", mock.Anything, uint(1), mock.AnythingOfType("int64")).Return(repository.ErrTokenAlreadyUsed).Once()
+	assert.ErrorIs(t, mfaService.VerifyCode(context.Background(), 1, code), service.ErrInvalidMFACode)
+
+	// Case 3: A recovery code, in any case and with separators
+	mockRepo.On("ConsumeRecoveryCode", mock.Anything, uint(1), token.HashOpaqueToken("abcdefghijklmnop")).Return(nil).Once()
+	assert.NoError(t, mfaService.VerifyCode(context.Background(), 1, "ABCD-EFGH-IJKL-MNOP"))
+
+	// Case 4: An unknown or used recovery code
+	mockRepo.On("ConsumeRecoveryCode", mock.Anything, uint(1), mock.AnythingOfType("string")).Return(gorm.ErrRecordNotFound).Once()
+	assert.ErrorIs(t, mfaService.VerifyCode(context.Background(), 1, "zzzz-zzzz-zzzz-zzzz"), service.ErrInvalidMFACode)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestMFADisable(t *testing.T) {
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockRepo := new(mocks.MockMFARepository)
+	mfaService := service.NewMFAService(mockUserRepo, mockRepo)
+
+	secret, _ := totp.GenerateSecret()
+	enabledAt := time.Now()
+	code, _ := totp.Code(secret, time.Now())
+
+	// Case 1: Users may turn MFA off with a current code
+	user := &model.User{Model: gorm.Model{ID: 1}, Role: model.RoleUser, MFASecret: secret, MFAEnabledAt: &enabledAt}
+	mockUserRepo.On("FindByID", mock.Anything, uint(1)).Return(user, nil).Once()
+	mockRepo.On("AcceptStep", mock.Anything, uint(1), mock.AnythingOfType("int64")).Return(nil).Once()
+	mockRepo.On("Disable", mock.Anything, uint(1)).Return(nil).Once()
+
+	assert.NoError(t, mfaService.Disable(context.Background(), 1, code))
+
+	// Case 2: Admins may not
+	admin := &model.User{Model: gorm.Model{ID: 2}, Role: model.RoleAdmin, MFASecret: secret, MFAEnabledAt: &enabledAt}
+	mockUserRepo.On("FindByID", mock.Anything, uint(2)).Return(admin, nil).Once()
+
+	assert.ErrorIs(t, mfaService.Disable(context.Background(), 2, code), service.ErrMFARequired)
+
+	// Case 3: Regenerating recovery codes takes a valid code too
+	mockUserRepo.On("FindByID", mock.Anything, uint(1)).Return(user, nil).Once()
+	mockRepo.On("AcceptStep", mock.Anything, uint(1), mock.AnythingOfType("int64")).Return(nil).Once()
+	mockRepo.On("ReplaceRecoveryCodes", mock.Anything, uint(1), mock.AnythingOfType("[]model.MFARecoveryCode")).Return(nil).Once()
+
+	codes, err := mfaService.RegenerateRecoveryCodes(context.Background(), 1, code)
+	assert.NoError(t, err)
+	assert.Len(t, codes, 10)
+
+	mockUserRepo.AssertExpectations(t)
+	mockRepo.AssertExpectations(t)
+}
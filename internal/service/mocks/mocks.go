@@ -1,7 +1,13 @@
 package mocks
 
 import (
+	"context"
+
+	"github.com/beingaloksharma/book-backend/internal/identity"
 	"github.com/beingaloksharma/book-backend/internal/model"
+	"github.com/beingaloksharma/book-backend/internal/repository"
+	"github.com/beingaloksharma/book-backend/internal/service"
+	"github.com/beingaloksharma/book-backend/utils/mail"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -10,13 +16,176 @@ type MockAuthService struct {
 	mock.Mock
 }
 
-func (m *MockAuthService) Signup(name, email, password string, role model.Role) error {
-	args := m.Called(name, email, password, role)
+func (m *MockAuthService) Signup(ctx context.Context, name, email, password string) error {
+	args := m.Called(ctx, name, email, password)
+	return args.Error(0)
+}
+func (m *MockAuthService) Login(ctx context.Context, email, password, ip string) (*service.LoginResult, error) {
+	args := m.Called(ctx, email, password, ip)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*service.LoginResult), args.Error(1)
+}
+func (m *MockAuthService) BeginMFAEnrollment(ctx context.Context, challengeToken string) (*service.MFAEnrollment, error) {
+	args := m.Called(ctx, challengeToken)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*service.MFAEnrollment), args.Error(1)
+}
+func (m *MockAuthService) VerifyMFA(ctx context.Context, challengeToken, code, ip string) (*service.MFALoginResult, error) {
+	args := m.Called(ctx, challengeToken, code, ip)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*service.MFALoginResult), args.Error(1)
+}
+func (m *MockAuthService) Refresh(ctx context.Context, refreshToken string) (*service.TokenPair, error) {
+	args := m.Called(ctx, refreshToken)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*service.TokenPair), args.Error(1)
+}
+func (m *MockAuthService) Logout(ctx context.Context, refreshToken string) error {
+	args := m.Called(ctx, refreshToken)
+	return args.Error(0)
+}
+func (m *MockAuthService) LogoutAll(ctx context.Context, userID uint) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+// MockPasswordService
+type MockPasswordService struct {
+	mock.Mock
+}
+
+func (m *MockPasswordService) ForgotPassword(ctx context.Context, email string) error {
+	args := m.Called(ctx, email)
+	return args.Error(0)
+}
+func (m *MockPasswordService) ResetPassword(ctx context.Context, resetToken, newPassword string) error {
+	args := m.Called(ctx, resetToken, newPassword)
+	return args.Error(0)
+}
+
+// MockMFAService
+type MockMFAService struct {
+	mock.Mock
+}
+
+func (m *MockMFAService) Required(role model.Role) bool {
+	args := m.Called(role)
+	return args.Bool(0)
+}
+func (m *MockMFAService) BeginEnrollment(ctx context.Context, userID uint) (*service.MFAEnrollment, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*service.MFAEnrollment), args.Error(1)
+}
+func (m *MockMFAService) ConfirmEnrollment(ctx context.Context, userID uint, code string) ([]string, error) {
+	args := m.Called(ctx, userID, code)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+func (m *MockMFAService) VerifyCode(ctx context.Context, userID uint, code string) error {
+	args := m.Called(ctx, userID, code)
+	return args.Error(0)
+}
+func (m *MockMFAService) Disable(ctx context.Context, userID uint, code string) error {
+	args := m.Called(ctx, userID, code)
+	return args.Error(0)
+}
+func (m *MockMFAService) RegenerateRecoveryCodes(ctx context.Context, userID uint, code string) ([]string, error) {
+	args := m.Called(ctx, userID, code)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+// MockLoginGuard
+type MockLoginGuard struct {
+	mock.Mock
+}
+
+func (m *MockLoginGuard) Check(ctx context.Context, email, ip string) error {
+	args := m.Called(ctx, email, ip)
+	return args.Error(0)
+}
+func (m *MockLoginGuard) RecordFailure(ctx context.Context, email, ip string) error {
+	args := m.Called(ctx, email, ip)
+	return args.Error(0)
+}
+func (m *MockLoginGuard) RecordSuccess(ctx context.Context, email string) error {
+	args := m.Called(ctx, email)
+	return args.Error(0)
+}
+func (m *MockLoginGuard) Unlock(ctx context.Context, userID, unlockedBy uint) error {
+	args := m.Called(ctx, userID, unlockedBy)
+	return args.Error(0)
+}
+func (m *MockLoginGuard) ListAuditEvents(ctx context.Context, limit int) ([]model.AuditEvent, error) {
+	args := m.Called(ctx, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.AuditEvent), args.Error(1)
+}
+
+// MockVerificationService
+type MockVerificationService struct {
+	mock.Mock
+}
+
+func (m *MockVerificationService) SendVerification(ctx context.Context, user *model.User) error {
+	args := m.Called(ctx, user)
+	return args.Error(0)
+}
+func (m *MockVerificationService) VerifyEmail(ctx context.Context, verifyToken string) error {
+	args := m.Called(ctx, verifyToken)
+	return args.Error(0)
+}
+func (m *MockVerificationService) ResendVerification(ctx context.Context, email string) error {
+	args := m.Called(ctx, email)
+	return args.Error(0)
+}
+
+// MockProfileService
+type MockProfileService struct {
+	mock.Mock
+}
+
+func (m *MockProfileService) UpdateProfile(ctx context.Context, userID uint, changes service.ProfileChanges) (*model.User, error) {
+	args := m.Called(ctx, userID, changes)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.User), args.Error(1)
+}
+func (m *MockProfileService) ChangePassword(ctx context.Context, userID uint, current, newPassword string) error {
+	args := m.Called(ctx, userID, current, newPassword)
 	return args.Error(0)
 }
-func (m *MockAuthService) Login(email, password string) (string, error) {
-	args := m.Called(email, password)
-	return args.String(0), args.Error(1)
+func (m *MockProfileService) DeleteAccount(ctx context.Context, userID uint) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+// MockMailSender
+type MockMailSender struct {
+	mock.Mock
+}
+
+func (m *MockMailSender) Send(msg mail.Message) error {
+	args := m.Called(msg)
+	return args.Error(0)
 }
 
 // MockUserService
@@ -24,53 +193,119 @@ type MockUserService struct {
 	mock.Mock
 }
 
-func (m *MockUserService) GetProfile(userID uint) (*model.User, error) {
-	args := m.Called(userID)
+func (m *MockUserService) GetProfile(ctx context.Context, userID uint) (*model.User, error) {
+	args := m.Called(ctx, userID)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*model.User), args.Error(1)
 }
-func (m *MockUserService) AddAddress(userID uint, street, city, state, zip, country string) error {
-	args := m.Called(userID, street, city, state, zip, country)
+func (m *MockUserService) AddAddress(ctx context.Context, userID uint, street, city, state, zip, country string) error {
+	args := m.Called(ctx, userID, street, city, state, zip, country)
 	return args.Error(0)
 }
-func (m *MockUserService) GetAddresses(userID uint) ([]model.Address, error) {
-	args := m.Called(userID)
+func (m *MockUserService) GetAddresses(ctx context.Context, userID uint) ([]model.Address, error) {
+	args := m.Called(ctx, userID)
 	return args.Get(0).([]model.Address), args.Error(1)
 }
-func (m *MockUserService) GetAllUsers() ([]model.User, error) {
-	args := m.Called()
+func (m *MockUserService) UpdateAddress(ctx context.Context, userID, addressID uint, changes model.Address) (*model.Address, error) {
+	args := m.Called(ctx, userID, addressID, changes)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Address), args.Error(1)
+}
+func (m *MockUserService) DeleteAddress(ctx context.Context, userID, addressID uint) error {
+	args := m.Called(ctx, userID, addressID)
+	return args.Error(0)
+}
+func (m *MockUserService) GetAllUsers(ctx context.Context) ([]model.User, error) {
+	args := m.Called(ctx)
 	return args.Get(0).([]model.User), args.Error(1)
 }
+func (m *MockUserService) ChangeRole(ctx context.Context, userID uint, role model.Role, actor identity.Principal, note string) (*model.User, error) {
+	args := m.Called(ctx, userID, role, actor, note)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.User), args.Error(1)
+}
+func (m *MockUserService) GetRoleHistory(ctx context.Context, userID uint) ([]model.UserRoleHistory, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.UserRoleHistory), args.Error(1)
+}
+
+// MockRoleService
+type MockRoleService struct {
+	mock.Mock
+}
+
+func (m *MockRoleService) ListRoles(ctx context.Context) ([]model.RoleDefinition, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.RoleDefinition), args.Error(1)
+}
+func (m *MockRoleService) CreateRole(ctx context.Context, name model.Role, description string, permissions []model.Permission) (*model.RoleDefinition, error) {
+	args := m.Called(ctx, name, description, permissions)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.RoleDefinition), args.Error(1)
+}
+func (m *MockRoleService) SetRolePermissions(ctx context.Context, name model.Role, permissions []model.Permission) (*model.RoleDefinition, error) {
+	args := m.Called(ctx, name, permissions)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.RoleDefinition), args.Error(1)
+}
+func (m *MockRoleService) DeleteRole(ctx context.Context, name model.Role) error {
+	args := m.Called(ctx, name)
+	return args.Error(0)
+}
 
 // MockBookService
 type MockBookService struct {
 	mock.Mock
 }
 
-func (m *MockBookService) CreateBook(title, author, description string, price float64, stock int) error {
-	args := m.Called(title, author, description, price, stock)
+func (m *MockBookService) CreateBook(ctx context.Context, title, author, description string, price float64, stock int) error {
+	args := m.Called(ctx, title, author, description, price, stock)
 	return args.Error(0)
 }
-func (m *MockBookService) UpdateBook(id uint, title, author, description string, price float64, stock int) error {
-	args := m.Called(id, title, author, description, price, stock)
+func (m *MockBookService) UpdateBook(ctx context.Context, id uint, title, author, description string, price float64, stock int) error {
+	args := m.Called(ctx, id, title, author, description, price, stock)
 	return args.Error(0)
 }
-func (m *MockBookService) DeleteBook(id uint) error {
-	args := m.Called(id)
+func (m *MockBookService) DeleteBook(ctx context.Context, id uint) error {
+	args := m.Called(ctx, id)
 	return args.Error(0)
 }
-func (m *MockBookService) GetBook(id uint) (*model.Book, error) {
-	args := m.Called(id)
+func (m *MockBookService) GetBook(ctx context.Context, id uint) (*model.Book, error) {
+	args := m.Called(ctx, id)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*model.Book), args.Error(1)
 }
-func (m *MockBookService) ListBooks() ([]model.Book, error) {
-	args := m.Called()
-	return args.Get(0).([]model.Book), args.Error(1)
+func (m *MockBookService) ListBooks(ctx context.Context, query repository.BookQuery) ([]model.Book, int64, error) {
+	args := m.Called(ctx, query)
+	if args.Get(0) == nil {
+		return nil, 0, args.Error(2)
+	}
+	return args.Get(0).([]model.Book), args.Get(1).(int64), args.Error(2)
+}
+func (m *MockBookService) SearchBooks(ctx context.Context, query repository.BookSearchQuery) ([]repository.BookSearchResult, int64, error) {
+	args := m.Called(ctx, query)
+	if args.Get(0) == nil {
+		return nil, 0, args.Error(2)
+	}
+	return args.Get(0).([]repository.BookSearchResult), args.Get(1).(int64), args.Error(2)
 }
 
 // MockCartService
@@ -78,16 +313,28 @@ type MockCartService struct {
 	mock.Mock
 }
 
-func (m *MockCartService) AddToCart(userID, bookID uint, quantity int) error {
-	args := m.Called(userID, bookID, quantity)
+func (m *MockCartService) AddToCart(ctx context.Context, userID, bookID uint, quantity int) error {
+	args := m.Called(ctx, userID, bookID, quantity)
 	return args.Error(0)
 }
-func (m *MockCartService) GetCart(userID uint) (*model.Cart, error) {
-	args := m.Called(userID)
+func (m *MockCartService) GetCart(ctx context.Context, userID uint) (*model.CartSummary, error) {
+	args := m.Called(ctx, userID)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
-	return args.Get(0).(*model.Cart), args.Error(1)
+	return args.Get(0).(*model.CartSummary), args.Error(1)
+}
+func (m *MockCartService) UpdateCartItem(ctx context.Context, userID, bookID uint, quantity int) error {
+	args := m.Called(ctx, userID, bookID, quantity)
+	return args.Error(0)
+}
+func (m *MockCartService) RemoveCartItem(ctx context.Context, userID, bookID uint) error {
+	args := m.Called(ctx, userID, bookID)
+	return args.Error(0)
+}
+func (m *MockCartService) ClearCart(ctx context.Context, userID uint) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
 }
 
 // MockOrderService
@@ -95,15 +342,36 @@ type MockOrderService struct {
 	mock.Mock
 }
 
-func (m *MockOrderService) PlaceOrder(userID, addressID uint) error {
-	args := m.Called(userID, addressID)
+func (m *MockOrderService) PlaceOrder(ctx context.Context, userID, addressID uint) error {
+	args := m.Called(ctx, userID, addressID)
 	return args.Error(0)
 }
-func (m *MockOrderService) GetOrders(userID uint) ([]model.Order, error) {
-	args := m.Called(userID)
+func (m *MockOrderService) GetOrders(ctx context.Context, userID uint) ([]model.Order, error) {
+	args := m.Called(ctx, userID)
 	return args.Get(0).([]model.Order), args.Error(1)
 }
-func (m *MockOrderService) GetAllOrders() ([]model.Order, error) {
-	args := m.Called()
+func (m *MockOrderService) GetAllOrders(ctx context.Context) ([]model.Order, error) {
+	args := m.Called(ctx)
 	return args.Get(0).([]model.Order), args.Error(1)
 }
+func (m *MockOrderService) UpdateOrderStatus(ctx context.Context, orderID uint, status model.OrderStatus, changedBy uint, note string) (*model.Order, error) {
+	args := m.Called(ctx, orderID, status, changedBy, note)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Order), args.Error(1)
+}
+func (m *MockOrderService) GetOrderStatusHistory(ctx context.Context, orderID uint) ([]model.OrderStatusHistory, error) {
+	args := m.Called(ctx, orderID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.OrderStatusHistory), args.Error(1)
+}
+func (m *MockOrderService) CancelOrder(ctx context.Context, orderID uint, actor identity.Principal, reason string) (*model.Order, error) {
+	args := m.Called(ctx, orderID, actor, reason)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Order), args.Error(1)
+}
@@ -1,47 +1,238 @@
 package service
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"sort"
 
+	"github.com/beingaloksharma/book-backend/internal/identity"
 	"github.com/beingaloksharma/book-backend/internal/model"
 	"github.com/beingaloksharma/book-backend/internal/repository"
+	"gorm.io/gorm"
 )
 
 type OrderService struct {
 	OrderRepo repository.OrderRepositoryInterface
 	CartRepo  repository.CartRepositoryInterface
 	BookRepo  repository.BookRepositoryInterface
+	UserRepo  repository.UserRepositoryInterface
+	TxManager repository.TransactionManagerInterface
+	RoleRepo  repository.RoleRepositoryInterface
 }
 
-func NewOrderService(orderRepo repository.OrderRepositoryInterface, cartRepo repository.CartRepositoryInterface, bookRepo repository.BookRepositoryInterface) *OrderService {
+func NewOrderService(orderRepo repository.OrderRepositoryInterface, cartRepo repository.CartRepositoryInterface, bookRepo repository.BookRepositoryInterface, userRepo repository.UserRepositoryInterface, txManager repository.TransactionManagerInterface, roleRepo repository.RoleRepositoryInterface) *OrderService {
 	return &OrderService{
 		OrderRepo: orderRepo,
 		CartRepo:  cartRepo,
 		BookRepo:  bookRepo,
+		UserRepo:  userRepo,
+		TxManager: txManager,
+		RoleRepo:  roleRepo,
 	}
 }
 
-func (s *OrderService) PlaceOrder(userID, addressID uint) error {
+func (s *OrderService) PlaceOrder(ctx context.Context, userID, addressID uint) error {
+	if verificationRequired(VerificationPolicyOrders) {
+		user, err := s.UserRepo.FindByID(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if !user.IsVerified() {
+			return ErrEmailNotVerified
+		}
+	}
+
 	// Get Cart
-	cart, err := s.CartRepo.FindCartByUserID(userID)
-	if err != nil || len(cart.Items) == 0 {
-		return errors.New("cart is empty")
+	cart, err := s.CartRepo.FindCartByUserID(ctx, userID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if cart == nil || len(cart.Items) == 0 {
+		return ErrEmptyCart
+	}
+
+	address, err := s.findUserAddress(ctx, userID, addressID)
+	if err != nil {
+		return err
 	}
 
 	order := &model.Order{
-		UserID:    userID,
-		AddressID: addressID,
-		Status:    model.OrderStatusPending,
+		UserID:          userID,
+		AddressID:       address.ID,
+		ShippingAddress: address.Snapshot(),
+		Status:          model.OrderStatusPending,
 	}
 
-	// Use Transaction in Repository
-	return s.OrderRepo.PlaceOrderTransaction(order, cart.Items, cart.ID)
+	return s.TxManager.WithinTransaction(ctx, func(ctx context.Context) error {
+		// Lock books in a stable order to avoid deadlocking with other orders
+		items := append([]model.CartItem(nil), cart.Items...)
+		sort.Slice(items, func(i, j int) bool { return items[i].BookID < items[j].BookID })
+
+		for _, item := range items {
+			book, err := s.BookRepo.FindByIDForUpdate(ctx, item.BookID)
+			if err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return fmt.Errorf("%w: %d", ErrBookNotFound, item.BookID)
+				}
+				return err
+			}
+			if book.Stock < item.Quantity {
+				return fmt.Errorf("%w for book: %s", ErrInsufficientStock, book.Title)
+			}
+			if err := s.BookRepo.AdjustStock(ctx, book.ID, -item.Quantity); err != nil {
+				return err
+			}
+
+			order.Amount += book.Price * float64(item.Quantity)
+			order.Items = append(order.Items, model.OrderItem{
+				BookID:   item.BookID,
+				Quantity: item.Quantity,
+				Price:    book.Price,
+			})
+		}
+
+		if err := s.OrderRepo.CreateOrder(ctx, order); err != nil {
+			return err
+		}
+		return s.CartRepo.ClearCart(ctx, cart.ID)
+	})
 }
 
-func (s *OrderService) GetOrders(userID uint) ([]model.Order, error) {
-	return s.OrderRepo.FindByUserID(userID)
+// findUserAddress returns the address only if it belongs to userID. A zero
+// addressID selects the user's default shipping address.
+func (s *OrderService) findUserAddress(ctx context.Context, userID, addressID uint) (*model.Address, error) {
+	addresses, err := s.UserRepo.GetAddresses(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range addresses {
+		if addresses[i].ID == addressID || (addressID == 0 && addresses[i].IsDefaultShipping) {
+			return &addresses[i], nil
+		}
+	}
+	if addressID == 0 {
+		return nil, fmt.Errorf("%w: no default shipping address", ErrAddressNotFound)
+	}
+	return nil, ErrAddressNotFound
+}
+
+func (s *OrderService) GetOrders(ctx context.Context, userID uint) ([]model.Order, error) {
+	return s.OrderRepo.FindByUserID(ctx, userID)
+}
+
+func (s *OrderService) GetAllOrders(ctx context.Context) ([]model.Order, error) {
+	return s.OrderRepo.FindAllOrders(ctx)
+}
+
+// UpdateOrderStatus moves an order to status if the order state machine allows it,
+// recording the change against changedBy. Cancelling also returns the items to stock.
+func (s *OrderService) UpdateOrderStatus(ctx context.Context, orderID uint, status model.OrderStatus, changedBy uint, note string) (*model.Order, error) {
+	if !status.IsValid() {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidOrderStatus, status)
+	}
+
+	order, err := s.findOrder(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !order.Status.CanTransitionTo(status) {
+		return nil, fmt.Errorf("%w: cannot move order from %s to %s", ErrInvalidStatusTransition, order.Status, status)
+	}
+
+	return s.transition(ctx, order, status, changedBy, note)
 }
 
-func (s *OrderService) GetAllOrders() ([]model.Order, error) {
-	return s.OrderRepo.FindAllOrders()
+// transition persists an already validated status change of order.
+func (s *OrderService) transition(ctx context.Context, order *model.Order, status model.OrderStatus, changedBy uint, note string) (*model.Order, error) {
+	var err error
+	history := &model.OrderStatusHistory{
+		ChangedBy: changedBy,
+		Note:      note,
+	}
+	if status == model.OrderStatusCancelled {
+		err = s.cancel(ctx, order.ID, order.Status, history)
+	} else {
+		err = s.OrderRepo.UpdateStatus(ctx, order.ID, order.Status, status, history)
+	}
+	if err != nil {
+		if errors.Is(err, repository.ErrStatusConflict) {
+			return nil, fmt.Errorf("%w: order %d was updated by someone else, retry", ErrInvalidStatusTransition, order.ID)
+		}
+		return nil, err
+	}
+
+	order.Status = status
+	return order, nil
+}
+
+// cancel cancels the order and returns every item's quantity to stock. It
+// returns repository.ErrStatusConflict if the order is no longer in the from status.
+func (s *OrderService) cancel(ctx context.Context, orderID uint, from model.OrderStatus, history *model.OrderStatusHistory) error {
+	return s.TxManager.WithinTransaction(ctx, func(ctx context.Context) error {
+		// Lock the order so a concurrent cancel or status change cannot restock twice
+		order, err := s.OrderRepo.FindByIDForUpdate(ctx, orderID)
+		if err != nil {
+			return err
+		}
+		if order.Status != from {
+			return repository.ErrStatusConflict
+		}
+
+		// Restock in a stable order to avoid deadlocking with other orders
+		items := append([]model.OrderItem(nil), order.Items...)
+		sort.Slice(items, func(i, j int) bool { return items[i].BookID < items[j].BookID })
+		for _, item := range items {
+			if err := s.BookRepo.AdjustStock(ctx, item.BookID, item.Quantity); err != nil {
+				return err
+			}
+		}
+
+		return s.OrderRepo.UpdateStatus(ctx, orderID, from, model.OrderStatusCancelled, history)
+	})
+}
+
+// CancelOrder cancels an order on behalf of actor, who must own it or have a
+// role that grants orders:manage, and restores the stock of every item.
+func (s *OrderService) CancelOrder(ctx context.Context, orderID uint, actor identity.Principal, reason string) (*model.Order, error) {
+	order, err := s.findOrder(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Hide other users' orders entirely rather than reporting them as forbidden
+	if order.UserID != actor.UserID {
+		allowed, err := s.RoleRepo.HasPermission(ctx, actor.Role, model.PermissionOrdersManage)
+		if err != nil {
+			return nil, err
+		}
+		if !allowed {
+			return nil, ErrOrderNotFound
+		}
+	}
+
+	if !order.Status.CanTransitionTo(model.OrderStatusCancelled) {
+		return nil, fmt.Errorf("%w: order in status %s can no longer be cancelled", ErrInvalidStatusTransition, order.Status)
+	}
+
+	return s.transition(ctx, order, model.OrderStatusCancelled, actor.UserID, reason)
+}
+
+func (s *OrderService) findOrder(ctx context.Context, orderID uint) (*model.Order, error) {
+	order, err := s.OrderRepo.FindByID(ctx, orderID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOrderNotFound
+		}
+		return nil, err
+	}
+	return order, nil
+}
+
+func (s *OrderService) GetOrderStatusHistory(ctx context.Context, orderID uint) ([]model.OrderStatusHistory, error) {
+	if _, err := s.findOrder(ctx, orderID); err != nil {
+		return nil, err
+	}
+	return s.OrderRepo.FindStatusHistory(ctx, orderID)
 }
@@ -1,12 +1,17 @@
 package service_test
 
 import (
+	"context"
 	"errors"
 	"testing"
+	"time"
 
+	"github.com/beingaloksharma/book-backend/internal/identity"
 	"github.com/beingaloksharma/book-backend/internal/model"
+	"github.com/beingaloksharma/book-backend/internal/repository"
 	"github.com/beingaloksharma/book-backend/internal/repository/mocks"
 	"github.com/beingaloksharma/book-backend/internal/service"
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"gorm.io/gorm"
@@ -16,14 +21,25 @@ func TestPlaceOrder(t *testing.T) {
 	mockOrderRepo := new(mocks.MockOrderRepository)
 	mockCartRepo := new(mocks.MockCartRepository)
 	mockBookRepo := new(mocks.MockBookRepository)
-	orderService := service.NewOrderService(mockOrderRepo, mockCartRepo, mockBookRepo)
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockTxManager := new(mocks.MockTransactionManager)
+	orderService := service.NewOrderService(mockOrderRepo, mockCartRepo, mockBookRepo, mockUserRepo, mockTxManager, new(mocks.MockRoleRepository))
 
 	// Case 1: Cart Empty
-	mockCartRepo.On("FindCartByUserID", uint(1)).Return(&model.Cart{Items: []model.CartItem{}}, nil).Once()
-	err := orderService.PlaceOrder(1, 1)
+	mockCartRepo.On("FindCartByUserID", mock.Anything, uint(1)).Return(&model.Cart{Items: []model.CartItem{}}, nil).Once()
+	err := orderService.PlaceOrder(context.Background(), 1, 1)
 	assert.Error(t, err)
 	assert.Equal(t, "cart is empty", err.Error())
 
+	mockCartRepo.On("FindCartByUserID", mock.Anything, uint(1)).Return(nil, gorm.ErrRecordNotFound).Once()
+	err = orderService.PlaceOrder(context.Background(), 1, 1)
+	assert.ErrorIs(t, err, service.ErrEmptyCart)
+
+	// A failed cart lookup is not reported as an empty cart
+	mockCartRepo.On("FindCartByUserID", mock.Anything, uint(1)).Return(nil, errors.New("connection reset")).Once()
+	err = orderService.PlaceOrder(context.Background(), 1, 1)
+	assert.NotErrorIs(t, err, service.ErrEmptyCart)
+
 	// Case 2: Success
 	cart := &model.Cart{
 		Model: gorm.Model{ID: 10},
@@ -31,26 +47,115 @@ func TestPlaceOrder(t *testing.T) {
 			{BookID: 1, Quantity: 2},
 		},
 	}
-	mockCartRepo.On("FindCartByUserID", uint(1)).Return(cart, nil).Once()
-	mockOrderRepo.On("PlaceOrderTransaction", mock.AnythingOfType("*model.Order"), cart.Items, cart.ID).Return(nil).Once()
+	address := model.Address{Model: gorm.Model{ID: 1}, UserID: 1, Street: "1 Main St", City: "Springfield", State: "IL", ZipCode: "62701", Country: "USA"}
+	book := &model.Book{Model: gorm.Model{ID: 1}, Title: "Go", Price: 10, Stock: 5}
+	mockCartRepo.On("FindCartByUserID", mock.Anything, uint(1)).Return(cart, nil).Once()
+	mockUserRepo.On("GetAddresses", mock.Anything, uint(1)).Return([]model.Address{address}, nil).Once()
+	mockTxManager.On("WithinTransaction", mock.Anything).Return(nil).Once()
+	mockBookRepo.On("FindByIDForUpdate", mock.Anything, uint(1)).Return(book, nil).Once()
+	mockBookRepo.On("AdjustStock", mock.Anything, uint(1), -2).Return(nil).Once()
+	mockOrderRepo.On("CreateOrder", mock.Anything, mock.MatchedBy(func(order *model.Order) bool {
+		return order.AddressID == 1 && order.ShippingAddress == address.Snapshot() &&
+			order.Amount == 20 && len(order.Items) == 1 && order.Items[0].Price == 10
+	})).Return(nil).Once()
+	mockCartRepo.On("ClearCart", mock.Anything, cart.ID).Return(nil).Once()
 
-	err = orderService.PlaceOrder(1, 1)
+	err = orderService.PlaceOrder(context.Background(), 1, 1)
 	assert.NoError(t, err)
 
+	// Case 3: Address belongs to someone else
+	mockCartRepo.On("FindCartByUserID", mock.Anything, uint(1)).Return(cart, nil).Once()
+	mockUserRepo.On("GetAddresses", mock.Anything, uint(1)).Return([]model.Address{address}, nil).Once()
+
+	err = orderService.PlaceOrder(context.Background(), 1, 2)
+	assert.ErrorIs(t, err, service.ErrAddressNotFound)
+
+	// Case 4: No address given, default shipping address is used
+	other := model.Address{Model: gorm.Model{ID: 3}, UserID: 1, City: "Elsewhere"}
+	defaultAddress := address
+	defaultAddress.IsDefaultShipping = true
+	mockCartRepo.On("FindCartByUserID", mock.Anything, uint(1)).Return(cart, nil).Once()
+	mockUserRepo.On("GetAddresses", mock.Anything, uint(1)).Return([]model.Address{other, defaultAddress}, nil).Once()
+	mockTxManager.On("WithinTransaction", mock.Anything).Return(nil).Once()
+	mockBookRepo.On("FindByIDForUpdate", mock.Anything, uint(1)).Return(book, nil).Once()
+	mockBookRepo.On("AdjustStock", mock.Anything, uint(1), -2).Return(nil).Once()
+	mockOrderRepo.On("CreateOrder", mock.Anything, mock.MatchedBy(func(order *model.Order) bool {
+		return order.AddressID == 1 && order.ShippingAddress.City == "Springfield"
+	})).Return(nil).Once()
+	mockCartRepo.On("ClearCart", mock.Anything, cart.ID).Return(nil).Once()
+
+	err = orderService.PlaceOrder(context.Background(), 1, 0)
+	assert.NoError(t, err)
+
+	// Case 5: No address given and no default set
+	mockCartRepo.On("FindCartByUserID", mock.Anything, uint(1)).Return(cart, nil).Once()
+	mockUserRepo.On("GetAddresses", mock.Anything, uint(1)).Return([]model.Address{other}, nil).Once()
+
+	err = orderService.PlaceOrder(context.Background(), 1, 0)
+	assert.ErrorIs(t, err, service.ErrAddressNotFound)
+
+	// Case 6: Not enough stock; nothing is written
+	mockCartRepo.On("FindCartByUserID", mock.Anything, uint(1)).Return(cart, nil).Once()
+	mockUserRepo.On("GetAddresses", mock.Anything, uint(1)).Return([]model.Address{address}, nil).Once()
+	mockTxManager.On("WithinTransaction", mock.Anything).Return(nil).Once()
+	mockBookRepo.On("FindByIDForUpdate", mock.Anything, uint(1)).Return(&model.Book{Model: gorm.Model{ID: 1}, Title: "Go", Stock: 1}, nil).Once()
+
+	err = orderService.PlaceOrder(context.Background(), 1, 1)
+	assert.ErrorIs(t, err, service.ErrInsufficientStock)
+
+	// Case 7: Book removed from the catalog
+	mockCartRepo.On("FindCartByUserID", mock.Anything, uint(1)).Return(cart, nil).Once()
+	mockUserRepo.On("GetAddresses", mock.Anything, uint(1)).Return([]model.Address{address}, nil).Once()
+	mockTxManager.On("WithinTransaction", mock.Anything).Return(nil).Once()
+	mockBookRepo.On("FindByIDForUpdate", mock.Anything, uint(1)).Return(nil, gorm.ErrRecordNotFound).Once()
+
+	err = orderService.PlaceOrder(context.Background(), 1, 1)
+	assert.ErrorIs(t, err, service.ErrBookNotFound)
+
 	mockOrderRepo.AssertExpectations(t)
 	mockCartRepo.AssertExpectations(t)
+	mockBookRepo.AssertExpectations(t)
+	mockUserRepo.AssertExpectations(t)
+	mockTxManager.AssertExpectations(t)
+}
+
+func TestPlaceOrder_RequiresVerifiedEmail(t *testing.T) {
+	viper.Set("email_verification.require", "orders")
+	defer viper.Set("email_verification.require", "")
+
+	mockCartRepo := new(mocks.MockCartRepository)
+	mockUserRepo := new(mocks.MockUserRepository)
+	orderService := service.NewOrderService(new(mocks.MockOrderRepository), mockCartRepo, new(mocks.MockBookRepository), mockUserRepo, new(mocks.MockTransactionManager), new(mocks.MockRoleRepository))
+
+	// Case 1: Unverified account is refused before the cart is read
+	mockUserRepo.On("FindByID", mock.Anything, uint(1)).Return(&model.User{Model: gorm.Model{ID: 1}}, nil).Once()
+
+	err := orderService.PlaceOrder(context.Background(), 1, 1)
+	assert.ErrorIs(t, err, service.ErrEmailNotVerified)
+
+	// Case 2: Verified account goes on to the cart
+	now := time.Now()
+	mockUserRepo.On("FindByID", mock.Anything, uint(1)).Return(&model.User{Model: gorm.Model{ID: 1}, VerifiedAt: &now}, nil).Once()
+	mockCartRepo.On("FindCartByUserID", mock.Anything, uint(1)).Return(&model.Cart{}, nil).Once()
+
+	err = orderService.PlaceOrder(context.Background(), 1, 1)
+	assert.EqualError(t, err, "cart is empty")
+
+	mockUserRepo.AssertExpectations(t)
+	mockCartRepo.AssertExpectations(t)
 }
 
 func TestGetOrders(t *testing.T) {
 	mockOrderRepo := new(mocks.MockOrderRepository)
 	mockCartRepo := new(mocks.MockCartRepository)
 	mockBookRepo := new(mocks.MockBookRepository)
-	orderService := service.NewOrderService(mockOrderRepo, mockCartRepo, mockBookRepo)
+	mockUserRepo := new(mocks.MockUserRepository)
+	orderService := service.NewOrderService(mockOrderRepo, mockCartRepo, mockBookRepo, mockUserRepo, new(mocks.MockTransactionManager), new(mocks.MockRoleRepository))
 
 	orders := []model.Order{{UserID: 1}}
-	mockOrderRepo.On("FindByUserID", uint(1)).Return(orders, nil)
+	mockOrderRepo.On("FindByUserID", mock.Anything, uint(1)).Return(orders, nil)
 
-	result, err := orderService.GetOrders(1)
+	result, err := orderService.GetOrders(context.Background(), 1)
 	assert.NoError(t, err)
 	assert.Equal(t, 1, len(result))
 
@@ -61,12 +166,13 @@ func TestGetAllOrders(t *testing.T) {
 	mockOrderRepo := new(mocks.MockOrderRepository)
 	mockCartRepo := new(mocks.MockCartRepository)
 	mockBookRepo := new(mocks.MockBookRepository)
-	orderService := service.NewOrderService(mockOrderRepo, mockCartRepo, mockBookRepo)
+	mockUserRepo := new(mocks.MockUserRepository)
+	orderService := service.NewOrderService(mockOrderRepo, mockCartRepo, mockBookRepo, mockUserRepo, new(mocks.MockTransactionManager), new(mocks.MockRoleRepository))
 
 	orders := []model.Order{{UserID: 1}}
-	mockOrderRepo.On("FindAllOrders").Return(orders, nil)
+	mockOrderRepo.On("FindAllOrders", mock.Anything).Return(orders, nil)
 
-	result, err := orderService.GetAllOrders()
+	result, err := orderService.GetAllOrders(context.Background())
 	assert.NoError(t, err)
 	assert.Equal(t, 1, len(result))
 
@@ -77,11 +183,13 @@ func TestPlaceOrder_RepoError(t *testing.T) {
 	mockOrderRepo := new(mocks.MockOrderRepository)
 	mockCartRepo := new(mocks.MockCartRepository)
 	mockBookRepo := new(mocks.MockBookRepository)
-	orderService := service.NewOrderService(mockOrderRepo, mockCartRepo, mockBookRepo)
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockTxManager := new(mocks.MockTransactionManager)
+	orderService := service.NewOrderService(mockOrderRepo, mockCartRepo, mockBookRepo, mockUserRepo, mockTxManager, new(mocks.MockRoleRepository))
 
 	// Case 1: Cart Error
-	mockCartRepo.On("FindCartByUserID", uint(1)).Return(nil, errors.New("db error"))
-	err := orderService.PlaceOrder(1, 1)
+	mockCartRepo.On("FindCartByUserID", mock.Anything, uint(1)).Return(nil, errors.New("db error"))
+	err := orderService.PlaceOrder(context.Background(), 1, 1)
 	assert.Error(t, err)
 
 	// Case 2: Transaction Error
@@ -89,10 +197,11 @@ func TestPlaceOrder_RepoError(t *testing.T) {
 		Model: gorm.Model{ID: 10},
 		Items: []model.CartItem{{BookID: 1, Quantity: 1}},
 	}
-	mockCartRepo.On("FindCartByUserID", uint(1)).Return(cart, nil)
-	mockOrderRepo.On("PlaceOrderTransaction", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("tx error"))
+	mockCartRepo.On("FindCartByUserID", mock.Anything, uint(1)).Return(cart, nil)
+	mockUserRepo.On("GetAddresses", mock.Anything, uint(1)).Return([]model.Address{{Model: gorm.Model{ID: 1}, UserID: 1}}, nil)
+	mockTxManager.On("WithinTransaction", mock.Anything).Return(errors.New("tx error"))
 
-	err = orderService.PlaceOrder(1, 1)
+	err = orderService.PlaceOrder(context.Background(), 1, 1)
 	assert.Error(t, err)
 }
 
@@ -100,10 +209,177 @@ func TestGetOrders_Error(t *testing.T) {
 	mockOrderRepo := new(mocks.MockOrderRepository)
 	mockCartRepo := new(mocks.MockCartRepository)
 	mockBookRepo := new(mocks.MockBookRepository)
-	orderService := service.NewOrderService(mockOrderRepo, mockCartRepo, mockBookRepo)
+	mockUserRepo := new(mocks.MockUserRepository)
+	orderService := service.NewOrderService(mockOrderRepo, mockCartRepo, mockBookRepo, mockUserRepo, new(mocks.MockTransactionManager), new(mocks.MockRoleRepository))
 
-	mockOrderRepo.On("FindByUserID", uint(1)).Return(nil, errors.New("db error"))
+	mockOrderRepo.On("FindByUserID", mock.Anything, uint(1)).Return(nil, errors.New("db error"))
 
-	_, err := orderService.GetOrders(1)
+	_, err := orderService.GetOrders(context.Background(), 1)
 	assert.Error(t, err)
 }
+
+func TestUpdateOrderStatus(t *testing.T) {
+	mockOrderRepo := new(mocks.MockOrderRepository)
+	mockCartRepo := new(mocks.MockCartRepository)
+	mockBookRepo := new(mocks.MockBookRepository)
+	mockUserRepo := new(mocks.MockUserRepository)
+	orderService := service.NewOrderService(mockOrderRepo, mockCartRepo, mockBookRepo, mockUserRepo, new(mocks.MockTransactionManager), new(mocks.MockRoleRepository))
+
+	// Case 1: Legal transition records who made it
+	mockOrderRepo.On("FindByID", mock.Anything, uint(1)).Return(&model.Order{Model: gorm.Model{ID: 1}, Status: model.OrderStatusPending}, nil).Once()
+	mockOrderRepo.On("UpdateStatus", mock.Anything, uint(1), model.OrderStatusPending, model.OrderStatusPaid, mock.MatchedBy(func(h *model.OrderStatusHistory) bool {
+		return h.ChangedBy == 9 && h.Note == "paid by card"
+	})).Return(nil).Once()
+
+	order, err := orderService.UpdateOrderStatus(context.Background(), 1, model.OrderStatusPaid, 9, "paid by card")
+	assert.NoError(t, err)
+	assert.Equal(t, model.OrderStatusPaid, order.Status)
+
+	// Case 2: Illegal transition
+	mockOrderRepo.On("FindByID", mock.Anything, uint(1)).Return(&model.Order{Model: gorm.Model{ID: 1}, Status: model.OrderStatusPending}, nil).Once()
+
+	_, err = orderService.UpdateOrderStatus(context.Background(), 1, model.OrderStatusDelivered, 9, "")
+	assert.ErrorIs(t, err, service.ErrInvalidStatusTransition)
+	assert.Contains(t, err.Error(), "cannot move order from PENDING to DELIVERED")
+
+	// Case 3: Terminal status
+	mockOrderRepo.On("FindByID", mock.Anything, uint(2)).Return(&model.Order{Model: gorm.Model{ID: 2}, Status: model.OrderStatusCancelled}, nil).Once()
+
+	_, err = orderService.UpdateOrderStatus(context.Background(), 2, model.OrderStatusPaid, 9, "")
+	assert.ErrorIs(t, err, service.ErrInvalidStatusTransition)
+
+	// Case 4: Unknown status
+	_, err = orderService.UpdateOrderStatus(context.Background(), 1, model.OrderStatus("LOST"), 9, "")
+	assert.ErrorIs(t, err, service.ErrInvalidOrderStatus)
+
+	// Case 5: Order not found
+	mockOrderRepo.On("FindByID", mock.Anything, uint(3)).Return(nil, gorm.ErrRecordNotFound).Once()
+
+	_, err = orderService.UpdateOrderStatus(context.Background(), 3, model.OrderStatusPaid, 9, "")
+	assert.ErrorIs(t, err, service.ErrOrderNotFound)
+
+	// Case 6: Concurrent update
+	mockOrderRepo.On("FindByID", mock.Anything, uint(4)).Return(&model.Order{Model: gorm.Model{ID: 4}, Status: model.OrderStatusPaid}, nil).Once()
+	mockOrderRepo.On("UpdateStatus", mock.Anything, uint(4), model.OrderStatusPaid, model.OrderStatusShipped, mock.Anything).Return(repository.ErrStatusConflict).Once()
+
+	_, err = orderService.UpdateOrderStatus(context.Background(), 4, model.OrderStatusShipped, 9, "")
+	assert.ErrorIs(t, err, service.ErrInvalidStatusTransition)
+
+	mockOrderRepo.AssertExpectations(t)
+}
+
+func TestGetOrderStatusHistory(t *testing.T) {
+	mockOrderRepo := new(mocks.MockOrderRepository)
+	mockCartRepo := new(mocks.MockCartRepository)
+	mockBookRepo := new(mocks.MockBookRepository)
+	mockUserRepo := new(mocks.MockUserRepository)
+	orderService := service.NewOrderService(mockOrderRepo, mockCartRepo, mockBookRepo, mockUserRepo, new(mocks.MockTransactionManager), new(mocks.MockRoleRepository))
+
+	history := []model.OrderStatusHistory{{OrderID: 1, FromStatus: model.OrderStatusPending, ToStatus: model.OrderStatusPaid}}
+	mockOrderRepo.On("FindByID", mock.Anything, uint(1)).Return(&model.Order{Model: gorm.Model{ID: 1}}, nil).Once()
+	mockOrderRepo.On("FindStatusHistory", mock.Anything, uint(1)).Return(history, nil).Once()
+
+	result, err := orderService.GetOrderStatusHistory(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Len(t, result, 1)
+
+	mockOrderRepo.On("FindByID", mock.Anything, uint(2)).Return(nil, gorm.ErrRecordNotFound).Once()
+	_, err = orderService.GetOrderStatusHistory(context.Background(), 2)
+	assert.ErrorIs(t, err, service.ErrOrderNotFound)
+
+	mockOrderRepo.AssertExpectations(t)
+}
+
+func TestCancelOrder(t *testing.T) {
+	mockOrderRepo := new(mocks.MockOrderRepository)
+	mockCartRepo := new(mocks.MockCartRepository)
+	mockBookRepo := new(mocks.MockBookRepository)
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockTxManager := new(mocks.MockTransactionManager)
+	mockRoleRepo := new(mocks.MockRoleRepository)
+	orderService := service.NewOrderService(mockOrderRepo, mockCartRepo, mockBookRepo, mockUserRepo, mockTxManager, mockRoleRepo)
+	owner := identity.Principal{UserID: 5, Role: model.RoleUser}
+	mockRoleRepo.On("HasPermission", mock.Anything, model.RoleUser, model.PermissionOrdersManage).Return(false, nil)
+	mockRoleRepo.On("HasPermission", mock.Anything, model.Role("SUPPORT"), model.PermissionOrdersManage).Return(true, nil)
+	mockRoleRepo.On("HasPermission", mock.Anything, model.RoleAdmin, model.PermissionOrdersManage).Return(false, nil)
+
+	// Case 1: Owner cancels a pending order
+	pending := &model.Order{Model: gorm.Model{ID: 1}, UserID: 5, Status: model.OrderStatusPending, Items: []model.OrderItem{
+		{BookID: 200, Quantity: 1},
+		{BookID: 100, Quantity: 2},
+	}}
+	mockOrderRepo.On("FindByID", mock.Anything, uint(1)).Return(pending, nil).Once()
+	mockTxManager.On("WithinTransaction", mock.Anything).Return(nil).Once()
+	mockOrderRepo.On("FindByIDForUpdate", mock.Anything, uint(1)).Return(pending, nil).Once()
+	restock100 := mockBookRepo.On("AdjustStock", mock.Anything, uint(100), 2).Return(nil).Once()
+	mockBookRepo.On("AdjustStock", mock.Anything, uint(200), 1).Return(nil).Once().NotBefore(restock100)
+	mockOrderRepo.On("UpdateStatus", mock.Anything, uint(1), model.OrderStatusPending, model.OrderStatusCancelled, mock.MatchedBy(func(h *model.OrderStatusHistory) bool {
+		return h.ChangedBy == 5 && h.Note == "changed my mind"
+	})).Return(nil).Once()
+
+	order, err := orderService.CancelOrder(context.Background(), 1, owner, "changed my mind")
+	assert.NoError(t, err)
+	assert.Equal(t, model.OrderStatusCancelled, order.Status)
+
+	// Case 2: Another user's order
+	mockOrderRepo.On("FindByID", mock.Anything, uint(1)).Return(&model.Order{Model: gorm.Model{ID: 1}, UserID: 5, Status: model.OrderStatusPending}, nil).Once()
+
+	_, err = orderService.CancelOrder(context.Background(), 1, identity.Principal{UserID: 6, Role: model.RoleUser}, "")
+	assert.ErrorIs(t, err, service.ErrOrderNotFound)
+
+	// Case 3: A role with orders:manage cancels someone else's paid order
+	paid := &model.Order{Model: gorm.Model{ID: 2}, UserID: 5, Status: model.OrderStatusPaid}
+	mockOrderRepo.On("FindByID", mock.Anything, uint(2)).Return(paid, nil).Once()
+	mockTxManager.On("WithinTransaction", mock.Anything).Return(nil).Once()
+	mockOrderRepo.On("FindByIDForUpdate", mock.Anything, uint(2)).Return(paid, nil).Once()
+	mockOrderRepo.On("UpdateStatus", mock.Anything, uint(2), model.OrderStatusPaid, model.OrderStatusCancelled, mock.Anything).Return(nil).Once()
+
+	_, err = orderService.CancelOrder(context.Background(), 2, identity.Principal{UserID: 1, Role: "SUPPORT"}, "")
+	assert.NoError(t, err)
+
+	// Case 4: Already shipped
+	mockOrderRepo.On("FindByID", mock.Anything, uint(3)).Return(&model.Order{Model: gorm.Model{ID: 3}, UserID: 5, Status: model.OrderStatusShipped}, nil).Once()
+
+	_, err = orderService.CancelOrder(context.Background(), 3, owner, "")
+	assert.ErrorIs(t, err, service.ErrInvalidStatusTransition)
+
+	// Case 5: Shipped by someone else after it was read; nothing is restocked
+	mockOrderRepo.On("FindByID", mock.Anything, uint(4)).Return(&model.Order{Model: gorm.Model{ID: 4}, UserID: 5, Status: model.OrderStatusPending}, nil).Once()
+	mockTxManager.On("WithinTransaction", mock.Anything).Return(nil).Once()
+	mockOrderRepo.On("FindByIDForUpdate", mock.Anything, uint(4)).Return(&model.Order{Model: gorm.Model{ID: 4}, UserID: 5, Status: model.OrderStatusShipped}, nil).Once()
+
+	_, err = orderService.CancelOrder(context.Background(), 4, owner, "")
+	assert.ErrorIs(t, err, service.ErrInvalidStatusTransition)
+
+	// Case 6: The ADMIN name alone is not enough once orders:manage is taken away
+	mockOrderRepo.On("FindByID", mock.Anything, uint(1)).Return(&model.Order{Model: gorm.Model{ID: 1}, UserID: 5, Status: model.OrderStatusPending}, nil).Once()
+
+	_, err = orderService.CancelOrder(context.Background(), 1, identity.Principal{UserID: 9, Role: model.RoleAdmin}, "")
+	assert.ErrorIs(t, err, service.ErrOrderNotFound)
+
+	mockOrderRepo.AssertExpectations(t)
+	mockBookRepo.AssertExpectations(t)
+	mockTxManager.AssertExpectations(t)
+}
+
+func TestUpdateOrderStatus_CancelRestocks(t *testing.T) {
+	mockOrderRepo := new(mocks.MockOrderRepository)
+	mockCartRepo := new(mocks.MockCartRepository)
+	mockBookRepo := new(mocks.MockBookRepository)
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockTxManager := new(mocks.MockTransactionManager)
+	orderService := service.NewOrderService(mockOrderRepo, mockCartRepo, mockBookRepo, mockUserRepo, mockTxManager, new(mocks.MockRoleRepository))
+
+	order := &model.Order{Model: gorm.Model{ID: 1}, Status: model.OrderStatusPending, Items: []model.OrderItem{{BookID: 100, Quantity: 3}}}
+	mockOrderRepo.On("FindByID", mock.Anything, uint(1)).Return(order, nil).Once()
+	mockTxManager.On("WithinTransaction", mock.Anything).Return(nil).Once()
+	mockOrderRepo.On("FindByIDForUpdate", mock.Anything, uint(1)).Return(order, nil).Once()
+	mockBookRepo.On("AdjustStock", mock.Anything, uint(100), 3).Return(nil).Once()
+	mockOrderRepo.On("UpdateStatus", mock.Anything, uint(1), model.OrderStatusPending, model.OrderStatusCancelled, mock.Anything).Return(nil).Once()
+
+	_, err := orderService.UpdateOrderStatus(context.Background(), 1, model.OrderStatusCancelled, 9, "")
+	assert.NoError(t, err)
+
+	mockOrderRepo.AssertExpectations(t)
+	mockBookRepo.AssertExpectations(t)
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/beingaloksharma/book-backend/internal/model"
+	"github.com/beingaloksharma/book-backend/internal/repository"
+	"github.com/beingaloksharma/book-backend/utils/crypto"
+	"github.com/beingaloksharma/book-backend/utils/mail"
+	"github.com/beingaloksharma/book-backend/utils/token"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+// DefaultPasswordResetTTL is how long a password reset link stays valid.
+const DefaultPasswordResetTTL = time.Hour
+
+type PasswordService struct {
+	UserRepo  repository.UserRepositoryInterface
+	TokenRepo repository.OneTimeTokenRepositoryInterface
+	Sessions  repository.RefreshTokenRepositoryInterface
+	Mailer    mail.Sender
+}
+
+func NewPasswordService(userRepo repository.UserRepositoryInterface, tokenRepo repository.OneTimeTokenRepositoryInterface, sessions repository.RefreshTokenRepositoryInterface, mailer mail.Sender) *PasswordService {
+	return &PasswordService{
+		UserRepo:  userRepo,
+		TokenRepo: tokenRepo,
+		Sessions:  sessions,
+		Mailer:    mailer,
+	}
+}
+
+// ForgotPassword emails a reset link if the address belongs to an account.
+// Unknown addresses succeed silently so callers cannot probe for accounts; the
+// link is issued and sent in the background so that known addresses do not
+// take measurably longer to answer either. Failures there are only logged.
+func (s *PasswordService) ForgotPassword(ctx context.Context, email string) error {
+	user, err := s.UserRepo.FindByEmail(ctx, email)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		if err := s.sendResetLink(context.WithoutCancel(ctx), user); err != nil {
+			logrus.WithError(err).WithField("user_id", user.ID).Warn("Failed to send password reset email")
+		}
+	}()
+	return nil
+}
+
+func (s *PasswordService) sendResetLink(ctx context.Context, user *model.User) error {
+	plain, hash, err := token.NewOpaqueToken()
+	if err != nil {
+		return err
+	}
+	ttl := passwordResetTTL()
+	record := &model.OneTimeToken{
+		UserID:    user.ID,
+		Purpose:   model.TokenPurposePasswordReset,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := s.TokenRepo.Issue(ctx, record); err != nil {
+		return err
+	}
+
+	return s.Mailer.Send(mail.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nUse this link to choose a new password. It expires in %s and works once:\n\n%s%s\n\nIf you did not ask for this, ignore this email.\n",
+			user.Name, ttl, viper.GetString("password_reset.url"), plain),
+	})
+}
+
+// ResetPassword sets a new password using a token from ForgotPassword. The
+// token is spent even if it is replayed concurrently, and every session of the
+// user is ended since the old password may be known to someone else.
+func (s *PasswordService) ResetPassword(ctx context.Context, resetToken, newPassword string) error {
+	record, err := s.TokenRepo.FindByHash(ctx, model.TokenPurposePasswordReset, token.HashOpaqueToken(resetToken))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrInvalidResetToken
+	}
+	if err != nil {
+		return err
+	}
+	if record.UsedAt != nil || time.Now().After(record.ExpiresAt) {
+		return ErrInvalidResetToken
+	}
+
+	hashedPwd, err := crypto.HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+	if err := s.TokenRepo.Consume(ctx, record); err != nil {
+		if errors.Is(err, repository.ErrTokenAlreadyUsed) {
+			return ErrInvalidResetToken
+		}
+		return err
+	}
+	if err := s.UserRepo.UpdatePassword(ctx, record.UserID, hashedPwd); err != nil {
+		return err
+	}
+	return s.Sessions.RevokeAllForUser(ctx, record.UserID)
+}
+
+func passwordResetTTL() time.Duration {
+	if ttl := viper.GetDuration("password_reset.ttl"); ttl > 0 {
+		return ttl
+	}
+	return DefaultPasswordResetTTL
+}
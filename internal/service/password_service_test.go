@@ -0,0 +1,131 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/beingaloksharma/book-backend/internal/model"
+	"github.com/beingaloksharma/book-backend/internal/repository"
+	"github.com/beingaloksharma/book-backend/internal/repository/mocks"
+	"github.com/beingaloksharma/book-backend/internal/service"
+	servicemocks "github.com/beingaloksharma/book-backend/internal/service/mocks"
+	"github.com/beingaloksharma/book-backend/utils/crypto"
+	"github.com/beingaloksharma/book-backend/utils/mail"
+	"github.com/beingaloksharma/book-backend/utils/token"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+func TestForgotPassword(t *testing.T) {
+	viper.Set("password_reset.url", "https://shop.example.com/reset?token=")
+	defer viper.Set("password_reset.url", "")
+
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockTokenRepo := new(mocks.MockOneTimeTokenRepository)
+	mockMailer := new(servicemocks.MockMailSender)
+	passwordService := service.NewPasswordService(mockUserRepo, mockTokenRepo, new(mocks.MockRefreshTokenRepository), mockMailer)
+
+	// Case 1: Known email gets a link whose token matches the stored hash,
+	// without waiting for it to be delivered
+	user := &model.User{Model: gorm.Model{ID: 1}, Name: "John", Email: "john@example.com"}
+	mockUserRepo.On("FindByEmail", mock.Anything, "john@example.com").Return(user, nil).Once()
+
+	var issued *model.OneTimeToken
+	mockTokenRepo.On("Issue", mock.Anything, mock.MatchedBy(func(r *model.OneTimeToken) bool {
+		issued = r
+		return r.UserID == 1 && r.Purpose == model.TokenPurposePasswordReset && r.ExpiresAt.After(time.Now())
+	})).Return(nil).Once()
+
+	release := make(chan struct{})
+	delivered := make(chan mail.Message, 1)
+	mockMailer.On("Send", mock.MatchedBy(func(m mail.Message) bool {
+		return m.To == "john@example.com"
+	})).Run(func(args mock.Arguments) {
+		<-release
+		delivered <- args.Get(0).(mail.Message)
+	}).Return(nil).Once()
+
+	assert.NoError(t, passwordService.ForgotPassword(context.Background(), "john@example.com"))
+	close(release)
+	sent := <-delivered
+	i := strings.Index(sent.Body, "https://shop.example.com/reset?token=")
+	assert.GreaterOrEqual(t, i, 0)
+	plain := strings.Fields(sent.Body[i+len("https://shop.example.com/reset?token="):])[0]
+	assert.Equal(t, issued.TokenHash, token.HashOpaqueToken(plain))
+
+	// Case 2: Unknown email does nothing and reports success, like a known one
+	mockUserRepo.On("FindByEmail", mock.Anything, "nobody@example.com").Return(nil, gorm.ErrRecordNotFound).Once()
+
+	assert.NoError(t, passwordService.ForgotPassword(context.Background(), "nobody@example.com"))
+
+	// Case 3: Delivery failure is not reported either
+	failed := make(chan struct{})
+	mockUserRepo.On("FindByEmail", mock.Anything, "john@example.com").Return(user, nil).Once()
+	mockTokenRepo.On("Issue", mock.Anything, mock.Anything).Return(nil).Once()
+	mockMailer.On("Send", mock.Anything).Run(func(mock.Arguments) { close(failed) }).Return(errors.New("smtp down")).Once()
+
+	assert.NoError(t, passwordService.ForgotPassword(context.Background(), "john@example.com"))
+	<-failed
+
+	// Case 4: Lookup failure
+	mockUserRepo.On("FindByEmail", mock.Anything, "john@example.com").Return(nil, errors.New("db down")).Once()
+
+	assert.Error(t, passwordService.ForgotPassword(context.Background(), "john@example.com"))
+
+	mockUserRepo.AssertExpectations(t)
+	mockTokenRepo.AssertExpectations(t)
+	mockMailer.AssertExpectations(t)
+}
+
+func TestResetPassword(t *testing.T) {
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockTokenRepo := new(mocks.MockOneTimeTokenRepository)
+	mockSessions := new(mocks.MockRefreshTokenRepository)
+	passwordService := service.NewPasswordService(mockUserRepo, mockTokenRepo, mockSessions, new(servicemocks.MockMailSender))
+
+	hash := token.HashOpaqueToken("reset-1")
+	purpose := model.TokenPurposePasswordReset
+
+	// Case 1: Success changes the password and ends every session
+	record := &model.OneTimeToken{Model: gorm.Model{ID: 3}, UserID: 1, Purpose: purpose, TokenHash: hash, ExpiresAt: time.Now().Add(time.Hour)}
+	mockTokenRepo.On("FindByHash", mock.Anything, purpose, hash).Return(record, nil).Once()
+	mockTokenRepo.On("Consume", mock.Anything, record).Return(nil).Once()
+	mockUserRepo.On("UpdatePassword", mock.Anything, uint(1), mock.MatchedBy(func(h string) bool {
+		return crypto.CheckPasswordHash("new-secret", h)
+	})).Return(nil).Once()
+	mockSessions.On("RevokeAllForUser", mock.Anything, uint(1)).Return(nil).Once()
+
+	assert.NoError(t, passwordService.ResetPassword(context.Background(), "reset-1", "new-secret"))
+
+	// Case 2: Unknown token
+	mockTokenRepo.On("FindByHash", mock.Anything, purpose, token.HashOpaqueToken("bogus")).Return(nil, gorm.ErrRecordNotFound).Once()
+
+	assert.ErrorIs(t, passwordService.ResetPassword(context.Background(), "bogus", "new-secret"), service.ErrInvalidResetToken)
+
+	// Case 3: Already used
+	used := time.Now()
+	mockTokenRepo.On("FindByHash", mock.Anything, purpose, hash).Return(&model.OneTimeToken{UserID: 1, UsedAt: &used, ExpiresAt: time.Now().Add(time.Hour)}, nil).Once()
+
+	assert.ErrorIs(t, passwordService.ResetPassword(context.Background(), "reset-1", "new-secret"), service.ErrInvalidResetToken)
+
+	// Case 4: Expired
+	mockTokenRepo.On("FindByHash", mock.Anything, purpose, hash).Return(&model.OneTimeToken{UserID: 1, ExpiresAt: time.Now().Add(-time.Minute)}, nil).Once()
+
+	assert.ErrorIs(t, passwordService.ResetPassword(context.Background(), "reset-1", "new-secret"), service.ErrInvalidResetToken)
+
+	// Case 5: Used concurrently by another request
+	racing := &model.OneTimeToken{Model: gorm.Model{ID: 4}, UserID: 1, ExpiresAt: time.Now().Add(time.Hour)}
+	mockTokenRepo.On("FindByHash", mock.Anything, purpose, hash).Return(racing, nil).Once()
+	mockTokenRepo.On("Consume", mock.Anything, racing).Return(repository.ErrTokenAlreadyUsed).Once()
+
+	assert.ErrorIs(t, passwordService.ResetPassword(context.Background(), "reset-1", "new-secret"), service.ErrInvalidResetToken)
+
+	mockUserRepo.AssertExpectations(t)
+	mockTokenRepo.AssertExpectations(t)
+	mockSessions.AssertExpectations(t)
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/beingaloksharma/book-backend/internal/model"
+	"github.com/beingaloksharma/book-backend/internal/repository"
+	"github.com/beingaloksharma/book-backend/utils/crypto"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+type ProfileService struct {
+	UserRepo     repository.UserRepositoryInterface
+	Sessions     repository.RefreshTokenRepositoryInterface
+	Verification VerificationServiceInterface
+	TxManager    repository.TransactionManagerInterface
+}
+
+func NewProfileService(userRepo repository.UserRepositoryInterface, sessions repository.RefreshTokenRepositoryInterface, verification VerificationServiceInterface, txManager repository.TransactionManagerInterface) *ProfileService {
+	return &ProfileService{
+		UserRepo:     userRepo,
+		Sessions:     sessions,
+		Verification: verification,
+		TxManager:    txManager,
+	}
+}
+
+// ProfileChanges lists the profile fields to update; nil fields are left as they are.
+type ProfileChanges struct {
+	Name  *string
+	Email *string
+}
+
+// UpdateProfile changes the user's name and email. A new email address is
+// unverified until the user opens the link sent to it.
+func (s *ProfileService) UpdateProfile(ctx context.Context, userID uint, changes ProfileChanges) (*model.User, error) {
+	user, err := s.findUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if changes.Name != nil {
+		user.Name = strings.TrimSpace(*changes.Name)
+	}
+	emailChanged := false
+	if changes.Email != nil && !strings.EqualFold(*changes.Email, user.Email) {
+		existing, err := s.UserRepo.FindByEmail(ctx, *changes.Email)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		if existing != nil {
+			return nil, ErrEmailTaken
+		}
+		user.Email = *changes.Email
+		user.VerifiedAt = nil
+		emailChanged = true
+	}
+
+	if err := s.UserRepo.UpdateProfile(ctx, user); err != nil {
+		return nil, err
+	}
+
+	// The change is saved either way; the user can ask for the link again
+	if emailChanged {
+		if err := s.Verification.SendVerification(ctx, user); err != nil {
+			logrus.WithError(err).WithField("user_id", user.ID).Warn("Failed to send verification email")
+		}
+	}
+	return user, nil
+}
+
+// ChangePassword replaces the password after checking the current one, then
+// logs the user out of every session.
+func (s *ProfileService) ChangePassword(ctx context.Context, userID uint, current, newPassword string) error {
+	user, err := s.findUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !crypto.CheckPasswordHash(current, user.Password) {
+		return ErrIncorrectPassword
+	}
+
+	hashedPwd, err := crypto.HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+	if err := s.UserRepo.UpdatePassword(ctx, user.ID, hashedPwd); err != nil {
+		return err
+	}
+	return s.Sessions.RevokeAllForUser(ctx, user.ID)
+}
+
+// DeleteAccount closes the account: personal data is erased and every session
+// ends, while orders stay in place for accounting.
+func (s *ProfileService) DeleteAccount(ctx context.Context, userID uint) error {
+	user, err := s.findUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	err = s.TxManager.WithinTransaction(ctx, func(ctx context.Context) error {
+		if user.Role == model.RoleAdmin {
+			if err := ensureAnotherAdmin(ctx, s.UserRepo); err != nil {
+				return err
+			}
+		}
+		return s.UserRepo.Anonymize(ctx, user.ID)
+	})
+	if err != nil {
+		return err
+	}
+	return s.Sessions.RevokeAllForUser(ctx, user.ID)
+}
+
+func (s *ProfileService) findUser(ctx context.Context, userID uint) (*model.User, error) {
+	user, err := s.UserRepo.FindByID(ctx, userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrUserNotFound
+	}
+	return user, err
+}
@@ -0,0 +1,135 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/beingaloksharma/book-backend/internal/model"
+	"github.com/beingaloksharma/book-backend/internal/repository/mocks"
+	"github.com/beingaloksharma/book-backend/internal/service"
+	servicemocks "github.com/beingaloksharma/book-backend/internal/service/mocks"
+	"github.com/beingaloksharma/book-backend/utils/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+func TestUpdateProfile(t *testing.T) {
+	mockRepo := new(mocks.MockUserRepository)
+	mockVerification := new(servicemocks.MockVerificationService)
+	profileService := service.NewProfileService(mockRepo, new(mocks.MockRefreshTokenRepository), mockVerification, new(mocks.MockTransactionManager))
+
+	verified := time.Now()
+	name := "Johnny"
+	email := "johnny@example.com"
+
+	// Case 1: Name change keeps the verified email
+	mockRepo.On("FindByID", mock.Anything, uint(1)).Return(&model.User{Model: gorm.Model{ID: 1}, Name: "John", Email: "john@example.com", VerifiedAt: &verified}, nil).Once()
+	mockRepo.On("UpdateProfile", mock.Anything, mock.MatchedBy(func(u *model.User) bool {
+		return u.Name == "Johnny" && u.Email == "john@example.com" && u.IsVerified()
+	})).Return(nil).Once()
+
+	user, err := profileService.UpdateProfile(context.Background(), 1, service.ProfileChanges{Name: &name})
+	assert.NoError(t, err)
+	assert.Equal(t, "Johnny", user.Name)
+
+	// Case 2: New email is unverified and gets a verification link
+	mockRepo.On("FindByID", mock.Anything, uint(1)).Return(&model.User{Model: gorm.Model{ID: 1}, Email: "john@example.com", VerifiedAt: &verified}, nil).Once()
+	mockRepo.On("FindByEmail", mock.Anything, email).Return(nil, gorm.ErrRecordNotFound).Once()
+	mockRepo.On("UpdateProfile", mock.Anything, mock.MatchedBy(func(u *model.User) bool {
+		return u.Email == email && !u.IsVerified()
+	})).Return(nil).Once()
+	mockVerification.On("SendVerification", mock.Anything, mock.MatchedBy(func(u *model.User) bool {
+		return u.Email == email
+	})).Return(nil).Once()
+
+	user, err = profileService.UpdateProfile(context.Background(), 1, service.ProfileChanges{Email: &email})
+	assert.NoError(t, err)
+	assert.Nil(t, user.VerifiedAt)
+
+	// Case 3: Email belongs to another account
+	mockRepo.On("FindByID", mock.Anything, uint(1)).Return(&model.User{Model: gorm.Model{ID: 1}, Email: "john@example.com"}, nil).Once()
+	mockRepo.On("FindByEmail", mock.Anything, email).Return(&model.User{Model: gorm.Model{ID: 2}, Email: email}, nil).Once()
+
+	_, err = profileService.UpdateProfile(context.Background(), 1, service.ProfileChanges{Email: &email})
+	assert.ErrorIs(t, err, service.ErrEmailTaken)
+
+	// Case 4: User not found
+	mockRepo.On("FindByID", mock.Anything, uint(9)).Return(nil, gorm.ErrRecordNotFound).Once()
+
+	_, err = profileService.UpdateProfile(context.Background(), 9, service.ProfileChanges{Name: &name})
+	assert.ErrorIs(t, err, service.ErrUserNotFound)
+
+	mockRepo.AssertExpectations(t)
+	mockVerification.AssertExpectations(t)
+}
+
+func TestChangePassword(t *testing.T) {
+	mockRepo := new(mocks.MockUserRepository)
+	mockSessions := new(mocks.MockRefreshTokenRepository)
+	profileService := service.NewProfileService(mockRepo, mockSessions, new(servicemocks.MockVerificationService), new(mocks.MockTransactionManager))
+
+	hashedPwd, _ := crypto.HashPassword("old-secret")
+	user := &model.User{Model: gorm.Model{ID: 1}, Password: hashedPwd}
+
+	// Case 1: Success stores the new hash and ends every session
+	mockRepo.On("FindByID", mock.Anything, uint(1)).Return(user, nil).Once()
+	mockRepo.On("UpdatePassword", mock.Anything, uint(1), mock.MatchedBy(func(h string) bool {
+		return crypto.CheckPasswordHash("new-secret", h)
+	})).Return(nil).Once()
+	mockSessions.On("RevokeAllForUser", mock.Anything, uint(1)).Return(nil).Once()
+
+	assert.NoError(t, profileService.ChangePassword(context.Background(), 1, "old-secret", "new-secret"))
+
+	// Case 2: Wrong current password
+	mockRepo.On("FindByID", mock.Anything, uint(1)).Return(user, nil).Once()
+
+	assert.ErrorIs(t, profileService.ChangePassword(context.Background(), 1, "wrong", "new-secret"), service.ErrIncorrectPassword)
+
+	mockRepo.AssertExpectations(t)
+	mockSessions.AssertExpectations(t)
+}
+
+func TestDeleteAccount(t *testing.T) {
+	mockRepo := new(mocks.MockUserRepository)
+	mockSessions := new(mocks.MockRefreshTokenRepository)
+	mockTxManager := new(mocks.MockTransactionManager)
+	profileService := service.NewProfileService(mockRepo, mockSessions, new(servicemocks.MockVerificationService), mockTxManager)
+
+	// Case 1: Success anonymizes the account and ends every session
+	mockRepo.On("FindByID", mock.Anything, uint(1)).Return(&model.User{Model: gorm.Model{ID: 1}, Role: model.RoleUser}, nil).Once()
+	mockTxManager.On("WithinTransaction", mock.Anything).Return(nil).Once()
+	mockRepo.On("Anonymize", mock.Anything, uint(1)).Return(nil).Once()
+	mockSessions.On("RevokeAllForUser", mock.Anything, uint(1)).Return(nil).Once()
+
+	assert.NoError(t, profileService.DeleteAccount(context.Background(), 1))
+
+	// Case 2: Last admin
+	mockRepo.On("FindByID", mock.Anything, uint(2)).Return(&model.User{Model: gorm.Model{ID: 2}, Role: model.RoleAdmin}, nil).Once()
+	mockTxManager.On("WithinTransaction", mock.Anything).Return(nil).Once()
+	mockRepo.On("CountByRoleForUpdate", mock.Anything, model.RoleAdmin).Return(int64(1), nil).Once()
+
+	assert.ErrorIs(t, profileService.DeleteAccount(context.Background(), 2), service.ErrLastAdmin)
+
+	// Case 3: One of several admins
+	mockRepo.On("FindByID", mock.Anything, uint(2)).Return(&model.User{Model: gorm.Model{ID: 2}, Role: model.RoleAdmin}, nil).Once()
+	mockTxManager.On("WithinTransaction", mock.Anything).Return(nil).Once()
+	mockRepo.On("CountByRoleForUpdate", mock.Anything, model.RoleAdmin).Return(int64(2), nil).Once()
+	mockRepo.On("Anonymize", mock.Anything, uint(2)).Return(nil).Once()
+	mockSessions.On("RevokeAllForUser", mock.Anything, uint(2)).Return(nil).Once()
+
+	assert.NoError(t, profileService.DeleteAccount(context.Background(), 2))
+
+	// Case 4: Repository failure
+	mockRepo.On("FindByID", mock.Anything, uint(1)).Return(&model.User{Model: gorm.Model{ID: 1}, Role: model.RoleUser}, nil).Once()
+	mockTxManager.On("WithinTransaction", mock.Anything).Return(nil).Once()
+	mockRepo.On("Anonymize", mock.Anything, uint(1)).Return(errors.New("db down")).Once()
+
+	assert.Error(t, profileService.DeleteAccount(context.Background(), 1))
+
+	mockRepo.AssertExpectations(t)
+	mockSessions.AssertExpectations(t)
+	mockTxManager.AssertExpectations(t)
+}
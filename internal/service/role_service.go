@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/beingaloksharma/book-backend/internal/model"
+	"github.com/beingaloksharma/book-backend/internal/repository"
+	"gorm.io/gorm"
+)
+
+var roleNamePattern = regexp.MustCompile(`^[A-Z][A-Z0-9_]{1,63}$`)
+
+type RoleService struct {
+	Repo     repository.RoleRepositoryInterface
+	UserRepo repository.UserRepositoryInterface
+}
+
+func NewRoleService(repo repository.RoleRepositoryInterface, userRepo repository.UserRepositoryInterface) *RoleService {
+	return &RoleService{
+		Repo:     repo,
+		UserRepo: userRepo,
+	}
+}
+
+// SyncBuiltinRoles creates the USER role if it is missing and grants ADMIN
+// every permission, including ones added since the last start.
+func (s *RoleService) SyncBuiltinRoles(ctx context.Context) error {
+	if _, err := s.Repo.FindByName(ctx, model.RoleUser); errors.Is(err, gorm.ErrRecordNotFound) {
+		user := &model.RoleDefinition{Name: model.RoleUser, Description: "Customer account"}
+		if err := s.Repo.Create(ctx, user); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+
+	if _, err := s.Repo.FindByName(ctx, model.RoleAdmin); errors.Is(err, gorm.ErrRecordNotFound) {
+		admin := &model.RoleDefinition{Name: model.RoleAdmin, Description: "Full administrator"}
+		if err := s.Repo.Create(ctx, admin); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+	return s.Repo.SetPermissions(ctx, model.RoleAdmin, model.AllPermissions)
+}
+
+func (s *RoleService) ListRoles(ctx context.Context) ([]model.RoleDefinition, error) {
+	return s.Repo.FindAll(ctx)
+}
+
+// CreateRole defines a new role. Names are upper-cased, e.g. INVENTORY_MANAGER.
+func (s *RoleService) CreateRole(ctx context.Context, name model.Role, description string, permissions []model.Permission) (*model.RoleDefinition, error) {
+	name = model.Role(strings.ToUpper(strings.TrimSpace(string(name))))
+	if !roleNamePattern.MatchString(string(name)) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidRole, name)
+	}
+	if err := validatePermissions(permissions); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.Repo.FindByName(ctx, name); err == nil {
+		return nil, ErrRoleExists
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	role := &model.RoleDefinition{Name: name, Description: description}
+	for _, p := range permissions {
+		role.Permissions = append(role.Permissions, model.RolePermission{Role: name, Permission: p})
+	}
+	if err := s.Repo.Create(ctx, role); err != nil {
+		return nil, err
+	}
+	return role, nil
+}
+
+// SetRolePermissions replaces the permissions of a role. ADMIN always holds
+// every permission and cannot be edited.
+func (s *RoleService) SetRolePermissions(ctx context.Context, name model.Role, permissions []model.Permission) (*model.RoleDefinition, error) {
+	if name == model.RoleAdmin {
+		return nil, ErrBuiltinRole
+	}
+	if err := validatePermissions(permissions); err != nil {
+		return nil, err
+	}
+	if _, err := s.findRole(ctx, name); err != nil {
+		return nil, err
+	}
+
+	if err := s.Repo.SetPermissions(ctx, name, permissions); err != nil {
+		return nil, err
+	}
+	return s.findRole(ctx, name)
+}
+
+// DeleteRole removes a custom role that nobody holds any more.
+func (s *RoleService) DeleteRole(ctx context.Context, name model.Role) error {
+	if name.IsBuiltin() {
+		return ErrBuiltinRole
+	}
+	if _, err := s.findRole(ctx, name); err != nil {
+		return err
+	}
+
+	holders, err := s.UserRepo.CountByRole(ctx, name)
+	if err != nil {
+		return err
+	}
+	if holders > 0 {
+		return fmt.Errorf("%w: %d users still hold it", ErrRoleInUse, holders)
+	}
+	return s.Repo.Delete(ctx, name)
+}
+
+func (s *RoleService) findRole(ctx context.Context, name model.Role) (*model.RoleDefinition, error) {
+	role, err := s.Repo.FindByName(ctx, name)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrRoleNotFound
+	}
+	return role, err
+}
+
+func validatePermissions(permissions []model.Permission) error {
+	seen := make(map[model.Permission]bool)
+	for _, p := range permissions {
+		if !p.IsValid() {
+			return fmt.Errorf("%w: %q", ErrInvalidPermission, p)
+		}
+		if seen[p] {
+			return fmt.Errorf("%w: %q listed twice", ErrInvalidPermission, p)
+		}
+		seen[p] = true
+	}
+	return nil
+}
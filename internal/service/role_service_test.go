@@ -0,0 +1,118 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/beingaloksharma/book-backend/internal/model"
+	"github.com/beingaloksharma/book-backend/internal/repository/mocks"
+	"github.com/beingaloksharma/book-backend/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+func TestSyncBuiltinRoles(t *testing.T) {
+	mockRepo := new(mocks.MockRoleRepository)
+	roleService := service.NewRoleService(mockRepo, new(mocks.MockUserRepository))
+
+	// Case 1: Fresh database creates both roles
+	mockRepo.On("FindByName", mock.Anything, model.RoleUser).Return(nil, gorm.ErrRecordNotFound).Once()
+	mockRepo.On("Create", mock.Anything, mock.MatchedBy(func(r *model.RoleDefinition) bool { return r.Name == model.RoleUser })).Return(nil).Once()
+	mockRepo.On("FindByName", mock.Anything, model.RoleAdmin).Return(nil, gorm.ErrRecordNotFound).Once()
+	mockRepo.On("Create", mock.Anything, mock.MatchedBy(func(r *model.RoleDefinition) bool { return r.Name == model.RoleAdmin })).Return(nil).Once()
+	mockRepo.On("SetPermissions", mock.Anything, model.RoleAdmin, model.AllPermissions).Return(nil).Once()
+
+	assert.NoError(t, roleService.SyncBuiltinRoles(context.Background()))
+
+	// Case 2: Existing roles keep their rows; ADMIN is topped up
+	mockRepo.On("FindByName", mock.Anything, model.RoleUser).Return(&model.RoleDefinition{Name: model.RoleUser}, nil).Once()
+	mockRepo.On("FindByName", mock.Anything, model.RoleAdmin).Return(&model.RoleDefinition{Name: model.RoleAdmin}, nil).Once()
+	mockRepo.On("SetPermissions", mock.Anything, model.RoleAdmin, model.AllPermissions).Return(nil).Once()
+
+	assert.NoError(t, roleService.SyncBuiltinRoles(context.Background()))
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateRole(t *testing.T) {
+	mockRepo := new(mocks.MockRoleRepository)
+	roleService := service.NewRoleService(mockRepo, new(mocks.MockUserRepository))
+
+	// Case 1: Success normalizes the name
+	mockRepo.On("FindByName", mock.Anything, model.Role("INVENTORY_MANAGER")).Return(nil, gorm.ErrRecordNotFound).Once()
+	mockRepo.On("Create", mock.Anything, mock.MatchedBy(func(r *model.RoleDefinition) bool {
+		return r.Name == "INVENTORY_MANAGER" && r.Grants(model.PermissionBooksWrite)
+	})).Return(nil).Once()
+
+	role, err := roleService.CreateRole(context.Background(), " inventory_manager ", "Stock keeping", []model.Permission{model.PermissionBooksWrite})
+	assert.NoError(t, err)
+	assert.Equal(t, model.Role("INVENTORY_MANAGER"), role.Name)
+
+	// Case 2: Bad name
+	_, err = roleService.CreateRole(context.Background(), "x y", "", nil)
+	assert.ErrorIs(t, err, service.ErrInvalidRole)
+
+	// Case 3: Unknown permission
+	_, err = roleService.CreateRole(context.Background(), "SUPPORT", "", []model.Permission{"books:burn"})
+	assert.ErrorIs(t, err, service.ErrInvalidPermission)
+
+	// Case 4: Already defined
+	mockRepo.On("FindByName", mock.Anything, model.Role("SUPPORT")).Return(&model.RoleDefinition{Name: "SUPPORT"}, nil).Once()
+	_, err = roleService.CreateRole(context.Background(), "SUPPORT", "", nil)
+	assert.ErrorIs(t, err, service.ErrRoleExists)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestSetRolePermissions(t *testing.T) {
+	mockRepo := new(mocks.MockRoleRepository)
+	roleService := service.NewRoleService(mockRepo, new(mocks.MockUserRepository))
+	perms := []model.Permission{model.PermissionOrdersReadAll}
+
+	// Case 1: Success
+	updated := &model.RoleDefinition{Name: "SUPPORT", Permissions: []model.RolePermission{{Role: "SUPPORT", Permission: model.PermissionOrdersReadAll}}}
+	mockRepo.On("FindByName", mock.Anything, model.Role("SUPPORT")).Return(&model.RoleDefinition{Name: "SUPPORT"}, nil).Once()
+	mockRepo.On("SetPermissions", mock.Anything, model.Role("SUPPORT"), perms).Return(nil).Once()
+	mockRepo.On("FindByName", mock.Anything, model.Role("SUPPORT")).Return(updated, nil).Once()
+
+	role, err := roleService.SetRolePermissions(context.Background(), "SUPPORT", perms)
+	assert.NoError(t, err)
+	assert.True(t, role.Grants(model.PermissionOrdersReadAll))
+
+	// Case 2: ADMIN is fixed
+	_, err = roleService.SetRolePermissions(context.Background(), model.RoleAdmin, nil)
+	assert.ErrorIs(t, err, service.ErrBuiltinRole)
+
+	// Case 3: Unknown role
+	mockRepo.On("FindByName", mock.Anything, model.Role("MISSING")).Return(nil, gorm.ErrRecordNotFound).Once()
+	_, err = roleService.SetRolePermissions(context.Background(), "MISSING", perms)
+	assert.ErrorIs(t, err, service.ErrRoleNotFound)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestDeleteRole(t *testing.T) {
+	mockRepo := new(mocks.MockRoleRepository)
+	mockUserRepo := new(mocks.MockUserRepository)
+	roleService := service.NewRoleService(mockRepo, mockUserRepo)
+
+	// Case 1: Success
+	mockRepo.On("FindByName", mock.Anything, model.Role("SUPPORT")).Return(&model.RoleDefinition{Name: "SUPPORT"}, nil).Once()
+	mockUserRepo.On("CountByRole", mock.Anything, model.Role("SUPPORT")).Return(int64(0), nil).Once()
+	mockRepo.On("Delete", mock.Anything, model.Role("SUPPORT")).Return(nil).Once()
+
+	assert.NoError(t, roleService.DeleteRole(context.Background(), "SUPPORT"))
+
+	// Case 2: Still assigned
+	mockRepo.On("FindByName", mock.Anything, model.Role("SUPPORT")).Return(&model.RoleDefinition{Name: "SUPPORT"}, nil).Once()
+	mockUserRepo.On("CountByRole", mock.Anything, model.Role("SUPPORT")).Return(int64(3), nil).Once()
+
+	assert.ErrorIs(t, roleService.DeleteRole(context.Background(), "SUPPORT"), service.ErrRoleInUse)
+
+	// Case 3: Built-in
+	assert.ErrorIs(t, roleService.DeleteRole(context.Background(), model.RoleUser), service.ErrBuiltinRole)
+
+	mockRepo.AssertExpectations(t)
+	mockUserRepo.AssertExpectations(t)
+}
@@ -1 +1,39 @@
 package service
+
+import "errors"
+
+var (
+	ErrOrderNotFound            = errors.New("order not found")
+	ErrAddressNotFound          = errors.New("address not found")
+	ErrEmptyCart                = errors.New("cart is empty")
+	ErrInvalidOrderStatus       = errors.New("invalid order status")
+	ErrInvalidStatusTransition  = errors.New("invalid order status transition")
+	ErrCartItemNotFound         = errors.New("item not in cart")
+	ErrInvalidQuantity          = errors.New("quantity must be positive")
+	ErrBookNotFound             = errors.New("book not found")
+	ErrInsufficientStock        = errors.New("not enough stock")
+	ErrUserNotFound             = errors.New("user not found")
+	ErrInvalidRole              = errors.New("invalid role")
+	ErrLastAdmin                = errors.New("cannot remove the last admin")
+	ErrBootstrapAdminUnproven   = errors.New("existing account is not verified or bootstrap_admin.password does not match it")
+	ErrRoleConflict             = errors.New("role was changed concurrently")
+	ErrRoleEscalation           = errors.New("cannot assign roles with permissions you do not have")
+	ErrRoleNotFound             = errors.New("role not found")
+	ErrRoleExists               = errors.New("role already exists")
+	ErrRoleInUse                = errors.New("role is still assigned")
+	ErrBuiltinRole              = errors.New("built-in role cannot be changed")
+	ErrInvalidPermission        = errors.New("invalid permission")
+	ErrInvalidRefreshToken      = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused       = errors.New("refresh token reuse detected; session revoked")
+	ErrInvalidResetToken        = errors.New("invalid or expired password reset token")
+	ErrInvalidVerificationToken = errors.New("invalid or expired verification token")
+	ErrEmailNotVerified         = errors.New("email address is not verified")
+	ErrEmailTaken               = errors.New("email address is already in use")
+	ErrIncorrectPassword        = errors.New("current password is incorrect")
+	ErrTooManyLoginAttempts     = errors.New("too many failed login attempts")
+	ErrInvalidMFAChallenge      = errors.New("invalid or expired MFA challenge")
+	ErrInvalidMFACode           = errors.New("invalid MFA code")
+	ErrMFANotEnrolled           = errors.New("MFA is not set up")
+	ErrMFAAlreadyEnabled        = errors.New("MFA is already enabled")
+	ErrMFARequired              = errors.New("MFA is required for this role")
+)
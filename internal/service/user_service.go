@@ -1,38 +1,223 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/beingaloksharma/book-backend/internal/identity"
 	"github.com/beingaloksharma/book-backend/internal/model"
 	"github.com/beingaloksharma/book-backend/internal/repository"
+	"gorm.io/gorm"
 )
 
 type UserService struct {
-	Repo repository.UserRepositoryInterface
+	Repo      repository.UserRepositoryInterface
+	RoleRepo  repository.RoleRepositoryInterface
+	Sessions  repository.RefreshTokenRepositoryInterface
+	TxManager repository.TransactionManagerInterface
 }
 
-func NewUserService(repo repository.UserRepositoryInterface) *UserService {
-	return &UserService{Repo: repo}
+func NewUserService(repo repository.UserRepositoryInterface, roleRepo repository.RoleRepositoryInterface, sessions repository.RefreshTokenRepositoryInterface, txManager repository.TransactionManagerInterface) *UserService {
+	return &UserService{
+		Repo:      repo,
+		RoleRepo:  roleRepo,
+		Sessions:  sessions,
+		TxManager: txManager,
+	}
 }
 
-func (s *UserService) GetProfile(userID uint) (*model.User, error) {
-	return s.Repo.FindByID(userID)
+func (s *UserService) GetProfile(ctx context.Context, userID uint) (*model.User, error) {
+	user, err := s.Repo.FindByID(ctx, userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrUserNotFound
+	}
+	return user, err
 }
 
-func (s *UserService) AddAddress(userID uint, street, city, state, zip, country string) error {
+// AddAddress saves a new address; a user's first address becomes their default
+// shipping and billing address.
+func (s *UserService) AddAddress(ctx context.Context, userID uint, street, city, state, zip, country string) error {
+	existing, err := s.Repo.GetAddresses(ctx, userID)
+	if err != nil {
+		return err
+	}
+
 	address := &model.Address{
-		UserID:  userID,
-		Street:  street,
-		City:    city,
-		State:   state,
-		ZipCode: zip,
-		Country: country,
+		UserID:            userID,
+		Street:            street,
+		City:              city,
+		State:             state,
+		ZipCode:           zip,
+		Country:           country,
+		IsDefaultShipping: len(existing) == 0,
+		IsDefaultBilling:  len(existing) == 0,
+	}
+	return s.Repo.AddAddress(ctx, address)
+}
+
+func (s *UserService) GetAddresses(ctx context.Context, userID uint) ([]model.Address, error) {
+	return s.Repo.GetAddresses(ctx, userID)
+}
+
+// UpdateAddress replaces the fields and default flags of one of the user's
+// addresses. Orders keep their own snapshot, so history is unaffected.
+func (s *UserService) UpdateAddress(ctx context.Context, userID, addressID uint, changes model.Address) (*model.Address, error) {
+	address, err := s.findAddress(ctx, userID, addressID)
+	if err != nil {
+		return nil, err
+	}
+
+	address.Street = changes.Street
+	address.City = changes.City
+	address.State = changes.State
+	address.ZipCode = changes.ZipCode
+	address.Country = changes.Country
+	address.IsDefaultShipping = changes.IsDefaultShipping
+	address.IsDefaultBilling = changes.IsDefaultBilling
+
+	if err := s.Repo.UpdateAddress(ctx, address); err != nil {
+		return nil, err
+	}
+	return address, nil
+}
+
+func (s *UserService) DeleteAddress(ctx context.Context, userID, addressID uint) error {
+	err := s.Repo.DeleteAddress(ctx, userID, addressID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrAddressNotFound
+	}
+	return err
+}
+
+// findAddress loads an address only if it belongs to userID.
+func (s *UserService) findAddress(ctx context.Context, userID, addressID uint) (*model.Address, error) {
+	address, err := s.Repo.FindAddress(ctx, userID, addressID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrAddressNotFound
+	}
+	return address, err
+}
+
+func (s *UserService) GetAllUsers(ctx context.Context) ([]model.User, error) {
+	return s.Repo.FindAllUsers(ctx)
+}
+
+// ChangeRole moves a user to a new role on behalf of actor and records who did
+// it. Demoting the only remaining admin is refused so the store cannot lock
+// itself out. The user's sessions end, since their access tokens still carry
+// the old role.
+//
+// An actor without roles:manage may only hand out and take away roles whose
+// permissions its own role has, and never ADMIN or roles:manage; otherwise
+// ErrRoleEscalation is returned. Roles:manage holders, who could grant
+// themselves any permission anyway, are not limited.
+func (s *UserService) ChangeRole(ctx context.Context, userID uint, role model.Role, actor identity.Principal, note string) (*model.User, error) {
+	definition, err := s.RoleRepo.FindByName(ctx, role)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidRole, role)
+		}
+		return nil, err
+	}
+
+	user, err := s.findUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Role == role {
+		return user, nil
+	}
+
+	if err := s.checkCanAssign(ctx, actor, definition, user.Role); err != nil {
+		return nil, err
 	}
-	return s.Repo.AddAddress(address)
+
+	history := &model.UserRoleHistory{ChangedBy: actor.UserID, Note: note}
+	err = s.TxManager.WithinTransaction(ctx, func(ctx context.Context) error {
+		if user.Role == model.RoleAdmin {
+			if err := ensureAnotherAdmin(ctx, s.Repo); err != nil {
+				return err
+			}
+		}
+		return s.Repo.UpdateRole(ctx, user.ID, user.Role, role, history)
+	})
+	if err != nil {
+		if errors.Is(err, repository.ErrRoleConflict) {
+			return nil, ErrRoleConflict
+		}
+		return nil, err
+	}
+	if err := s.Sessions.RevokeAllForUser(ctx, user.ID); err != nil {
+		return nil, err
+	}
+
+	user.Role = role
+	return user, nil
 }
 
-func (s *UserService) GetAddresses(userID uint) ([]model.Address, error) {
-	return s.Repo.GetAddresses(userID)
+// checkCanAssign makes sure actor's role covers both the role being given and
+// the role being taken away, so it cannot raise anyone, itself included, above
+// itself, nor demote someone who outranks it. Holding roles:manage covers every
+// role.
+func (s *UserService) checkCanAssign(ctx context.Context, actor identity.Principal, to *model.RoleDefinition, from model.Role) error {
+	own, err := s.RoleRepo.FindByName(ctx, actor.Role)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrRoleEscalation
+		}
+		return err
+	}
+	if own.Grants(model.PermissionRolesManage) {
+		return nil
+	}
+	current, err := s.RoleRepo.FindByName(ctx, from)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	for _, role := range []*model.RoleDefinition{to, current} {
+		if role == nil {
+			continue
+		}
+		if role.Name == model.RoleAdmin || role.Grants(model.PermissionRolesManage) {
+			return fmt.Errorf("%w: %s needs %s", ErrRoleEscalation, role.Name, model.PermissionRolesManage)
+		}
+		for _, p := range role.Permissions {
+			if !own.Grants(p.Permission) {
+				return fmt.Errorf("%w: %s grants %s", ErrRoleEscalation, role.Name, p.Permission)
+			}
+		}
+	}
+	return nil
 }
 
-func (s *UserService) GetAllUsers() ([]model.User, error) {
-	return s.Repo.FindAllUsers()
+// ensureAnotherAdmin returns ErrLastAdmin unless there is more than one
+// administrator. It locks the administrators' rows, so call it in the same
+// transaction as the change that removes one: a concurrent removal then waits
+// for it and counts again.
+func ensureAnotherAdmin(ctx context.Context, users repository.UserRepositoryInterface) error {
+	admins, err := users.CountByRoleForUpdate(ctx, model.RoleAdmin)
+	if err != nil {
+		return err
+	}
+	if admins <= 1 {
+		return ErrLastAdmin
+	}
+	return nil
+}
+
+func (s *UserService) GetRoleHistory(ctx context.Context, userID uint) ([]model.UserRoleHistory, error) {
+	if _, err := s.findUser(ctx, userID); err != nil {
+		return nil, err
+	}
+	return s.Repo.FindRoleHistory(ctx, userID)
+}
+
+func (s *UserService) findUser(ctx context.Context, userID uint) (*model.User, error) {
+	user, err := s.Repo.FindByID(ctx, userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrUserNotFound
+	}
+	return user, err
 }
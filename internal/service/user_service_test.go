@@ -1,24 +1,28 @@
 package service_test
 
 import (
+	"context"
 	"errors"
 	"testing"
 
+	"github.com/beingaloksharma/book-backend/internal/identity"
 	"github.com/beingaloksharma/book-backend/internal/model"
+	"github.com/beingaloksharma/book-backend/internal/repository"
 	"github.com/beingaloksharma/book-backend/internal/repository/mocks"
 	"github.com/beingaloksharma/book-backend/internal/service"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
 )
 
 func TestGetProfile(t *testing.T) {
 	mockRepo := new(mocks.MockUserRepository)
-	userService := service.NewUserService(mockRepo)
+	userService := service.NewUserService(mockRepo, new(mocks.MockRoleRepository), new(mocks.MockRefreshTokenRepository), new(mocks.MockTransactionManager))
 
 	user := &model.User{Name: "John"}
-	mockRepo.On("FindByID", uint(1)).Return(user, nil)
+	mockRepo.On("FindByID", mock.Anything, uint(1)).Return(user, nil)
 
-	result, err := userService.GetProfile(1)
+	result, err := userService.GetProfile(context.Background(), 1)
 	assert.NoError(t, err)
 	assert.Equal(t, "John", result.Name)
 
@@ -27,22 +31,40 @@ func TestGetProfile(t *testing.T) {
 
 func TestGetProfile_Error(t *testing.T) {
 	mockRepo := new(mocks.MockUserRepository)
-	userService := service.NewUserService(mockRepo)
+	userService := service.NewUserService(mockRepo, new(mocks.MockRoleRepository), new(mocks.MockRefreshTokenRepository), new(mocks.MockTransactionManager))
 
-	mockRepo.On("FindByID", uint(1)).Return(nil, errors.New("db error"))
+	mockRepo.On("FindByID", mock.Anything, uint(1)).Return(nil, errors.New("db error"))
 
-	_, err := userService.GetProfile(1)
+	_, err := userService.GetProfile(context.Background(), 1)
 	assert.Error(t, err)
 	assert.Equal(t, "db error", err.Error())
+
+	mockRepo.On("FindByID", mock.Anything, uint(2)).Return(nil, gorm.ErrRecordNotFound)
+
+	_, err = userService.GetProfile(context.Background(), 2)
+	assert.ErrorIs(t, err, service.ErrUserNotFound)
 }
 
 func TestAddAddress(t *testing.T) {
 	mockRepo := new(mocks.MockUserRepository)
-	userService := service.NewUserService(mockRepo)
+	userService := service.NewUserService(mockRepo, new(mocks.MockRoleRepository), new(mocks.MockRefreshTokenRepository), new(mocks.MockTransactionManager))
+
+	// Case 1: First address becomes the default
+	mockRepo.On("GetAddresses", mock.Anything, uint(1)).Return([]model.Address{}, nil).Once()
+	mockRepo.On("AddAddress", mock.Anything, mock.MatchedBy(func(a *model.Address) bool {
+		return a.IsDefaultShipping && a.IsDefaultBilling
+	})).Return(nil).Once()
+
+	err := userService.AddAddress(context.Background(), 1, "Street", "City", "State", "Zip", "Country")
+	assert.NoError(t, err)
 
-	mockRepo.On("AddAddress", mock.AnythingOfType("*model.Address")).Return(nil)
+	// Case 2: Later addresses leave the default alone
+	mockRepo.On("GetAddresses", mock.Anything, uint(1)).Return([]model.Address{{UserID: 1, IsDefaultShipping: true}}, nil).Once()
+	mockRepo.On("AddAddress", mock.Anything, mock.MatchedBy(func(a *model.Address) bool {
+		return !a.IsDefaultShipping && !a.IsDefaultBilling
+	})).Return(nil).Once()
 
-	err := userService.AddAddress(1, "Street", "City", "State", "Zip", "Country")
+	err = userService.AddAddress(context.Background(), 1, "Street", "City", "State", "Zip", "Country")
 	assert.NoError(t, err)
 
 	mockRepo.AssertExpectations(t)
@@ -50,38 +72,252 @@ func TestAddAddress(t *testing.T) {
 
 func TestAddAddress_Error(t *testing.T) {
 	mockRepo := new(mocks.MockUserRepository)
-	userService := service.NewUserService(mockRepo)
+	userService := service.NewUserService(mockRepo, new(mocks.MockRoleRepository), new(mocks.MockRefreshTokenRepository), new(mocks.MockTransactionManager))
 
-	mockRepo.On("AddAddress", mock.AnythingOfType("*model.Address")).Return(errors.New("db error"))
+	mockRepo.On("GetAddresses", mock.Anything, uint(1)).Return([]model.Address{}, nil)
+	mockRepo.On("AddAddress", mock.Anything, mock.AnythingOfType("*model.Address")).Return(errors.New("db error"))
 
-	err := userService.AddAddress(1, "Street", "City", "State", "Zip", "Country")
+	err := userService.AddAddress(context.Background(), 1, "Street", "City", "State", "Zip", "Country")
 	assert.Error(t, err)
 }
 
 func TestGetAddresses(t *testing.T) {
 	mockRepo := new(mocks.MockUserRepository)
-	userService := service.NewUserService(mockRepo)
+	userService := service.NewUserService(mockRepo, new(mocks.MockRoleRepository), new(mocks.MockRefreshTokenRepository), new(mocks.MockTransactionManager))
 
 	addresses := []model.Address{{City: "City"}}
-	mockRepo.On("GetAddresses", uint(1)).Return(addresses, nil)
+	mockRepo.On("GetAddresses", mock.Anything, uint(1)).Return(addresses, nil)
 
-	result, err := userService.GetAddresses(1)
+	result, err := userService.GetAddresses(context.Background(), 1)
 	assert.NoError(t, err)
 	assert.Equal(t, 1, len(result))
 
 	mockRepo.AssertExpectations(t)
 }
 
+func TestUpdateAddress(t *testing.T) {
+	mockRepo := new(mocks.MockUserRepository)
+	userService := service.NewUserService(mockRepo, new(mocks.MockRoleRepository), new(mocks.MockRefreshTokenRepository), new(mocks.MockTransactionManager))
+
+	changes := model.Address{Street: "New St", City: "New City", State: "NS", ZipCode: "00001", Country: "USA", IsDefaultBilling: true}
+
+	// Case 1: Success
+	existing := &model.Address{Model: gorm.Model{ID: 5}, UserID: 1, Street: "Old St", IsDefaultShipping: true}
+	mockRepo.On("FindAddress", mock.Anything, uint(1), uint(5)).Return(existing, nil).Once()
+	mockRepo.On("UpdateAddress", mock.Anything, mock.MatchedBy(func(a *model.Address) bool {
+		return a.ID == 5 && a.UserID == 1 && a.Street == "New St" && !a.IsDefaultShipping && a.IsDefaultBilling
+	})).Return(nil).Once()
+
+	address, err := userService.UpdateAddress(context.Background(), 1, 5, changes)
+	assert.NoError(t, err)
+	assert.Equal(t, "New City", address.City)
+
+	// Case 2: Address belongs to someone else
+	mockRepo.On("FindAddress", mock.Anything, uint(1), uint(6)).Return(nil, gorm.ErrRecordNotFound).Once()
+
+	_, err = userService.UpdateAddress(context.Background(), 1, 6, changes)
+	assert.ErrorIs(t, err, service.ErrAddressNotFound)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestDeleteAddress(t *testing.T) {
+	mockRepo := new(mocks.MockUserRepository)
+	userService := service.NewUserService(mockRepo, new(mocks.MockRoleRepository), new(mocks.MockRefreshTokenRepository), new(mocks.MockTransactionManager))
+
+	// Case 1: Success
+	mockRepo.On("DeleteAddress", mock.Anything, uint(1), uint(5)).Return(nil).Once()
+	assert.NoError(t, userService.DeleteAddress(context.Background(), 1, 5))
+
+	// Case 2: Address belongs to someone else
+	mockRepo.On("DeleteAddress", mock.Anything, uint(1), uint(6)).Return(gorm.ErrRecordNotFound).Once()
+	assert.ErrorIs(t, userService.DeleteAddress(context.Background(), 1, 6), service.ErrAddressNotFound)
+
+	mockRepo.AssertExpectations(t)
+}
+
 func TestGetAllUsers(t *testing.T) {
 	mockRepo := new(mocks.MockUserRepository)
-	userService := service.NewUserService(mockRepo)
+	userService := service.NewUserService(mockRepo, new(mocks.MockRoleRepository), new(mocks.MockRefreshTokenRepository), new(mocks.MockTransactionManager))
 
 	users := []model.User{{Name: "John"}}
-	mockRepo.On("FindAllUsers").Return(users, nil)
+	mockRepo.On("FindAllUsers", mock.Anything).Return(users, nil)
 
-	result, err := userService.GetAllUsers()
+	result, err := userService.GetAllUsers(context.Background())
 	assert.NoError(t, err)
 	assert.Equal(t, 1, len(result))
 
 	mockRepo.AssertExpectations(t)
 }
+
+func TestChangeRole(t *testing.T) {
+	mockRepo := new(mocks.MockUserRepository)
+	mockRoleRepo := new(mocks.MockRoleRepository)
+	mockSessions := new(mocks.MockRefreshTokenRepository)
+	mockTxManager := new(mocks.MockTransactionManager)
+	userService := service.NewUserService(mockRepo, mockRoleRepo, mockSessions, mockTxManager)
+	mockTxManager.On("WithinTransaction", mock.Anything).Return(nil)
+	admin := identity.Principal{UserID: 9, Role: model.RoleAdmin}
+	adminRole := &model.RoleDefinition{Name: model.RoleAdmin}
+	for _, p := range model.AllPermissions {
+		adminRole.Permissions = append(adminRole.Permissions, model.RolePermission{Role: model.RoleAdmin, Permission: p})
+	}
+	mockRoleRepo.On("FindByName", mock.Anything, model.RoleAdmin).Return(adminRole, nil)
+	mockRoleRepo.On("FindByName", mock.Anything, model.RoleUser).Return(&model.RoleDefinition{Name: model.RoleUser}, nil)
+	mockRoleRepo.On("FindByName", mock.Anything, model.Role("OWNER")).Return(nil, gorm.ErrRecordNotFound)
+
+	// Case 1: Promote a user
+	mockRepo.On("FindByID", mock.Anything, uint(2)).Return(&model.User{Model: gorm.Model{ID: 2}, Role: model.RoleUser}, nil).Once()
+	mockRepo.On("UpdateRole", mock.Anything, uint(2), model.RoleUser, model.RoleAdmin, mock.MatchedBy(func(h *model.UserRoleHistory) bool {
+		return h.ChangedBy == 9 && h.Note == "promoted"
+	})).Return(nil).Once()
+	mockSessions.On("RevokeAllForUser", mock.Anything, uint(2)).Return(nil).Once()
+
+	user, err := userService.ChangeRole(context.Background(), 2, model.RoleAdmin, admin, "promoted")
+	assert.NoError(t, err)
+	assert.Equal(t, model.RoleAdmin, user.Role)
+
+	// Case 2: Role not defined
+	_, err = userService.ChangeRole(context.Background(), 2, model.Role("OWNER"), admin, "")
+	assert.ErrorIs(t, err, service.ErrInvalidRole)
+
+	// Case 3: Demoting the last admin
+	mockRepo.On("FindByID", mock.Anything, uint(9)).Return(&model.User{Model: gorm.Model{ID: 9}, Role: model.RoleAdmin}, nil).Once()
+	mockRepo.On("CountByRoleForUpdate", mock.Anything, model.RoleAdmin).Return(int64(1), nil).Once()
+
+	_, err = userService.ChangeRole(context.Background(), 9, model.RoleUser, admin, "")
+	assert.ErrorIs(t, err, service.ErrLastAdmin)
+
+	// Case 4: Demoting one of several admins
+	mockRepo.On("FindByID", mock.Anything, uint(9)).Return(&model.User{Model: gorm.Model{ID: 9}, Role: model.RoleAdmin}, nil).Once()
+	mockRepo.On("CountByRoleForUpdate", mock.Anything, model.RoleAdmin).Return(int64(2), nil).Once()
+	mockRepo.On("UpdateRole", mock.Anything, uint(9), model.RoleAdmin, model.RoleUser, mock.Anything).Return(nil).Once()
+	mockSessions.On("RevokeAllForUser", mock.Anything, uint(9)).Return(nil).Once() // the demoted admin's tokens stop working
+
+	user, err = userService.ChangeRole(context.Background(), 9, model.RoleUser, identity.Principal{UserID: 1, Role: model.RoleAdmin}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, model.RoleUser, user.Role)
+
+	// Case 5: Role already set, nothing recorded
+	mockRepo.On("FindByID", mock.Anything, uint(3)).Return(&model.User{Model: gorm.Model{ID: 3}, Role: model.RoleUser}, nil).Once()
+
+	_, err = userService.ChangeRole(context.Background(), 3, model.RoleUser, admin, "")
+	assert.NoError(t, err)
+
+	// Case 6: Not found
+	mockRepo.On("FindByID", mock.Anything, uint(4)).Return(nil, gorm.ErrRecordNotFound).Once()
+
+	_, err = userService.ChangeRole(context.Background(), 4, model.RoleAdmin, admin, "")
+	assert.ErrorIs(t, err, service.ErrUserNotFound)
+
+	// Case 7: Concurrent change
+	mockRepo.On("FindByID", mock.Anything, uint(5)).Return(&model.User{Model: gorm.Model{ID: 5}, Role: model.RoleUser}, nil).Once()
+	mockRepo.On("UpdateRole", mock.Anything, uint(5), model.RoleUser, model.RoleAdmin, mock.Anything).Return(repository.ErrRoleConflict).Once()
+
+	_, err = userService.ChangeRole(context.Background(), 5, model.RoleAdmin, admin, "")
+	assert.ErrorIs(t, err, service.ErrRoleConflict)
+
+	// Case 8: Custom role defined by an admin
+	mockRoleRepo.On("FindByName", mock.Anything, model.Role("SUPPORT_AGENT")).Return(&model.RoleDefinition{Name: "SUPPORT_AGENT"}, nil).Once()
+	mockRepo.On("FindByID", mock.Anything, uint(6)).Return(&model.User{Model: gorm.Model{ID: 6}, Role: model.RoleUser}, nil).Once()
+	mockRepo.On("UpdateRole", mock.Anything, uint(6), model.RoleUser, model.Role("SUPPORT_AGENT"), mock.Anything).Return(nil).Once()
+	mockSessions.On("RevokeAllForUser", mock.Anything, uint(6)).Return(nil).Once()
+
+	user, err = userService.ChangeRole(context.Background(), 6, "SUPPORT_AGENT", admin, "")
+	assert.NoError(t, err)
+	assert.Equal(t, model.Role("SUPPORT_AGENT"), user.Role)
+
+	mockRepo.AssertExpectations(t)
+	mockSessions.AssertExpectations(t)
+}
+
+func TestChangeRole_Escalation(t *testing.T) {
+	mockRepo := new(mocks.MockUserRepository)
+	mockRoleRepo := new(mocks.MockRoleRepository)
+	mockSessions := new(mocks.MockRefreshTokenRepository)
+	mockTxManager := new(mocks.MockTransactionManager)
+	userService := service.NewUserService(mockRepo, mockRoleRepo, mockSessions, mockTxManager)
+	mockTxManager.On("WithinTransaction", mock.Anything).Return(nil)
+
+	grants := func(name model.Role, permissions ...model.Permission) *model.RoleDefinition {
+		role := &model.RoleDefinition{Name: name}
+		for _, p := range permissions {
+			role.Permissions = append(role.Permissions, model.RolePermission{Role: name, Permission: p})
+		}
+		return role
+	}
+	mockRoleRepo.On("FindByName", mock.Anything, model.RoleAdmin).Return(grants(model.RoleAdmin, model.AllPermissions...), nil)
+	mockRoleRepo.On("FindByName", mock.Anything, model.RoleUser).Return(grants(model.RoleUser), nil)
+	mockRoleRepo.On("FindByName", mock.Anything, model.Role("SUPPORT")).Return(grants("SUPPORT", model.PermissionAdminAccess, model.PermissionUsersRead, model.PermissionUsersManage), nil)
+	mockRoleRepo.On("FindByName", mock.Anything, model.Role("HELPDESK")).Return(grants("HELPDESK", model.PermissionUsersRead), nil)
+	mockRoleRepo.On("FindByName", mock.Anything, model.Role("CATALOG")).Return(grants("CATALOG", model.PermissionBooksWrite), nil)
+	mockRoleRepo.On("FindByName", mock.Anything, model.Role("ROLE_ADMIN")).Return(grants("ROLE_ADMIN", model.PermissionRolesManage), nil)
+	mockRoleRepo.On("FindByName", mock.Anything, model.Role("MANAGER")).Return(grants("MANAGER", model.PermissionAdminAccess, model.PermissionUsersManage, model.PermissionRolesManage), nil)
+	mockRepo.On("FindByID", mock.Anything, uint(7)).Return(&model.User{Model: gorm.Model{ID: 7}, Role: "SUPPORT"}, nil)
+	mockRepo.On("FindByID", mock.Anything, uint(2)).Return(&model.User{Model: gorm.Model{ID: 2}, Role: model.RoleUser}, nil)
+	mockRepo.On("FindByID", mock.Anything, uint(9)).Return(&model.User{Model: gorm.Model{ID: 9}, Role: model.RoleAdmin}, nil)
+	support := identity.Principal{UserID: 7, Role: "SUPPORT"}
+
+	// Case 1: users:manage alone cannot hand out ADMIN, to itself or anyone else
+	for _, userID := range []uint{7, 2} {
+		_, err := userService.ChangeRole(context.Background(), userID, model.RoleAdmin, support, "")
+		assert.ErrorIs(t, err, service.ErrRoleEscalation)
+	}
+
+	// Case 2: Nor a role that can manage roles
+	_, err := userService.ChangeRole(context.Background(), 2, "ROLE_ADMIN", support, "")
+	assert.ErrorIs(t, err, service.ErrRoleEscalation)
+
+	// Case 3: Nor a role with a permission it does not have
+	_, err = userService.ChangeRole(context.Background(), 2, "CATALOG", support, "")
+	assert.ErrorIs(t, err, service.ErrRoleEscalation)
+
+	// Case 4: Nor demote an admin
+	_, err = userService.ChangeRole(context.Background(), 9, model.RoleUser, support, "")
+	assert.ErrorIs(t, err, service.ErrRoleEscalation)
+
+	// Case 5: A role within its own permissions is fine
+	mockRepo.On("UpdateRole", mock.Anything, uint(2), model.RoleUser, model.Role("HELPDESK"), mock.MatchedBy(func(h *model.UserRoleHistory) bool {
+		return h.ChangedBy == 7
+	})).Return(nil).Once()
+	mockSessions.On("RevokeAllForUser", mock.Anything, uint(2)).Return(nil).Once()
+
+	user, err := userService.ChangeRole(context.Background(), 2, "HELPDESK", support, "")
+	assert.NoError(t, err)
+	assert.Equal(t, model.Role("HELPDESK"), user.Role)
+
+	// Case 6: A role holding roles:manage can hand out ADMIN
+	mockRepo.On("FindByID", mock.Anything, uint(3)).Return(&model.User{Model: gorm.Model{ID: 3}, Role: model.RoleUser}, nil).Once()
+	mockRepo.On("UpdateRole", mock.Anything, uint(3), model.RoleUser, model.RoleAdmin, mock.Anything).Return(nil).Once()
+	mockSessions.On("RevokeAllForUser", mock.Anything, uint(3)).Return(nil).Once()
+
+	_, err = userService.ChangeRole(context.Background(), 3, model.RoleAdmin, identity.Principal{UserID: 8, Role: "MANAGER"}, "")
+	assert.NoError(t, err)
+
+	// Case 7: The ADMIN name alone is not enough once roles:manage is taken away
+	strippedRoles := new(mocks.MockRoleRepository)
+	strippedRoles.On("FindByName", mock.Anything, model.RoleAdmin).Return(grants(model.RoleAdmin, model.PermissionAdminAccess, model.PermissionUsersManage), nil)
+	strippedRoles.On("FindByName", mock.Anything, model.Role("CATALOG")).Return(grants("CATALOG", model.PermissionBooksWrite), nil)
+	strippedRoles.On("FindByName", mock.Anything, model.RoleUser).Return(grants(model.RoleUser), nil)
+	mockRepo.On("FindByID", mock.Anything, uint(4)).Return(&model.User{Model: gorm.Model{ID: 4}, Role: model.RoleUser}, nil).Once()
+
+	_, err = service.NewUserService(mockRepo, strippedRoles, mockSessions, mockTxManager).ChangeRole(context.Background(), 4, "CATALOG", identity.Principal{UserID: 9, Role: model.RoleAdmin}, "")
+	assert.ErrorIs(t, err, service.ErrRoleEscalation)
+
+	mockRepo.AssertExpectations(t)
+	mockSessions.AssertExpectations(t)
+}
+
+func TestGetRoleHistory(t *testing.T) {
+	mockRepo := new(mocks.MockUserRepository)
+	userService := service.NewUserService(mockRepo, new(mocks.MockRoleRepository), new(mocks.MockRefreshTokenRepository), new(mocks.MockTransactionManager))
+
+	mockRepo.On("FindByID", mock.Anything, uint(2)).Return(&model.User{Model: gorm.Model{ID: 2}}, nil).Once()
+	mockRepo.On("FindRoleHistory", mock.Anything, uint(2)).Return([]model.UserRoleHistory{{UserID: 2, ToRole: model.RoleAdmin}}, nil).Once()
+
+	history, err := userService.GetRoleHistory(context.Background(), 2)
+	assert.NoError(t, err)
+	assert.Len(t, history, 1)
+
+	mockRepo.AssertExpectations(t)
+}
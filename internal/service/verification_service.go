@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/beingaloksharma/book-backend/internal/model"
+	"github.com/beingaloksharma/book-backend/internal/repository"
+	"github.com/beingaloksharma/book-backend/utils/mail"
+	"github.com/beingaloksharma/book-backend/utils/token"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+// DefaultVerificationTTL is how long an email verification link stays valid.
+const DefaultVerificationTTL = 48 * time.Hour
+
+// VerificationPolicy is what an unverified account is kept from doing, set by
+// email_verification.require.
+type VerificationPolicy string
+
+const (
+	VerificationPolicyNone   VerificationPolicy = "none"
+	VerificationPolicyOrders VerificationPolicy = "orders" // Block placing orders
+	VerificationPolicyLogin  VerificationPolicy = "login"  // Block logging in, and so everything else
+)
+
+type VerificationService struct {
+	UserRepo  repository.UserRepositoryInterface
+	TokenRepo repository.OneTimeTokenRepositoryInterface
+	Mailer    mail.Sender
+}
+
+func NewVerificationService(userRepo repository.UserRepositoryInterface, tokenRepo repository.OneTimeTokenRepositoryInterface, mailer mail.Sender) *VerificationService {
+	return &VerificationService{
+		UserRepo:  userRepo,
+		TokenRepo: tokenRepo,
+		Mailer:    mailer,
+	}
+}
+
+// SendVerification emails the user a link confirming they own their address.
+// Earlier links stop working.
+func (s *VerificationService) SendVerification(ctx context.Context, user *model.User) error {
+	plain, hash, err := token.NewOpaqueToken()
+	if err != nil {
+		return err
+	}
+	ttl := verificationTTL()
+	record := &model.OneTimeToken{
+		UserID:    user.ID,
+		Purpose:   model.TokenPurposeEmailVerification,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := s.TokenRepo.Issue(ctx, record); err != nil {
+		return err
+	}
+
+	return s.Mailer.Send(mail.Message{
+		To:      user.Email,
+		Subject: "Confirm your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm your email address by opening this link within %s:\n\n%s%s\n",
+			user.Name, ttl, viper.GetString("email_verification.url"), plain),
+	})
+}
+
+// VerifyEmail marks the account of a verification token as verified.
+func (s *VerificationService) VerifyEmail(ctx context.Context, verifyToken string) error {
+	record, err := s.TokenRepo.FindByHash(ctx, model.TokenPurposeEmailVerification, token.HashOpaqueToken(verifyToken))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrInvalidVerificationToken
+	}
+	if err != nil {
+		return err
+	}
+	if record.UsedAt != nil || time.Now().After(record.ExpiresAt) {
+		return ErrInvalidVerificationToken
+	}
+
+	if err := s.TokenRepo.Consume(ctx, record); err != nil {
+		if errors.Is(err, repository.ErrTokenAlreadyUsed) {
+			return ErrInvalidVerificationToken
+		}
+		return err
+	}
+	return s.UserRepo.MarkVerified(ctx, record.UserID)
+}
+
+// ResendVerification sends a fresh link to an unverified account. Unknown and
+// already verified addresses succeed silently so callers cannot probe for
+// accounts; the link is issued and sent in the background so that unverified
+// addresses do not take measurably longer to answer either. Failures there are
+// only logged.
+func (s *VerificationService) ResendVerification(ctx context.Context, email string) error {
+	user, err := s.UserRepo.FindByEmail(ctx, email)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if user.IsVerified() {
+		return nil
+	}
+
+	go func() {
+		if err := s.SendVerification(context.WithoutCancel(ctx), user); err != nil {
+			logrus.WithError(err).WithField("user_id", user.ID).Warn("Failed to send verification email")
+		}
+	}()
+	return nil
+}
+
+// verificationRequired reports whether the configured policy keeps unverified
+// accounts from the given action.
+func verificationRequired(action VerificationPolicy) bool {
+	policy := VerificationPolicy(viper.GetString("email_verification.require"))
+	switch action {
+	case VerificationPolicyOrders:
+		return policy == VerificationPolicyOrders || policy == VerificationPolicyLogin
+	case VerificationPolicyLogin:
+		return policy == VerificationPolicyLogin
+	default:
+		return false
+	}
+}
+
+func verificationTTL() time.Duration {
+	if ttl := viper.GetDuration("email_verification.ttl"); ttl > 0 {
+		return ttl
+	}
+	return DefaultVerificationTTL
+}
@@ -0,0 +1,138 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/beingaloksharma/book-backend/internal/model"
+	"github.com/beingaloksharma/book-backend/internal/repository"
+	"github.com/beingaloksharma/book-backend/internal/repository/mocks"
+	"github.com/beingaloksharma/book-backend/internal/service"
+	servicemocks "github.com/beingaloksharma/book-backend/internal/service/mocks"
+	"github.com/beingaloksharma/book-backend/utils/mail"
+	"github.com/beingaloksharma/book-backend/utils/token"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+func TestSendVerification(t *testing.T) {
+	viper.Set("email_verification.url", "https://shop.example.com/verify?token=")
+	defer viper.Set("email_verification.url", "")
+
+	mockTokenRepo := new(mocks.MockOneTimeTokenRepository)
+	mockMailer := new(servicemocks.MockMailSender)
+	verificationService := service.NewVerificationService(new(mocks.MockUserRepository), mockTokenRepo, mockMailer)
+
+	user := &model.User{Model: gorm.Model{ID: 1}, Name: "John", Email: "john@example.com"}
+
+	var issued *model.OneTimeToken
+	mockTokenRepo.On("Issue", mock.Anything, mock.MatchedBy(func(r *model.OneTimeToken) bool {
+		issued = r
+		return r.UserID == 1 && r.Purpose == model.TokenPurposeEmailVerification && r.ExpiresAt.After(time.Now().Add(47*time.Hour))
+	})).Return(nil).Once()
+
+	var sent mail.Message
+	mockMailer.On("Send", mock.MatchedBy(func(m mail.Message) bool {
+		sent = m
+		return m.To == "john@example.com"
+	})).Return(nil).Once()
+
+	assert.NoError(t, verificationService.SendVerification(context.Background(), user))
+	i := strings.Index(sent.Body, "https://shop.example.com/verify?token=")
+	assert.GreaterOrEqual(t, i, 0)
+	plain := strings.Fields(sent.Body[i+len("https://shop.example.com/verify?token="):])[0]
+	assert.Equal(t, issued.TokenHash, token.HashOpaqueToken(plain))
+
+	mockTokenRepo.AssertExpectations(t)
+	mockMailer.AssertExpectations(t)
+}
+
+func TestVerifyEmail(t *testing.T) {
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockTokenRepo := new(mocks.MockOneTimeTokenRepository)
+	verificationService := service.NewVerificationService(mockUserRepo, mockTokenRepo, new(servicemocks.MockMailSender))
+
+	hash := token.HashOpaqueToken("verify-1")
+	purpose := model.TokenPurposeEmailVerification
+
+	// Case 1: Success marks the account verified
+	record := &model.OneTimeToken{Model: gorm.Model{ID: 3}, UserID: 1, Purpose: purpose, TokenHash: hash, ExpiresAt: time.Now().Add(time.Hour)}
+	mockTokenRepo.On("FindByHash", mock.Anything, purpose, hash).Return(record, nil).Once()
+	mockTokenRepo.On("Consume", mock.Anything, record).Return(nil).Once()
+	mockUserRepo.On("MarkVerified", mock.Anything, uint(1)).Return(nil).Once()
+
+	assert.NoError(t, verificationService.VerifyEmail(context.Background(), "verify-1"))
+
+	// Case 2: Unknown token
+	mockTokenRepo.On("FindByHash", mock.Anything, purpose, token.HashOpaqueToken("bogus")).Return(nil, gorm.ErrRecordNotFound).Once()
+
+	assert.ErrorIs(t, verificationService.VerifyEmail(context.Background(), "bogus"), service.ErrInvalidVerificationToken)
+
+	// Case 3: Expired
+	mockTokenRepo.On("FindByHash", mock.Anything, purpose, hash).Return(&model.OneTimeToken{UserID: 1, ExpiresAt: time.Now().Add(-time.Minute)}, nil).Once()
+
+	assert.ErrorIs(t, verificationService.VerifyEmail(context.Background(), "verify-1"), service.ErrInvalidVerificationToken)
+
+	// Case 4: Used concurrently by another request
+	racing := &model.OneTimeToken{Model: gorm.Model{ID: 4}, UserID: 1, ExpiresAt: time.Now().Add(time.Hour)}
+	mockTokenRepo.On("FindByHash", mock.Anything, purpose, hash).Return(racing, nil).Once()
+	mockTokenRepo.On("Consume", mock.Anything, racing).Return(repository.ErrTokenAlreadyUsed).Once()
+
+	assert.ErrorIs(t, verificationService.VerifyEmail(context.Background(), "verify-1"), service.ErrInvalidVerificationToken)
+
+	mockUserRepo.AssertExpectations(t)
+	mockTokenRepo.AssertExpectations(t)
+}
+
+func TestResendVerification(t *testing.T) {
+	mockUserRepo := new(mocks.MockUserRepository)
+	mockTokenRepo := new(mocks.MockOneTimeTokenRepository)
+	mockMailer := new(servicemocks.MockMailSender)
+	verificationService := service.NewVerificationService(mockUserRepo, mockTokenRepo, mockMailer)
+
+	// Case 1: Unverified account gets a new link, without waiting for it to be
+	// delivered
+	user := &model.User{Model: gorm.Model{ID: 1}, Email: "john@example.com"}
+	mockUserRepo.On("FindByEmail", mock.Anything, "john@example.com").Return(user, nil).Once()
+	mockTokenRepo.On("Issue", mock.Anything, mock.AnythingOfType("*model.OneTimeToken")).Return(nil).Once()
+
+	release := make(chan struct{})
+	delivered := make(chan struct{})
+	mockMailer.On("Send", mock.Anything).Run(func(mock.Arguments) {
+		<-release
+		close(delivered)
+	}).Return(nil).Once()
+
+	assert.NoError(t, verificationService.ResendVerification(context.Background(), "john@example.com"))
+	close(release)
+	<-delivered
+
+	// Case 2: Already verified account is left alone
+	now := time.Now()
+	mockUserRepo.On("FindByEmail", mock.Anything, "jane@example.com").Return(&model.User{Email: "jane@example.com", VerifiedAt: &now}, nil).Once()
+
+	assert.NoError(t, verificationService.ResendVerification(context.Background(), "jane@example.com"))
+
+	// Case 3: Unknown email does nothing and reports success
+	mockUserRepo.On("FindByEmail", mock.Anything, "nobody@example.com").Return(nil, gorm.ErrRecordNotFound).Once()
+
+	assert.NoError(t, verificationService.ResendVerification(context.Background(), "nobody@example.com"))
+
+	// Case 4: Delivery failure is not reported either
+	failed := make(chan struct{})
+	mockUserRepo.On("FindByEmail", mock.Anything, "john@example.com").Return(user, nil).Once()
+	mockTokenRepo.On("Issue", mock.Anything, mock.Anything).Return(nil).Once()
+	mockMailer.On("Send", mock.Anything).Run(func(mock.Arguments) { close(failed) }).Return(errors.New("smtp down")).Once()
+
+	assert.NoError(t, verificationService.ResendVerification(context.Background(), "john@example.com"))
+	<-failed
+
+	mockUserRepo.AssertExpectations(t)
+	mockTokenRepo.AssertExpectations(t)
+	mockMailer.AssertExpectations(t)
+}
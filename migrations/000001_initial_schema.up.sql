@@ -0,0 +1,233 @@
+-- Schema as of the switch from AutoMigrate to versioned migrations. Every
+-- statement is IF NOT EXISTS: on a database the last AutoMigrate release set
+-- up, nothing here creates anything and the migration is only recorded.
+-- Tables that already exist are skipped, not altered, so a database from an
+-- older release must be brought up to date by that last release first; see
+-- "Upgrading from AutoMigrate" in the README.
+
+CREATE TABLE IF NOT EXISTS users (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    deleted_at TIMESTAMPTZ,
+    name TEXT,
+    email TEXT CONSTRAINT uni_users_email UNIQUE,
+    password TEXT,
+    role TEXT DEFAULT 'USER',
+    verified_at TIMESTAMPTZ,
+    mfa_secret TEXT,
+    mfa_enabled_at TIMESTAMPTZ,
+    mfa_last_step BIGINT
+);
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at);
+
+CREATE TABLE IF NOT EXISTS books (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    deleted_at TIMESTAMPTZ,
+    title TEXT,
+    author TEXT,
+    price DECIMAL,
+    stock BIGINT,
+    description TEXT,
+    search_vector TSVECTOR GENERATED ALWAYS AS (
+        setweight(to_tsvector('english', coalesce(title, '')), 'A') ||
+        setweight(to_tsvector('english', coalesce(author, '')), 'B') ||
+        setweight(to_tsvector('english', coalesce(description, '')), 'C')
+    ) STORED
+);
+CREATE INDEX IF NOT EXISTS idx_books_deleted_at ON books (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_books_search_vector ON books USING gin (search_vector);
+
+CREATE TABLE IF NOT EXISTS addresses (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    deleted_at TIMESTAMPTZ,
+    user_id BIGINT,
+    street TEXT,
+    city TEXT,
+    state TEXT,
+    zip_code TEXT,
+    country TEXT,
+    is_default_shipping BOOLEAN,
+    is_default_billing BOOLEAN
+);
+CREATE INDEX IF NOT EXISTS idx_addresses_deleted_at ON addresses (deleted_at);
+
+CREATE TABLE IF NOT EXISTS carts (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    deleted_at TIMESTAMPTZ,
+    user_id BIGINT CONSTRAINT uni_carts_user_id UNIQUE
+);
+CREATE INDEX IF NOT EXISTS idx_carts_deleted_at ON carts (deleted_at);
+
+CREATE TABLE IF NOT EXISTS cart_items (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    deleted_at TIMESTAMPTZ,
+    cart_id BIGINT CONSTRAINT fk_carts_items REFERENCES carts (id),
+    book_id BIGINT CONSTRAINT fk_cart_items_book REFERENCES books (id),
+    quantity BIGINT,
+    price_at_add DECIMAL
+);
+CREATE INDEX IF NOT EXISTS idx_cart_items_deleted_at ON cart_items (deleted_at);
+
+CREATE TABLE IF NOT EXISTS orders (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    deleted_at TIMESTAMPTZ,
+    user_id BIGINT,
+    address_id BIGINT,
+    shipping_street TEXT,
+    shipping_city TEXT,
+    shipping_state TEXT,
+    shipping_zip_code TEXT,
+    shipping_country TEXT,
+    amount DECIMAL,
+    status TEXT DEFAULT 'PENDING'
+);
+CREATE INDEX IF NOT EXISTS idx_orders_deleted_at ON orders (deleted_at);
+
+CREATE TABLE IF NOT EXISTS order_items (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    deleted_at TIMESTAMPTZ,
+    order_id BIGINT CONSTRAINT fk_orders_items REFERENCES orders (id),
+    book_id BIGINT CONSTRAINT fk_order_items_book REFERENCES books (id),
+    quantity BIGINT,
+    price DECIMAL
+);
+CREATE INDEX IF NOT EXISTS idx_order_items_deleted_at ON order_items (deleted_at);
+
+CREATE TABLE IF NOT EXISTS order_status_histories (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    deleted_at TIMESTAMPTZ,
+    order_id BIGINT,
+    from_status TEXT,
+    to_status TEXT,
+    changed_by BIGINT,
+    note TEXT
+);
+CREATE INDEX IF NOT EXISTS idx_order_status_histories_deleted_at ON order_status_histories (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_order_status_histories_order_id ON order_status_histories (order_id);
+
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    deleted_at TIMESTAMPTZ,
+    user_id BIGINT,
+    idempotency_key VARCHAR(255),
+    fingerprint TEXT,
+    response_code BIGINT,
+    response_body BYTEA,
+    expires_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_deleted_at ON idempotency_keys (deleted_at);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_idempotency_user_key ON idempotency_keys (user_id, idempotency_key);
+
+CREATE TABLE IF NOT EXISTS user_role_histories (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    deleted_at TIMESTAMPTZ,
+    user_id BIGINT,
+    from_role TEXT,
+    to_role TEXT,
+    changed_by BIGINT,
+    note TEXT
+);
+CREATE INDEX IF NOT EXISTS idx_user_role_histories_deleted_at ON user_role_histories (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_user_role_histories_user_id ON user_role_histories (user_id);
+
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    deleted_at TIMESTAMPTZ,
+    user_id BIGINT,
+    family_id VARCHAR(64),
+    token_hash VARCHAR(64),
+    expires_at TIMESTAMPTZ,
+    rotated_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_deleted_at ON refresh_tokens (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user_id ON refresh_tokens (user_id);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family_id ON refresh_tokens (family_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_refresh_tokens_token_hash ON refresh_tokens (token_hash);
+
+CREATE TABLE IF NOT EXISTS roles (
+    name VARCHAR(64) PRIMARY KEY,
+    description TEXT,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role VARCHAR(64) CONSTRAINT fk_roles_permissions REFERENCES roles (name) ON DELETE CASCADE,
+    permission VARCHAR(64),
+    PRIMARY KEY (role, permission)
+);
+
+CREATE TABLE IF NOT EXISTS one_time_tokens (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    deleted_at TIMESTAMPTZ,
+    user_id BIGINT,
+    purpose VARCHAR(32),
+    token_hash VARCHAR(64),
+    expires_at TIMESTAMPTZ,
+    used_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_one_time_tokens_deleted_at ON one_time_tokens (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_one_time_tokens_user_id ON one_time_tokens (user_id);
+CREATE INDEX IF NOT EXISTS idx_one_time_tokens_purpose ON one_time_tokens (purpose);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_one_time_tokens_token_hash ON one_time_tokens (token_hash);
+
+CREATE TABLE IF NOT EXISTS login_attempts (
+    subject VARCHAR(320) PRIMARY KEY,
+    failures BIGINT,
+    last_failure TIMESTAMPTZ,
+    locked_until TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS audit_events (
+    id BIGSERIAL PRIMARY KEY,
+    type VARCHAR(64),
+    user_id BIGINT,
+    actor_id BIGINT,
+    subject TEXT,
+    ip TEXT,
+    detail TEXT,
+    created_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_audit_events_type ON audit_events (type);
+CREATE INDEX IF NOT EXISTS idx_audit_events_user_id ON audit_events (user_id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_created_at ON audit_events (created_at);
+
+CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT,
+    code_hash VARCHAR(64),
+    used_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_mfa_recovery_codes_user_id ON mfa_recovery_codes (user_id);
+CREATE INDEX IF NOT EXISTS idx_mfa_recovery_codes_code_hash ON mfa_recovery_codes (code_hash);
+
+CREATE TABLE IF NOT EXISTS rate_limit_buckets (
+    subject VARCHAR(320) PRIMARY KEY,
+    tokens DECIMAL,
+    refilled_at TIMESTAMPTZ
+);
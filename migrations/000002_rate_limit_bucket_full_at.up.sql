@@ -0,0 +1,5 @@
+-- Record when each rate limit bucket will be full again, so that full buckets
+-- can be deleted. Existing buckets are treated as full since their last refill.
+ALTER TABLE rate_limit_buckets ADD COLUMN IF NOT EXISTS full_at TIMESTAMPTZ;
+UPDATE rate_limit_buckets SET full_at = refilled_at WHERE full_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_rate_limit_buckets_full_at ON rate_limit_buckets (full_at);
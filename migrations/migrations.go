@@ -0,0 +1,28 @@
+// Package migrations holds the versioned SQL schema changes applied by
+// `server migrate up`. They are embedded in the server binary.
+//
+// Every migration is written twice: once for Postgres in this directory and
+// once for SQLite in sqlite/, with the same version and name.
+package migrations
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed *.sql
+var Files embed.FS
+
+//go:embed sqlite/*.sql
+var sqliteFiles embed.FS
+
+// SQLiteFiles holds the migrations for database.driver sqlite.
+var SQLiteFiles, _ = fs.Sub(sqliteFiles, "sqlite")
+
+// ForDriver returns the migrations for a database.driver value.
+func ForDriver(driver string) fs.FS {
+	if driver == "sqlite" {
+		return SQLiteFiles
+	}
+	return Files
+}
@@ -0,0 +1,34 @@
+package migrations
+
+import (
+	"testing"
+
+	"github.com/beingaloksharma/book-backend/utils/migrate"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := migrate.Load(Files)
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+
+	for i, m := range migrations {
+		assert.Equal(t, int64(i+1), m.Version, "versions are numbered without gaps")
+		assert.NotEmpty(t, m.Down, "%06d_%s has no down migration", m.Version, m.Name)
+	}
+}
+
+func TestSQLiteMigrationsMatch(t *testing.T) {
+	postgres, err := migrate.Load(Files)
+	require.NoError(t, err)
+	sqlite, err := migrate.Load(SQLiteFiles)
+	require.NoError(t, err)
+
+	require.Len(t, sqlite, len(postgres), "every migration needs a SQLite version")
+	for i, m := range sqlite {
+		assert.Equal(t, postgres[i].Version, m.Version)
+		assert.Equal(t, postgres[i].Name, m.Name)
+		assert.NotEmpty(t, m.Down, "%06d_%s has no down migration", m.Version, m.Name)
+	}
+}
@@ -0,0 +1,18 @@
+DROP TABLE IF EXISTS rate_limit_buckets;
+DROP TABLE IF EXISTS mfa_recovery_codes;
+DROP TABLE IF EXISTS audit_events;
+DROP TABLE IF EXISTS login_attempts;
+DROP TABLE IF EXISTS one_time_tokens;
+DROP TABLE IF EXISTS role_permissions;
+DROP TABLE IF EXISTS roles;
+DROP TABLE IF EXISTS refresh_tokens;
+DROP TABLE IF EXISTS user_role_histories;
+DROP TABLE IF EXISTS idempotency_keys;
+DROP TABLE IF EXISTS order_status_histories;
+DROP TABLE IF EXISTS order_items;
+DROP TABLE IF EXISTS orders;
+DROP TABLE IF EXISTS cart_items;
+DROP TABLE IF EXISTS carts;
+DROP TABLE IF EXISTS addresses;
+DROP TABLE IF EXISTS books;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,224 @@
+-- SQLite version of ../000001_initial_schema.up.sql for local development and
+-- tests. Books have no search_vector; search falls back to matching words in
+-- the server.
+
+CREATE TABLE IF NOT EXISTS users (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at DATETIME,
+    updated_at DATETIME,
+    deleted_at DATETIME,
+    name TEXT,
+    email TEXT CONSTRAINT uni_users_email UNIQUE,
+    password TEXT,
+    role TEXT DEFAULT 'USER',
+    verified_at DATETIME,
+    mfa_secret TEXT,
+    mfa_enabled_at DATETIME,
+    mfa_last_step INTEGER
+);
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at);
+
+CREATE TABLE IF NOT EXISTS books (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at DATETIME,
+    updated_at DATETIME,
+    deleted_at DATETIME,
+    title TEXT,
+    author TEXT,
+    price REAL,
+    stock INTEGER,
+    description TEXT
+);
+CREATE INDEX IF NOT EXISTS idx_books_deleted_at ON books (deleted_at);
+
+CREATE TABLE IF NOT EXISTS addresses (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at DATETIME,
+    updated_at DATETIME,
+    deleted_at DATETIME,
+    user_id INTEGER,
+    street TEXT,
+    city TEXT,
+    state TEXT,
+    zip_code TEXT,
+    country TEXT,
+    is_default_shipping BOOLEAN,
+    is_default_billing BOOLEAN
+);
+CREATE INDEX IF NOT EXISTS idx_addresses_deleted_at ON addresses (deleted_at);
+
+CREATE TABLE IF NOT EXISTS carts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at DATETIME,
+    updated_at DATETIME,
+    deleted_at DATETIME,
+    user_id INTEGER CONSTRAINT uni_carts_user_id UNIQUE
+);
+CREATE INDEX IF NOT EXISTS idx_carts_deleted_at ON carts (deleted_at);
+
+CREATE TABLE IF NOT EXISTS cart_items (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at DATETIME,
+    updated_at DATETIME,
+    deleted_at DATETIME,
+    cart_id INTEGER CONSTRAINT fk_carts_items REFERENCES carts (id),
+    book_id INTEGER CONSTRAINT fk_cart_items_book REFERENCES books (id),
+    quantity INTEGER,
+    price_at_add REAL
+);
+CREATE INDEX IF NOT EXISTS idx_cart_items_deleted_at ON cart_items (deleted_at);
+
+CREATE TABLE IF NOT EXISTS orders (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at DATETIME,
+    updated_at DATETIME,
+    deleted_at DATETIME,
+    user_id INTEGER,
+    address_id INTEGER,
+    shipping_street TEXT,
+    shipping_city TEXT,
+    shipping_state TEXT,
+    shipping_zip_code TEXT,
+    shipping_country TEXT,
+    amount REAL,
+    status TEXT DEFAULT 'PENDING'
+);
+CREATE INDEX IF NOT EXISTS idx_orders_deleted_at ON orders (deleted_at);
+
+CREATE TABLE IF NOT EXISTS order_items (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at DATETIME,
+    updated_at DATETIME,
+    deleted_at DATETIME,
+    order_id INTEGER CONSTRAINT fk_orders_items REFERENCES orders (id),
+    book_id INTEGER CONSTRAINT fk_order_items_book REFERENCES books (id),
+    quantity INTEGER,
+    price REAL
+);
+CREATE INDEX IF NOT EXISTS idx_order_items_deleted_at ON order_items (deleted_at);
+
+CREATE TABLE IF NOT EXISTS order_status_histories (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at DATETIME,
+    updated_at DATETIME,
+    deleted_at DATETIME,
+    order_id INTEGER,
+    from_status TEXT,
+    to_status TEXT,
+    changed_by INTEGER,
+    note TEXT
+);
+CREATE INDEX IF NOT EXISTS idx_order_status_histories_deleted_at ON order_status_histories (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_order_status_histories_order_id ON order_status_histories (order_id);
+
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at DATETIME,
+    updated_at DATETIME,
+    deleted_at DATETIME,
+    user_id INTEGER,
+    idempotency_key VARCHAR(255),
+    fingerprint TEXT,
+    response_code INTEGER,
+    response_body BLOB,
+    expires_at DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_deleted_at ON idempotency_keys (deleted_at);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_idempotency_user_key ON idempotency_keys (user_id, idempotency_key);
+
+CREATE TABLE IF NOT EXISTS user_role_histories (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at DATETIME,
+    updated_at DATETIME,
+    deleted_at DATETIME,
+    user_id INTEGER,
+    from_role TEXT,
+    to_role TEXT,
+    changed_by INTEGER,
+    note TEXT
+);
+CREATE INDEX IF NOT EXISTS idx_user_role_histories_deleted_at ON user_role_histories (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_user_role_histories_user_id ON user_role_histories (user_id);
+
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at DATETIME,
+    updated_at DATETIME,
+    deleted_at DATETIME,
+    user_id INTEGER,
+    family_id VARCHAR(64),
+    token_hash VARCHAR(64),
+    expires_at DATETIME,
+    rotated_at DATETIME,
+    revoked_at DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_deleted_at ON refresh_tokens (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user_id ON refresh_tokens (user_id);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family_id ON refresh_tokens (family_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_refresh_tokens_token_hash ON refresh_tokens (token_hash);
+
+CREATE TABLE IF NOT EXISTS roles (
+    name VARCHAR(64) PRIMARY KEY,
+    description TEXT,
+    created_at DATETIME,
+    updated_at DATETIME
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role VARCHAR(64) CONSTRAINT fk_roles_permissions REFERENCES roles (name) ON DELETE CASCADE,
+    permission VARCHAR(64),
+    PRIMARY KEY (role, permission)
+);
+
+CREATE TABLE IF NOT EXISTS one_time_tokens (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at DATETIME,
+    updated_at DATETIME,
+    deleted_at DATETIME,
+    user_id INTEGER,
+    purpose VARCHAR(32),
+    token_hash VARCHAR(64),
+    expires_at DATETIME,
+    used_at DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_one_time_tokens_deleted_at ON one_time_tokens (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_one_time_tokens_user_id ON one_time_tokens (user_id);
+CREATE INDEX IF NOT EXISTS idx_one_time_tokens_purpose ON one_time_tokens (purpose);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_one_time_tokens_token_hash ON one_time_tokens (token_hash);
+
+CREATE TABLE IF NOT EXISTS login_attempts (
+    subject VARCHAR(320) PRIMARY KEY,
+    failures INTEGER,
+    last_failure DATETIME,
+    locked_until DATETIME
+);
+
+CREATE TABLE IF NOT EXISTS audit_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    type VARCHAR(64),
+    user_id INTEGER,
+    actor_id INTEGER,
+    subject TEXT,
+    ip TEXT,
+    detail TEXT,
+    created_at DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_audit_events_type ON audit_events (type);
+CREATE INDEX IF NOT EXISTS idx_audit_events_user_id ON audit_events (user_id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_created_at ON audit_events (created_at);
+
+CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER,
+    code_hash VARCHAR(64),
+    used_at DATETIME,
+    created_at DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_mfa_recovery_codes_user_id ON mfa_recovery_codes (user_id);
+CREATE INDEX IF NOT EXISTS idx_mfa_recovery_codes_code_hash ON mfa_recovery_codes (code_hash);
+
+CREATE TABLE IF NOT EXISTS rate_limit_buckets (
+    subject VARCHAR(320) PRIMARY KEY,
+    tokens REAL,
+    refilled_at DATETIME
+);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_rate_limit_buckets_full_at;
+ALTER TABLE rate_limit_buckets DROP COLUMN full_at;
@@ -0,0 +1,4 @@
+-- SQLite version of ../000002_rate_limit_bucket_full_at.up.sql.
+ALTER TABLE rate_limit_buckets ADD COLUMN full_at DATETIME;
+UPDATE rate_limit_buckets SET full_at = refilled_at WHERE full_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_rate_limit_buckets_full_at ON rate_limit_buckets (full_at);
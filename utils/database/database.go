@@ -1,68 +1,271 @@
 package database
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
 	"sync"
+	"time"
 
+	"github.com/glebarez/sqlite"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
+	"gorm.io/plugin/dbresolver"
 )
 
+// Drivers accepted in database.driver.
+const (
+	Postgres = "postgres"
+	SQLite   = "sqlite"
+)
+
+// Memory is the database.path that keeps a SQLite database in memory only.
+const Memory = ":memory:"
+
+// ReplicaResolver names the read replicas registered with dbresolver. Reads
+// only go to them when they ask to with dbresolver.Use(ReplicaResolver);
+// everything else, and everything in a transaction, uses the primary.
+const ReplicaResolver = "replicas"
+
+// ErrUnknownDriver is returned for a database.driver other than Postgres or
+// SQLite. Connect does not retry it.
+var ErrUnknownDriver = errors.New("unknown database.driver")
+
 var once sync.Once
 var dba *gorm.DB
 
+// sleep waits between connection attempts; tests replace it.
+var sleep = time.Sleep
+
+// replicas holds the connection pools of the read replicas registered on each
+// database Open returned, for Check.
+var replicas = struct {
+	sync.Mutex
+	pools map[*gorm.DB][]*sql.DB
+}{pools: map[*gorm.DB][]*sql.DB{}}
+
 // GetInstance - Returns a DB instance
 func GetInstance() *gorm.DB {
 	once.Do(func() {
-		//documentation  - https://gorm.io/docs/connecting_to_the_database.html#PostgreSQL
-		// user
-		user := viper.GetString("database.user")
-		// password
-		password := viper.GetString("database.password")
-		// hots
-		host := viper.GetString("database.host")
-		// port
-		port := viper.GetString("database.port")
-		// database name
-		dbname := viper.GetString("database.dbname")
-		// sslmode
-		sslmode := viper.GetString("database.sslmode")
-		//TimeZone
-		timezone := viper.GetString("database.timezone")
-		//Schema
-		shcema := viper.GetString("database.schema")
-
-		//dsn
-		dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=%s TimeZone=%s search_path=%s", host, user, password, dbname, port, sslmode, timezone, shcema)
-		//database configuration
-		db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
-			SkipDefaultTransaction: true,
-			PrepareStmt:            true,
-			Logger:                 logger.Default.LogMode(logger.Info),
-		})
-		// instance of db
-		dba = db
+		db, err := Connect()
 		if err != nil {
-			logrus.Panicf("Error connecting to the database at %s:%s/%s", host, port, dbname)
+			logrus.Fatalf("Error connecting to the database: %s", err)
 		}
-		//print log when database connection is established
-		logrus.Infof("Successfully Established Connection to -- %s:%s/%s", host, port, dbname)
+		// instance of db
+		dba = db
 	})
 	//return
 	return dba
 }
 
-// Migrate - AutoMigrate models
-func Migrate(models ...interface{}) {
-	if dba == nil {
-		GetInstance()
+// Driver returns the configured database.driver, postgres by default.
+func Driver() string {
+	if driver := viper.GetString("database.driver"); driver != "" {
+		return driver
 	}
-	if err := dba.AutoMigrate(models...); err != nil {
-		logrus.Errorf("Migration failed: %s", err)
+	return Postgres
+}
+
+// InMemory reports whether the database is a SQLite database kept in memory,
+// which starts out empty and is gone when the process exits.
+func InMemory() bool {
+	return Driver() == SQLite && sqlitePath() == Memory
+}
+
+// Open connects to the database selected by database.driver, and to its
+// database.replicas. Unlike GetInstance it returns new connection pools on
+// every call.
+func Open() (*gorm.DB, error) {
+	var dialector gorm.Dialector
+	var target string
+	switch driver := Driver(); driver {
+	case Postgres:
+		dialector, target = postgresDialector()
+	case SQLite:
+		dialector, target = sqliteDialector()
+	default:
+		return nil, fmt.Errorf("%w %q (want %s or %s)", ErrUnknownDriver, driver, Postgres, SQLite)
+	}
+
+	db, sqlDB, err := openPool(dialector, target)
+	if err != nil {
+		return nil, err
+	}
+	if InMemory() {
+		// Every connection to :memory: opens a separate, empty database, so
+		// the one connection must never be closed either
+		sqlDB.SetMaxOpenConns(1)
+		sqlDB.SetMaxIdleConns(1)
 	} else {
-		logrus.Info("Database Migration Completed Successfully")
+		configurePool(sqlDB)
+	}
+	//print log when database connection is established
+	logrus.Infof("Successfully Established Connection to -- %s", target)
+
+	if err := registerReplicas(db); err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// Connect opens the database like Open, retrying with exponential backoff
+// while it cannot be reached. It gives up after database.connect.attempts
+// tries, waiting database.connect.backoff_base after the first and doubling
+// up to database.connect.backoff_max.
+func Connect() (*gorm.DB, error) {
+	return connect(Open)
+}
+
+func connect(open func() (*gorm.DB, error)) (*gorm.DB, error) {
+	viper.SetDefault("database.connect.attempts", 5)
+	viper.SetDefault("database.connect.backoff_base", time.Second)
+	viper.SetDefault("database.connect.backoff_max", 30*time.Second)
+	attempts := max(viper.GetInt("database.connect.attempts"), 1)
+	backoff := viper.GetDuration("database.connect.backoff_base")
+	backoffMax := viper.GetDuration("database.connect.backoff_max")
+
+	for attempt := 1; ; attempt++ {
+		db, err := open()
+		if err == nil {
+			return db, nil
+		}
+		if errors.Is(err, ErrUnknownDriver) || attempt >= attempts {
+			return nil, err
+		}
+		logrus.Warnf("Database unavailable (attempt %d of %d), retrying in %s: %s", attempt, attempts, backoff, err)
+		sleep(backoff)
+		backoff = min(backoff*2, backoffMax)
+	}
+}
+
+// openPool connects with the settings every pool shares.
+func openPool(dialector gorm.Dialector, target string) (*gorm.DB, *sql.DB, error) {
+	//database configuration
+	db, err := gorm.Open(dialector, &gorm.Config{
+		SkipDefaultTransaction: true,
+		PrepareStmt:            true,
+		Logger:                 logger.Default.LogMode(logger.Info),
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("connecting to %s: %w", target, err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, nil, err
+	}
+	return db, sqlDB, nil
+}
+
+// configurePool sizes a connection pool from database.pool. Connections are
+// recycled after conn_max_lifetime so that they move to a replaced server or
+// a replica added behind the same address.
+func configurePool(sqlDB *sql.DB) {
+	viper.SetDefault("database.pool.max_open_conns", 25)
+	viper.SetDefault("database.pool.max_idle_conns", 10)
+	viper.SetDefault("database.pool.conn_max_lifetime", 30*time.Minute)
+	viper.SetDefault("database.pool.conn_max_idle_time", 5*time.Minute)
+	sqlDB.SetMaxOpenConns(viper.GetInt("database.pool.max_open_conns"))
+	sqlDB.SetMaxIdleConns(viper.GetInt("database.pool.max_idle_conns"))
+	sqlDB.SetConnMaxLifetime(viper.GetDuration("database.pool.conn_max_lifetime"))
+	sqlDB.SetConnMaxIdleTime(viper.GetDuration("database.pool.conn_max_idle_time"))
+}
+
+// registerReplicas connects to every database.replicas entry and registers
+// them with db under ReplicaResolver. Replicas are Postgres only.
+func registerReplicas(db *gorm.DB) error {
+	var settings []map[string]any
+	if err := viper.UnmarshalKey("database.replicas", &settings); err != nil {
+		return fmt.Errorf("reading database.replicas: %w", err)
+	}
+	if len(settings) == 0 {
+		return nil
+	}
+	if Driver() != Postgres {
+		logrus.Warnf("Ignoring database.replicas: read replicas need the %s driver", Postgres)
+		return nil
+	}
+
+	var pools []*sql.DB
+	var dialectors []gorm.Dialector
+	closeAll := func() {
+		for _, pool := range pools {
+			pool.Close()
+		}
+	}
+	for _, replica := range settings {
+		dialector, target := postgresDialector(replica)
+		_, sqlDB, err := openPool(dialector, target)
+		if err != nil {
+			closeAll()
+			return fmt.Errorf("read replica: %w", err)
+		}
+		configurePool(sqlDB)
+		logrus.Infof("Successfully Established Connection to read replica -- %s", target)
+		pools = append(pools, sqlDB)
+		dialectors = append(dialectors, postgres.New(postgres.Config{Conn: sqlDB}))
+	}
+
+	if err := db.Use(dbresolver.Register(dbresolver.Config{Replicas: dialectors}, ReplicaResolver)); err != nil {
+		closeAll()
+		return fmt.Errorf("registering read replicas: %w", err)
+	}
+	replicas.Lock()
+	replicas.pools[db] = pools
+	replicas.Unlock()
+	return nil
+}
+
+// postgresDialector connects to the primary, or to a read replica given its
+// database.replicas entry; settings the entry leaves out are the primary's.
+func postgresDialector(replica ...map[string]any) (gorm.Dialector, string) {
+	setting := func(key string) string {
+		if len(replica) > 0 {
+			if value, ok := replica[0][key]; ok {
+				return fmt.Sprint(value)
+			}
+		}
+		return viper.GetString("database." + key)
+	}
+	//documentation  - https://gorm.io/docs/connecting_to_the_database.html#PostgreSQL
+	// user
+	user := setting("user")
+	// password
+	password := setting("password")
+	// hots
+	host := setting("host")
+	// port
+	port := setting("port")
+	// database name
+	dbname := setting("dbname")
+	// sslmode
+	sslmode := setting("sslmode")
+	//TimeZone
+	timezone := setting("timezone")
+	//Schema
+	shcema := setting("schema")
+
+	//dsn
+	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=%s TimeZone=%s search_path=%s", host, user, password, dbname, port, sslmode, timezone, shcema)
+	return postgres.Open(dsn), fmt.Sprintf("%s:%s/%s", host, port, dbname)
+}
+
+// sqliteDialector opens the file at database.path, or an in-memory database.
+// SQLite locks the whole database rather than rows, so FOR UPDATE clauses are
+// dropped; transactions take the write lock when they begin instead, which
+// serializes them the way the row locks would.
+func sqliteDialector() (gorm.Dialector, string) {
+	path := sqlitePath()
+	dsn := path + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_txlock=immediate"
+	return sqlite.Open(dsn), path
+}
+
+func sqlitePath() string {
+	if path := viper.GetString("database.path"); path != "" {
+		return path
 	}
+	return Memory
 }
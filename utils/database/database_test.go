@@ -0,0 +1,113 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestOpen(t *testing.T) {
+	defer viper.Set("database.driver", nil)
+	defer viper.Set("database.path", nil)
+
+	// Case 1: Postgres unless configured otherwise
+	assert.Equal(t, Postgres, Driver())
+
+	// Case 2: SQLite in memory stays on one connection
+	viper.Set("database.driver", SQLite)
+	assert.True(t, InMemory())
+	db, err := Open()
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	assert.Equal(t, 1, sqlDB.Stats().MaxOpenConnections)
+	require.NoError(t, sqlDB.Close())
+
+	// Case 3: SQLite file with foreign keys enforced
+	viper.Set("database.path", filepath.Join(t.TempDir(), "book.db"))
+	assert.False(t, InMemory())
+	db, err = Open()
+	require.NoError(t, err)
+	var foreignKeys int
+	require.NoError(t, db.Raw("PRAGMA foreign_keys").Scan(&foreignKeys).Error)
+	assert.Equal(t, 1, foreignKeys)
+	sqlDB, _ = db.DB()
+	require.NoError(t, sqlDB.Close())
+
+	// Case 4: Unknown driver
+	viper.Set("database.driver", "mysql")
+	_, err = Open()
+	assert.Error(t, err)
+}
+
+func TestConnect(t *testing.T) {
+	defer func() { sleep = time.Sleep }()
+	var waits []time.Duration
+	sleep = func(d time.Duration) { waits = append(waits, d) }
+	viper.Set("database.connect.attempts", 5)
+	viper.Set("database.connect.backoff_max", 3*time.Second)
+	defer viper.Set("database.connect.attempts", nil)
+	defer viper.Set("database.connect.backoff_max", nil)
+
+	unreachable := errors.New("connection refused")
+	failing := func(times int) func() (*gorm.DB, error) {
+		return func() (*gorm.DB, error) {
+			if times--; times >= 0 {
+				return nil, unreachable
+			}
+			return &gorm.DB{}, nil
+		}
+	}
+
+	// Case 1: Retried with doubling backoff until it comes up
+	db, err := connect(failing(3))
+	require.NoError(t, err)
+	assert.NotNil(t, db)
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second, 3 * time.Second}, waits)
+
+	// Case 2: Gives up after the last attempt
+	waits = nil
+	_, err = connect(failing(10))
+	assert.ErrorIs(t, err, unreachable)
+	assert.Len(t, waits, 4)
+
+	// Case 3: A configuration error is not retried
+	waits = nil
+	_, err = connect(func() (*gorm.DB, error) { return nil, ErrUnknownDriver })
+	assert.ErrorIs(t, err, ErrUnknownDriver)
+	assert.Empty(t, waits)
+}
+
+func TestCheck(t *testing.T) {
+	viper.Set("database.driver", SQLite)
+	viper.Set("database.path", filepath.Join(t.TempDir(), "book.db"))
+	viper.Set("database.pool.max_open_conns", 7)
+	defer viper.Set("database.driver", nil)
+	defer viper.Set("database.path", nil)
+	defer viper.Set("database.pool.max_open_conns", nil)
+
+	db, err := Open()
+	require.NoError(t, err)
+	sqlDB, _ := db.DB()
+
+	// Case 1: The pool is sized from the configuration
+	statuses := Check(context.Background(), db)
+	require.Len(t, statuses, 1)
+	assert.Equal(t, "primary", statuses[0].Name)
+	assert.True(t, statuses[0].Up)
+	assert.Equal(t, 7, statuses[0].MaxOpenConnections)
+	assert.Equal(t, 1, statuses[0].OpenConnections)
+
+	// Case 2: A database that stopped answering is down
+	require.NoError(t, sqlDB.Close())
+	statuses = Check(context.Background(), db)
+	assert.False(t, statuses[0].Up)
+	assert.NotEmpty(t, statuses[0].Error)
+}
@@ -0,0 +1,79 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// PingTimeout bounds the ping of each database, so a database that hangs is
+// reported down instead of holding up the check.
+const PingTimeout = 2 * time.Second
+
+// unavailable is the Error of a database that does not answer. The cause is
+// only logged, since it can name hosts and addresses.
+const unavailable = "unavailable"
+
+// PoolStatus is whether one database answers and how its connection pool is doing.
+type PoolStatus struct {
+	Name               string `json:"name" example:"primary"`
+	Up                 bool   `json:"up"`
+	Error              string `json:"error,omitempty" example:"unavailable"`
+	MaxOpenConnections int    `json:"max_open_connections"`
+	OpenConnections    int    `json:"open_connections"`
+	InUse              int    `json:"in_use"`
+	Idle               int    `json:"idle"`
+	WaitCount          int64  `json:"wait_count"`           // connections waited for
+	WaitDurationMs     int64  `json:"wait_duration_ms"`     // total time spent waiting
+	MaxIdleClosed      int64  `json:"max_idle_closed"`      // closed because of max_idle_conns
+	MaxIdleTimeClosed  int64  `json:"max_idle_time_closed"` // closed because of conn_max_idle_time
+	MaxLifetimeClosed  int64  `json:"max_lifetime_closed"`  // closed because of conn_max_lifetime
+}
+
+// Check pings the primary behind db and each of its read replicas, and reports
+// their pool statistics, primary first.
+func Check(ctx context.Context, db *gorm.DB) []PoolStatus {
+	replicas.Lock()
+	pools := replicas.pools[db]
+	replicas.Unlock()
+
+	primary, err := db.DB()
+	if err != nil {
+		logrus.WithError(err).Warn("Health check could not reach the primary database")
+		return []PoolStatus{{Name: "primary", Error: unavailable}}
+	}
+	statuses := []PoolStatus{poolStatus(ctx, "primary", primary)}
+	for i, pool := range pools {
+		statuses = append(statuses, poolStatus(ctx, fmt.Sprintf("replica-%d", i+1), pool))
+	}
+	return statuses
+}
+
+func poolStatus(ctx context.Context, name string, sqlDB *sql.DB) PoolStatus {
+	stats := sqlDB.Stats()
+	status := PoolStatus{
+		Name:               name,
+		Up:                 true,
+		MaxOpenConnections: stats.MaxOpenConnections,
+		OpenConnections:    stats.OpenConnections,
+		InUse:              stats.InUse,
+		Idle:               stats.Idle,
+		WaitCount:          stats.WaitCount,
+		WaitDurationMs:     stats.WaitDuration.Milliseconds(),
+		MaxIdleClosed:      stats.MaxIdleClosed,
+		MaxIdleTimeClosed:  stats.MaxIdleTimeClosed,
+		MaxLifetimeClosed:  stats.MaxLifetimeClosed,
+	}
+	ctx, cancel := context.WithTimeout(ctx, PingTimeout)
+	defer cancel()
+	if err := sqlDB.PingContext(ctx); err != nil {
+		logrus.WithError(err).WithField("database", name).Warn("Health check ping failed")
+		status.Up = false
+		status.Error = unavailable
+	}
+	return status
+}
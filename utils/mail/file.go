@@ -0,0 +1,50 @@
+package mail
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// FileSender writes every message to its own .eml file in Dir, for local
+// development and tests.
+type FileSender struct {
+	Dir  string
+	From string
+	seq  atomic.Uint64
+}
+
+func NewFileSender(dir, from string) (*FileSender, error) {
+	if dir == "" {
+		dir = "mail"
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileSender{Dir: dir, From: from}, nil
+}
+
+func (s *FileSender) Send(msg Message) error {
+	recipient := strings.NewReplacer("@", "_at_", "/", "_", "\\", "_").Replace(msg.To)
+	name := fmt.Sprintf("%s-%03d-%s.eml", time.Now().Format("20060102T150405"), s.seq.Add(1), recipient)
+	return os.WriteFile(filepath.Join(s.Dir, name), render(s.From, msg), 0o600)
+}
+
+// LogSender writes messages to the application log instead of sending them.
+type LogSender struct {
+	From string
+}
+
+func (s *LogSender) Send(msg Message) error {
+	logrus.WithFields(logrus.Fields{
+		"from":    s.From,
+		"to":      msg.To,
+		"subject": msg.Subject,
+	}).Info("Email (log driver):\n" + msg.Body)
+	return nil
+}
@@ -0,0 +1,45 @@
+package mail
+
+import (
+	"fmt"
+
+	"github.com/spf13/viper"
+)
+
+// Message is a plain-text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender delivers email. Services depend on this interface so delivery can be
+// swapped between SMTP in production and files or logs in development.
+type Sender interface {
+	Send(msg Message) error
+}
+
+// NewSender builds the sender selected by mail.driver: smtp, file or log (the default).
+func NewSender() (Sender, error) {
+	from := viper.GetString("mail.from")
+	if from == "" {
+		from = "no-reply@localhost"
+	}
+
+	switch driver := viper.GetString("mail.driver"); driver {
+	case "smtp":
+		return &SMTPSender{
+			Host:     viper.GetString("mail.smtp.host"),
+			Port:     viper.GetInt("mail.smtp.port"),
+			Username: viper.GetString("mail.smtp.username"),
+			Password: viper.GetString("mail.smtp.password"),
+			From:     from,
+		}, nil
+	case "file":
+		return NewFileSender(viper.GetString("mail.dir"), from)
+	case "", "log":
+		return &LogSender{From: from}, nil
+	default:
+		return nil, fmt.Errorf("unknown mail.driver %q", driver)
+	}
+}
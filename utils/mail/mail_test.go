@@ -0,0 +1,49 @@
+package mail
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileSender(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "outbox")
+	sender, err := NewFileSender(dir, "shop@example.com")
+	assert.NoError(t, err)
+
+	assert.NoError(t, sender.Send(Message{To: "john@example.com", Subject: "Hello", Body: "First"}))
+	assert.NoError(t, sender.Send(Message{To: "john@example.com", Subject: "Hello", Body: "Second"}))
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	assert.NoError(t, err)
+	assert.Len(t, files, 2)
+
+	content, err := os.ReadFile(files[0])
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), "From: shop@example.com\r\n")
+	assert.Contains(t, string(content), "To: john@example.com\r\n")
+	assert.Contains(t, string(content), "Subject: Hello\r\n")
+	assert.Contains(t, string(content), "\r\n\r\nFirst")
+}
+
+func TestNewSender(t *testing.T) {
+	defer viper.Set("mail.driver", "")
+
+	viper.Set("mail.driver", "")
+	sender, err := NewSender()
+	assert.NoError(t, err)
+	assert.IsType(t, &LogSender{}, sender)
+
+	viper.Set("mail.driver", "smtp")
+	viper.Set("mail.smtp.host", "smtp.example.com")
+	sender, err = NewSender()
+	assert.NoError(t, err)
+	assert.Equal(t, "smtp.example.com", sender.(*SMTPSender).Host)
+
+	viper.Set("mail.driver", "pigeon")
+	_, err = NewSender()
+	assert.Error(t, err)
+}
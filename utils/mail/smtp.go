@@ -0,0 +1,41 @@
+package mail
+
+import (
+	"bytes"
+	"fmt"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+// SMTPSender delivers mail through an SMTP relay, authenticating with PLAIN
+// auth when a username is set.
+type SMTPSender struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+func (s *SMTPSender) Send(msg Message) error {
+	var auth smtp.Auth
+	if s.Username != "" {
+		auth = smtp.PlainAuth("", s.Username, s.Password, s.Host)
+	}
+	addr := s.Host + ":" + strconv.Itoa(s.Port)
+	return smtp.SendMail(addr, auth, s.From, []string{msg.To}, render(s.From, msg))
+}
+
+// render formats msg as an RFC 5322 message.
+func render(from string, msg Message) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	b.WriteString(msg.Body)
+	return b.Bytes()
+}
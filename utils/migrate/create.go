@@ -0,0 +1,45 @@
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var nameCleaner = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Create adds an empty up and down file for a new migration to dir, numbered
+// after the latest migration there, and returns their paths.
+func Create(dir, name string) ([]string, error) {
+	name = strings.Trim(nameCleaner.ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if name == "" {
+		return nil, errors.New("migration name must contain letters or digits")
+	}
+
+	existing, err := Load(os.DirFS(dir))
+	if err != nil {
+		return nil, err
+	}
+	version := int64(1)
+	if len(existing) > 0 {
+		version = existing[len(existing)-1].Version + 1
+	}
+
+	base := fmt.Sprintf("%06d_%s", version, name)
+	files := map[string]string{
+		"up":   "-- " + base + ": the schema change.\n",
+		"down": "-- " + base + ": undo the up migration.\n",
+	}
+	var paths []string
+	for _, direction := range []string{"up", "down"} {
+		path := filepath.Join(dir, base+"."+direction+".sql")
+		if err := os.WriteFile(path, []byte(files[direction]), 0o644); err != nil {
+			return paths, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
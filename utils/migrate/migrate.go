@@ -0,0 +1,397 @@
+// Package migrate applies versioned SQL migrations and records them in the
+// schema_migrations table.
+//
+// A migration is a pair of files named <version>_<name>.up.sql and
+// <version>_<name>.down.sql. Each runs in a transaction. The table keeps a
+// checksum of every applied up file, so a file changed after it was applied
+// is noticed. A migration that failed stays marked as failed until it is
+// applied successfully.
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// LockID is the Postgres advisory lock held while migrations run, so server
+// instances starting together do not apply the same migration twice.
+const LockID int64 = 727_100_421
+
+// Dialects a Migrator can keep schema_migrations in.
+const (
+	Postgres = "postgres"
+	SQLite   = "sqlite"
+)
+
+var (
+	ErrPending        = errors.New("migration pending")
+	ErrFailed         = errors.New("migration failed")
+	ErrModified       = errors.New("applied migration was modified")
+	ErrUnknownVersion = errors.New("database has a migration this build does not know")
+	ErrNoDown         = errors.New("migration has no down file")
+)
+
+var fileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Migration is one schema change read from its files.
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string // SHA-256 of Up
+}
+
+type State string
+
+const (
+	StateApplied  State = "applied"
+	StatePending  State = "pending"
+	StateFailed   State = "failed"
+	StateModified State = "modified" // Applied, but the up file has changed since
+	StateUnknown  State = "unknown"  // Applied, but there is no file for it
+)
+
+// Status is the state of one migration in the database.
+type Status struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	State     State      `json:"state"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+// Load reads the migrations in the top directory of fsys, in version order.
+// Files that do not follow the naming scheme are ignored.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: %w", entry.Name(), err)
+		}
+		body, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration version %d is used by both %q and %q", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(body)
+			sum := sha256.Sum256(body)
+			m.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Migrator applies Migrations to DB.
+type Migrator struct {
+	DB         *sql.DB
+	Migrations []Migration
+	Dialect    string // Postgres or SQLite
+}
+
+// New loads the migrations in fsys for a Postgres db.
+func New(db *sql.DB, fsys fs.FS) (*Migrator, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{DB: db, Migrations: migrations, Dialect: Postgres}, nil
+}
+
+// applied is a row of schema_migrations.
+type applied struct {
+	name      string
+	checksum  string
+	dirty     bool
+	appliedAt time.Time
+}
+
+// Up applies every pending migration, and retries a failed one, in version
+// order. It stops at the first failure. Nothing is applied while an applied
+// migration was modified or is unknown to this build.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var done []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		statuses, err := m.status(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			if s.State == StateModified || s.State == StateUnknown {
+				return stateError(s)
+			}
+		}
+
+		for _, migration := range m.Migrations {
+			if stateOf(statuses, migration.Version) == StateApplied {
+				continue
+			}
+			if err := m.apply(ctx, conn, migration); err != nil {
+				return fmt.Errorf("%w: %d_%s: %v", ErrFailed, migration.Version, migration.Name, err)
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down reverts the latest steps applied migrations, newest first.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var done []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		statuses, err := m.status(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(statuses) - 1; i >= 0 && len(done) < steps; i-- {
+			s := statuses[i]
+			switch s.State {
+			case StatePending:
+				continue
+			case StateApplied:
+			default:
+				return stateError(s)
+			}
+
+			migration := m.find(s.Version)
+			if migration.Down == "" {
+				return fmt.Errorf("%w: %d_%s", ErrNoDown, migration.Version, migration.Name)
+			}
+			if err := m.revert(ctx, conn, *migration); err != nil {
+				return fmt.Errorf("reverting %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			done = append(done, *migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Status lists every migration known to this build or recorded in the
+// database, in version order.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		var err error
+		statuses, err = m.status(ctx, conn)
+		return err
+	})
+	return statuses, err
+}
+
+// Check returns an error unless every migration is applied, unmodified, and
+// known to this build. The server refuses to start when it fails.
+func (m *Migrator) Check(ctx context.Context) error {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+	for _, s := range statuses {
+		if s.State != StateApplied {
+			return stateError(s)
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, migration Migration) error {
+	// Recorded as failed first, so a crash part way through is not mistaken for success
+	_, err := conn.ExecContext(ctx,
+		`INSERT INTO schema_migrations (version, name, checksum, dirty, applied_at) VALUES ($1, $2, $3, TRUE, $4)
+		ON CONFLICT (version) DO UPDATE SET name = excluded.name, checksum = excluded.checksum, dirty = TRUE, applied_at = excluded.applied_at`,
+		migration.Version, migration.Name, migration.Checksum, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, migration.Up); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE schema_migrations SET dirty = FALSE WHERE version = $1`, migration.Version); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (m *Migrator) revert(ctx context.Context, conn *sql.Conn, migration Migration) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, migration.Down); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = $1`, migration.Version); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (m *Migrator) status(ctx context.Context, conn *sql.Conn) ([]Status, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version, name, checksum, dirty, applied_at FROM schema_migrations ORDER BY version`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := make(map[int64]applied)
+	for rows.Next() {
+		var version int64
+		var r applied
+		if err := rows.Scan(&version, &r.name, &r.checksum, &r.dirty, &r.appliedAt); err != nil {
+			return nil, err
+		}
+		records[version] = r
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var statuses []Status
+	for _, migration := range m.Migrations {
+		s := Status{Version: migration.Version, Name: migration.Name, State: StatePending}
+		if r, ok := records[migration.Version]; ok {
+			appliedAt := r.appliedAt
+			s.AppliedAt = &appliedAt
+			switch {
+			case r.dirty:
+				s.State = StateFailed
+			case r.checksum != migration.Checksum:
+				s.State = StateModified
+			default:
+				s.State = StateApplied
+			}
+			delete(records, migration.Version)
+		}
+		statuses = append(statuses, s)
+	}
+	for version, r := range records {
+		appliedAt := r.appliedAt
+		statuses = append(statuses, Status{Version: version, Name: r.name, State: StateUnknown, AppliedAt: &appliedAt})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// withLock runs fn on a connection holding the migration lock, after making
+// sure schema_migrations exists.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// SQLite has no advisory locks. Its databases are local to one machine,
+	// where migrations are not expected to run concurrently.
+	if m.Dialect != SQLite {
+		if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, LockID); err != nil {
+			return fmt.Errorf("acquiring migration lock: %w", err)
+		}
+		defer func() {
+			// The lock also ends with the session if this fails
+			_, _ = conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, LockID)
+		}()
+	}
+
+	_, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT PRIMARY KEY,
+		name TEXT NOT NULL,
+		checksum TEXT NOT NULL,
+		dirty BOOLEAN NOT NULL DEFAULT FALSE,
+		applied_at `+m.timestampType()+` NOT NULL
+	)`)
+	if err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+func (m *Migrator) timestampType() string {
+	if m.Dialect == SQLite {
+		return "DATETIME"
+	}
+	return "TIMESTAMPTZ"
+}
+
+func (m *Migrator) find(version int64) *Migration {
+	for i := range m.Migrations {
+		if m.Migrations[i].Version == version {
+			return &m.Migrations[i]
+		}
+	}
+	return nil
+}
+
+func stateOf(statuses []Status, version int64) State {
+	for _, s := range statuses {
+		if s.Version == version {
+			return s.State
+		}
+	}
+	return StatePending
+}
+
+func stateError(s Status) error {
+	var err error
+	switch s.State {
+	case StatePending:
+		err = ErrPending
+	case StateFailed:
+		err = ErrFailed
+	case StateModified:
+		err = ErrModified
+	case StateUnknown:
+		err = ErrUnknownVersion
+	default:
+		return nil
+	}
+	return fmt.Errorf("%w: %d_%s", err, s.Version, s.Name)
+}
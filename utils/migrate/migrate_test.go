@@ -0,0 +1,236 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	_ "github.com/glebarez/go-sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testFiles() fstest.MapFS {
+	return fstest.MapFS{
+		"000001_create_books.up.sql":   {Data: []byte("CREATE TABLE books (id BIGINT);")},
+		"000001_create_books.down.sql": {Data: []byte("DROP TABLE books;")},
+		"000002_add_stock.up.sql":      {Data: []byte("ALTER TABLE books ADD COLUMN stock BIGINT;")},
+		"000002_add_stock.down.sql":    {Data: []byte("ALTER TABLE books DROP COLUMN stock;")},
+		"README.md":                    {Data: []byte("not a migration")},
+	}
+}
+
+func newTestMigrator(t *testing.T) (*Migrator, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	migrator, err := New(db, testFiles())
+	require.NoError(t, err)
+	return migrator, mock
+}
+
+func expectLock(mock sqlmock.Sqlmock) {
+	mock.ExpectExec(`SELECT pg_advisory_lock\(\$1\)`).WithArgs(LockID).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS schema_migrations`).WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+func expectUnlock(mock sqlmock.Sqlmock) {
+	mock.ExpectExec(`SELECT pg_advisory_unlock\(\$1\)`).WithArgs(LockID).WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+func expectApplied(mock sqlmock.Sqlmock, rows *sqlmock.Rows) {
+	mock.ExpectQuery(`SELECT version, name, checksum, dirty, applied_at FROM schema_migrations`).WillReturnRows(rows)
+}
+
+func appliedRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"version", "name", "checksum", "dirty", "applied_at"})
+}
+
+func TestLoad(t *testing.T) {
+	// Case 1: Migrations are paired and sorted; other files are ignored
+	migrations, err := Load(testFiles())
+	require.NoError(t, err)
+	require.Len(t, migrations, 2)
+	assert.Equal(t, int64(1), migrations[0].Version)
+	assert.Equal(t, "create_books", migrations[0].Name)
+	assert.Equal(t, "DROP TABLE books;", migrations[0].Down)
+	assert.Len(t, migrations[0].Checksum, 64)
+
+	// Case 2: A version may not be used twice
+	files := testFiles()
+	files["000002_other.up.sql"] = &fstest.MapFile{Data: []byte("SELECT 1;")}
+	_, err = Load(files)
+	assert.Error(t, err)
+
+	// Case 3: A down file alone is not a migration
+	_, err = Load(fstest.MapFS{"000003_x.down.sql": {Data: []byte("SELECT 1;")}})
+	assert.Error(t, err)
+}
+
+func TestUp(t *testing.T) {
+	migrator, mock := newTestMigrator(t)
+	first := migrator.Migrations[0]
+
+	// Case 1: Only pending migrations run, each in a transaction after being marked failed
+	expectLock(mock)
+	expectApplied(mock, appliedRows().AddRow(1, "create_books", first.Checksum, false, time.Now()))
+	mock.ExpectExec(`INSERT INTO schema_migrations .* ON CONFLICT \(version\) DO UPDATE`).
+		WithArgs(int64(2), "add_stock", migrator.Migrations[1].Checksum, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectBegin()
+	mock.ExpectExec(`ALTER TABLE books ADD COLUMN stock BIGINT`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`UPDATE schema_migrations SET dirty = FALSE WHERE version = \$1`).
+		WithArgs(int64(2)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	expectUnlock(mock)
+
+	done, err := migrator.Up(context.Background())
+	require.NoError(t, err)
+	require.Len(t, done, 1)
+	assert.Equal(t, int64(2), done[0].Version)
+
+	// Case 2: A failing migration is rolled back and stays marked failed
+	expectLock(mock)
+	expectApplied(mock, appliedRows())
+	mock.ExpectExec(`INSERT INTO schema_migrations`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectBegin()
+	mock.ExpectExec(`CREATE TABLE books`).WillReturnError(errors.New("syntax error"))
+	mock.ExpectRollback()
+	expectUnlock(mock)
+
+	done, err = migrator.Up(context.Background())
+	assert.ErrorIs(t, err, ErrFailed)
+	assert.Empty(t, done)
+
+	// Case 3: Nothing runs while an applied migration was modified
+	expectLock(mock)
+	expectApplied(mock, appliedRows().AddRow(1, "create_books", "old-checksum", false, time.Now()))
+	expectUnlock(mock)
+
+	_, err = migrator.Up(context.Background())
+	assert.ErrorIs(t, err, ErrModified)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDown(t *testing.T) {
+	migrator, mock := newTestMigrator(t)
+
+	// Case 1: The latest applied migration is reverted and forgotten
+	expectLock(mock)
+	expectApplied(mock, appliedRows().
+		AddRow(1, "create_books", migrator.Migrations[0].Checksum, false, time.Now()).
+		AddRow(2, "add_stock", migrator.Migrations[1].Checksum, false, time.Now()))
+	mock.ExpectBegin()
+	mock.ExpectExec(`ALTER TABLE books DROP COLUMN stock`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM schema_migrations WHERE version = \$1`).
+		WithArgs(int64(2)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	expectUnlock(mock)
+
+	done, err := migrator.Down(context.Background(), 1)
+	require.NoError(t, err)
+	require.Len(t, done, 1)
+	assert.Equal(t, "add_stock", done[0].Name)
+
+	// Case 2: A failed migration must be fixed and applied first
+	expectLock(mock)
+	expectApplied(mock, appliedRows().AddRow(1, "create_books", migrator.Migrations[0].Checksum, true, time.Now()))
+	expectUnlock(mock)
+
+	_, err = migrator.Down(context.Background(), 1)
+	assert.ErrorIs(t, err, ErrFailed)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCheck(t *testing.T) {
+	migrator, mock := newTestMigrator(t)
+	checksums := []string{migrator.Migrations[0].Checksum, migrator.Migrations[1].Checksum}
+
+	cases := []struct {
+		name string
+		rows *sqlmock.Rows
+		want error
+	}{
+		{"up to date", appliedRows().AddRow(1, "create_books", checksums[0], false, time.Now()).AddRow(2, "add_stock", checksums[1], false, time.Now()), nil},
+		{"pending", appliedRows().AddRow(1, "create_books", checksums[0], false, time.Now()), ErrPending},
+		{"failed", appliedRows().AddRow(1, "create_books", checksums[0], false, time.Now()).AddRow(2, "add_stock", checksums[1], true, time.Now()), ErrFailed},
+		{"newer database", appliedRows().AddRow(1, "create_books", checksums[0], false, time.Now()).AddRow(2, "add_stock", checksums[1], false, time.Now()).AddRow(3, "later", "x", false, time.Now()), ErrUnknownVersion},
+	}
+	for _, tc := range cases {
+		expectLock(mock)
+		expectApplied(mock, tc.rows)
+		expectUnlock(mock)
+
+		err := migrator.Check(context.Background())
+		if tc.want == nil {
+			assert.NoError(t, err, tc.name)
+		} else {
+			assert.ErrorIs(t, err, tc.want, tc.name)
+		}
+	}
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSQLite(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1) // every connection to :memory: is a separate database
+
+	migrator, err := New(db, testFiles())
+	require.NoError(t, err)
+	migrator.Dialect = SQLite
+	ctx := context.Background()
+
+	// Case 1: Applied without an advisory lock
+	done, err := migrator.Up(ctx)
+	require.NoError(t, err)
+	assert.Len(t, done, 2)
+	require.NoError(t, migrator.Check(ctx))
+
+	statuses, err := migrator.Status(ctx)
+	require.NoError(t, err)
+	require.Len(t, statuses, 2)
+	assert.Equal(t, StateApplied, statuses[1].State)
+	assert.NotNil(t, statuses[1].AppliedAt)
+
+	// Case 2: Reverted
+	_, err = migrator.Down(ctx, 1)
+	require.NoError(t, err)
+	assert.ErrorIs(t, migrator.Check(ctx), ErrPending)
+}
+
+func TestCreate(t *testing.T) {
+	dir := t.TempDir()
+
+	// Case 1: The first migration
+	paths, err := Create(dir, "Add Book Ratings")
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "000001_add_book_ratings.up.sql"),
+		filepath.Join(dir, "000001_add_book_ratings.down.sql"),
+	}, paths)
+
+	// Case 2: Numbered after the latest
+	paths, err = Create(dir, "index ratings")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "000002_index_ratings.up.sql"), paths[0])
+
+	migrations, err := Load(os.DirFS(dir))
+	require.NoError(t, err)
+	assert.Len(t, migrations, 2)
+
+	// Case 3: Name required
+	_, err = Create(dir, "--")
+	assert.Error(t, err)
+}
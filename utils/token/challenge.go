@@ -0,0 +1,97 @@
+package token
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/spf13/viper"
+)
+
+// DefaultChallengeTTL is how long a user has to enter their second factor
+// after the password was accepted.
+const DefaultChallengeTTL = 5 * time.Minute
+
+// ChallengeTTL returns the configured MFA challenge lifetime.
+func ChallengeTTL() time.Duration {
+	if ttl := viper.GetDuration("mfa.challenge_ttl"); ttl > 0 {
+		return ttl
+	}
+	return DefaultChallengeTTL
+}
+
+// challengeAudience keeps challenge tokens apart from access tokens: neither
+// is accepted in place of the other.
+func challengeAudience() string {
+	return Audience() + "/mfa"
+}
+
+// GenerateChallengeToken issues a short-lived token proving the user passed the
+// password step of a login, to be exchanged together with a second factor.
+func GenerateChallengeToken(userID uint) (string, error) {
+	ks, err := currentKeys()
+	if err != nil {
+		return "", err
+	}
+	k, err := ks.signingKey(time.Now())
+	if err != nil {
+		return "", err
+	}
+	tokenID, err := NewSessionID()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims := &jwt.RegisteredClaims{
+		Subject:   strconv.FormatUint(uint64(userID), 10),
+		Issuer:    Issuer(),
+		Audience:  jwt.ClaimStrings{challengeAudience()},
+		ID:        tokenID,
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(ChallengeTTL())),
+	}
+	token := jwt.NewWithClaims(ks.method, claims)
+	if k.kid != "" {
+		token.Header["kid"] = k.kid
+	}
+	return token.SignedString(k.signer)
+}
+
+// ValidateChallengeToken checks a token from GenerateChallengeToken and returns
+// the user it was issued to.
+func ValidateChallengeToken(tokenString string) (uint, error) {
+	ks, err := currentKeys()
+	if err != nil {
+		return 0, err
+	}
+
+	claims := &jwt.RegisteredClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		k, err := ks.verificationKey(kid)
+		if err != nil {
+			return nil, err
+		}
+		return k.verifier, nil
+	},
+		jwt.WithValidMethods([]string{ks.method.Alg()}),
+		jwt.WithIssuer(Issuer()),
+		jwt.WithAudience(challengeAudience()),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+	if err != nil {
+		return 0, err
+	}
+	if !token.Valid {
+		return 0, fmt.Errorf("invalid token")
+	}
+
+	id, err := strconv.ParseUint(claims.Subject, 10, 32)
+	if err != nil || id == 0 {
+		return 0, fmt.Errorf("invalid subject %q", claims.Subject)
+	}
+	return uint(id), nil
+}
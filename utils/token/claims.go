@@ -0,0 +1,60 @@
+package token
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/spf13/viper"
+)
+
+const (
+	DefaultIssuer   = "book-backend"
+	DefaultAudience = "book-backend-api"
+)
+
+// Claims are the claims carried by an access token. The subject is the user ID
+// and the ID (jti) is unique per token.
+type Claims struct {
+	Role      string `json:"role"`
+	SessionID string `json:"sid"`
+	jwt.RegisteredClaims
+}
+
+// UserID returns the user the token was issued to.
+func (c *Claims) UserID() (uint, error) {
+	id, err := strconv.ParseUint(c.Subject, 10, 32)
+	if err != nil || id == 0 {
+		return 0, fmt.Errorf("invalid subject %q", c.Subject)
+	}
+	return uint(id), nil
+}
+
+// Validate runs after the registered claims are checked, so a token that
+// parses always names a user and a session.
+func (c *Claims) Validate() error {
+	if _, err := c.UserID(); err != nil {
+		return err
+	}
+	if c.SessionID == "" {
+		return errors.New("token has no session")
+	}
+	return nil
+}
+
+// Issuer returns the iss claim this server puts in and expects on access tokens.
+func Issuer() string {
+	if issuer := viper.GetString("jwt.issuer"); issuer != "" {
+		return issuer
+	}
+	return DefaultIssuer
+}
+
+// Audience returns the aud claim this server puts in and expects on access tokens.
+func Audience() string {
+	if audience := viper.GetString("jwt.audience"); audience != "" {
+		return audience
+	}
+	return DefaultAudience
+}
@@ -0,0 +1,226 @@
+package token
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/spf13/viper"
+)
+
+const (
+	// DefaultSecret is the development-only HS256 secret; it is refused outside the dev profile.
+	DefaultSecret = "supersecretkey"
+	devProfile    = "dev"
+)
+
+// KeyConfig describes one key in jwt.keys. A key with a private key file can
+// sign once ActiveFrom (RFC 3339) has passed; a key with only a public key
+// file is kept for verifying tokens signed elsewhere or by a retired key.
+type KeyConfig struct {
+	KID        string `mapstructure:"kid"`
+	PrivateKey string `mapstructure:"private_key"`
+	PublicKey  string `mapstructure:"public_key"`
+	ActiveFrom string `mapstructure:"active_from"`
+}
+
+type key struct {
+	kid        string
+	signer     interface{} // nil for verification-only keys
+	verifier   interface{}
+	activeFrom time.Time
+}
+
+// keySet holds every key of the configured algorithm. All of them verify; the
+// newest active one with a private key signs, so listing a key with a future
+// active_from schedules a rotation and publishes it in the JWKS ahead of time.
+type keySet struct {
+	method jwt.SigningMethod
+	keys   []*key
+}
+
+func loadKeySet() (*keySet, error) {
+	algorithm := viper.GetString("jwt.algorithm")
+	if algorithm == "" {
+		algorithm = jwt.SigningMethodHS256.Alg()
+	}
+
+	switch algorithm {
+	case jwt.SigningMethodHS256.Alg():
+		return loadSecret()
+	case jwt.SigningMethodRS256.Alg():
+		return loadKeyFiles(jwt.SigningMethodRS256)
+	case jwt.SigningMethodEdDSA.Alg():
+		return loadKeyFiles(jwt.SigningMethodEdDSA)
+	default:
+		return nil, fmt.Errorf("unsupported jwt.algorithm %q", algorithm)
+	}
+}
+
+func loadSecret() (*keySet, error) {
+	secret := viper.GetString("jwt.secret")
+	if secret == "" || secret == DefaultSecret {
+		if viper.GetString("application.profile") != devProfile {
+			return nil, errors.New("jwt.secret is unset or the default; set a real secret or use RS256/EdDSA keys outside the dev profile")
+		}
+		secret = DefaultSecret
+	}
+
+	k := &key{signer: []byte(secret), verifier: []byte(secret)}
+	return &keySet{method: jwt.SigningMethodHS256, keys: []*key{k}}, nil
+}
+
+func loadKeyFiles(method jwt.SigningMethod) (*keySet, error) {
+	var configs []KeyConfig
+	if err := viper.UnmarshalKey("jwt.keys", &configs); err != nil {
+		return nil, fmt.Errorf("reading jwt.keys: %w", err)
+	}
+	if len(configs) == 0 {
+		return nil, fmt.Errorf("jwt.algorithm %s needs at least one entry in jwt.keys", method.Alg())
+	}
+
+	ks := &keySet{method: method}
+	seen := make(map[string]bool)
+	for _, cfg := range configs {
+		if cfg.KID == "" {
+			return nil, errors.New("every jwt.keys entry needs a kid")
+		}
+		if seen[cfg.KID] {
+			return nil, fmt.Errorf("duplicate kid %q in jwt.keys", cfg.KID)
+		}
+		seen[cfg.KID] = true
+
+		k, err := loadKey(method, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("loading key %q: %w", cfg.KID, err)
+		}
+		ks.keys = append(ks.keys, k)
+	}
+
+	// Newest first, so the first active signer wins
+	sort.SliceStable(ks.keys, func(i, j int) bool {
+		return ks.keys[i].activeFrom.After(ks.keys[j].activeFrom)
+	})
+	return ks, nil
+}
+
+func loadKey(method jwt.SigningMethod, cfg KeyConfig) (*key, error) {
+	k := &key{kid: cfg.KID}
+	if cfg.ActiveFrom != "" {
+		activeFrom, err := time.Parse(time.RFC3339, cfg.ActiveFrom)
+		if err != nil {
+			return nil, fmt.Errorf("active_from: %w", err)
+		}
+		k.activeFrom = activeFrom
+	}
+
+	switch {
+	case cfg.PrivateKey != "":
+		pem, err := os.ReadFile(cfg.PrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		if method == jwt.SigningMethodRS256 {
+			private, err := jwt.ParseRSAPrivateKeyFromPEM(pem)
+			if err != nil {
+				return nil, err
+			}
+			k.signer, k.verifier = private, &private.PublicKey
+		} else {
+			private, err := jwt.ParseEdPrivateKeyFromPEM(pem)
+			if err != nil {
+				return nil, err
+			}
+			k.signer, k.verifier = private, private.(crypto.Signer).Public()
+		}
+	case cfg.PublicKey != "":
+		pem, err := os.ReadFile(cfg.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		if method == jwt.SigningMethodRS256 {
+			k.verifier, err = jwt.ParseRSAPublicKeyFromPEM(pem)
+		} else {
+			k.verifier, err = jwt.ParseEdPublicKeyFromPEM(pem)
+		}
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("private_key or public_key is required")
+	}
+	return k, nil
+}
+
+// signingKey returns the key that signs tokens at the given time.
+func (ks *keySet) signingKey(now time.Time) (*key, error) {
+	for _, k := range ks.keys {
+		if k.signer != nil && !k.activeFrom.After(now) {
+			return k, nil
+		}
+	}
+	return nil, errors.New("no active signing key")
+}
+
+// verificationKey finds the key a token names in its kid header. Only the
+// shared-secret mode accepts tokens without a kid.
+func (ks *keySet) verificationKey(kid string) (*key, error) {
+	for _, k := range ks.keys {
+		if k.kid == kid {
+			return k, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// JWK is the public half of a signing key in JSON Web Key form.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// PublicJWKS lists every verification key so other services can check tokens
+// without the signing key. Shared secrets are never published.
+func PublicJWKS() (JWKSet, error) {
+	ks, err := currentKeys()
+	if err != nil {
+		return JWKSet{}, err
+	}
+
+	set := JWKSet{Keys: []JWK{}}
+	for _, k := range ks.keys {
+		jwk := JWK{KeyID: k.kid, Use: "sig", Algorithm: ks.method.Alg()}
+		switch public := k.verifier.(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set, nil
+}
@@ -0,0 +1,60 @@
+package token
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// DefaultRefreshTokenTTL is how long a session survives without being refreshed.
+const DefaultRefreshTokenTTL = 30 * 24 * time.Hour
+
+// RefreshTokenTTL returns the configured refresh token lifetime.
+func RefreshTokenTTL() time.Duration {
+	if ttl := viper.GetDuration("jwt.refresh_expiration"); ttl > 0 {
+		return ttl
+	}
+	return DefaultRefreshTokenTTL
+}
+
+// NewRefreshToken returns an opaque refresh token for the client and the hash
+// to store in its place.
+func NewRefreshToken() (string, string, error) {
+	return NewOpaqueToken()
+}
+
+// HashRefreshToken hashes a refresh token for lookup.
+func HashRefreshToken(plain string) string {
+	return HashOpaqueToken(plain)
+}
+
+// NewOpaqueToken returns a random token to hand out, for example in an email
+// link, and the hash to store in its place.
+func NewOpaqueToken() (string, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	plain := base64.RawURLEncoding.EncodeToString(b)
+	return plain, HashOpaqueToken(plain), nil
+}
+
+// HashOpaqueToken hashes a token from NewOpaqueToken for lookup. The token
+// carries 256 bits of randomness, so a fast unsalted hash is enough.
+func HashOpaqueToken(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewSessionID returns a random identifier for a login session (a refresh token family).
+func NewSessionID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
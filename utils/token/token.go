@@ -1,51 +1,121 @@
 package token
 
 import (
+	"fmt"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/spf13/viper"
 )
 
-var secretKey []byte
+// DefaultAccessTokenTTL keeps access tokens short-lived; clients renew them with a refresh token.
+const DefaultAccessTokenTTL = 15 * time.Minute
 
-func Init() {
-	secret := viper.GetString("jwt.secret")
-	if secret == "" {
-		secret = "supersecretkey" // Fallback should be changed in production
+var (
+	mu   sync.RWMutex
+	keys *keySet
+)
+
+// Init loads the signing and verification keys from the jwt config. It fails
+// on unreadable keys and, outside the dev profile, on a missing or default secret.
+func Init() error {
+	ks, err := loadKeySet()
+	if err != nil {
+		return err
 	}
-	secretKey = []byte(secret)
+	mu.Lock()
+	keys = ks
+	mu.Unlock()
+	return nil
 }
 
-func GenerateToken(userID uint, role string) (string, error) {
-	if len(secretKey) == 0 {
-		Init()
+func currentKeys() (*keySet, error) {
+	mu.RLock()
+	ks := keys
+	mu.RUnlock()
+	if ks != nil {
+		return ks, nil
+	}
+	if err := Init(); err != nil {
+		return nil, err
 	}
+	return currentKeys()
+}
 
-	claims := jwt.MapClaims{
-		"user_id": userID,
-		"role":    role,
-		"exp":     time.Now().Add(time.Hour * 24).Unix(),
+// AccessTokenTTL returns the configured access token lifetime.
+func AccessTokenTTL() time.Duration {
+	if ttl := viper.GetDuration("jwt.expiration"); ttl > 0 {
+		return ttl
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(secretKey)
+	return DefaultAccessTokenTTL
 }
 
-func ValidateToken(tokenString string) (jwt.MapClaims, error) {
-	if len(secretKey) == 0 {
-		Init()
+// GenerateToken issues an access token for the session identified by sessionID,
+// so revoking the session also rejects its outstanding access tokens.
+func GenerateToken(userID uint, role, sessionID string) (string, error) {
+	ks, err := currentKeys()
+	if err != nil {
+		return "", err
+	}
+	k, err := ks.signingKey(time.Now())
+	if err != nil {
+		return "", err
+	}
+	tokenID, err := NewSessionID()
+	if err != nil {
+		return "", err
 	}
 
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		return secretKey, nil
-	})
+	now := time.Now()
+	claims := &Claims{
+		Role:      role,
+		SessionID: sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   strconv.FormatUint(uint64(userID), 10),
+			Issuer:    Issuer(),
+			Audience:  jwt.ClaimStrings{Audience()},
+			ID:        tokenID,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(AccessTokenTTL())),
+		},
+	}
+	token := jwt.NewWithClaims(ks.method, claims)
+	if k.kid != "" {
+		token.Header["kid"] = k.kid
+	}
+	return token.SignedString(k.signer)
+}
 
+// ValidateToken verifies the signature with the key named by the token and
+// checks expiry, issuer and audience before returning its claims.
+func ValidateToken(tokenString string) (*Claims, error) {
+	ks, err := currentKeys()
 	if err != nil {
 		return nil, err
 	}
 
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		return claims, nil
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		k, err := ks.verificationKey(kid)
+		if err != nil {
+			return nil, err
+		}
+		return k.verifier, nil
+	},
+		jwt.WithValidMethods([]string{ks.method.Alg()}),
+		jwt.WithIssuer(Issuer()),
+		jwt.WithAudience(Audience()),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, fmt.Errorf("invalid token")
 	}
-	return nil, err
+	return claims, nil
 }
@@ -1,6 +1,13 @@
 package token
 
 import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -18,7 +25,7 @@ func TestTokenFlow(t *testing.T) {
 	role := "admin"
 
 	// 1. Generate Token
-	tokenString, err := GenerateToken(userID, role)
+	tokenString, err := GenerateToken(userID, role, "session-1")
 	assert.NoError(t, err)
 	assert.NotEmpty(t, tokenString)
 
@@ -28,12 +35,119 @@ func TestTokenFlow(t *testing.T) {
 	assert.NotNil(t, claims)
 
 	// Check Claims
-	// jwt.MapClaims unmarshals numbers as float64
-	uid := uint(claims["user_id"].(float64))
-	r := claims["role"].(string)
-
+	uid, err := claims.UserID()
+	assert.NoError(t, err)
 	assert.Equal(t, userID, uid)
-	assert.Equal(t, role, r)
+	assert.Equal(t, "123", claims.Subject)
+	assert.Equal(t, role, claims.Role)
+	assert.Equal(t, "session-1", claims.SessionID)
+	assert.Equal(t, DefaultIssuer, claims.Issuer)
+	assert.Equal(t, jwt.ClaimStrings{DefaultAudience}, claims.Audience)
+	assert.NotEmpty(t, claims.ID)
+	assert.NotNil(t, claims.IssuedAt)
+
+	// 3. Every token gets its own ID
+	other, err := GenerateToken(userID, role, "session-1")
+	assert.NoError(t, err)
+	otherClaims, err := ValidateToken(other)
+	assert.NoError(t, err)
+	assert.NotEqual(t, claims.ID, otherClaims.ID)
+}
+
+// validClaims returns claims that pass every check, for hand-built tokens.
+func validClaims() *Claims {
+	return &Claims{
+		Role:      "USER",
+		SessionID: "session-1",
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "1",
+			Issuer:    DefaultIssuer,
+			Audience:  jwt.ClaimStrings{DefaultAudience},
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		},
+	}
+}
+
+func TestValidateTokenClaims(t *testing.T) {
+	viper.Set("jwt.secret", "testsecret")
+	Init()
+
+	sign := func(claims *Claims) string {
+		s, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("testsecret"))
+		return s
+	}
+
+	// Case 1: Valid
+	_, err := ValidateToken(sign(validClaims()))
+	assert.NoError(t, err)
+
+	// Case 2: Wrong issuer
+	claims := validClaims()
+	claims.Issuer = "someone-else"
+	_, err = ValidateToken(sign(claims))
+	assert.ErrorIs(t, err, jwt.ErrTokenInvalidIssuer)
+
+	// Case 3: Wrong audience
+	claims = validClaims()
+	claims.Audience = jwt.ClaimStrings{"another-api"}
+	_, err = ValidateToken(sign(claims))
+	assert.ErrorIs(t, err, jwt.ErrTokenInvalidAudience)
+
+	// Case 4: No expiry
+	claims = validClaims()
+	claims.ExpiresAt = nil
+	_, err = ValidateToken(sign(claims))
+	assert.ErrorIs(t, err, jwt.ErrTokenRequiredClaimMissing)
+
+	// Case 5: Subject is not a user ID
+	claims = validClaims()
+	claims.Subject = "admin"
+	_, err = ValidateToken(sign(claims))
+	assert.Error(t, err)
+
+	// Case 6: No session
+	claims = validClaims()
+	claims.SessionID = ""
+	_, err = ValidateToken(sign(claims))
+	assert.Error(t, err)
+
+	// Case 7: Configured issuer and audience
+	viper.Set("jwt.issuer", "https://books.example.com")
+	viper.Set("jwt.audience", "books")
+	defer viper.Set("jwt.issuer", "")
+	defer viper.Set("jwt.audience", "")
+	_, err = ValidateToken(sign(validClaims()))
+	assert.ErrorIs(t, err, jwt.ErrTokenInvalidIssuer)
+	tokenString, err := GenerateToken(1, "USER", "session-1")
+	assert.NoError(t, err)
+	_, err = ValidateToken(tokenString)
+	assert.NoError(t, err)
+}
+
+func TestAccessTokenTTL(t *testing.T) {
+	viper.Set("jwt.expiration", "")
+	assert.Equal(t, DefaultAccessTokenTTL, AccessTokenTTL())
+
+	viper.Set("jwt.expiration", "5m")
+	assert.Equal(t, 5*time.Minute, AccessTokenTTL())
+	viper.Set("jwt.expiration", "")
+}
+
+func TestRefreshToken(t *testing.T) {
+	plain, hash, err := NewRefreshToken()
+	assert.NoError(t, err)
+	assert.NotEmpty(t, plain)
+	assert.Equal(t, HashRefreshToken(plain), hash)
+	assert.NotEqual(t, plain, hash)
+
+	other, _, err := NewRefreshToken()
+	assert.NoError(t, err)
+	assert.NotEqual(t, plain, other)
+
+	sid, err := NewSessionID()
+	assert.NoError(t, err)
+	assert.Len(t, sid, 32)
 }
 
 func TestInvalidToken(t *testing.T) {
@@ -61,3 +175,165 @@ func TestExpiredToken(t *testing.T) {
 	_, err := ValidateToken(tokenString)
 	assert.Error(t, err)
 }
+
+func TestChallengeToken(t *testing.T) {
+	viper.Set("jwt.secret", "testsecret")
+	Init()
+
+	// Case 1: Round trip
+	challenge, err := GenerateChallengeToken(7)
+	assert.NoError(t, err)
+	userID, err := ValidateChallengeToken(challenge)
+	assert.NoError(t, err)
+	assert.Equal(t, uint(7), userID)
+
+	// Case 2: A challenge is not an access token, and the other way round
+	_, err = ValidateToken(challenge)
+	assert.Error(t, err)
+
+	access, err := GenerateToken(7, "ADMIN", "session-1")
+	assert.NoError(t, err)
+	_, err = ValidateChallengeToken(access)
+	assert.Error(t, err)
+
+	// Case 3: A non-positive lifetime falls back to the default
+	viper.Set("mfa.challenge_ttl", "-1m")
+	defer viper.Set("mfa.challenge_ttl", "")
+	assert.Equal(t, DefaultChallengeTTL, ChallengeTTL())
+}
+
+// writeKey stores a PKCS#8 private key and its PKIX public key under dir.
+func writeKey(t *testing.T, dir, kid string, private interface{}, public interface{}) (string, string) {
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	assert.NoError(t, err)
+	privatePath := filepath.Join(dir, kid+".pem")
+	assert.NoError(t, os.WriteFile(privatePath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600))
+
+	der, err = x509.MarshalPKIXPublicKey(public)
+	assert.NoError(t, err)
+	publicPath := filepath.Join(dir, kid+".pub.pem")
+	assert.NoError(t, os.WriteFile(publicPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600))
+	return privatePath, publicPath
+}
+
+func useKeys(t *testing.T, algorithm string, keys []map[string]interface{}) {
+	viper.Set("jwt.algorithm", algorithm)
+	viper.Set("jwt.keys", keys)
+	t.Cleanup(func() {
+		viper.Set("jwt.algorithm", "")
+		viper.Set("jwt.keys", nil)
+		viper.Set("jwt.secret", "testsecret")
+		Init()
+	})
+	assert.NoError(t, Init())
+}
+
+func TestRS256RotationAndJWKS(t *testing.T) {
+	dir := t.TempDir()
+	oldKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	newKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	_, oldPublic := writeKey(t, dir, "old", oldKey, &oldKey.PublicKey)
+	newPrivate, _ := writeKey(t, dir, "new", newKey, &newKey.PublicKey)
+	nextPrivate, _ := writeKey(t, dir, "next", newKey, &newKey.PublicKey)
+
+	// Case 1: the newest key already active signs; a future key is only published
+	useKeys(t, "RS256", []map[string]interface{}{
+		{"kid": "old", "public_key": oldPublic},
+		{"kid": "new", "private_key": newPrivate, "active_from": time.Now().Add(-time.Hour).Format(time.RFC3339)},
+		{"kid": "next", "private_key": nextPrivate, "active_from": time.Now().Add(time.Hour).Format(time.RFC3339)},
+	})
+
+	tokenString, err := GenerateToken(7, "USER", "session-1")
+	assert.NoError(t, err)
+	parsed, _, err := jwt.NewParser().ParseUnverified(tokenString, jwt.MapClaims{})
+	assert.NoError(t, err)
+	assert.Equal(t, "new", parsed.Header["kid"])
+	assert.Equal(t, "RS256", parsed.Method.Alg())
+
+	claims, err := ValidateToken(tokenString)
+	assert.NoError(t, err)
+	assert.Equal(t, "7", claims.Subject)
+
+	// Case 2: a token from the verification-only key is still accepted
+	old := jwt.NewWithClaims(jwt.SigningMethodRS256, validClaims())
+	old.Header["kid"] = "old"
+	oldString, _ := old.SignedString(oldKey)
+	_, err = ValidateToken(oldString)
+	assert.NoError(t, err)
+
+	// Case 3: unknown kid
+	old.Header["kid"] = "missing"
+	missingString, _ := old.SignedString(oldKey)
+	_, err = ValidateToken(missingString)
+	assert.Error(t, err)
+
+	set, err := PublicJWKS()
+	assert.NoError(t, err)
+	assert.Len(t, set.Keys, 3)
+	for _, k := range set.Keys {
+		assert.Equal(t, "RSA", k.KeyType)
+		assert.Equal(t, "RS256", k.Algorithm)
+		assert.Equal(t, "AQAB", k.E)
+		assert.NotEmpty(t, k.N)
+	}
+}
+
+func TestEdDSAKeys(t *testing.T) {
+	dir := t.TempDir()
+	public, private, _ := ed25519.GenerateKey(rand.Reader)
+	privatePath, _ := writeKey(t, dir, "ed", private, public)
+	useKeys(t, "EdDSA", []map[string]interface{}{{"kid": "ed", "private_key": privatePath}})
+
+	tokenString, err := GenerateToken(1, "USER", "session-1")
+	assert.NoError(t, err)
+	_, err = ValidateToken(tokenString)
+	assert.NoError(t, err)
+
+	set, err := PublicJWKS()
+	assert.NoError(t, err)
+	assert.Len(t, set.Keys, 1)
+	assert.Equal(t, "OKP", set.Keys[0].KeyType)
+	assert.Equal(t, "Ed25519", set.Keys[0].Curve)
+}
+
+func TestValidateTokenPinsSigningMethod(t *testing.T) {
+	dir := t.TempDir()
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	privatePath, publicPath := writeKey(t, dir, "rsa", key, &key.PublicKey)
+	useKeys(t, "RS256", []map[string]interface{}{{"kid": "rsa", "private_key": privatePath}})
+
+	// An HS256 token keyed with the public key bytes must not verify
+	publicPEM, _ := os.ReadFile(publicPath)
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, validClaims())
+	forged.Header["kid"] = "rsa"
+	forgedString, _ := forged.SignedString(publicPEM)
+	_, err := ValidateToken(forgedString)
+	assert.Error(t, err)
+
+	unsigned := jwt.NewWithClaims(jwt.SigningMethodNone, validClaims())
+	unsignedString, _ := unsigned.SignedString(jwt.UnsafeAllowNoneSignatureType)
+	_, err = ValidateToken(unsignedString)
+	assert.Error(t, err)
+}
+
+func TestDefaultSecretRefusedOutsideDev(t *testing.T) {
+	t.Cleanup(func() {
+		viper.Set("application.profile", "")
+		viper.Set("jwt.secret", "testsecret")
+		Init()
+	})
+
+	viper.Set("jwt.secret", DefaultSecret)
+	viper.Set("application.profile", "production")
+	assert.Error(t, Init())
+
+	viper.Set("jwt.secret", "")
+	assert.Error(t, Init())
+
+	viper.Set("application.profile", "dev")
+	assert.NoError(t, Init())
+
+	set, err := PublicJWKS()
+	assert.NoError(t, err)
+	assert.Empty(t, set.Keys)
+}
@@ -0,0 +1,73 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rfcSecret is the SHA1 key from the RFC 6238 test vectors.
+var rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestCode_RFC6238Vectors(t *testing.T) {
+	// The RFC lists 8-digit codes; the 6-digit code is their last six digits
+	vectors := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+	for unix, want := range vectors {
+		got, err := Code(rfcSecret, time.Unix(unix, 0))
+		require.NoError(t, err)
+		assert.Equal(t, want, got, "time %d", unix)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+
+	// Case 1: Current code
+	step, ok := Validate(rfcSecret, "081804", now, 1)
+	assert.True(t, ok)
+	assert.Equal(t, Step(now), step)
+
+	// Case 2: Previous step is accepted within the skew
+	previous, _ := Code(rfcSecret, now.Add(-Period))
+	step, ok = Validate(rfcSecret, previous, now, 1)
+	assert.True(t, ok)
+	assert.Equal(t, Step(now)-1, step)
+
+	// Case 3: Outside the skew
+	old, _ := Code(rfcSecret, now.Add(-3*Period))
+	_, ok = Validate(rfcSecret, old, now, 1)
+	assert.False(t, ok)
+
+	// Case 4: Malformed input
+	_, ok = Validate(rfcSecret, "12345", now, 1)
+	assert.False(t, ok)
+	_, ok = Validate("not base32!", "081804", now, 1)
+	assert.False(t, ok)
+}
+
+func TestGenerateSecretAndURI(t *testing.T) {
+	secret, err := GenerateSecret()
+	require.NoError(t, err)
+	assert.Len(t, secret, 32)
+
+	code, err := Code(secret, time.Now())
+	require.NoError(t, err)
+	_, ok := Validate(secret, code, time.Now(), 0)
+	assert.True(t, ok)
+
+	uri := URI("Book Store", "john@example.com", secret)
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/Book%20Store:john@example.com?"))
+	assert.Contains(t, uri, "secret="+secret)
+	assert.Contains(t, uri, "issuer=Book%20Store")
+}